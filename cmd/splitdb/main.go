@@ -0,0 +1,199 @@
+// Command splitdb copies the tables of the shared ecommerce.db into one
+// database file per service. The shared tables have the schema of each
+// service's first migration, so the rows are copied right after it and the
+// later migrations convert them the way they convert seed data: backfilled
+// columns, opening balances and the like are derived from the copied rows.
+// The target is then ready to be opened by the service.
+//
+//	go run ./cmd/splitdb -source ./data/ecommerce.db -out ./data
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	order "monorepo-ecommerce/micro-services/order/migrations"
+	product "monorepo-ecommerce/micro-services/product/migrations"
+	shop "monorepo-ecommerce/micro-services/shop/migrations"
+	user "monorepo-ecommerce/micro-services/user/migrations"
+	warehouse "monorepo-ecommerce/micro-services/warehouse/migrations"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/migration"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type target struct {
+	service string
+	fsys    fs.FS
+	// tables in copy order, parents first
+	tables []string
+	// derived are tables the later migrations fill from tables. When the
+	// source has them as well, they are copied after every migration, in
+	// place of what was derived.
+	derived []string
+}
+
+var targets = []target{
+	{service: "user", fsys: user.For(database.SQLite), tables: []string{"users"}},
+	{service: "product", fsys: product.For(database.SQLite), tables: []string{"products"}},
+	{service: "order", fsys: order.For(database.SQLite), tables: []string{"orders", "order_items"}},
+	{service: "shop", fsys: shop.For(database.SQLite), tables: []string{"shops"}},
+	{service: "warehouse", fsys: warehouse.For(database.SQLite), tables: []string{"warehouses", "stocks"},
+		derived: []string{"stock_movements", "stock_lots"}},
+}
+
+func main() {
+	source := flag.String("source", "./data/ecommerce.db", "shared database to split")
+	out := flag.String("out", "./data", "directory for the per-service databases")
+	force := flag.Bool("force", false, "overwrite existing per-service databases")
+	flag.Parse()
+
+	if _, err := os.Stat(*source); err != nil {
+		log.Fatalf("Source database not found: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, t := range targets {
+		path := filepath.Join(*out, t.service+".db")
+		if err := split(ctx, *source, path, t, *force); err != nil {
+			log.Fatalf("Failed to split %s: %v", t.service, err)
+		}
+	}
+}
+
+func split(ctx context.Context, source string, path string, t target, force bool) error {
+	if _, err := os.Stat(path); err == nil {
+		if !force {
+			return fmt.Errorf("%s already exists, use -force to overwrite", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// ATTACH is per connection, so every statement has to share one
+	db.SetMaxOpenConns(1)
+
+	migrator := migration.New(db, t.service, t.fsys)
+	if err := migrator.To(ctx, 1); err != nil {
+		return err
+	}
+	if err := copyTables(ctx, db, source, t.service, t.tables, "keeping seed data"); err != nil {
+		return err
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		return err
+	}
+	if err := copyTables(ctx, db, source, t.service, t.derived, "keeping what the migrations derived"); err != nil {
+		return err
+	}
+
+	log.Printf("%s: written %s", t.service, path)
+	return nil
+}
+
+// copyTables replaces the rows of tables with those of source in one
+// transaction. A table the source lacks keeps its rows, as missing says. The
+// source is attached only meanwhile, so the migrations never see its tables.
+func copyTables(ctx context.Context, db *sql.DB, source string, service string, tables []string, missing string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "ATTACH DATABASE ? AS src", source); err != nil {
+		return fmt.Errorf("failed attach source: %w", err)
+	}
+	defer db.ExecContext(ctx, "DETACH DATABASE src")
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		columns, err := sharedColumns(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			log.Printf("%s: table %s not found in source, %s", service, table, missing)
+			continue
+		}
+
+		// the source rows replace what the migrations seeded or derived
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM main.%s", table)); err != nil {
+			return fmt.Errorf("failed clear %s: %w", table, err)
+		}
+
+		list := strings.Join(columns, ", ")
+		res, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM src.%s", table, list, list, table))
+		if err != nil {
+			return fmt.Errorf("failed copy %s: %w", table, err)
+		}
+		rows, _ := res.RowsAffected()
+		log.Printf("%s: copied %d rows into %s", service, rows, table)
+	}
+
+	return tx.Commit()
+}
+
+// sharedColumns returns the columns table has in both databases, or none when
+// the source lacks the table.
+func sharedColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	src, err := columnsOf(ctx, tx, "src", table)
+	if err != nil || len(src) == 0 {
+		return nil, err
+	}
+	dst, err := columnsOf(ctx, tx, "main", table)
+	if err != nil {
+		return nil, err
+	}
+
+	inSource := map[string]bool{}
+	for _, c := range src {
+		inSource[c] = true
+	}
+
+	var shared []string
+	for _, c := range dst {
+		if inSource[c] {
+			shared = append(shared, c)
+		}
+	}
+
+	return shared, nil
+}
+
+func columnsOf(ctx context.Context, tx *sql.Tx, schema string, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info('%s', '%s')", table, schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed read columns of %s.%s: %w", schema, table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"database/sql"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newSource writes a shared database with the warehouse tables as they were
+// before the split, holding stock other than the seeded one, and runs extra
+// on it.
+func newSource(t *testing.T, extra string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ecommerce.db")
+	db, err := sql.Open("sqlite3", path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE warehouses (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, status TEXT);
+		CREATE TABLE stocks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			warehouse_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			quantity INTEGER NOT NULL,
+			UNIQUE (warehouse_id, product_id)
+		);
+		INSERT INTO warehouses (id, name, status) VALUES (1, 'Warehouse A', 'active'), (2, 'Warehouse B', 'inactive');
+		INSERT INTO stocks (warehouse_id, product_id, quantity) VALUES (1, 1, 99), (1, 4, 7), (2, 2, 0);
+	` + extra)
+	assert.NoError(t, err)
+	return path
+}
+
+func split(t *testing.T, source string) *sql.DB {
+	t.Helper()
+
+	out := t.TempDir()
+	cmd := exec.Command("go", "run", "..", "-source", source, "-out", out)
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(output))
+
+	db, err := sql.Open("sqlite3", filepath.Join(out, "warehouse.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSplitDB(t *testing.T) {
+	db := split(t, newSource(t, ""))
+
+	t.Run("should copy the stock of the source", func(t *testing.T) {
+		var count, total int
+		err := db.QueryRow("SELECT COUNT(*), SUM(quantity) FROM stocks").Scan(&count, &total)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, 106, total)
+	})
+
+	t.Run("should post the copied stock as opening balance", func(t *testing.T) {
+		var drifted int
+		err := db.QueryRow(`SELECT COUNT(*) FROM stocks s
+			WHERE s.quantity <> (SELECT COALESCE(SUM(m.quantity), 0) FROM stock_movements m
+				WHERE m.product_id = s.product_id AND m.warehouse_id = s.warehouse_id)`).Scan(&drifted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, drifted)
+	})
+
+	t.Run("should backfill the metadata of the copied warehouses", func(t *testing.T) {
+		var address, status string
+		var priority int
+		err := db.QueryRow("SELECT address, status, priority FROM warehouses WHERE id = 1").Scan(&address, &status, &priority)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Jakarta", address)
+		assert.Equal(t, "active", status)
+		assert.Equal(t, 1, priority)
+	})
+
+	t.Run("should put the copied stock into default lots", func(t *testing.T) {
+		assert.Equal(t, 0, lotDrift(t, db))
+
+		var lotNumber string
+		err := db.QueryRow("SELECT lot_number FROM stock_lots WHERE product_id = 4 AND warehouse_id = 1").Scan(&lotNumber)
+		assert.NoError(t, err)
+		assert.Equal(t, "", lotNumber)
+	})
+}
+
+func TestSplitDB_SourceLots(t *testing.T) {
+	db := split(t, newSource(t, `
+		CREATE TABLE stock_lots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			product_id INTEGER NOT NULL,
+			warehouse_id INTEGER NOT NULL,
+			lot_number TEXT NOT NULL DEFAULT '',
+			expiry_date DATE,
+			quantity INTEGER NOT NULL DEFAULT 0
+		);
+		INSERT INTO stock_lots (product_id, warehouse_id, lot_number, expiry_date, quantity)
+		VALUES (1, 1, 'L1', '2030-01-01', 90), (1, 1, 'L2', '2031-01-01', 9), (4, 1, '', NULL, 7);
+	`))
+
+	t.Run("should copy the lots of the source", func(t *testing.T) {
+		var lots int
+		err := db.QueryRow("SELECT COUNT(*) FROM stock_lots WHERE product_id = 1 AND warehouse_id = 1 AND lot_number IN ('L1', 'L2')").Scan(&lots)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, lots)
+		assert.Equal(t, 0, lotDrift(t, db))
+	})
+}
+
+// lotDrift counts the stock rows whose lots do not add up to them.
+func lotDrift(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var drifted int
+	err := db.QueryRow(`SELECT COUNT(*) FROM stocks s
+		WHERE s.quantity <> (SELECT COALESCE(SUM(l.quantity), 0) FROM stock_lots l
+			WHERE l.product_id = s.product_id AND l.warehouse_id = s.warehouse_id)`).Scan(&drifted)
+	assert.NoError(t, err)
+	return drifted
+}
@@ -1 +1,90 @@
 package config
+
+import (
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type Config struct {
+	Port              int           `yaml:"port" env:"PORT"`
+	InternalPort      int           `yaml:"internal_port" env:"INTERNAL_PORT"`
+	DatabaseDriver    string        `yaml:"database_driver" env:"DATABASE_DRIVER"`
+	DatabasePath      string        `yaml:"database_path" env:"DATABASE_PATH"`
+	DatabaseURL       string        `yaml:"database_url" env:"DATABASE_URL" secret:"true"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ProductServiceURL string        `yaml:"product_service_url" env:"PRODUCT_SERVICE_URL"`
+	ShopServiceURL    string        `yaml:"shop_service_url" env:"SHOP_SERVICE_URL"`
+	UserServiceURL    string        `yaml:"user_service_url" env:"USER_SERVICE_URL"`
+	UpstreamTimeout   time.Duration `yaml:"upstream_timeout" env:"UPSTREAM_TIMEOUT"`
+	JWTIssuer         string        `yaml:"jwt_issuer" env:"JWT_ISSUER"`
+	JWTAudience       string        `yaml:"jwt_audience" env:"JWT_AUDIENCE"`
+	// JWKSCacheTTL is how long the user service's signing keys are cached.
+	JWKSCacheTTL       time.Duration `yaml:"jwks_cache_ttl" env:"JWKS_CACHE_TTL"`
+	AutoCancelSchedule string        `yaml:"auto_cancel_schedule" env:"AUTO_CANCEL_SCHEDULE"`
+	PendingOrderTTL    time.Duration `yaml:"pending_order_ttl" env:"PENDING_ORDER_TTL"`
+	// RevocationRefreshSchedule is how often revoked tokens are fetched.
+	RevocationRefreshSchedule string `yaml:"revocation_refresh_schedule" env:"REVOCATION_REFRESH_SCHEDULE"`
+}
+
+func Default() Config {
+	return Config{
+		Port:                      7003,
+		InternalPort:              8003,
+		DatabaseDriver:            "sqlite",
+		DatabasePath:              "./../../data/order.db",
+		ShutdownTimeout:           15 * time.Second,
+		ProductServiceURL:         "http://localhost:7002",
+		ShopServiceURL:            "http://localhost:7004",
+		UserServiceURL:            "http://localhost:8001",
+		UpstreamTimeout:           5 * time.Second,
+		JWTIssuer:                 "user-service",
+		JWTAudience:               "monorepo-ecommerce",
+		JWKSCacheTTL:              10 * time.Minute,
+		AutoCancelSchedule:        "@every 2m",
+		PendingOrderTTL:           2 * time.Minute,
+		RevocationRefreshSchedule: "@every 10s",
+	}
+}
+
+// Load returns the defaults overridden by CONFIG_FILE and the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+	if err := configloader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var scheduleErr error
+	if _, err := cron.ParseStandard(c.AutoCancelSchedule); err != nil {
+		scheduleErr = fmt.Errorf("auto_cancel_schedule is invalid: %w", err)
+	}
+
+	var revocationScheduleErr error
+	if _, err := cron.ParseStandard(c.RevocationRefreshSchedule); err != nil {
+		revocationScheduleErr = fmt.Errorf("revocation_refresh_schedule is invalid: %w", err)
+	}
+
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateInternalPort(c.Port, c.InternalPort),
+		database.NewConfig(c.DatabaseDriver, c.DatabasePath, c.DatabaseURL).Validate(),
+		configloader.ValidatePositive("shutdown_timeout", c.ShutdownTimeout),
+		configloader.ValidateURL("product_service_url", c.ProductServiceURL),
+		configloader.ValidateURL("shop_service_url", c.ShopServiceURL),
+		configloader.ValidateURL("user_service_url", c.UserServiceURL),
+		configloader.ValidatePositive("upstream_timeout", c.UpstreamTimeout),
+		configloader.ValidateRequired("jwt_issuer", c.JWTIssuer),
+		configloader.ValidateRequired("jwt_audience", c.JWTAudience),
+		configloader.ValidatePositive("jwks_cache_ttl", c.JWKSCacheTTL),
+		scheduleErr,
+		configloader.ValidatePositive("pending_order_ttl", c.PendingOrderTTL),
+		revocationScheduleErr,
+	)
+}
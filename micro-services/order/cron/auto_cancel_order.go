@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"context"
 	"log"
 	"monorepo-ecommerce/micro-services/order/repository"
 	"time"
@@ -9,23 +10,24 @@ import (
 type AutoCancelJob struct {
 	OrderRepo   repository.OrderRepository
 	ProductRepo repository.ProductRepository
+	PendingTTL  time.Duration
 }
 
-func NewAutoCancelJob(orderRepo repository.OrderRepository, productRepo repository.ProductRepository) *AutoCancelJob {
-	return &AutoCancelJob{OrderRepo: orderRepo, ProductRepo: productRepo}
+func NewAutoCancelJob(orderRepo repository.OrderRepository, productRepo repository.ProductRepository, pendingTTL time.Duration) *AutoCancelJob {
+	return &AutoCancelJob{OrderRepo: orderRepo, ProductRepo: productRepo, PendingTTL: pendingTTL}
 }
 
-func (job *AutoCancelJob) Run() {
-	// check within 2 minutes orders
-	cutoffTime := time.Now().Add(-2 * time.Minute)
-	orders, err := job.OrderRepo.GetExpiredOrders("pending", cutoffTime)
+func (job *AutoCancelJob) Run(ctx context.Context) {
+	// orders still pending after PendingTTL are expired
+	cutoffTime := time.Now().Add(-job.PendingTTL)
+	orders, err := job.OrderRepo.GetExpiredOrders(ctx, "pending", cutoffTime)
 	if err != nil {
 		log.Printf("Error fetching expired orders: %v", err)
 		return
 	}
 
 	for _, order := range orders {
-		err := job.OrderRepo.UpdateOrderStatus(order.Id, "cancelled")
+		err := job.OrderRepo.UpdateOrderStatus(ctx, order.Id, "cancelled")
 		if err != nil {
 			log.Printf("Failed to cancel order ID %d: %v", order.Id, err)
 			continue
@@ -33,7 +35,7 @@ func (job *AutoCancelJob) Run() {
 		log.Printf("Order Id %d successfully cancelled", order.Id)
 
 		for _, item := range order.Items {
-			err = job.ProductRepo.RestoreStock(item.ProductId, item.Quantity)
+			err = job.ProductRepo.RestoreStock(ctx, item.ProductId, item.Quantity)
 			if err != nil {
 				log.Printf("failed to restore stock for product %d: %v", item.ProductId, err)
 				continue
@@ -0,0 +1,23 @@
+package cron
+
+import (
+	"context"
+	"log"
+	"monorepo-ecommerce/micro-services/order/middleware"
+)
+
+type RefreshRevokedTokens struct {
+	revocations *middleware.RevocationList
+}
+
+func NewRefreshRevokedTokensJob(revocations *middleware.RevocationList) *RefreshRevokedTokens {
+	return &RefreshRevokedTokens{revocations: revocations}
+}
+
+// Run fetches the revoked access tokens from the user service. When that
+// fails the last list stays in use until the next run.
+func (job *RefreshRevokedTokens) Run(ctx context.Context) {
+	if err := job.revocations.Refresh(ctx); err != nil {
+		log.Printf("Error refreshing revoked tokens: %v", err)
+	}
+}
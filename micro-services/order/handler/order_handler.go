@@ -4,6 +4,8 @@ import (
 	"monorepo-ecommerce/micro-services/order/middleware"
 	"monorepo-ecommerce/micro-services/order/models"
 	"monorepo-ecommerce/micro-services/order/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/jwtauth"
 	"net/http"
 	"strconv"
 
@@ -24,10 +26,15 @@ func (h *OrderHandler) Checkout(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
+	userId, ok := c.Get("user_id").(int64)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Token invalid"})
+	}
+
 	// Checkout process
-	order, err := h.OrderService.CreateOrder(c, &orderRequest)
+	order, err := h.OrderService.CreateOrder(c.Request().Context(), userId, &orderRequest)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, order)
@@ -47,16 +54,53 @@ func (h *OrderHandler) Payment(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, "invalid request body")
 	}
 
-	order, err := h.OrderService.ProcessPayment(orderId, paymentRequest.Paid)
+	order, err := h.OrderService.ProcessPayment(c.Request().Context(), orderId, paymentRequest.Paid)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, order)
 }
 
-func RegisterOrderRoutes(e *echo.Echo, orderService service.OrderService) {
+// GetPendingStock serves the warehouse service, which reconciles product
+// stock against what pending orders hold.
+func (h *OrderHandler) GetPendingStock(c echo.Context) error {
+	stocks, err := h.OrderService.GetPendingStock(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+	if stocks == nil {
+		stocks = []models.PendingStock{}
+	}
+
+	return c.JSON(http.StatusOK, stocks)
+}
+
+// GetPendingOrderIds serves the warehouse service, whose allocations stay
+// open while their order is pending.
+func (h *OrderHandler) GetPendingOrderIds(c echo.Context) error {
+	ids, err := h.OrderService.GetPendingOrderIds(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	return c.JSON(http.StatusOK, ids)
+}
+
+func RegisterOrderRoutes(e *echo.Echo, orderService service.OrderService, verifier *jwtauth.Verifier, revocations *middleware.RevocationList) {
+	handler := NewOrderHandler(orderService)
+	auth := middleware.IsAuthenticated(verifier, revocations)
+	e.POST("/order/checkout", handler.Checkout, auth)
+	e.POST("/order/payment/:orderId", handler.Payment, auth)
+}
+
+// RegisterInternalOrderRoutes registers the routes only the other services
+// call, on the internal listener.
+func RegisterInternalOrderRoutes(e *echo.Echo, orderService service.OrderService) {
 	handler := NewOrderHandler(orderService)
-	e.POST("/order/checkout", handler.Checkout, middleware.IsAuthenticated)
-	e.POST("/order/payment/:orderId", handler.Payment, middleware.IsAuthenticated)
+	e.GET("/orders/pending-stock", handler.GetPendingStock)
+	e.GET("/orders/pending-ids", handler.GetPendingOrderIds)
 }
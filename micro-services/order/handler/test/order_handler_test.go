@@ -56,9 +56,10 @@ func TestCheckout(t *testing.T) {
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.Set("user_id", int64(1))
 
 		mockOrderService.EXPECT().
-			CreateOrder(c, &reqBody).
+			CreateOrder(gomock.Any(), int64(1), &reqBody).
 			Return(&mockOrder, nil)
 
 		err := h.Checkout(c)
@@ -89,6 +90,29 @@ func TestCheckout(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
+	t.Run("should unauthorized when user is missing from context", func(t *testing.T) {
+		reqBody := models.OrderRequest{
+			Items: []models.OrderItem{
+				{
+					ProductId: 1,
+					Quantity:  10,
+				},
+			},
+		}
+
+		reqJSON, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/order/checkout", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.Checkout(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
 	t.Run("should internal server error when failed to checkout", func(t *testing.T) {
 		reqBody := models.OrderRequest{
 			Items: []models.OrderItem{
@@ -109,9 +133,10 @@ func TestCheckout(t *testing.T) {
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.Set("user_id", int64(1))
 
 		mockOrderService.EXPECT().
-			CreateOrder(c, &reqBody).
+			CreateOrder(gomock.Any(), int64(1), &reqBody).
 			Return(&mockOrder, errors.New("failed"))
 
 		err := h.Checkout(c)
@@ -157,7 +182,7 @@ func Test(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockOrderService.EXPECT().
-			ProcessPayment(mockId, reqBody.Paid).
+			ProcessPayment(gomock.Any(), mockId, reqBody.Paid).
 			Return(&mockOrder, nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/order/payment/1", bytes.NewBuffer(reqJSON))
@@ -198,7 +223,7 @@ func Test(t *testing.T) {
 		err := h.Payment(c)
 
 		assert.NoError(t, err)
-		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
 	t.Run("should bad request when request invalid", func(t *testing.T) {
@@ -239,7 +264,7 @@ func Test(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockOrderService.EXPECT().
-			ProcessPayment(mockId, reqBody.Paid).
+			ProcessPayment(gomock.Any(), mockId, reqBody.Paid).
 			Return(&mockOrder, errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/order/payment/1", bytes.NewBuffer(reqJSON))
@@ -257,3 +282,51 @@ func Test(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestGetPendingStock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockOrderService := mocks.NewMockOrderService(ctrl)
+	h := handler.NewOrderHandler(mockOrderService)
+	e := echo.New()
+
+	t.Run("should list an empty array when no order is pending", func(t *testing.T) {
+		mockOrderService.EXPECT().
+			GetPendingStock(gomock.Any()).
+			Return(nil, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/orders/pending-stock", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetPendingStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+}
+
+func TestGetPendingOrderIds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockOrderService := mocks.NewMockOrderService(ctrl)
+	h := handler.NewOrderHandler(mockOrderService)
+	e := echo.New()
+
+	t.Run("should list the ids of pending orders", func(t *testing.T) {
+		mockOrderService.EXPECT().
+			GetPendingOrderIds(gomock.Any()).
+			Return([]int64{3, 5}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/orders/pending-ids", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetPendingOrderIds(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[3, 5]`, rec.Body.String())
+	})
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"monorepo-ecommerce/micro-services/order/config"
 	cj "monorepo-ecommerce/micro-services/order/cron"
-	"monorepo-ecommerce/micro-services/order/db"
 	"monorepo-ecommerce/micro-services/order/handler"
+	ordermiddleware "monorepo-ecommerce/micro-services/order/middleware"
+	"monorepo-ecommerce/micro-services/order/migrations"
 	"monorepo-ecommerce/micro-services/order/repository"
 	"monorepo-ecommerce/micro-services/order/service"
-	"net/http"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/httpclient"
+	"monorepo-ecommerce/pkg/jwtauth"
+	"monorepo-ecommerce/pkg/lifecycle"
+	"monorepo-ecommerce/pkg/migration"
+	"monorepo-ecommerce/pkg/requestid"
+	"os"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -15,45 +26,96 @@ import (
 )
 
 func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.Printf("Effective configuration: %s", configloader.Redact(cfg))
+
+	clientCfg := httpclient.DefaultConfig()
+	clientCfg.Timeout = cfg.UpstreamTimeout
+
+	runner := lifecycle.New(cfg.ShutdownTimeout)
+
 	// Init database
-	dbConn := db.InitDatabase("./../../data/ecommerce.db")
-	db.RunMigrations(dbConn, "./migrations/init.sql")
-	defer dbConn.Close()
+	dbConn, err := database.Open(database.NewConfig(cfg.DatabaseDriver, cfg.DatabasePath, cfg.DatabaseURL))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Apply schema migrations, or only run the migrate subcommand
+	migrator := migration.New(dbConn.Writer(), "order", migrations.For(dbConn.Dialect()))
+	migrator.Dialect = dbConn.Dialect()
+	if migration.IsCommand(os.Args) {
+		err := migration.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout)
+		dbConn.Close()
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
 
 	// Init Product Repository
-	productRepo := repository.NewProductRepository("http://localhost:7002") // URL Product Service
+	productClient := httpclient.New("product", cfg.ProductServiceURL, clientCfg)
+	productRepo := repository.NewProductRepository(productClient)
 
 	// Init Shop Repository
-	shopRepo := repository.NewShopRepository("http://localhost:7004")
+	shopClient := httpclient.New("shop", cfg.ShopServiceURL, clientCfg)
+	shopRepo := repository.NewShopRepository(shopClient)
+
+	// Verify tokens against the keys the user service publishes, and refuse
+	// the revoked ones listed by a cronjob
+	userClient := httpclient.New("user", cfg.UserServiceURL, clientCfg)
+	verifier := jwtauth.NewVerifier(jwtauth.NewRemoteKeys(userClient, cfg.JWKSCacheTTL), cfg.JWTIssuer, cfg.JWTAudience)
+	revocations := ordermiddleware.NewRevocationList(repository.NewUserRepository(userClient))
+	refreshRevokedTokens := cj.NewRefreshRevokedTokensJob(revocations)
+	refreshRevokedTokens.Run(context.Background())
 
 	// Initiate Echo
 	e := echo.New()
 
 	// Use middleware
+	e.Use(requestid.Middleware())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
+	// Endpoints for operators and the other services listen on the internal
+	// port, which is not published
+	internal := echo.New()
+	internal.HideBanner = true
+	internal.Use(requestid.Middleware())
+	internal.Use(middleware.Logger())
+	internal.Use(middleware.Recover())
+	internal.GET("/internal/db/stats", database.StatsHandler(dbConn))
+
 	// Init Order Repository, Service, Handler
 	orderRepo := repository.NewOrderRepository(dbConn)
 	orderService := service.NewOrderService(orderRepo, productRepo, shopRepo)
-	handler.RegisterOrderRoutes(e, orderService)
+	handler.RegisterOrderRoutes(e, orderService, verifier, revocations)
+	handler.RegisterInternalOrderRoutes(internal, orderService)
 
 	// Init cronjob
-	autoCancelJob := cj.NewAutoCancelJob(orderRepo, productRepo)
+	autoCancelJob := cj.NewAutoCancelJob(orderRepo, productRepo, cfg.PendingOrderTTL)
 	c := cron.New()
-	c.AddFunc("@every 2m", func() {
-		autoCancelJob.Run()
+	c.AddFunc(cfg.AutoCancelSchedule, func() {
+		autoCancelJob.Run(runner.Context())
 	})
-	c.Start()
-
-	// Start server in goroutine
-	go func() {
-		log.Println("Starting server on port 7003...")
-		if err := e.Start(":7003"); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+	c.AddFunc(cfg.RevocationRefreshSchedule, func() {
+		refreshRevokedTokens.Run(runner.Context())
+	})
+	runner.Cron(c)
 
-	// Waiting program
-	select {}
+	// Run until SIGINT/SIGTERM, then drain and close the database last
+	runner.OnStop("database", func(ctx context.Context) error {
+		return dbConn.Close()
+	})
+	runner.HTTPServer(e, fmt.Sprintf(":%d", cfg.Port))
+	runner.HTTPServer(internal, fmt.Sprintf(":%d", cfg.InternalPort))
+	if err := runner.Run(context.Background()); err != nil {
+		log.Fatalf("Service stopped with error: %v", err)
+	}
 }
@@ -1,61 +1,14 @@
 package middleware
 
 import (
-	"net/http"
-	"strings"
+	"monorepo-ecommerce/pkg/jwtauth"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-var jwtSecret = []byte("secret-key")
-
-func IsAuthenticated(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"message": "Token not found, please login first",
-			})
-		}
-
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"message": "Invalid token format, please login first",
-			})
-		}
-
-		// Verifiy token
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, echo.NewHTTPError(http.StatusUnauthorized, "Token invalid")
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"message": "Token invalid or expired",
-			})
-		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userIdFloat64 := claims["user_id"].(float64)
-			email := claims["email"].(string)
-			phone := claims["phone"].(string)
-			userId := int64(userIdFloat64)
-
-			c.Set("user_id", userId)
-			c.Set("email", email)
-			c.Set("phone", phone)
-		} else {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"message": "Token invalid",
-			})
-		}
-
-		return next(c)
-	}
+// IsAuthenticated verifies the bearer token against the user service's
+// published keys, refuses it when revocations lists its jti, and stores its
+// claims on the context.
+func IsAuthenticated(verifier *jwtauth.Verifier, revocations *RevocationList) echo.MiddlewareFunc {
+	return jwtauth.Middleware(verifier, revocations.IsRevoked)
 }
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/order/repository"
+	"sync"
+	"time"
+)
+
+// RevocationList holds the access tokens the user service revoked before
+// they expire, as of the last refresh. Checking it costs no request; a token
+// revoked since then is refused from the next refresh on.
+type RevocationList struct {
+	userRepo repository.UserRepository
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+func NewRevocationList(userRepo repository.UserRepository) *RevocationList {
+	return &RevocationList{userRepo: userRepo, revoked: map[string]time.Time{}}
+}
+
+// Refresh replaces the list with the one of the user service. When that
+// fails, the previous list is kept.
+func (l *RevocationList) Refresh(ctx context.Context) error {
+	tokens, err := l.userRepo.GetRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		revoked[token.TokenId] = token.ExpiresAt
+	}
+
+	l.mu.Lock()
+	l.revoked = revoked
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(tokenId string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	expiresAt, ok := l.revoked[tokenId]
+	return ok && time.Now().Before(expiresAt)
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"monorepo-ecommerce/micro-services/order/middleware"
+	mocks "monorepo-ecommerce/micro-services/order/mocks/mock_micro-services/order/repository"
+	"monorepo-ecommerce/micro-services/order/repository"
+	"monorepo-ecommerce/pkg/jwtauth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// signingKey stands in for the key the user service publishes.
+type signingKey struct {
+	private ed25519.PrivateKey
+	kid     string
+}
+
+func (k signingKey) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if kid != k.kid {
+		return nil, jwtauth.ErrUnknownKey
+	}
+	return k.private.Public(), nil
+}
+
+func (k signingKey) sign(t *testing.T, tokenId string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"user_id": 1,
+		"email":   "test@example.com",
+		"phone":   "0811",
+		"iss":     "user-service",
+		"aud":     "monorepo-ecommerce",
+		"exp":     time.Now().Add(15 * time.Minute).Unix(),
+	}
+	if tokenId != "" {
+		claims["jti"] = tokenId
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.kid
+	signed, err := token.SignedString(k.private)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestIsAuthenticated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockUserRepo := mocks.NewMockUserRepository(ctrl)
+	revocations := middleware.NewRevocationList(mockUserRepo)
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	key := signingKey{private: private, kid: "key-1"}
+	auth := middleware.IsAuthenticated(jwtauth.NewVerifier(key, "user-service", "monorepo-ecommerce"), revocations)
+	e := echo.New()
+
+	mockUserRepo.EXPECT().
+		GetRevokedTokens(gomock.Any()).
+		Return([]repository.RevokedToken{{TokenId: "revoked", ExpiresAt: time.Now().Add(15 * time.Minute)}}, nil)
+	assert.NoError(t, revocations.Refresh(context.Background()))
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := auth(func(c echo.Context) error {
+			assert.Equal(t, int64(1), c.Get("user_id"))
+			return c.NoContent(http.StatusOK)
+		})(c)
+		assert.NoError(t, err)
+		return rec
+	}
+
+	t.Run("should pass a valid token", func(t *testing.T) {
+		rec := serve(key.sign(t, "active"))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should unauthorized a token without jti", func(t *testing.T) {
+		rec := serve(key.sign(t, ""))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("should unauthorized a token signed with the old shared secret", func(t *testing.T) {
+		legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 1,
+			"exp":     time.Now().Add(15 * time.Minute).Unix(),
+		}).SignedString([]byte("secret-key"))
+
+		rec := serve(legacy)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("should unauthorized a revoked token", func(t *testing.T) {
+		rec := serve(key.sign(t, "revoked"))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Token revoked")
+	})
+
+	t.Run("should keep the last list when the refresh fails", func(t *testing.T) {
+		mockUserRepo.EXPECT().
+			GetRevokedTokens(gomock.Any()).
+			Return(nil, errors.New("user service unavailable"))
+
+		err := revocations.Refresh(context.Background())
+
+		assert.Error(t, err)
+		assert.True(t, revocations.IsRevoked("revoked"))
+	})
+}
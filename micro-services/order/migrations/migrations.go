@@ -0,0 +1,21 @@
+// Package migrations embeds the versioned schema of the service, written
+// once per database dialect.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"monorepo-ecommerce/pkg/database"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// For returns the migrations for dialect.
+func For(dialect database.Dialect) fs.FS {
+	sub, err := fs.Sub(files, string(dialect))
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
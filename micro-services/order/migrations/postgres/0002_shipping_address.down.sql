@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN shipping_longitude;
+ALTER TABLE orders DROP COLUMN shipping_latitude;
+ALTER TABLE orders DROP COLUMN shipping_address;
@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN shipping_address TEXT;
+ALTER TABLE orders ADD COLUMN shipping_latitude DOUBLE PRECISION;
+ALTER TABLE orders ADD COLUMN shipping_longitude DOUBLE PRECISION;
@@ -0,0 +1,3 @@
+ALTER TABLE orders ADD COLUMN shipping_address TEXT;
+ALTER TABLE orders ADD COLUMN shipping_latitude REAL;
+ALTER TABLE orders ADD COLUMN shipping_longitude REAL;
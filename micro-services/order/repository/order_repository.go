@@ -1,77 +1,85 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"monorepo-ecommerce/micro-services/order/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
 	"time"
 )
 
 type OrderRepository interface {
-	CreateOrder(order *models.Order) (*models.Order, error)
-	GetOrderById(orderId int64) (*models.Order, error)
-	UpdateOrderStatus(orderId int64, status string) error
-	GetExpiredOrders(status string, cutoffTime time.Time) ([]models.Order, error)
+	CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error)
+	GetOrderById(ctx context.Context, orderId int64) (*models.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderId int64, status string) error
+	GetExpiredOrders(ctx context.Context, status string, cutoffTime time.Time) ([]models.Order, error)
+	// GetPendingStock sums the items of pending orders per product.
+	GetPendingStock(ctx context.Context) ([]models.PendingStock, error)
+	GetPendingOrderIds(ctx context.Context) ([]int64, error)
 }
 
 type orderRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewOrderRepository(db *sql.DB) OrderRepository {
+func NewOrderRepository(db *database.DB) OrderRepository {
 	return &orderRepository{db: db}
 }
 
-func (r *orderRepository) CreateOrder(order *models.Order) (*models.Order, error) {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("failed begin transaction: %v", err)
-	}
-
-	orderQuery := "INSERT INTO orders (user_id, total_price, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
-	result, err := tx.Exec(orderQuery, order.UserId, order.TotalPrice, order.Status, time.Now(), time.Now())
-	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed insert order: %v", err)
-	}
-
-	orderId, err := result.LastInsertId()
-	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed retreive Id order: %v", err)
-	}
-
-	for _, item := range order.Items {
-		itemQuery := "INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)"
-		_, err := tx.Exec(itemQuery, orderId, item.ProductId, item.Quantity, item.Price)
+func (r *orderRepository) CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	var orderId int64
+	err := r.db.WithTx(ctx, func(tx *database.Tx) error {
+		var err error
+		address, latitude, longitude := shippingColumns(order.ShippingAddress)
+		orderQuery := "INSERT INTO orders (user_id, total_price, status, shipping_address, shipping_latitude, shipping_longitude, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+		orderId, err = tx.InsertReturningID(ctx, orderQuery, order.UserId, order.TotalPrice, order.Status, address, latitude, longitude, time.Now(), time.Now())
 		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed insert item order: %v", err)
+			return fmt.Errorf("failed insert order: %v", err)
+		}
+
+		for _, item := range order.Items {
+			itemQuery := "INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)"
+			_, err := tx.ExecContext(ctx, itemQuery, orderId, item.ProductId, item.Quantity, item.Price)
+			if err != nil {
+				return fmt.Errorf("failed insert item order: %v", err)
+			}
 		}
-	}
 
-	err = tx.Commit()
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed commit transaction: %v", err)
+		return nil, err
 	}
 
 	order.Id = orderId
 	return order, nil
 }
 
-func (r *orderRepository) GetOrderById(orderId int64) (*models.Order, error) {
+func (r *orderRepository) GetOrderById(ctx context.Context, orderId int64) (*models.Order, error) {
 	var order models.Order
-	row := r.db.QueryRow("SELECT id, user_id, status, total_price FROM orders WHERE id = ?", orderId)
-	err := row.Scan(&order.Id, &order.UserId, &order.Status, &order.TotalPrice)
+	var address sql.NullString
+	var latitude, longitude sql.NullFloat64
+	row := r.db.QueryRowContext(ctx, "SELECT id, user_id, status, total_price, shipping_address, shipping_latitude, shipping_longitude FROM orders WHERE id = ?", orderId)
+	err := row.Scan(&order.Id, &order.UserId, &order.Status, &order.TotalPrice, &address, &latitude, &longitude)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order with Id %d not found", orderId)
+			return nil, &apperror.NotFoundError{Resource: "order", Id: orderId}
 		}
 
 		return nil, err
 	}
 
-	items, err := r.getOrderItems(order.Id)
+	if address.Valid || latitude.Valid || longitude.Valid {
+		order.ShippingAddress = &models.ShippingAddress{Address: address.String}
+		if latitude.Valid && longitude.Valid {
+			order.ShippingAddress.Latitude = &latitude.Float64
+			order.ShippingAddress.Longitude = &longitude.Float64
+		}
+	}
+
+	items, err := r.getOrderItems(ctx, order.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -80,33 +88,34 @@ func (r *orderRepository) GetOrderById(orderId int64) (*models.Order, error) {
 	return &order, nil
 }
 
-func (r *orderRepository) UpdateOrderStatus(orderId int64, status string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+func shippingColumns(address *models.ShippingAddress) (sql.NullString, *float64, *float64) {
+	if address == nil {
+		return sql.NullString{}, nil, nil
 	}
+	return sql.NullString{String: address.Address, Valid: true}, address.Latitude, address.Longitude
+}
 
-	_, err = r.GetOrderById(orderId)
+func (r *orderRepository) UpdateOrderStatus(ctx context.Context, orderId int64, status string) error {
+	// a single statement is atomic on its own, the affected rows tell whether the order exists
+	result, err := r.db.ExecContext(ctx, "UPDATE orders SET status = ?, updated_at = ? WHERE id = ?", status, time.Now(), orderId)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE orders SET status = ? WHERE id = ?", status, orderId)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return err
+	if rowsAffected == 0 {
+		return &apperror.NotFoundError{Resource: "order", Id: orderId}
 	}
 
 	return nil
 }
 
-func (r *orderRepository) GetExpiredOrders(status string, cutoffTime time.Time) ([]models.Order, error) {
-	rows, err := r.db.Query("SELECT id, user_id, total_price, status FROM orders WHERE status = ? AND created_at < ?", status, cutoffTime)
+func (r *orderRepository) GetExpiredOrders(ctx context.Context, status string, cutoffTime time.Time) ([]models.Order, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, user_id, total_price, status FROM orders WHERE status = ? AND created_at < ?", status, cutoffTime)
 	if err != nil {
 		return nil, err
 	}
@@ -118,9 +127,8 @@ func (r *orderRepository) GetExpiredOrders(status string, cutoffTime time.Time)
 		if err := rows.Scan(&order.Id, &order.UserId, &order.TotalPrice, &order.Status); err != nil {
 			return nil, err
 		}
-		orders = append(orders, order)
 
-		items, err := r.getOrderItems(order.Id)
+		items, err := r.getOrderItems(ctx, order.Id)
 		if err != nil {
 			return nil, err
 		}
@@ -132,8 +140,52 @@ func (r *orderRepository) GetExpiredOrders(status string, cutoffTime time.Time)
 	return orders, nil
 }
 
-func (r *orderRepository) getOrderItems(orderId int64) ([]models.OrderItem, error) {
-	rows, err := r.db.Query("SELECT oi.id, oi.product_id, oi.quantity, oi.price FROM order_items oi WHERE oi.order_id = ?", orderId)
+func (r *orderRepository) GetPendingStock(ctx context.Context) ([]models.PendingStock, error) {
+	query := `SELECT oi.product_id, SUM(oi.quantity)
+              FROM order_items oi
+              JOIN orders o ON o.id = oi.order_id
+              WHERE o.status = 'pending'
+              GROUP BY oi.product_id
+              ORDER BY oi.product_id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []models.PendingStock
+	for rows.Next() {
+		var stock models.PendingStock
+		if err := rows.Scan(&stock.ProductId, &stock.Quantity); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, stock)
+	}
+
+	return stocks, rows.Err()
+}
+
+func (r *orderRepository) GetPendingOrderIds(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM orders WHERE status = 'pending' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (r *orderRepository) getOrderItems(ctx context.Context, orderId int64) ([]models.OrderItem, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT oi.id, oi.product_id, oi.quantity, oi.price FROM order_items oi WHERE oi.order_id = ?", orderId)
 	if err != nil {
 		return nil, err
 	}
@@ -1,20 +1,19 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-
-	"github.com/parnurzeal/gorequest"
+	"monorepo-ecommerce/pkg/httpclient"
 )
 
 type ProductRepository interface {
-	GetProductStock(productId int64) (*Product, error)
-	DeductStock(productId int64, quantity int) error
-	RestoreStock(productId int64, quantity int) error
+	GetProductStock(ctx context.Context, productId int64) (*Product, error)
+	DeductStock(ctx context.Context, productId int64, quantity int) error
+	RestoreStock(ctx context.Context, productId int64, quantity int) error
 }
 
 type productRepository struct {
-	baseURL string
+	client *httpclient.Client
 }
 
 type Product struct {
@@ -23,74 +22,30 @@ type Product struct {
 	Price float64 `json:"price"`
 }
 
-func NewProductRepository(baseURL string) ProductRepository {
+func NewProductRepository(client *httpclient.Client) ProductRepository {
 	return &productRepository{
-		baseURL: baseURL,
+		client: client,
 	}
 }
 
-func (r *productRepository) GetProductStock(productId int64) (*Product, error) {
-	url := fmt.Sprintf("%s/products/%d", r.baseURL, productId)
-
-	request := gorequest.New()
-	resp, body, errs := request.Get(url).
-		End()
-
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("failed to do a request: %v", errs)
-	}
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error occured: %v", resp.Status)
-	}
-
+func (r *productRepository) GetProductStock(ctx context.Context, productId int64) (*Product, error) {
 	var product Product
-	err := json.Unmarshal([]byte(body), &product)
+	err := r.client.Get(ctx, fmt.Sprintf("/products/%d", productId), &product)
 	if err != nil {
-		return nil, fmt.Errorf("failed unmarshall JSON: %v", err)
+		return nil, err
 	}
 
 	return &product, nil
 }
 
-func (r *productRepository) DeductStock(productId int64, quantity int) error {
-	url := fmt.Sprintf("%s/products/deduct/%d", r.baseURL, productId)
-
+func (r *productRepository) DeductStock(ctx context.Context, productId int64, quantity int) error {
 	body := map[string]int{"quantity": quantity}
 
-	request := gorequest.New()
-	resp, _, errs := request.Post(url).
-		Send(body).
-		End()
-
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to do a request: %v", errs)
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed deduct stock: %v", resp.Status)
-	}
-
-	return nil
+	return r.client.Post(ctx, fmt.Sprintf("/products/deduct/%d", productId), body, nil)
 }
 
-func (r *productRepository) RestoreStock(productId int64, quantity int) error {
-	url := fmt.Sprintf("%s/products/restore/%d", r.baseURL, productId)
-
+func (r *productRepository) RestoreStock(ctx context.Context, productId int64, quantity int) error {
 	body := map[string]int{"quantity": quantity}
 
-	request := gorequest.New()
-	resp, _, errs := request.Post(url).
-		Send(body).
-		End()
-
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to do a request: %v", errs)
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed deduct stock: %v", resp.Status)
-	}
-
-	return nil
+	return r.client.Post(ctx, fmt.Sprintf("/products/restore/%d", productId), body, nil)
 }
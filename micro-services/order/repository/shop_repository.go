@@ -1,27 +1,27 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"monorepo-ecommerce/micro-services/order/models"
-
-	"github.com/parnurzeal/gorequest"
+	"monorepo-ecommerce/pkg/httpclient"
 )
 
 type ShopRepository interface {
-	ForwardOrderToShop(order models.Order) error
+	ForwardOrderToShop(ctx context.Context, order models.Order) error
 }
 
 type shopRepository struct {
-	baseURL string
+	client *httpclient.Client
 }
 
-func NewShopRepository(baseURL string) ShopRepository {
-	return &shopRepository{baseURL: baseURL}
+func NewShopRepository(client *httpclient.Client) ShopRepository {
+	return &shopRepository{client: client}
 }
 
 type ProceedOrderRequest struct {
-	OrderID int64                 `json:"order_id"`
-	Items   []ProductOrderDetails `json:"items"`
+	OrderID         int64                   `json:"order_id"`
+	Items           []ProductOrderDetails   `json:"items"`
+	ShippingAddress *models.ShippingAddress `json:"shipping_address,omitempty"`
 }
 
 type ProductOrderDetails struct {
@@ -29,12 +29,11 @@ type ProductOrderDetails struct {
 	Quantity  int   `json:"quantity"`
 }
 
-func (r *shopRepository) ForwardOrderToShop(order models.Order) error {
-	url := fmt.Sprintf("%s/shop/proceed-order", r.baseURL)
-
+func (r *shopRepository) ForwardOrderToShop(ctx context.Context, order models.Order) error {
 	requestBody := ProceedOrderRequest{
-		OrderID: order.Id,
-		Items:   make([]ProductOrderDetails, len(order.Items)),
+		OrderID:         order.Id,
+		Items:           make([]ProductOrderDetails, len(order.Items)),
+		ShippingAddress: order.ShippingAddress,
 	}
 	for i, item := range order.Items {
 		requestBody.Items[i] = ProductOrderDetails{
@@ -43,18 +42,5 @@ func (r *shopRepository) ForwardOrderToShop(order models.Order) error {
 		}
 	}
 
-	request := gorequest.New()
-	resp, body, errs := request.Post(url).
-		SendStruct(requestBody).
-		End()
-
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to call warehouse service: %v", errs[0])
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("warehouse service returned error: %s", body)
-	}
-
-	return nil
+	return r.client.Post(ctx, "/shop/proceed-order", requestBody, nil)
 }
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/order/migrations"
+	"monorepo-ecommerce/micro-services/order/models"
+	"monorepo-ecommerce/micro-services/order/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openDB(t *testing.T) *database.DB {
+	return dbtest.Open(t, "order", migrations.For)
+}
+
+func TestOrderRepository_UpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewOrderRepository(openDB(t))
+
+	order, err := orderRepo.CreateOrder(ctx, &models.Order{
+		UserId:     1,
+		TotalPrice: 100,
+		Status:     "pending",
+		Items:      []models.OrderItem{{ProductId: 1, Quantity: 1, Price: 100}},
+	})
+	assert.NoError(t, err)
+
+	t.Run("should update existing order", func(t *testing.T) {
+		assert.NoError(t, orderRepo.UpdateOrderStatus(ctx, order.Id, "success"))
+
+		updated, err := orderRepo.GetOrderById(ctx, order.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "success", updated.Status)
+		assert.Len(t, updated.Items, 1)
+	})
+
+	t.Run("should report unknown order", func(t *testing.T) {
+		err := orderRepo.UpdateOrderStatus(ctx, 999, "success")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+func TestOrderRepository_ShippingAddress(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewOrderRepository(openDB(t))
+	latitude, longitude := -7.9666, 112.6326
+
+	t.Run("should keep the shipping address of an order", func(t *testing.T) {
+		order, err := orderRepo.CreateOrder(ctx, &models.Order{
+			UserId:          1,
+			TotalPrice:      100,
+			Status:          "pending",
+			Items:           []models.OrderItem{{ProductId: 1, Quantity: 1, Price: 100}},
+			ShippingAddress: &models.ShippingAddress{Address: "Malang", Latitude: &latitude, Longitude: &longitude},
+		})
+		assert.NoError(t, err)
+
+		found, err := orderRepo.GetOrderById(ctx, order.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Malang", found.ShippingAddress.Address)
+		assert.Equal(t, latitude, *found.ShippingAddress.Latitude)
+		assert.Equal(t, longitude, *found.ShippingAddress.Longitude)
+	})
+
+	t.Run("should leave the address empty when none was given", func(t *testing.T) {
+		order, err := orderRepo.CreateOrder(ctx, &models.Order{UserId: 1, TotalPrice: 0, Status: "pending"})
+		assert.NoError(t, err)
+
+		found, err := orderRepo.GetOrderById(ctx, order.Id)
+		assert.NoError(t, err)
+		assert.Nil(t, found.ShippingAddress)
+	})
+}
+
+func TestOrderRepository_PendingOrders(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewOrderRepository(openDB(t))
+
+	create := func(status string, items ...models.OrderItem) *models.Order {
+		order, err := orderRepo.CreateOrder(ctx, &models.Order{UserId: 1, Status: status, Items: items})
+		assert.NoError(t, err)
+		return order
+	}
+	first := create("pending", models.OrderItem{ProductId: 1, Quantity: 2}, models.OrderItem{ProductId: 2, Quantity: 1})
+	second := create("pending", models.OrderItem{ProductId: 1, Quantity: 3})
+	create("success", models.OrderItem{ProductId: 1, Quantity: 7})
+
+	t.Run("should sum the items of pending orders per product", func(t *testing.T) {
+		stocks, err := orderRepo.GetPendingStock(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.PendingStock{{ProductId: 1, Quantity: 5}, {ProductId: 2, Quantity: 1}}, stocks)
+	})
+
+	t.Run("should list the ids of pending orders only", func(t *testing.T) {
+		ids, err := orderRepo.GetPendingOrderIds(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{first.Id, second.Id}, ids)
+	})
+}
+
+func TestOrderRepository_GetExpiredOrders(t *testing.T) {
+	ctx := context.Background()
+	orderRepo := repository.NewOrderRepository(openDB(t))
+
+	expired, err := orderRepo.CreateOrder(ctx, &models.Order{UserId: 1, Status: "pending", Items: []models.OrderItem{{ProductId: 1, Quantity: 2}, {ProductId: 2, Quantity: 1}}})
+	assert.NoError(t, err)
+	_, err = orderRepo.CreateOrder(ctx, &models.Order{UserId: 1, Status: "success", Items: []models.OrderItem{{ProductId: 1, Quantity: 7}}})
+	assert.NoError(t, err)
+
+	t.Run("should return each expired order once with its items", func(t *testing.T) {
+		orders, err := orderRepo.GetExpiredOrders(ctx, "pending", time.Now().Add(time.Minute))
+
+		assert.NoError(t, err)
+		if assert.Len(t, orders, 1) {
+			assert.Equal(t, expired.Id, orders[0].Id)
+			assert.Len(t, orders[0].Items, 2)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/order/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newProductServiceStub stands in for the product service over real HTTP.
+func newProductServiceStub() *httptest.Server {
+	e := echo.New()
+	e.GET("/products/:id", func(c echo.Context) error {
+		if c.Param("id") != "1" {
+			return apperror.JSON(c, http.StatusInternalServerError, &apperror.NotFoundError{Resource: "product", Id: 2})
+		}
+		return c.JSON(http.StatusOK, repository.Product{Id: 1, Stock: 3, Price: 100})
+	})
+	e.POST("/products/deduct/:id", func(c echo.Context) error {
+		return apperror.JSON(c, http.StatusInternalServerError, &apperror.InsufficientStockError{ProductId: 1, Requested: 5, Available: 3})
+	})
+
+	return httptest.NewServer(e)
+}
+
+func clientConfig() httpclient.Config {
+	cfg := httpclient.DefaultConfig()
+	cfg.Timeout = time.Second
+	cfg.BaseBackoff = time.Millisecond
+	cfg.MaxBackoff = time.Millisecond
+	return cfg
+}
+
+func TestGetProductStock(t *testing.T) {
+	srv := newProductServiceStub()
+	defer srv.Close()
+
+	productRepo := repository.NewProductRepository(httpclient.New("product", srv.URL, clientConfig()))
+
+	t.Run("should success", func(t *testing.T) {
+		product, err := productRepo.GetProductStock(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, product.Stock)
+		assert.Equal(t, float64(100), product.Price)
+	})
+
+	t.Run("should return not found", func(t *testing.T) {
+		product, err := productRepo.GetProductStock(context.Background(), 2)
+
+		assert.Nil(t, product)
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+	})
+}
+
+func TestDeductStock(t *testing.T) {
+	t.Run("should decode insufficient stock", func(t *testing.T) {
+		srv := newProductServiceStub()
+		defer srv.Close()
+
+		productRepo := repository.NewProductRepository(httpclient.New("product", srv.URL, clientConfig()))
+
+		err := productRepo.DeductStock(context.Background(), 1, 5)
+
+		var insufficient *apperror.InsufficientStockError
+		assert.True(t, errors.As(err, &insufficient))
+		assert.Equal(t, 3, insufficient.Available)
+	})
+
+	t.Run("should be upstream unavailable when product service is down", func(t *testing.T) {
+		srv := newProductServiceStub()
+		srv.Close()
+
+		productRepo := repository.NewProductRepository(httpclient.New("product", srv.URL, clientConfig()))
+
+		err := productRepo.DeductStock(context.Background(), 1, 5)
+
+		assert.True(t, errors.Is(err, apperror.ErrUpstreamUnavailable))
+	})
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/pkg/httpclient"
+	"time"
+)
+
+type UserRepository interface {
+	// GetRevokedTokens lists the access tokens revoked before they expire.
+	GetRevokedTokens(ctx context.Context) ([]RevokedToken, error)
+}
+
+type userRepository struct {
+	client *httpclient.Client
+}
+
+func NewUserRepository(client *httpclient.Client) UserRepository {
+	return &userRepository{client: client}
+}
+
+// RevokedToken is an access token, by its jti, that is refused until it
+// expires.
+type RevokedToken struct {
+	TokenId   string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *userRepository) GetRevokedTokens(ctx context.Context) ([]RevokedToken, error) {
+	var tokens []RevokedToken
+	err := r.client.Get(ctx, "/user/revoked-tokens", &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
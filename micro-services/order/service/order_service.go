@@ -1,18 +1,22 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"monorepo-ecommerce/micro-services/order/models"
 	"monorepo-ecommerce/micro-services/order/repository"
-
-	"github.com/labstack/echo/v4"
+	"monorepo-ecommerce/pkg/apperror"
 )
 
 type OrderService interface {
-	CreateOrder(c echo.Context, orderRequest *models.OrderRequest) (*models.Order, error)
-	ProcessPayment(orderId int64, paid bool) (*models.Order, error)
-	CancelOrder(orderId int64) error
-	ForwardOrderToShop(order models.Order) error
+	CreateOrder(ctx context.Context, userId int64, orderRequest *models.OrderRequest) (*models.Order, error)
+	ProcessPayment(ctx context.Context, orderId int64, paid bool) (*models.Order, error)
+	CancelOrder(ctx context.Context, orderId int64) error
+	ForwardOrderToShop(ctx context.Context, order models.Order) error
+	// GetPendingStock reports the stock held by pending orders per product.
+	GetPendingStock(ctx context.Context) ([]models.PendingStock, error)
+	// GetPendingOrderIds lists the orders not paid or cancelled yet.
+	GetPendingOrderIds(ctx context.Context) ([]int64, error)
 }
 
 type orderService struct {
@@ -29,27 +33,30 @@ func NewOrderService(orderRepo repository.OrderRepository, productRepo repositor
 	}
 }
 
-func (s *orderService) CreateOrder(c echo.Context, orderRequest *models.OrderRequest) (*models.Order, error) {
-	userId := c.Get("user_id").(int64)
+func (s *orderService) CreateOrder(ctx context.Context, userId int64, orderRequest *models.OrderRequest) (*models.Order, error) {
+	if err := validateShippingAddress(orderRequest.ShippingAddress); err != nil {
+		return nil, err
+	}
+
 	var totalPrice float64
 	var items []models.OrderItem
 
 	for _, itemRequest := range orderRequest.Items {
 		// Fetch detail product based on ProductId
-		product, err := s.ProductRepo.GetProductStock(itemRequest.ProductId)
+		product, err := s.ProductRepo.GetProductStock(ctx, itemRequest.ProductId)
 		if err != nil {
-			return nil, fmt.Errorf("failed fetch product data: %v", err)
+			return nil, fmt.Errorf("failed fetch product data: %w", err)
 		}
 
 		// Check available quantity
 		if product.Stock < itemRequest.Quantity {
-			return nil, fmt.Errorf("product stock %d not enough", itemRequest.ProductId)
+			return nil, &apperror.InsufficientStockError{ProductId: itemRequest.ProductId, Requested: itemRequest.Quantity, Available: product.Stock}
 		}
 
 		// Reserve lock and deduction stock
-		err = s.ProductRepo.DeductStock(itemRequest.ProductId, itemRequest.Quantity)
+		err = s.ProductRepo.DeductStock(ctx, itemRequest.ProductId, itemRequest.Quantity)
 		if err != nil {
-			return nil, fmt.Errorf("failed to deduct stock: %v", err)
+			return nil, fmt.Errorf("failed to deduct stock: %w", err)
 		}
 
 		totalPrice += float64(itemRequest.Quantity) * product.Price
@@ -62,47 +69,67 @@ func (s *orderService) CreateOrder(c echo.Context, orderRequest *models.OrderReq
 	}
 
 	order := &models.Order{
-		UserId:     userId,
-		Items:      items,
-		TotalPrice: totalPrice,
-		Status:     "pending",
+		UserId:          userId,
+		Items:           items,
+		TotalPrice:      totalPrice,
+		Status:          "pending",
+		ShippingAddress: orderRequest.ShippingAddress,
 	}
 
-	createdOrder, err := s.OrderRepo.CreateOrder(order)
+	createdOrder, err := s.OrderRepo.CreateOrder(ctx, order)
 	if err != nil {
-		return nil, fmt.Errorf("failed create order: %v", err)
+		return nil, fmt.Errorf("failed create order: %w", err)
 	}
 
 	return createdOrder, nil
 }
 
-func (s *orderService) ProcessPayment(orderId int64, paid bool) (*models.Order, error) {
-	order, err := s.OrderRepo.GetOrderById(orderId)
+// validateShippingAddress requires coordinates to come in pairs and within range.
+func validateShippingAddress(address *models.ShippingAddress) error {
+	if address == nil {
+		return nil
+	}
+
+	if (address.Latitude == nil) != (address.Longitude == nil) {
+		return &apperror.InvalidInputError{Field: "shipping_address", Reason: "latitude and longitude must be given together"}
+	}
+	if address.Latitude != nil && (*address.Latitude < -90 || *address.Latitude > 90) {
+		return &apperror.InvalidInputError{Field: "shipping_address.latitude", Reason: "must be between -90 and 90"}
+	}
+	if address.Longitude != nil && (*address.Longitude < -180 || *address.Longitude > 180) {
+		return &apperror.InvalidInputError{Field: "shipping_address.longitude", Reason: "must be between -180 and 180"}
+	}
+
+	return nil
+}
+
+func (s *orderService) ProcessPayment(ctx context.Context, orderId int64, paid bool) (*models.Order, error) {
+	order, err := s.OrderRepo.GetOrderById(ctx, orderId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch order: %v", err)
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
 	}
 
 	if order.Status != "pending" {
-		return nil, fmt.Errorf("cannot process payment for order with status: %s", order.Status)
+		return nil, &apperror.ConflictError{Resource: "order", Reason: fmt.Sprintf("cannot process payment for order with status: %s", order.Status)}
 	}
 
 	if paid {
 		// send data to invoke shop service
-		err = s.ForwardOrderToShop(*order)
+		err = s.ForwardOrderToShop(ctx, *order)
 		if err != nil {
 			return nil, err
 		}
 
-		err = s.OrderRepo.UpdateOrderStatus(orderId, "success")
+		err = s.OrderRepo.UpdateOrderStatus(ctx, orderId, "success")
 		if err != nil {
-			return nil, fmt.Errorf("failed to update order status: %v", err)
+			return nil, fmt.Errorf("failed to update order status: %w", err)
 		}
 
 		order.Status = "success"
 	} else {
-		err = s.CancelOrder(orderId)
+		err = s.CancelOrder(ctx, orderId)
 		if err != nil {
-			return nil, fmt.Errorf("failed to cancel order: %v", err)
+			return nil, fmt.Errorf("failed to cancel order: %w", err)
 		}
 
 		order.Status = "cancelled"
@@ -111,32 +138,50 @@ func (s *orderService) ProcessPayment(orderId int64, paid bool) (*models.Order,
 	return order, nil
 }
 
-func (s *orderService) CancelOrder(orderId int64) error {
-	order, err := s.OrderRepo.GetOrderById(orderId)
+func (s *orderService) CancelOrder(ctx context.Context, orderId int64) error {
+	order, err := s.OrderRepo.GetOrderById(ctx, orderId)
 	if err != nil {
-		return fmt.Errorf("failed to fetch order: %v", err)
+		return fmt.Errorf("failed to fetch order: %w", err)
 	}
 
-	err = s.OrderRepo.UpdateOrderStatus(orderId, "cancelled")
+	err = s.OrderRepo.UpdateOrderStatus(ctx, orderId, "cancelled")
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %v", err)
+		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	for _, item := range order.Items {
-		err = s.ProductRepo.RestoreStock(item.ProductId, item.Quantity)
+		err = s.ProductRepo.RestoreStock(ctx, item.ProductId, item.Quantity)
 		if err != nil {
-			return fmt.Errorf("failed to restore stock for product %d: %v", item.ProductId, err)
+			return fmt.Errorf("failed to restore stock for product %d: %w", item.ProductId, err)
 		}
 	}
 
 	return nil
 }
 
-func (s *orderService) ForwardOrderToShop(order models.Order) error {
-	err := s.ShopRepo.ForwardOrderToShop(order)
+func (s *orderService) ForwardOrderToShop(ctx context.Context, order models.Order) error {
+	err := s.ShopRepo.ForwardOrderToShop(ctx, order)
 	if err != nil {
-		return fmt.Errorf("failed to forward order to shop")
+		return fmt.Errorf("failed to forward order to shop: %w", err)
 	}
 
 	return nil
 }
+
+func (s *orderService) GetPendingStock(ctx context.Context) ([]models.PendingStock, error) {
+	stocks, err := s.OrderRepo.GetPendingStock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending stock: %w", err)
+	}
+
+	return stocks, nil
+}
+
+func (s *orderService) GetPendingOrderIds(ctx context.Context) ([]int64, error) {
+	ids, err := s.OrderRepo.GetPendingOrderIds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending orders: %w", err)
+	}
+
+	return ids, nil
+}
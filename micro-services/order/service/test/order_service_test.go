@@ -1,15 +1,14 @@
 package test
 
 import (
+	"context"
 	mocks "monorepo-ecommerce/micro-services/order/mocks/mock_micro-services/order/repository"
 	"monorepo-ecommerce/micro-services/order/models"
 	"monorepo-ecommerce/micro-services/order/repository"
 	"monorepo-ecommerce/micro-services/order/service"
-	"net/http"
-	"net/http/httptest"
+	"monorepo-ecommerce/pkg/apperror"
 	"testing"
 
-	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
@@ -52,30 +51,33 @@ func TestCreateOrder(t *testing.T) {
 	}
 
 	mockProductRepo.EXPECT().
-		GetProductStock(gomock.Any()).
+		GetProductStock(gomock.Any(), gomock.Any()).
 		Return(getProductStock, nil)
 	mockProductRepo.EXPECT().
-		DeductStock(gomock.Any(), gomock.Any()).
+		DeductStock(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil)
 
 	mockOrderRepo.EXPECT().
-		CreateOrder(gomock.Any()).
+		CreateOrder(gomock.Any(), gomock.Any()).
 		Return(createOrder, nil)
 
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
-	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-	c.Set("user_id", int64(1))
-
-	order, err := orderService.CreateOrder(c, orderRequest)
+	order, err := orderService.CreateOrder(context.Background(), int64(1), orderRequest)
 
 	// Assertions
 	assert.NoError(t, err)
 	assert.NotNil(t, order)
 	assert.Equal(t, float64(200), order.TotalPrice)
 	assert.Equal(t, "pending", order.Status)
+
+	t.Run("should reject a shipping address with half its coordinates", func(t *testing.T) {
+		latitude := -6.2
+		_, err := orderService.CreateOrder(context.Background(), int64(1), &models.OrderRequest{
+			Items:           orderRequest.Items,
+			ShippingAddress: &models.ShippingAddress{Address: "Jakarta", Latitude: &latitude},
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
 }
 
 func TestProcessPayment(t *testing.T) {
@@ -95,18 +97,18 @@ func TestProcessPayment(t *testing.T) {
 	}
 
 	mockOrderRepo.EXPECT().
-		GetOrderById(int64(1)).
+		GetOrderById(gomock.Any(), int64(1)).
 		Return(order, nil)
 
 	mockShopRepo.EXPECT().
-		ForwardOrderToShop(gomock.Any()).
+		ForwardOrderToShop(gomock.Any(), gomock.Any()).
 		Return(nil)
 
 	mockOrderRepo.EXPECT().
-		UpdateOrderStatus(gomock.Any(), "success").
+		UpdateOrderStatus(gomock.Any(), gomock.Any(), "success").
 		Return(nil)
 
-	order, err := orderService.ProcessPayment(int64(1), true)
+	order, err := orderService.ProcessPayment(context.Background(), int64(1), true)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "success", order.Status)
@@ -130,18 +132,18 @@ func TestCancelOrder(t *testing.T) {
 	}
 
 	mockOrderRepo.EXPECT().
-		GetOrderById(gomock.Any()).
+		GetOrderById(gomock.Any(), gomock.Any()).
 		Return(order, nil)
 
 	mockOrderRepo.EXPECT().
-		UpdateOrderStatus(gomock.Any(), "cancelled").
+		UpdateOrderStatus(gomock.Any(), gomock.Any(), "cancelled").
 		Return(nil)
 
 	mockProductRepo.EXPECT().
-		RestoreStock(gomock.Any(), gomock.Any()).
+		RestoreStock(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil)
 
-	err := orderService.CancelOrder(int64(1))
+	err := orderService.CancelOrder(context.Background(), int64(1))
 
 	assert.NoError(t, err)
 }
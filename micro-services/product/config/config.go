@@ -1 +1,45 @@
 package config
+
+import (
+	"errors"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+)
+
+type Config struct {
+	Port            int           `yaml:"port" env:"PORT"`
+	InternalPort    int           `yaml:"internal_port" env:"INTERNAL_PORT"`
+	DatabaseDriver  string        `yaml:"database_driver" env:"DATABASE_DRIVER"`
+	DatabasePath    string        `yaml:"database_path" env:"DATABASE_PATH"`
+	DatabaseURL     string        `yaml:"database_url" env:"DATABASE_URL" secret:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+func Default() Config {
+	return Config{
+		Port:            7002,
+		InternalPort:    8002,
+		DatabaseDriver:  "sqlite",
+		DatabasePath:    "./../../data/product.db",
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// Load returns the defaults overridden by CONFIG_FILE and the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+	if err := configloader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateInternalPort(c.Port, c.InternalPort),
+		database.NewConfig(c.DatabaseDriver, c.DatabasePath, c.DatabaseURL).Validate(),
+		configloader.ValidatePositive("shutdown_timeout", c.ShutdownTimeout),
+	)
+}
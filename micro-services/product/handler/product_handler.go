@@ -3,8 +3,10 @@ package handler
 import (
 	"monorepo-ecommerce/micro-services/product/models"
 	"monorepo-ecommerce/micro-services/product/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -17,8 +19,18 @@ func NewProductHandler(service service.ProductService) *ProductHandler {
 	return &ProductHandler{service: service}
 }
 
+// GetProducts lists every product, the products of a comma separated list
+// of ids, or one page of them when limit is given; the next page starts after
+// the last id of this one.
 func (h *ProductHandler) GetProducts(c echo.Context) error {
-	products, err := h.service.GetAllProducts()
+	if c.QueryParam("ids") != "" {
+		return h.getProductsByIds(c)
+	}
+	if c.QueryParam("limit") != "" {
+		return h.getProductsPage(c)
+	}
+
+	products, err := h.service.GetAllProducts(c.Request().Context())
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch products"})
 	}
@@ -30,12 +42,56 @@ func (h *ProductHandler) GetProducts(c echo.Context) error {
 	return c.JSON(http.StatusOK, products)
 }
 
+func (h *ProductHandler) getProductsPage(c echo.Context) error {
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid limit"})
+	}
+	var afterId int64
+	if value := c.QueryParam("after_id"); value != "" {
+		if afterId, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid after_id"})
+		}
+	}
+
+	products, err := h.service.ListProducts(c.Request().Context(), afterId, limit)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+	if products == nil {
+		products = []models.Product{}
+	}
+
+	return c.JSON(http.StatusOK, products)
+}
+
+func (h *ProductHandler) getProductsByIds(c echo.Context) error {
+	var productIds []int64
+	for _, value := range strings.Split(c.QueryParam("ids"), ",") {
+		productId, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid ids"})
+		}
+		productIds = append(productIds, productId)
+	}
+
+	products, err := h.service.GetProductsByIds(c.Request().Context(), productIds)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+	if products == nil {
+		products = []models.Product{}
+	}
+
+	return c.JSON(http.StatusOK, products)
+}
+
 func (h *ProductHandler) GetProduct(c echo.Context) error {
 	id := c.Param("id")
 	productId, _ := strconv.ParseInt(id, 10, 64)
-	product, err := h.service.GetProductById(productId)
+	product, err := h.service.GetProductById(c.Request().Context(), productId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch product"})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, product)
@@ -53,9 +109,9 @@ func (h *ProductHandler) DeductStock(c echo.Context) error {
 	}
 
 	productId, _ := strconv.ParseInt(id, 10, 64)
-	err := h.service.DeductStock(productId, requestBody.Quantity)
+	err := h.service.DeductStock(c.Request().Context(), productId, requestBody.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed deduct product stock"})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Product stock success to deduct"})
@@ -73,9 +129,9 @@ func (h *ProductHandler) RestoreStock(c echo.Context) error {
 	}
 
 	productId, _ := strconv.ParseInt(id, 10, 64)
-	err := h.service.RestoreStock(productId, requestBody.Quantity)
+	err := h.service.RestoreStock(c.Request().Context(), productId, requestBody.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed deduct product stock"})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Product stock success to deduct"})
@@ -93,14 +149,32 @@ func (h *ProductHandler) UpdateTotalProductStock(c echo.Context) error {
 	}
 
 	productId, _ := strconv.ParseInt(id, 10, 64)
-	err := h.service.UpdateTotalStock(productId, requestBody.Quantity)
+	err := h.service.UpdateTotalStock(c.Request().Context(), productId, requestBody.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed deduct product stock"})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Product stock success to deduct"})
 }
 
+// UpdateTotalStocks sets the stock of a batch of products, as pushed by the
+// warehouse service whenever totals change.
+func (h *ProductHandler) UpdateTotalStocks(c echo.Context) error {
+	var requestBody struct {
+		Totals []models.StockTotal `json:"totals"`
+	}
+	if err := c.Bind(&requestBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Request body invalid"})
+	}
+
+	result, err := h.service.UpdateTotalStocks(c.Request().Context(), requestBody.Totals)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func RegisterProductRoutes(e *echo.Echo, productService service.ProductService) {
 	handler := NewProductHandler(productService)
 	e.GET("/products", handler.GetProducts)
@@ -108,4 +182,5 @@ func RegisterProductRoutes(e *echo.Echo, productService service.ProductService)
 	e.POST("/products/deduct/:id", handler.DeductStock)
 	e.POST("/products/restore/:id", handler.RestoreStock)
 	e.POST("/products/adjust-total-stock/:id", handler.UpdateTotalProductStock)
+	e.POST("/products/stock-totals", handler.UpdateTotalStocks)
 }
@@ -35,7 +35,7 @@ func TestGetProducts(t *testing.T) {
 		}
 
 		mockProductService.EXPECT().
-			GetAllProducts().
+			GetAllProducts(gomock.Any()).
 			Return(mockProducts, nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/products", nil)
@@ -53,7 +53,7 @@ func TestGetProducts(t *testing.T) {
 		mockProducts := []models.Product{}
 
 		mockProductService.EXPECT().
-			GetAllProducts().
+			GetAllProducts(gomock.Any()).
 			Return(mockProducts, nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/products", nil)
@@ -71,7 +71,7 @@ func TestGetProducts(t *testing.T) {
 		mockProducts := []models.Product{}
 
 		mockProductService.EXPECT().
-			GetAllProducts().
+			GetAllProducts(gomock.Any()).
 			Return(mockProducts, errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodGet, "/products", nil)
@@ -105,7 +105,7 @@ func TestGetProduct(t *testing.T) {
 		}
 
 		mockProductService.EXPECT().
-			GetProductById(mockId).
+			GetProductById(gomock.Any(), mockId).
 			Return(&mockProduct, nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
@@ -129,7 +129,7 @@ func TestGetProduct(t *testing.T) {
 		mockProduct := models.Product{}
 
 		mockProductService.EXPECT().
-			GetProductById(mockId).
+			GetProductById(gomock.Any(), mockId).
 			Return(&mockProduct, errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
@@ -169,7 +169,7 @@ func TestDeductStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			DeductStock(mockId, reqBody.Quantity).
+			DeductStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/products/deduct/1", bytes.NewBuffer(reqJSON))
@@ -223,7 +223,7 @@ func TestDeductStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			DeductStock(mockId, reqBody.Quantity).
+			DeductStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/products/deduct/1", bytes.NewBuffer(reqJSON))
@@ -263,7 +263,7 @@ func TestRestoreStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			RestoreStock(mockId, reqBody.Quantity).
+			RestoreStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/products/restore/1", bytes.NewBuffer(reqJSON))
@@ -317,7 +317,7 @@ func TestRestoreStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			RestoreStock(mockId, reqBody.Quantity).
+			RestoreStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/products/restore/1", bytes.NewBuffer(reqJSON))
@@ -357,7 +357,7 @@ func TestUpdateTotalProductStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			UpdateTotalStock(mockId, reqBody.Quantity).
+			UpdateTotalStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/products/adjust-total-stock/1", bytes.NewBuffer(reqJSON))
@@ -411,7 +411,7 @@ func TestUpdateTotalProductStock(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockProductService.EXPECT().
-			UpdateTotalStock(mockId, reqBody.Quantity).
+			UpdateTotalStock(gomock.Any(), mockId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/products/adjust-total-stock/1", bytes.NewBuffer(reqJSON))
@@ -429,3 +429,112 @@ func TestUpdateTotalProductStock(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestUpdateTotalStocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProductService := mocks.NewMockProductService(ctrl)
+	h := handler.NewProductHandler(mockProductService)
+	e := echo.New()
+
+	newContext := func(body any) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/products/stock-totals", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("should success", func(t *testing.T) {
+		c, rec := newContext(map[string]any{"totals": []models.StockTotal{{ProductId: 1, Stock: 10}, {ProductId: 2, Stock: 0}}})
+
+		mockProductService.EXPECT().
+			UpdateTotalStocks(gomock.Any(), []models.StockTotal{{ProductId: 1, Stock: 10}, {ProductId: 2, Stock: 0}}).
+			Return(&models.StockTotalsResult{Updated: 2}, nil)
+
+		err := h.UpdateTotalStocks(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"updated":2,"missing":null}`, rec.Body.String())
+	})
+
+	t.Run("should bad request when request invalid", func(t *testing.T) {
+		c, rec := newContext(map[string]any{"totals": "all"})
+
+		err := h.UpdateTotalStocks(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetProductsPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProductService := mocks.NewMockProductService(ctrl)
+	h := handler.NewProductHandler(mockProductService)
+	e := echo.New()
+
+	t.Run("should list the page after the given id", func(t *testing.T) {
+		mockProductService.EXPECT().
+			ListProducts(gomock.Any(), int64(100), 50).
+			Return(nil, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/products?after_id=100&limit=50", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetProducts(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	t.Run("should bad request when the limit is not a number", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/products?limit=all", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetProducts(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetProductsByIds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockProductService := mocks.NewMockProductService(ctrl)
+	h := handler.NewProductHandler(mockProductService)
+	e := echo.New()
+
+	t.Run("should list the products of the given ids", func(t *testing.T) {
+		mockProductService.EXPECT().
+			GetProductsByIds(gomock.Any(), []int64{3, 1}).
+			Return([]models.Product{{Id: 1, Name: "Product 1"}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/products?ids=3,1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetProducts(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id":1,"name":"Product 1","description":"","price":0,"stock":0}]`, rec.Body.String())
+	})
+
+	t.Run("should bad request when an id is not a number", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/products?ids=1,two", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.GetProducts(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
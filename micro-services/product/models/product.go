@@ -7,3 +7,16 @@ type Product struct {
 	Price       float64 `json:"price"`
 	Stock       int     `json:"stock"`
 }
+
+// StockTotal is the sellable stock of a product across the warehouses.
+type StockTotal struct {
+	ProductId int64 `json:"product_id"`
+	Stock     int   `json:"stock"`
+}
+
+// StockTotalsResult reports a batch of stock totals; totals of products
+// that do not exist are skipped and listed in Missing.
+type StockTotalsResult struct {
+	Updated int     `json:"updated"`
+	Missing []int64 `json:"missing"`
+}
@@ -1,27 +1,60 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"monorepo-ecommerce/micro-services/product/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
 )
 
 type ProductRepository interface {
-	GetAllProducts() ([]models.Product, error)
-	GetProductStock(productId int64) (*models.Product, error)
-	UpdateStock(productId int64, quantity int) error
+	GetAllProducts(ctx context.Context) ([]models.Product, error)
+	// GetProductsAfter returns up to limit products with an id above afterId,
+	// in id order.
+	GetProductsAfter(ctx context.Context, afterId int64, limit int) ([]models.Product, error)
+	// GetProductsByIds returns the products among productIds that exist, in
+	// id order.
+	GetProductsByIds(ctx context.Context, productIds []int64) ([]models.Product, error)
+	GetProductStock(ctx context.Context, productId int64) (*models.Product, error)
+	UpdateStock(ctx context.Context, productId int64, quantity int) error
+	// UpdateStocks sets the stock of every product in one transaction and
+	// returns the ids that matched no product.
+	UpdateStocks(ctx context.Context, totals []models.StockTotal) ([]int64, error)
 }
 
 type productRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewProductRepository(db *sql.DB) ProductRepository {
+func NewProductRepository(db *database.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) GetAllProducts() ([]models.Product, error) {
-	rows, err := r.db.Query("SELECT id, name, description, price, stock FROM products")
+func (r *productRepository) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	return r.queryProducts(ctx, "SELECT id, name, description, price, stock FROM products")
+}
+
+func (r *productRepository) GetProductsAfter(ctx context.Context, afterId int64, limit int) ([]models.Product, error) {
+	return r.queryProducts(ctx, "SELECT id, name, description, price, stock FROM products WHERE id > ? ORDER BY id LIMIT ?", afterId, limit)
+}
+
+func (r *productRepository) GetProductsByIds(ctx context.Context, productIds []int64) ([]models.Product, error) {
+	if len(productIds) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(productIds))
+	for _, productId := range productIds {
+		args = append(args, productId)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(productIds)), ", ")
+	return r.queryProducts(ctx, "SELECT id, name, description, price, stock FROM products WHERE id IN ("+placeholders+") ORDER BY id", args...)
+}
+
+func (r *productRepository) queryProducts(ctx context.Context, query string, args ...any) ([]models.Product, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -39,13 +72,13 @@ func (r *productRepository) GetAllProducts() ([]models.Product, error) {
 	return products, nil
 }
 
-func (r *productRepository) GetProductStock(productId int64) (*models.Product, error) {
+func (r *productRepository) GetProductStock(ctx context.Context, productId int64) (*models.Product, error) {
 	var product models.Product
-	row := r.db.QueryRow("SELECT id, name, description, price, stock FROM products WHERE id = ?", productId)
+	row := r.db.QueryRowContext(ctx, "SELECT id, name, description, price, stock FROM products WHERE id = ?", productId)
 	err := row.Scan(&product.Id, &product.Name, &product.Description, &product.Price, &product.Stock)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product with Id %d not found", productId)
+			return nil, &apperror.NotFoundError{Resource: "product", Id: productId}
 		}
 
 		return nil, err
@@ -54,21 +87,27 @@ func (r *productRepository) GetProductStock(productId int64) (*models.Product, e
 	return &product, nil
 }
 
-func (r *productRepository) UpdateStock(productId int64, newStock int) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	_, err = tx.Exec("UPDATE products SET stock = ? WHERE id = ?", newStock, productId)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	if err := tx.Commit(); err != nil {
-		return err
-	}
+func (r *productRepository) UpdateStock(ctx context.Context, productId int64, newStock int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE products SET stock = ? WHERE id = ?", newStock, productId)
+	return err
+}
 
-	return nil
+func (r *productRepository) UpdateStocks(ctx context.Context, totals []models.StockTotal) ([]int64, error) {
+	var missing []int64
+	err := r.db.WithTx(ctx, func(tx *database.Tx) error {
+		missing = nil
+		for _, total := range totals {
+			result, err := tx.ExecContext(ctx, "UPDATE products SET stock = ? WHERE id = ?", total.Stock, total.ProductId)
+			if err != nil {
+				return err
+			}
+			if updated, err := result.RowsAffected(); err != nil {
+				return err
+			} else if updated == 0 {
+				missing = append(missing, total.ProductId)
+			}
+		}
+		return nil
+	})
+	return missing, err
 }
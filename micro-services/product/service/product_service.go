@@ -1,19 +1,32 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"monorepo-ecommerce/micro-services/product/models"
 	"monorepo-ecommerce/micro-services/product/repository"
+	"monorepo-ecommerce/pkg/apperror"
 )
 
 type ProductService interface {
-	GetAllProducts() ([]models.Product, error)
-	GetProductById(productId int64) (*models.Product, error)
-	DeductStock(productId int64, quantity int) error
-	RestoreStock(productId int64, quantity int) error
-	UpdateTotalStock(productId int64, quantity int) error
+	GetAllProducts(ctx context.Context) ([]models.Product, error)
+	// ListProducts pages through the products by id: a page holds up to
+	// limit products with an id above afterId.
+	ListProducts(ctx context.Context, afterId int64, limit int) ([]models.Product, error)
+	GetProductById(ctx context.Context, productId int64) (*models.Product, error)
+	// GetProductsByIds returns the products among productIds that exist, in
+	// id order; at most MaxPageSize ids are looked up at once.
+	GetProductsByIds(ctx context.Context, productIds []int64) ([]models.Product, error)
+	DeductStock(ctx context.Context, productId int64, quantity int) error
+	RestoreStock(ctx context.Context, productId int64, quantity int) error
+	UpdateTotalStock(ctx context.Context, productId int64, quantity int) error
+	// UpdateTotalStocks sets the stock of many products at once.
+	UpdateTotalStocks(ctx context.Context, totals []models.StockTotal) (*models.StockTotalsResult, error)
 }
 
+// MaxPageSize bounds both a page of products and a batch of stock totals.
+const MaxPageSize = 500
+
 type productService struct {
 	repo repository.ProductRepository
 }
@@ -22,32 +35,56 @@ func NewProductService(repo repository.ProductRepository) ProductService {
 	return &productService{repo: repo}
 }
 
-func (s *productService) GetAllProducts() ([]models.Product, error) {
-	return s.repo.GetAllProducts()
+func (s *productService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	return s.repo.GetAllProducts(ctx)
 }
 
-func (s *productService) GetProductById(productId int64) (*models.Product, error) {
-	product, err := s.repo.GetProductStock(productId)
+func (s *productService) ListProducts(ctx context.Context, afterId int64, limit int) ([]models.Product, error) {
+	if limit <= 0 || limit > MaxPageSize {
+		return nil, &apperror.InvalidInputError{Field: "limit", Reason: fmt.Sprintf("must be between 1 and %d", MaxPageSize)}
+	}
+
+	products, err := s.repo.GetProductsAfter(ctx, afterId, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed fetch product: %v", err)
+		return nil, fmt.Errorf("failed fetch products: %w", err)
+	}
+	return products, nil
+}
+
+func (s *productService) GetProductById(ctx context.Context, productId int64) (*models.Product, error) {
+	product, err := s.repo.GetProductStock(ctx, productId)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch product: %w", err)
 	}
 	return product, nil
 }
 
-func (s *productService) DeductStock(productId int64, quantity int) error {
-	product, err := s.repo.GetProductStock(productId)
+func (s *productService) GetProductsByIds(ctx context.Context, productIds []int64) ([]models.Product, error) {
+	if len(productIds) > MaxPageSize {
+		return nil, &apperror.InvalidInputError{Field: "ids", Reason: fmt.Sprintf("must not list more than %d ids", MaxPageSize)}
+	}
+
+	products, err := s.repo.GetProductsByIds(ctx, productIds)
 	if err != nil {
-		return fmt.Errorf("product not found: %v", err)
+		return nil, fmt.Errorf("failed fetch products: %w", err)
+	}
+	return products, nil
+}
+
+func (s *productService) DeductStock(ctx context.Context, productId int64, quantity int) error {
+	product, err := s.repo.GetProductStock(ctx, productId)
+	if err != nil {
+		return fmt.Errorf("failed fetch product: %w", err)
 	}
 
 	// Validate stock
 	if product.Stock < quantity {
-		return fmt.Errorf("stock not enough")
+		return &apperror.InsufficientStockError{ProductId: productId, Requested: quantity, Available: product.Stock}
 	}
 
 	// stock deduction
 	newStock := product.Stock - quantity
-	err = s.repo.UpdateStock(productId, newStock)
+	err = s.repo.UpdateStock(ctx, productId, newStock)
 	if err != nil {
 		return err
 	}
@@ -55,15 +92,15 @@ func (s *productService) DeductStock(productId int64, quantity int) error {
 	return nil
 }
 
-func (s *productService) RestoreStock(productId int64, quantity int) error {
-	product, err := s.repo.GetProductStock(productId)
+func (s *productService) RestoreStock(ctx context.Context, productId int64, quantity int) error {
+	product, err := s.repo.GetProductStock(ctx, productId)
 	if err != nil {
-		return fmt.Errorf("product not found: %v", err)
+		return fmt.Errorf("failed fetch product: %w", err)
 	}
 
 	// stock deduction
 	newStock := product.Stock + quantity
-	err = s.repo.UpdateStock(productId, newStock)
+	err = s.repo.UpdateStock(ctx, productId, newStock)
 	if err != nil {
 		return err
 	}
@@ -71,11 +108,29 @@ func (s *productService) RestoreStock(productId int64, quantity int) error {
 	return nil
 }
 
-func (s *productService) UpdateTotalStock(productId int64, quantity int) error {
-	err := s.repo.UpdateStock(productId, quantity)
+func (s *productService) UpdateTotalStock(ctx context.Context, productId int64, quantity int) error {
+	err := s.repo.UpdateStock(ctx, productId, quantity)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (s *productService) UpdateTotalStocks(ctx context.Context, totals []models.StockTotal) (*models.StockTotalsResult, error) {
+	if len(totals) == 0 || len(totals) > MaxPageSize {
+		return nil, &apperror.InvalidInputError{Field: "totals", Reason: fmt.Sprintf("must hold between 1 and %d totals", MaxPageSize)}
+	}
+	for _, total := range totals {
+		if total.Stock < 0 {
+			return nil, &apperror.InvalidInputError{Field: "stock", Reason: fmt.Sprintf("of product %d must not be negative", total.ProductId)}
+		}
+	}
+
+	missing, err := s.repo.UpdateStocks(ctx, totals)
+	if err != nil {
+		return nil, fmt.Errorf("failed update stock totals: %w", err)
+	}
+
+	return &models.StockTotalsResult{Updated: len(totals) - len(missing), Missing: missing}, nil
+}
@@ -1,9 +1,11 @@
 package test
 
 import (
+	"context"
 	mocks "monorepo-ecommerce/micro-services/product/mocks/mock_micro-services/product/repository"
 	"monorepo-ecommerce/micro-services/product/models"
 	"monorepo-ecommerce/micro-services/product/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,9 +24,9 @@ func TestGetAllProducts(t *testing.T) {
 		{Id: 2, Name: "Product 2", Stock: 20, Price: 200},
 	}
 
-	mockRepo.EXPECT().GetAllProducts().Return(mockProducts, nil)
+	mockRepo.EXPECT().GetAllProducts(gomock.Any()).Return(mockProducts, nil)
 
-	products, err := productService.GetAllProducts()
+	products, err := productService.GetAllProducts(context.Background())
 
 	assert.NoError(t, err)
 	assert.Equal(t, mockProducts, products)
@@ -39,9 +41,9 @@ func TestGetProductById(t *testing.T) {
 
 	mockProduct := &models.Product{Id: 1, Name: "Product 1", Stock: 10, Price: 100}
 
-	mockRepo.EXPECT().GetProductStock(int64(1)).Return(mockProduct, nil)
+	mockRepo.EXPECT().GetProductStock(gomock.Any(), int64(1)).Return(mockProduct, nil)
 
-	product, err := productService.GetProductById(1)
+	product, err := productService.GetProductById(context.Background(), 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, mockProduct, product)
@@ -56,10 +58,10 @@ func TestDeductStock(t *testing.T) {
 	productService := service.NewProductService(mockRepo)
 
 	mockProduct := &models.Product{Id: 1, Name: "Product 1", Stock: 10, Price: 100}
-	mockRepo.EXPECT().GetProductStock(int64(1)).Return(mockProduct, nil)
-	mockRepo.EXPECT().UpdateStock(int64(1), 8).Return(nil)
+	mockRepo.EXPECT().GetProductStock(gomock.Any(), int64(1)).Return(mockProduct, nil)
+	mockRepo.EXPECT().UpdateStock(gomock.Any(), int64(1), 8).Return(nil)
 
-	err := productService.DeductStock(1, 2)
+	err := productService.DeductStock(context.Background(), 1, 2)
 
 	assert.NoError(t, err)
 }
@@ -73,10 +75,10 @@ func TestRestoreStock(t *testing.T) {
 
 	mockProduct := &models.Product{Id: 1, Name: "Product 1", Stock: 10, Price: 100}
 
-	mockRepo.EXPECT().GetProductStock(int64(1)).Return(mockProduct, nil)
-	mockRepo.EXPECT().UpdateStock(int64(1), 12).Return(nil) // Adding 2 to stock
+	mockRepo.EXPECT().GetProductStock(gomock.Any(), int64(1)).Return(mockProduct, nil)
+	mockRepo.EXPECT().UpdateStock(gomock.Any(), int64(1), 12).Return(nil) // Adding 2 to stock
 
-	err := productService.RestoreStock(1, 2)
+	err := productService.RestoreStock(context.Background(), 1, 2)
 
 	assert.NoError(t, err)
 }
@@ -88,9 +90,81 @@ func TestUpdateTotalStock(t *testing.T) {
 
 	productService := service.NewProductService(mockRepo)
 
-	mockRepo.EXPECT().UpdateStock(int64(1), 15).Return(nil) // Directly setting stock to 15
+	mockRepo.EXPECT().UpdateStock(gomock.Any(), int64(1), 15).Return(nil) // Directly setting stock to 15
 
-	err := productService.UpdateTotalStock(1, 15)
+	err := productService.UpdateTotalStock(context.Background(), 1, 15)
 
 	assert.NoError(t, err)
 }
+
+func TestUpdateTotalStocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockRepo := mocks.NewMockProductRepository(ctrl)
+
+	productService := service.NewProductService(mockRepo)
+
+	t.Run("should report the products that do not exist", func(t *testing.T) {
+		totals := []models.StockTotal{{ProductId: 1, Stock: 15}, {ProductId: 9, Stock: 3}}
+		mockRepo.EXPECT().UpdateStocks(gomock.Any(), totals).Return([]int64{9}, nil)
+
+		result, err := productService.UpdateTotalStocks(context.Background(), totals)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &models.StockTotalsResult{Updated: 1, Missing: []int64{9}}, result)
+	})
+
+	t.Run("should refuse a negative total", func(t *testing.T) {
+		_, err := productService.UpdateTotalStocks(context.Background(), []models.StockTotal{{ProductId: 1, Stock: -1}})
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+}
+
+func TestListProducts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockRepo := mocks.NewMockProductRepository(ctrl)
+
+	productService := service.NewProductService(mockRepo)
+
+	t.Run("should fetch the page after the given id", func(t *testing.T) {
+		mockProducts := []models.Product{{Id: 3, Name: "Product 3"}}
+		mockRepo.EXPECT().GetProductsAfter(gomock.Any(), int64(2), 100).Return(mockProducts, nil)
+
+		products, err := productService.ListProducts(context.Background(), 2, 100)
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockProducts, products)
+	})
+
+	t.Run("should refuse a page that is too large", func(t *testing.T) {
+		_, err := productService.ListProducts(context.Background(), 0, service.MaxPageSize+1)
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+}
+
+func TestGetProductsByIds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockRepo := mocks.NewMockProductRepository(ctrl)
+
+	productService := service.NewProductService(mockRepo)
+
+	t.Run("should fetch the products of the given ids", func(t *testing.T) {
+		mockProducts := []models.Product{{Id: 1, Name: "Product 1"}}
+		mockRepo.EXPECT().GetProductsByIds(gomock.Any(), []int64{1, 9}).Return(mockProducts, nil)
+
+		products, err := productService.GetProductsByIds(context.Background(), []int64{1, 9})
+
+		assert.NoError(t, err)
+		assert.Equal(t, mockProducts, products)
+	})
+
+	t.Run("should refuse too many ids at once", func(t *testing.T) {
+		_, err := productService.GetProductsByIds(context.Background(), make([]int64, service.MaxPageSize+1))
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+}
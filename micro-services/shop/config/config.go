@@ -1 +1,51 @@
-package configs
\ No newline at end of file
+package config
+
+import (
+	"errors"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+)
+
+type Config struct {
+	Port                int           `yaml:"port" env:"PORT"`
+	InternalPort        int           `yaml:"internal_port" env:"INTERNAL_PORT"`
+	DatabaseDriver      string        `yaml:"database_driver" env:"DATABASE_DRIVER"`
+	DatabasePath        string        `yaml:"database_path" env:"DATABASE_PATH"`
+	DatabaseURL         string        `yaml:"database_url" env:"DATABASE_URL" secret:"true"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	WarehouseServiceURL string        `yaml:"warehouse_service_url" env:"WAREHOUSE_SERVICE_URL"`
+	UpstreamTimeout     time.Duration `yaml:"upstream_timeout" env:"UPSTREAM_TIMEOUT"`
+}
+
+func Default() Config {
+	return Config{
+		Port:                7004,
+		InternalPort:        8004,
+		DatabaseDriver:      "sqlite",
+		DatabasePath:        "./../../data/shop.db",
+		ShutdownTimeout:     15 * time.Second,
+		WarehouseServiceURL: "http://localhost:7005",
+		UpstreamTimeout:     5 * time.Second,
+	}
+}
+
+// Load returns the defaults overridden by CONFIG_FILE and the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+	if err := configloader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateInternalPort(c.Port, c.InternalPort),
+		database.NewConfig(c.DatabaseDriver, c.DatabasePath, c.DatabaseURL).Validate(),
+		configloader.ValidatePositive("shutdown_timeout", c.ShutdownTimeout),
+		configloader.ValidateURL("warehouse_service_url", c.WarehouseServiceURL),
+		configloader.ValidatePositive("upstream_timeout", c.UpstreamTimeout),
+	)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"monorepo-ecommerce/micro-services/shop/models"
 	"monorepo-ecommerce/micro-services/shop/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -22,9 +23,9 @@ func (h *ShopHandler) ProcessOrder(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	err := h.ShopService.ProcessOrder(order)
+	err := h.ShopService.ProcessOrder(c.Request().Context(), order)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, "Order processed successfully")
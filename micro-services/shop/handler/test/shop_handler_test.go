@@ -38,7 +38,7 @@ func TestProcessOrder(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockShopService.EXPECT().
-			ProcessOrder(reqBody).
+			ProcessOrder(gomock.Any(), reqBody).
 			Return(nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/shop/proceed-order", bytes.NewBuffer(reqJSON))
@@ -84,7 +84,7 @@ func TestProcessOrder(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockShopService.EXPECT().
-			ProcessOrder(reqBody).
+			ProcessOrder(gomock.Any(), reqBody).
 			Return(errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/shop/proceed-order", bytes.NewBuffer(reqJSON))
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shops (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT
+);
+
+INSERT INTO shops (name, description)
+SELECT 'Shop A', 'Description of Shop A'
+WHERE NOT EXISTS (SELECT 1 FROM shops WHERE name = 'Shop A');
@@ -1,7 +1,8 @@
 package models
 
 type OrderRequest struct {
-	Items []OrderItem `json:"items"`
+	Items           []OrderItem      `json:"items"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
 }
 
 type Order struct {
@@ -10,6 +11,14 @@ type Order struct {
 	Items      []OrderItem `json:"items"`
 	TotalPrice float64     `json:"total_price"`
 	Status     string      `json:"status"`
+	// ShippingAddress lets the warehouse ship from the nearest stock.
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+}
+
+type ShippingAddress struct {
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
 }
 
 type OrderItem struct {
@@ -1,26 +1,28 @@
 package repository
 
 import (
-	"database/sql"
+	"context"
 	"monorepo-ecommerce/micro-services/shop/models"
+	"monorepo-ecommerce/pkg/database"
 )
 
 type ShopRepository interface {
-	GetAllShops() ([]models.Shop, error)
-	GetShopById(id int64) (*models.Shop, error)
+	GetAllShops(ctx context.Context) ([]models.Shop, error)
+	GetShopById(ctx context.Context, id int64) (*models.Shop, error)
 }
 
 type shopRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewShopRepository(db *sql.DB) ShopRepository {
+func NewShopRepository(db *database.DB) ShopRepository {
 	return &shopRepository{db: db}
 }
 
 type ProceedOrderRequest struct {
-	OrderID int64                 `json:"order_id"`
-	Items   []ProductOrderDetails `json:"items"`
+	OrderID         int64                   `json:"order_id"`
+	Items           []ProductOrderDetails   `json:"items"`
+	ShippingAddress *models.ShippingAddress `json:"shipping_address,omitempty"`
 }
 
 type ProductOrderDetails struct {
@@ -28,8 +30,8 @@ type ProductOrderDetails struct {
 	Quantity  int   `json:"quantity"`
 }
 
-func (r *shopRepository) GetAllShops() ([]models.Shop, error) {
-	rows, err := r.db.Query("SELECT id, name, description FROM shops")
+func (r *shopRepository) GetAllShops(ctx context.Context) ([]models.Shop, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, description FROM shops")
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +49,8 @@ func (r *shopRepository) GetAllShops() ([]models.Shop, error) {
 	return shops, nil
 }
 
-func (r *shopRepository) GetShopById(id int64) (*models.Shop, error) {
-	row := r.db.QueryRow("SELECT id, name, description FROM shops WHERE id = ?", id)
+func (r *shopRepository) GetShopById(ctx context.Context, id int64) (*models.Shop, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, name, description FROM shops WHERE id = ?", id)
 	var shop models.Shop
 	if err := row.Scan(&shop.Id, &shop.Name, &shop.Description); err != nil {
 		return nil, err
@@ -1,30 +1,28 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"monorepo-ecommerce/micro-services/shop/models"
-
-	"github.com/parnurzeal/gorequest"
+	"monorepo-ecommerce/pkg/httpclient"
 )
 
 type WarehouseRepository interface {
-	ForwardOrderToWarehouse(order models.Order) error
+	ForwardOrderToWarehouse(ctx context.Context, order models.Order) error
 }
 
 type warehouseRepository struct {
-	baseURL string
+	client *httpclient.Client
 }
 
-func NewWarehouseRepository(baseURL string) WarehouseRepository {
-	return &warehouseRepository{baseURL: baseURL}
+func NewWarehouseRepository(client *httpclient.Client) WarehouseRepository {
+	return &warehouseRepository{client: client}
 }
 
-func (r *warehouseRepository) ForwardOrderToWarehouse(order models.Order) error {
-	url := fmt.Sprintf("%s/warehouse/stock/proceed-order", r.baseURL)
-
+func (r *warehouseRepository) ForwardOrderToWarehouse(ctx context.Context, order models.Order) error {
 	requestBody := ProceedOrderRequest{
-		OrderID: order.Id,
-		Items:   make([]ProductOrderDetails, len(order.Items)),
+		OrderID:         order.Id,
+		Items:           make([]ProductOrderDetails, len(order.Items)),
+		ShippingAddress: order.ShippingAddress,
 	}
 	for i, item := range order.Items {
 		requestBody.Items[i] = ProductOrderDetails{
@@ -33,18 +31,5 @@ func (r *warehouseRepository) ForwardOrderToWarehouse(order models.Order) error
 		}
 	}
 
-	request := gorequest.New()
-	resp, body, errs := request.Post(url).
-		SendStruct(requestBody).
-		End()
-
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to call warehouse service: %v", errs[0])
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("warehouse service returned error: %s", body)
-	}
-
-	return nil
+	return r.client.Post(ctx, "/warehouse/stock/proceed-order", requestBody, nil)
 }
@@ -1,13 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"monorepo-ecommerce/micro-services/shop/models"
 	"monorepo-ecommerce/micro-services/shop/repository"
 )
 
 type ShopService interface {
-	ProcessOrder(order models.Order) error
+	ProcessOrder(ctx context.Context, order models.Order) error
 }
 
 type shopService struct {
@@ -22,11 +23,11 @@ func NewShopService(shopRepo repository.ShopRepository, warehouseRepo repository
 	}
 }
 
-func (s *shopService) ProcessOrder(order models.Order) error {
+func (s *shopService) ProcessOrder(ctx context.Context, order models.Order) error {
 	// Forward request to warehouse
-	err := s.WarehouseRepo.ForwardOrderToWarehouse(order)
+	err := s.WarehouseRepo.ForwardOrderToWarehouse(ctx, order)
 	if err != nil {
-		return fmt.Errorf("failed to forward order to warehouse: %v", err)
+		return fmt.Errorf("failed to forward order to warehouse: %w", err)
 	}
 
 	return nil
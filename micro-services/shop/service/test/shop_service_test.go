@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	mocks "monorepo-ecommerce/micro-services/shop/mocks/mock_micro-services/shop/repository"
 	"monorepo-ecommerce/micro-services/shop/models"
@@ -34,17 +35,17 @@ func TestProcessOrder(t *testing.T) {
 	}
 
 	t.Run("should success", func(t *testing.T) {
-		mockWarehouseRepo.EXPECT().ForwardOrderToWarehouse(order).Return(nil)
+		mockWarehouseRepo.EXPECT().ForwardOrderToWarehouse(gomock.Any(), order).Return(nil)
 
-		err := shopService.ProcessOrder(order)
+		err := shopService.ProcessOrder(context.Background(), order)
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("should failed forwarding", func(t *testing.T) {
-		mockWarehouseRepo.EXPECT().ForwardOrderToWarehouse(order).Return(fmt.Errorf("warehouse error"))
+		mockWarehouseRepo.EXPECT().ForwardOrderToWarehouse(gomock.Any(), order).Return(fmt.Errorf("warehouse error"))
 
-		err := shopService.ProcessOrder(order)
+		err := shopService.ProcessOrder(context.Background(), order)
 
 		assert.Error(t, err)
 		assert.EqualError(t, err, "failed to forward order to warehouse: warehouse error")
@@ -1 +1,61 @@
 package config
+
+import (
+	"errors"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+)
+
+type Config struct {
+	Port            int           `yaml:"port" env:"PORT"`
+	InternalPort    int           `yaml:"internal_port" env:"INTERNAL_PORT"`
+	DatabaseDriver  string        `yaml:"database_driver" env:"DATABASE_DRIVER"`
+	DatabasePath    string        `yaml:"database_path" env:"DATABASE_PATH"`
+	DatabaseURL     string        `yaml:"database_url" env:"DATABASE_URL" secret:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// JWTKeyDir holds the PEM private keys access tokens are signed with.
+	JWTKeyDir       string        `yaml:"jwt_key_dir" env:"JWT_KEY_DIR"`
+	JWTIssuer       string        `yaml:"jwt_issuer" env:"JWT_ISSUER"`
+	JWTAudience     string        `yaml:"jwt_audience" env:"JWT_AUDIENCE"`
+	TokenTTL        time.Duration `yaml:"token_ttl" env:"TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+}
+
+func Default() Config {
+	return Config{
+		Port:            7001,
+		InternalPort:    8001,
+		DatabaseDriver:  "sqlite",
+		DatabasePath:    "./../../data/user.db",
+		ShutdownTimeout: 15 * time.Second,
+		JWTKeyDir:       "./../../data/jwt-keys",
+		JWTIssuer:       "user-service",
+		JWTAudience:     "monorepo-ecommerce",
+		TokenTTL:        15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+	}
+}
+
+// Load returns the defaults overridden by CONFIG_FILE and the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+	if err := configloader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateInternalPort(c.Port, c.InternalPort),
+		database.NewConfig(c.DatabaseDriver, c.DatabasePath, c.DatabaseURL).Validate(),
+		configloader.ValidatePositive("shutdown_timeout", c.ShutdownTimeout),
+		configloader.ValidateRequired("jwt_key_dir", c.JWTKeyDir),
+		configloader.ValidateRequired("jwt_issuer", c.JWTIssuer),
+		configloader.ValidateRequired("jwt_audience", c.JWTAudience),
+		configloader.ValidatePositive("token_ttl", c.TokenTTL),
+		configloader.ValidatePositive("refresh_token_ttl", c.RefreshTokenTTL),
+	)
+}
@@ -7,9 +7,12 @@ import (
 	"monorepo-ecommerce/micro-services/user/handler"
 	mocks "monorepo-ecommerce/micro-services/user/mocks/mock_micro-services/user/service"
 	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/pkg/apperror"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +23,8 @@ func TestRegisterUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockUserService := mocks.NewMockUserService(ctrl)
-	h := handler.NewUserHandler(mockUserService)
+	mockTokenService := mocks.NewMockTokenService(ctrl)
+	h := handler.NewUserHandler(mockUserService, mockTokenService)
 	e := echo.New()
 
 	t.Run("should success", func(t *testing.T) {
@@ -38,7 +42,7 @@ func TestRegisterUser(t *testing.T) {
 		}
 
 		mockUserService.EXPECT().
-			RegisterUser(reqBody.Email, reqBody.Phone, reqBody.Password).
+			RegisterUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
 			Return(&mockUser, nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/user/register", bytes.NewBuffer(reqJSON))
@@ -78,7 +82,7 @@ func TestRegisterUser(t *testing.T) {
 		reqJSON, _ := json.Marshal(reqBody)
 
 		mockUserService.EXPECT().
-			RegisterUser(reqBody.Email, reqBody.Phone, reqBody.Password).
+			RegisterUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
 			Return(nil, errors.New("internal server error"))
 
 		req := httptest.NewRequest(http.MethodPost, "/user/register", bytes.NewBuffer(reqJSON))
@@ -91,13 +95,36 @@ func TestRegisterUser(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
+	t.Run("should conflict when email or phone already registered", func(t *testing.T) {
+		reqBody := handler.UserRequest{
+			Email:    "test@example.com",
+			Phone:    "1234567890",
+			Password: "password123",
+		}
+		reqJSON, _ := json.Marshal(reqBody)
+
+		mockUserService.EXPECT().
+			RegisterUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
+			Return(nil, &apperror.ConflictError{Resource: "user", Reason: "email or phone already registered"})
+
+		req := httptest.NewRequest(http.MethodPost, "/user/register", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.RegisterUser(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
 }
 
 func TestLoginUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
 	mockUserService := mocks.NewMockUserService(ctrl)
-	h := handler.NewUserHandler(mockUserService)
+	mockTokenService := mocks.NewMockTokenService(ctrl)
+	h := handler.NewUserHandler(mockUserService, mockTokenService)
 	e := echo.New()
 
 	t.Run("should success", func(t *testing.T) {
@@ -115,8 +142,11 @@ func TestLoginUser(t *testing.T) {
 		}
 
 		mockUserService.EXPECT().
-			LoginUser(reqBody.Email, reqBody.Phone, reqBody.Password).
+			LoginUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
 			Return(&mockUser, nil)
+		mockTokenService.EXPECT().
+			IssueTokens(gomock.Any(), &mockUser).
+			Return(&models.TokenPair{Token: "access", AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}, nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewBuffer(reqJSON))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -127,6 +157,7 @@ func TestLoginUser(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"refresh_token":"refresh"`)
 	})
 
 	t.Run("should bad request when request invalid", func(t *testing.T) {
@@ -157,8 +188,8 @@ func TestLoginUser(t *testing.T) {
 		mockUser := models.User{}
 
 		mockUserService.EXPECT().
-			LoginUser(reqBody.Email, reqBody.Phone, reqBody.Password).
-			Return(&mockUser, errors.New("user not found"))
+			LoginUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
+			Return(&mockUser, &apperror.UnauthorizedError{Reason: "user not found"})
 
 		req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewBuffer(reqJSON))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -182,7 +213,7 @@ func TestLoginUser(t *testing.T) {
 		mockUser := models.User{}
 
 		mockUserService.EXPECT().
-			LoginUser(reqBody.Email, reqBody.Phone, reqBody.Password).
+			LoginUser(gomock.Any(), reqBody.Email, reqBody.Phone, reqBody.Password).
 			Return(&mockUser, errors.New("failed"))
 
 		req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewBuffer(reqJSON))
@@ -196,3 +227,91 @@ func TestLoginUser(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 }
+
+func TestRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockUserService := mocks.NewMockUserService(ctrl)
+	mockTokenService := mocks.NewMockTokenService(ctrl)
+	h := handler.NewUserHandler(mockUserService, mockTokenService)
+	e := echo.New()
+
+	t.Run("should return a new token pair", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/user/refresh", strings.NewReader(`{"refresh_token":"refresh"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockTokenService.EXPECT().
+			RefreshTokens(gomock.Any(), "refresh").
+			Return(&models.TokenPair{Token: "access-2", AccessToken: "access-2", RefreshToken: "refresh-2", TokenType: "Bearer"}, nil)
+
+		err := h.RefreshToken(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"refresh_token":"refresh-2"`)
+	})
+
+	t.Run("should unauthorized when the refresh token was reused", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/user/refresh", strings.NewReader(`{"refresh_token":"refresh"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockTokenService.EXPECT().
+			RefreshTokens(gomock.Any(), "refresh").
+			Return(nil, &apperror.UnauthorizedError{Reason: "refresh token reused, please login again"})
+
+		err := h.RefreshToken(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockUserService := mocks.NewMockUserService(ctrl)
+	mockTokenService := mocks.NewMockTokenService(ctrl)
+	h := handler.NewUserHandler(mockUserService, mockTokenService)
+	e := echo.New()
+
+	t.Run("should revoke the bearer and refresh token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/user/logout", strings.NewReader(`{"refresh_token":"refresh"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		expiresAt := time.Now().Add(15 * time.Minute)
+		c.Set("user_id", int64(1))
+		c.Set("jti", "token-id")
+		c.Set("exp", expiresAt)
+
+		mockTokenService.EXPECT().
+			Logout(gomock.Any(), int64(1), models.RevokedToken{TokenId: "token-id", ExpiresAt: expiresAt}, "refresh").
+			Return(nil)
+
+		err := h.Logout(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("should internal server error when revoking fails", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/user/logout", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("user_id", int64(1))
+
+		mockTokenService.EXPECT().
+			Logout(gomock.Any(), int64(1), models.RevokedToken{}, "").
+			Return(errors.New("database is locked"))
+
+		err := h.Logout(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"monorepo-ecommerce/micro-services/user/models"
 	"monorepo-ecommerce/micro-services/user/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/jwtauth"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -13,6 +17,10 @@ type UserRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type UserResponse struct {
 	ID    int    `json:"id"`
 	Email string `json:"email"`
@@ -20,11 +28,12 @@ type UserResponse struct {
 }
 
 type UserHandler struct {
-	UserService service.UserService
+	UserService  service.UserService
+	TokenService service.TokenService
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
-	return &UserHandler{UserService: userService}
+func NewUserHandler(userService service.UserService, tokenService service.TokenService) *UserHandler {
+	return &UserHandler{UserService: userService, TokenService: tokenService}
 }
 
 func (h *UserHandler) RegisterUser(c echo.Context) error {
@@ -33,9 +42,9 @@ func (h *UserHandler) RegisterUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	user, err := h.UserService.RegisterUser(req.Email, req.Phone, req.Password)
+	user, err := h.UserService.RegisterUser(c.Request().Context(), req.Email, req.Phone, req.Password)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusCreated, user)
@@ -47,24 +56,88 @@ func (h *UserHandler) LoginUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	user, err := h.UserService.LoginUser(req.Email, req.Phone, req.Password)
+	user, err := h.UserService.LoginUser(c.Request().Context(), req.Email, req.Phone, req.Password)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
-		}
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusBadRequest, err)
 	}
 
-	token, err := service.GenerateToken(user.Id, user.Email, user.Phone)
+	tokens, err := h.TokenService.IssueTokens(c.Request().Context(), user)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to generate token"})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"token": token})
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *UserHandler) RefreshToken(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	tokens, err := h.TokenService.RefreshTokens(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
 }
 
-func RegisterUserRoutes(e *echo.Echo, userService service.UserService) {
-	handler := NewUserHandler(userService)
+// Logout revokes the bearer token and, when the body names it, the refresh
+// token of the session.
+func (h *UserHandler) Logout(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	userId, _ := c.Get("user_id").(int64)
+	accessToken := models.RevokedToken{}
+	accessToken.TokenId, _ = c.Get("jti").(string)
+	accessToken.ExpiresAt, _ = c.Get("exp").(time.Time)
+
+	err := h.TokenService.Logout(c.Request().Context(), userId, accessToken, req.RefreshToken)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetRevokedTokens serves the other services, which refuse access tokens
+// revoked before they expire.
+func (h *UserHandler) GetRevokedTokens(c echo.Context) error {
+	tokens, err := h.TokenService.GetRevokedTokens(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func RegisterUserRoutes(e *echo.Echo, userService service.UserService, tokenService service.TokenService, jwt *service.JWT) {
+	handler := NewUserHandler(userService, tokenService)
 	e.POST("/user/register", handler.RegisterUser)
 	e.POST("/user/login", handler.LoginUser)
+	e.POST("/user/refresh", handler.RefreshToken)
+	e.POST("/user/logout", handler.Logout, jwt.Middleware)
+	e.GET(jwtauth.JWKSPath, GetJWKS(jwt))
+}
+
+// RegisterInternalUserRoutes registers the routes the other services call on
+// the internal listener. The key set is public, but is served here as well so
+// they need only the one user service URL.
+func RegisterInternalUserRoutes(e *echo.Echo, userService service.UserService, tokenService service.TokenService, jwt *service.JWT) {
+	handler := NewUserHandler(userService, tokenService)
+	e.GET("/user/revoked-tokens", handler.GetRevokedTokens)
+	e.GET(jwtauth.JWKSPath, GetJWKS(jwt))
+}
+
+// GetJWKS publishes the public signing keys. Verifiers cache them, and fetch
+// them again when a token names a key they have not seen.
+func GetJWKS(jwt *service.JWT) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(http.StatusOK, jwt.JWKS())
+	}
 }
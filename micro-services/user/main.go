@@ -1,33 +1,103 @@
 package main
 
 import (
-	"monorepo-ecommerce/micro-services/user/db"
+	"context"
+	"fmt"
+	"log"
+	"monorepo-ecommerce/micro-services/user/config"
 	"monorepo-ecommerce/micro-services/user/handler"
+	"monorepo-ecommerce/micro-services/user/migrations"
 	"monorepo-ecommerce/micro-services/user/repository"
 	"monorepo-ecommerce/micro-services/user/service"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/lifecycle"
+	"monorepo-ecommerce/pkg/migration"
+	"monorepo-ecommerce/pkg/requestid"
+	"os"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
 func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.Printf("Effective configuration: %s", configloader.Redact(cfg))
+
+	runner := lifecycle.New(cfg.ShutdownTimeout)
+
 	// Initate Database
-	dbConn := db.InitDatabase("./../../data/ecommerce.db")
-	db.RunMigrations(dbConn, "./migrations/init.sql")
-	defer dbConn.Close()
+	dbConn, err := database.Open(database.NewConfig(cfg.DatabaseDriver, cfg.DatabasePath, cfg.DatabaseURL))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Apply schema migrations, or only run the migrate subcommand
+	migrator := migration.New(dbConn.Writer(), "user", migrations.For(dbConn.Dialect()))
+	migrator.Dialect = dbConn.Dialect()
+	if migration.IsCommand(os.Args) {
+		err := migration.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout)
+		dbConn.Close()
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	// Load the signing keys, or only add a new one with the rotate-key subcommand
+	if isRotateKeyCommand(os.Args) {
+		path, err := service.GenerateSigningKey(cfg.JWTKeyDir)
+		dbConn.Close()
+		if err != nil {
+			log.Fatalf("Failed to generate signing key: %v", err)
+		}
+		fmt.Fprintf(os.Stdout, "Wrote %s, it signs from the next start on\n", path)
+		return
+	}
+	signingKeys, err := service.LoadSigningKeys(cfg.JWTKeyDir)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
 
 	// Initiate Echo
 	e := echo.New()
 
 	// Use middleware
+	e.Use(requestid.Middleware())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
+	// Endpoints for operators and the other services listen on the internal
+	// port, which is not published
+	internal := echo.New()
+	internal.HideBanner = true
+	internal.Use(requestid.Middleware())
+	internal.Use(middleware.Logger())
+	internal.Use(middleware.Recover())
+	internal.GET("/internal/db/stats", database.StatsHandler(dbConn))
+
 	// Initialize repository, service, handler
 	userRepo := repository.NewUserRepository(dbConn)
 	userService := service.NewUserService(userRepo)
-	handler.RegisterUserRoutes(e, userService)
+	jwt := service.NewJWT(signingKeys, cfg.JWTIssuer, cfg.JWTAudience, cfg.TokenTTL)
+	tokenService := service.NewTokenService(userRepo, repository.NewTokenRepository(dbConn), jwt, cfg.RefreshTokenTTL)
+	handler.RegisterUserRoutes(e, userService, tokenService, jwt)
+	handler.RegisterInternalUserRoutes(internal, userService, tokenService, jwt)
 
-	// Start server
-	e.Logger.Fatal(e.Start(":7001"))
+	// Run until SIGINT/SIGTERM, then drain and close the database last
+	runner.OnStop("database", func(ctx context.Context) error {
+		return dbConn.Close()
+	})
+	runner.HTTPServer(e, fmt.Sprintf(":%d", cfg.Port))
+	runner.HTTPServer(internal, fmt.Sprintf(":%d", cfg.InternalPort))
+	if err := runner.Run(context.Background()); err != nil {
+		log.Fatalf("Service stopped with error: %v", err)
+	}
 }
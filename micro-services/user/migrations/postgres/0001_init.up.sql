@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT UNIQUE,
+    phone TEXT UNIQUE,
+    password TEXT
+);
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only the hash of the token is
+// kept. Tokens issued by rotating one another share a FamilyId, and each
+// remembers the access token issued with it.
+type RefreshToken struct {
+	Id              int64
+	UserId          int64
+	FamilyId        string
+	TokenHash       string
+	AccessTokenId   string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+	// UsedAt is set once the token was exchanged; using it again is reuse.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RevokedToken is an access token revoked before it expires.
+type RevokedToken struct {
+	TokenId   string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TokenPair is what a login or refresh hands out. Token repeats AccessToken
+// for clients written before refresh tokens.
+type TokenPair struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
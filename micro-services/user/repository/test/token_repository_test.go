@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/user/migrations"
+	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/micro-services/user/repository"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenRepository_RevokeFamily(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t, "user", migrations.For)
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+
+	user, err := userRepo.CreateUser(ctx, models.User{Email: "a@example.com", Phone: "0811", Password: "hashed"})
+	assert.NoError(t, err)
+
+	now := time.Now().UTC()
+	tokens := []models.RefreshToken{
+		{UserId: user.Id, FamilyId: "family", TokenHash: "hash-1", AccessTokenId: "expired", AccessExpiresAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{UserId: user.Id, FamilyId: "family", TokenHash: "hash-2", AccessTokenId: "current", AccessExpiresAt: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour)},
+		{UserId: user.Id, FamilyId: "other", TokenHash: "hash-3", AccessTokenId: "other", AccessExpiresAt: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour)},
+	}
+	for i := range tokens {
+		assert.NoError(t, tokenRepo.CreateRefreshToken(ctx, &tokens[i]))
+	}
+
+	t.Run("should revoke the refresh tokens and unexpired access tokens of the family", func(t *testing.T) {
+		err := tokenRepo.RevokeFamily(ctx, "family")
+		assert.NoError(t, err)
+
+		for _, hash := range []string{"hash-1", "hash-2"} {
+			stored, err := tokenRepo.GetRefreshTokenByHash(ctx, hash)
+			assert.NoError(t, err)
+			assert.NotNil(t, stored.RevokedAt)
+		}
+		other, err := tokenRepo.GetRefreshTokenByHash(ctx, "hash-3")
+		assert.NoError(t, err)
+		assert.Nil(t, other.RevokedAt)
+
+		revoked, err := tokenRepo.GetRevokedTokens(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, revoked, 1)
+		assert.Equal(t, "current", revoked[0].TokenId)
+	})
+
+	t.Run("should leave the revocation list alone when revoked again", func(t *testing.T) {
+		err := tokenRepo.RevokeFamily(ctx, "family")
+		assert.NoError(t, err)
+
+		revoked, err := tokenRepo.GetRevokedTokens(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, revoked, 1)
+	})
+}
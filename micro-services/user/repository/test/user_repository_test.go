@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/user/migrations"
+	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/micro-services/user/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserRepository_CreateUser(t *testing.T) {
+	ctx := context.Background()
+	userRepo := repository.NewUserRepository(dbtest.Open(t, "user", migrations.For))
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	t.Run("should return the generated id", func(t *testing.T) {
+		first, err := userRepo.CreateUser(ctx, models.User{Email: "a@example.com", Phone: "0811", Password: string(hashed)})
+		assert.NoError(t, err)
+		second, err := userRepo.CreateUser(ctx, models.User{Email: "b@example.com", Phone: "0812", Password: string(hashed)})
+		assert.NoError(t, err)
+
+		assert.Equal(t, first.Id+1, second.Id)
+
+		found, err := userRepo.GetUserByEmailOrPhone(ctx, "b@example.com", "", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, second.Id, found.Id)
+	})
+
+	t.Run("should report a duplicate email as conflict", func(t *testing.T) {
+		_, err := userRepo.CreateUser(ctx, models.User{Email: "a@example.com", Phone: "0899", Password: string(hashed)})
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+)
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// GetRefreshTokenByHash returns nil when no token has the hash.
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed reports false when the token was already used or
+	// revoked, so of two concurrent exchanges only one succeeds.
+	MarkRefreshTokenUsed(ctx context.Context, tokenId int64) (bool, error)
+	// RevokeFamily revokes every token of a family and, in the same
+	// transaction, the access tokens issued with them that have not expired
+	// yet.
+	RevokeFamily(ctx context.Context, familyId string) error
+	// RevokeAccessTokens adds access tokens to the revocation list and drops
+	// those that expired in the meantime.
+	RevokeAccessTokens(ctx context.Context, tokens ...models.RevokedToken) error
+	// GetRevokedTokens lists the revoked access tokens that have not expired.
+	GetRevokedTokens(ctx context.Context) ([]models.RevokedToken, error)
+}
+
+type tokenRepository struct {
+	db *database.DB
+}
+
+func NewTokenRepository(db *database.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	token.CreatedAt = time.Now().UTC()
+
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_token_id, access_expires_at, expires_at, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := r.db.InsertReturningID(ctx, query, token.UserId, token.FamilyId, token.TokenHash, token.AccessTokenId,
+		token.AccessExpiresAt, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	token.Id = id
+	return nil
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT id, user_id, family_id, token_hash, access_token_id, access_expires_at, expires_at, created_at, used_at, revoked_at
+              FROM refresh_tokens WHERE token_hash = ?`
+	var token models.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&token.Id, &token.UserId, &token.FamilyId, &token.TokenHash, &token.AccessTokenId,
+		&token.AccessExpiresAt, &token.ExpiresAt, &token.CreatedAt, &token.UsedAt, &token.RevokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *tokenRepository) MarkRefreshTokenUsed(ctx context.Context, tokenId int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL",
+		time.Now().UTC(), tokenId)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *tokenRepository) RevokeFamily(ctx context.Context, familyId string) error {
+	return r.db.WithTx(ctx, func(tx *database.Tx) error {
+		now := time.Now().UTC()
+		_, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, familyId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT access_token_id, access_expires_at FROM refresh_tokens WHERE family_id = ? AND access_expires_at > ?", familyId, now)
+		if err != nil {
+			return err
+		}
+		accessTokens, err := scanRevokedTokens(rows)
+		if err != nil {
+			return err
+		}
+
+		return revokeAccessTokens(ctx, tx, now, accessTokens)
+	})
+}
+
+func (r *tokenRepository) RevokeAccessTokens(ctx context.Context, tokens ...models.RevokedToken) error {
+	return r.db.WithTx(ctx, func(tx *database.Tx) error {
+		return revokeAccessTokens(ctx, tx, time.Now().UTC(), tokens)
+	})
+}
+
+func (r *tokenRepository) GetRevokedTokens(ctx context.Context) ([]models.RevokedToken, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT token_id, expires_at FROM revoked_tokens WHERE expires_at > ? ORDER BY expires_at", time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanRevokedTokens(rows)
+}
+
+func scanRevokedTokens(rows *sql.Rows) ([]models.RevokedToken, error) {
+	defer rows.Close()
+
+	tokens := []models.RevokedToken{}
+	for rows.Next() {
+		var token models.RevokedToken
+		if err := rows.Scan(&token.TokenId, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func revokeAccessTokens(ctx context.Context, q database.Querier, now time.Time, tokens []models.RevokedToken) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO revoked_tokens (token_id, expires_at, revoked_at) VALUES (?, ?, ?)
+              ON CONFLICT (token_id) DO NOTHING`
+	for _, token := range tokens {
+		if _, err := q.ExecContext(ctx, query, token.TokenId, token.ExpiresAt.UTC(), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,35 +1,40 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRepository interface {
-	CreateUser(user models.User) (*models.User, error)
-	GetUserByEmailOrPhone(email string, phone string, password string) (*models.User, error)
+	CreateUser(ctx context.Context, user models.User) (*models.User, error)
+	GetUserByEmailOrPhone(ctx context.Context, email string, phone string, password string) (*models.User, error)
+	GetUserById(ctx context.Context, userId int64) (*models.User, error)
 }
 
 type userRepository struct {
-	db *sql.DB
+	db *database.DB
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
+func NewUserRepository(db *database.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(user models.User) (res *models.User, err error) {
+func (r *userRepository) CreateUser(ctx context.Context, user models.User) (res *models.User, err error) {
 	query := `INSERT INTO users (email, phone, password) VALUES (?, ?, ?)`
-	data, err := r.db.Exec(query, user.Email, user.Phone, user.Password)
+	id, err := r.db.InsertReturningID(ctx, query, user.Email, user.Phone, user.Password)
 	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return res, &apperror.ConflictError{Resource: "user", Reason: "email or phone already registered"}
+		}
 		return res, err
 	}
 
-	id, _ := data.LastInsertId()
 	res = &models.User{
 		Id:    id,
 		Email: user.Email,
@@ -39,9 +44,9 @@ func (r *userRepository) CreateUser(user models.User) (res *models.User, err err
 	return res, nil
 }
 
-func (r *userRepository) GetUserByEmailOrPhone(email string, phone string, password string) (user *models.User, err error) {
+func (r *userRepository) GetUserByEmailOrPhone(ctx context.Context, email string, phone string, password string) (user *models.User, err error) {
 	query := `SELECT id, email, phone, password FROM users WHERE email = ? OR phone = ?`
-	row := r.db.QueryRow(query, email, phone)
+	row := r.db.QueryRowContext(ctx, query, email, phone)
 
 	var data models.User
 	if err := row.Scan(&data.Id, &data.Email, &data.Phone, &data.Password); err != nil {
@@ -53,7 +58,7 @@ func (r *userRepository) GetUserByEmailOrPhone(email string, phone string, passw
 
 	err = bcrypt.CompareHashAndPassword([]byte(data.Password), []byte(password))
 	if err != nil {
-		return nil, fmt.Errorf("invalid password")
+		return nil, &apperror.UnauthorizedError{Reason: "invalid password"}
 	}
 
 	user = &data
@@ -61,3 +66,18 @@ func (r *userRepository) GetUserByEmailOrPhone(email string, phone string, passw
 
 	return user, nil
 }
+
+func (r *userRepository) GetUserById(ctx context.Context, userId int64) (*models.User, error) {
+	query := `SELECT id, email, phone FROM users WHERE id = ?`
+	row := r.db.QueryRowContext(ctx, query, userId)
+
+	var user models.User
+	if err := row.Scan(&user.Id, &user.Email, &user.Phone); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &apperror.NotFoundError{Resource: "user", Id: userId}
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,7 @@
+package main
+
+// isRotateKeyCommand reports whether the binary was started as
+// `user rotate-key`, which adds a new signing key to jwt_key_dir.
+func isRotateKeyCommand(args []string) bool {
+	return len(args) > 1 && args[1] == "rotate-key"
+}
@@ -1,30 +1,87 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"monorepo-ecommerce/pkg/jwtauth"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("secret-key")
+// JWT signs access tokens with the current signing key, naming it by kid,
+// for the other services to verify against the published key set.
+type JWT struct {
+	keys     *SigningKeys
+	issuer   string
+	audience string
+	ttl      time.Duration
+	verifier *jwtauth.Verifier
+}
+
+func NewJWT(keys *SigningKeys, issuer string, audience string, ttl time.Duration) *JWT {
+	return &JWT{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		ttl:      ttl,
+		verifier: jwtauth.NewVerifier(keys, issuer, audience),
+	}
+}
 
-func GenerateToken(userId int64, email string, phone string) (string, error) {
+// AccessToken is a signed access token with the id (jti) and expiry it
+// carries, so it can be revoked.
+type AccessToken struct {
+	Token     string
+	Id        string
+	ExpiresAt time.Time
+}
+
+func (j *JWT) GenerateToken(userId int64, email string, phone string) (*AccessToken, error) {
+	now := time.Now()
+	accessToken := &AccessToken{Id: randomToken(16), ExpiresAt: now.Add(j.ttl)}
 	claims := jwt.MapClaims{
 		"user_id": userId,
 		"email":   email,
 		"phone":   phone,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"iss":     j.issuer,
+		"aud":     j.audience,
+		"jti":     accessToken.Id,
+		"iat":     now.Unix(),
+		"exp":     accessToken.ExpiresAt.Unix(),
+	}
+
+	key := j.keys.current()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.jwk.KeyId
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	accessToken.Token = signed
+	return accessToken, nil
+}
+
+func (j *JWT) ValidateToken(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	return j.verifier.Verify(ctx, tokenString)
+}
+
+// JWKS is the key set published for the other services.
+func (j *JWT) JWKS() jwtauth.JWKS {
+	return j.keys.JWKS()
+}
+
+// TTL is the lifetime of the access tokens it generates.
+func (j *JWT) TTL() time.Duration {
+	return j.ttl
 }
 
-func ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return jwtSecret, nil
-	})
+// randomToken returns size random bytes, URL-safe base64 encoded.
+func randomToken(size int) string {
+	b := make([]byte, size)
+	// crypto/rand never fails on supported platforms
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
 }
@@ -1,33 +1,12 @@
 package service
 
 import (
-	"net/http"
-	"strings"
+	"monorepo-ecommerce/pkg/jwtauth"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func JwtMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing token"})
-		}
-
-		// retrieve token from header
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := ValidateToken(tokenString)
-		if err != nil {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid token"})
-		}
-
-		// save claims to context
-		claims := token.Claims.(jwt.MapClaims)
-		c.Set("user_id", claims["user_id"])
-		c.Set("email", claims["email"])
-		c.Set("phone", claims["phone"])
-
-		return next(c)
-	}
+// Middleware verifies the bearer token the way every other service does.
+func (j *JWT) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return jwtauth.Middleware(j.verifier, nil)(next)
 }
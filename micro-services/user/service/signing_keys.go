@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/pkg/jwtauth"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKeys are the private keys access tokens are signed with. The last
+// one signs, the others are only published so tokens they signed stay valid
+// until they expire. That is how a key is rotated: add a newer key, and
+// remove the old one once token_ttl has passed.
+type SigningKeys struct {
+	keys []signingKey
+}
+
+type signingKey struct {
+	method  jwt.SigningMethod
+	private crypto.Signer
+	jwk     jwtauth.JWK
+}
+
+// NewSigningKeys takes Ed25519 or RSA private keys, oldest first.
+func NewSigningKeys(privateKeys ...crypto.Signer) (*SigningKeys, error) {
+	if len(privateKeys) == 0 {
+		return nil, errors.New("no signing key")
+	}
+
+	keys := make([]signingKey, 0, len(privateKeys))
+	for _, private := range privateKeys {
+		var method jwt.SigningMethod
+		switch key := private.(type) {
+		case ed25519.PrivateKey:
+			method = jwt.SigningMethodEdDSA
+		case *rsa.PrivateKey:
+			if key.N.BitLen() < 2048 {
+				return nil, errors.New("RSA signing keys must have at least 2048 bits")
+			}
+			method = jwt.SigningMethodRS256
+		default:
+			return nil, fmt.Errorf("unsupported signing key type %T", private)
+		}
+
+		jwk, err := jwtauth.NewJWK(private.Public())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, signingKey{method: method, private: private, jwk: jwk})
+	}
+
+	return &SigningKeys{keys: keys}, nil
+}
+
+// LoadSigningKeys reads the PEM private keys in dir, ordered by file name.
+// A directory without keys gets a new Ed25519 key first.
+func LoadSigningKeys(dir string) (*SigningKeys, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		path, err := GenerateSigningKey(dir)
+		if err != nil {
+			return nil, err
+		}
+		paths = []string{path}
+	}
+	sort.Strings(paths)
+
+	privateKeys := make([]crypto.Signer, 0, len(paths))
+	for _, path := range paths {
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		privateKeys = append(privateKeys, key)
+	}
+
+	return NewSigningKeys(privateKeys...)
+}
+
+// GenerateSigningKey writes a new Ed25519 key to dir, named after the current
+// time so it sorts after the existing keys and signs from the next start on.
+func GenerateSigningKey(dir string) (string, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, time.Now().UTC().Format("20060102T150405Z")+".pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func (k *SigningKeys) current() signingKey {
+	return k.keys[len(k.keys)-1]
+}
+
+func (k *SigningKeys) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	for _, key := range k.keys {
+		if key.jwk.KeyId == kid {
+			return key.private.Public(), nil
+		}
+	}
+	return nil, jwtauth.ErrUnknownKey
+}
+
+// JWKS lists the public keys, the signing one last.
+func (k *SigningKeys) JWKS() jwtauth.JWKS {
+	set := jwtauth.JWKS{Keys: make([]jwtauth.JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		set.Keys = append(set.Keys, key.jwk)
+	}
+	return set
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"monorepo-ecommerce/micro-services/user/service"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func newJWT(t *testing.T, ttl time.Duration) *service.JWT {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	keys, err := service.NewSigningKeys(private)
+	assert.NoError(t, err)
+	return service.NewJWT(keys, "user-service", "monorepo-ecommerce", ttl)
+}
+
+func TestJWT(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should sign with EdDSA and name the key", func(t *testing.T) {
+		jwt := newJWT(t, 15*time.Minute)
+
+		accessToken, err := jwt.GenerateToken(1, "test@example.com", "0811")
+		assert.NoError(t, err)
+
+		token, err := jwt.ValidateToken(ctx, accessToken.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "EdDSA", token.Method.Alg())
+		assert.Equal(t, jwt.JWKS().Keys[0].KeyId, token.Header["kid"])
+		claims := token.Claims.(gojwt.MapClaims)
+		assert.Equal(t, "user-service", claims["iss"])
+		assert.Equal(t, "monorepo-ecommerce", claims["aud"])
+		assert.Equal(t, accessToken.Id, claims["jti"])
+	})
+
+	t.Run("should reject a token signed with another key", func(t *testing.T) {
+		accessToken, err := newJWT(t, 15*time.Minute).GenerateToken(1, "test@example.com", "0811")
+		assert.NoError(t, err)
+
+		_, err = newJWT(t, 15*time.Minute).ValidateToken(ctx, accessToken.Token)
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		jwt := newJWT(t, -time.Minute)
+
+		accessToken, err := jwt.GenerateToken(1, "test@example.com", "0811")
+		assert.NoError(t, err)
+
+		_, err = jwt.ValidateToken(ctx, accessToken.Token)
+		assert.ErrorIs(t, err, gojwt.ErrTokenExpired)
+	})
+
+	t.Run("should reject an HS256 token signed with the old shared secret", func(t *testing.T) {
+		legacy, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{
+			"user_id": 1,
+			"iss":     "user-service",
+			"aud":     "monorepo-ecommerce",
+			"exp":     time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("secret-key"))
+		assert.NoError(t, err)
+
+		_, err = newJWT(t, 15*time.Minute).ValidateToken(ctx, legacy)
+		assert.Error(t, err)
+	})
+}
+
+func TestSigningKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should generate a key for an empty directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "keys")
+
+		keys, err := service.LoadSigningKeys(dir)
+
+		assert.NoError(t, err)
+		assert.Len(t, keys.JWKS().Keys, 1)
+		assert.Equal(t, "OKP", keys.JWKS().Keys[0].KeyType)
+		files, _ := os.ReadDir(dir)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("should sign with the newest key and keep publishing the old one", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := service.LoadSigningKeys(dir)
+		assert.NoError(t, err)
+		old, err := service.LoadSigningKeys(dir)
+		assert.NoError(t, err)
+		oldToken, err := service.NewJWT(old, "user-service", "monorepo-ecommerce", time.Minute).GenerateToken(1, "", "0811")
+		assert.NoError(t, err)
+
+		// keys are named by the second they were created in
+		time.Sleep(time.Second)
+		_, err = service.GenerateSigningKey(dir)
+		assert.NoError(t, err)
+		keys, err := service.LoadSigningKeys(dir)
+		assert.NoError(t, err)
+		jwt := service.NewJWT(keys, "user-service", "monorepo-ecommerce", time.Minute)
+
+		set := keys.JWKS()
+		assert.Len(t, set.Keys, 2)
+		newToken, err := jwt.GenerateToken(1, "", "0811")
+		assert.NoError(t, err)
+		token, err := jwt.ValidateToken(ctx, newToken.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, set.Keys[1].KeyId, token.Header["kid"])
+
+		_, err = jwt.ValidateToken(ctx, oldToken.Token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should refuse RSA keys shorter than 2048 bits", func(t *testing.T) {
+		private, err := rsa.GenerateKey(rand.Reader, 1024)
+		assert.NoError(t, err)
+
+		_, err = service.NewSigningKeys(private)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/user/migrations"
+	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/micro-services/user/repository"
+	"monorepo-ecommerce/micro-services/user/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenService(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t, "user", migrations.For)
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	jwt := newJWT(t, 15*time.Minute)
+	tokenService := service.NewTokenService(userRepo, tokenRepo, jwt, time.Hour)
+
+	user, err := userRepo.CreateUser(ctx, models.User{Email: "a@example.com", Phone: "0811", Password: "hashed"})
+	assert.NoError(t, err)
+
+	revokedIds := func(t *testing.T) []string {
+		revoked, err := tokenService.GetRevokedTokens(ctx)
+		assert.NoError(t, err)
+		ids := []string{}
+		for _, token := range revoked {
+			ids = append(ids, token.TokenId)
+		}
+		return ids
+	}
+	t.Run("should issue an access and refresh token", func(t *testing.T) {
+		tokens, err := tokenService.IssueTokens(ctx, user)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.Equal(t, tokens.AccessToken, tokens.Token)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, int64(900), tokens.ExpiresIn)
+	})
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		tokens, err := tokenService.IssueTokens(ctx, user)
+		assert.NoError(t, err)
+
+		rotated, err := tokenService.RefreshTokens(ctx, tokens.RefreshToken)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+		assert.NotEqual(t, tokens.AccessToken, rotated.AccessToken)
+
+		_, err = tokenService.RefreshTokens(ctx, rotated.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should revoke the family when a refresh token is reused", func(t *testing.T) {
+		tokens, err := tokenService.IssueTokens(ctx, user)
+		assert.NoError(t, err)
+		rotated, err := tokenService.RefreshTokens(ctx, tokens.RefreshToken)
+		assert.NoError(t, err)
+
+		_, err = tokenService.RefreshTokens(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+
+		// the token handed to the legitimate caller is revoked as well
+		_, err = tokenService.RefreshTokens(ctx, rotated.RefreshToken)
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+
+		revoked := revokedIds(t)
+		assert.Contains(t, revoked, accessTokenId(t, jwt, tokens.AccessToken))
+		assert.Contains(t, revoked, accessTokenId(t, jwt, rotated.AccessToken))
+	})
+
+	t.Run("should reject an unknown refresh token", func(t *testing.T) {
+		_, err := tokenService.RefreshTokens(ctx, "unknown")
+
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+	})
+
+	t.Run("should revoke the access token and family on logout", func(t *testing.T) {
+		tokens, err := tokenService.IssueTokens(ctx, user)
+		assert.NoError(t, err)
+		accessToken := models.RevokedToken{TokenId: accessTokenId(t, jwt, tokens.AccessToken), ExpiresAt: time.Now().Add(15 * time.Minute)}
+
+		err = tokenService.Logout(ctx, user.Id, accessToken, tokens.RefreshToken)
+
+		assert.NoError(t, err)
+		assert.Contains(t, revokedIds(t), accessToken.TokenId)
+		_, err = tokenService.RefreshTokens(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, apperror.ErrUnauthorized)
+	})
+
+	t.Run("should leave the refresh token of another user alone", func(t *testing.T) {
+		tokens, err := tokenService.IssueTokens(ctx, user)
+		assert.NoError(t, err)
+
+		err = tokenService.Logout(ctx, user.Id+1, models.RevokedToken{}, tokens.RefreshToken)
+
+		assert.NoError(t, err)
+		_, err = tokenService.RefreshTokens(ctx, tokens.RefreshToken)
+		assert.NoError(t, err)
+	})
+}
+
+func accessTokenId(t *testing.T, jwt *service.JWT, accessToken string) string {
+	t.Helper()
+
+	token, err := jwt.ValidateToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+	tokenId, _ := token.Claims.(gojwt.MapClaims)["jti"].(string)
+	return tokenId
+}
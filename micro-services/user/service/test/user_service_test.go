@@ -1,6 +1,7 @@
 package test
 
 import (
+	"context"
 	"errors"
 	mocks "monorepo-ecommerce/micro-services/user/mocks/mock_micro-services/user/repository"
 	"monorepo-ecommerce/micro-services/user/models"
@@ -18,6 +19,7 @@ func TestRegisterUser(t *testing.T) {
 	mockRepo := mocks.NewMockUserRepository(ctrl)
 
 	userService := service.NewUserService(mockRepo)
+	ctx := context.Background()
 
 	email := "test@example.com"
 	phone := "1234567890"
@@ -31,9 +33,9 @@ func TestRegisterUser(t *testing.T) {
 	}
 
 	t.Run("should success", func(t *testing.T) {
-		mockRepo.EXPECT().CreateUser(gomock.Any()).Return(user, nil)
+		mockRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(user, nil)
 
-		user, err := userService.RegisterUser(email, phone, password)
+		user, err := userService.RegisterUser(ctx, email, phone, password)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -42,7 +44,7 @@ func TestRegisterUser(t *testing.T) {
 	})
 
 	t.Run("should error validation", func(t *testing.T) {
-		_, err := userService.RegisterUser("", "", "password")
+		_, err := userService.RegisterUser(ctx, "", "", "password")
 		assert.Error(t, err)
 		assert.EqualError(t, err, "email or phone is required")
 	})
@@ -55,6 +57,7 @@ func TestLoginUser(t *testing.T) {
 	mockRepo := mocks.NewMockUserRepository(ctrl)
 
 	userService := service.NewUserService(mockRepo)
+	ctx := context.Background()
 
 	email := "test@example.com"
 	phone := "1234567890"
@@ -70,9 +73,9 @@ func TestLoginUser(t *testing.T) {
 	}
 
 	t.Run("should success", func(t *testing.T) {
-		mockRepo.EXPECT().GetUserByEmailOrPhone(email, phone, password).Return(user, nil)
+		mockRepo.EXPECT().GetUserByEmailOrPhone(gomock.Any(), email, phone, password).Return(user, nil)
 
-		user, err := userService.LoginUser(email, phone, password)
+		user, err := userService.LoginUser(ctx, email, phone, password)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -81,9 +84,9 @@ func TestLoginUser(t *testing.T) {
 	})
 
 	t.Run("should error when user not found", func(t *testing.T) {
-		mockRepo.EXPECT().GetUserByEmailOrPhone(email, phone, password).Return(nil, errors.New("user not found"))
+		mockRepo.EXPECT().GetUserByEmailOrPhone(gomock.Any(), email, phone, password).Return(nil, errors.New("user not found"))
 
-		user, err := userService.LoginUser(email, phone, password)
+		user, err := userService.LoginUser(ctx, email, phone, password)
 
 		assert.Error(t, err)
 		assert.Nil(t, user)
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/micro-services/user/models"
+	"monorepo-ecommerce/micro-services/user/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"time"
+)
+
+// TokenService hands out short-lived access tokens with rotating refresh
+// tokens, and keeps the list of access tokens revoked before they expire.
+type TokenService interface {
+	// IssueTokens starts a new token family for a user who just logged in.
+	IssueTokens(ctx context.Context, user *models.User) (*models.TokenPair, error)
+	// RefreshTokens exchanges a refresh token for a new pair of the same
+	// family. A refresh token exchanged twice was stolen by one of the two
+	// callers, so the whole family and its access tokens are revoked.
+	RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	// Logout revokes the access token and, when given, the family of the
+	// user's refresh token.
+	Logout(ctx context.Context, userId int64, accessToken models.RevokedToken, refreshToken string) error
+	GetRevokedTokens(ctx context.Context) ([]models.RevokedToken, error)
+}
+
+type tokenService struct {
+	userRepo   repository.UserRepository
+	tokenRepo  repository.TokenRepository
+	jwt        *JWT
+	refreshTTL time.Duration
+}
+
+func NewTokenService(userRepo repository.UserRepository, tokenRepo repository.TokenRepository, jwt *JWT, refreshTTL time.Duration) TokenService {
+	return &tokenService{
+		userRepo:   userRepo,
+		tokenRepo:  tokenRepo,
+		jwt:        jwt,
+		refreshTTL: refreshTTL,
+	}
+}
+
+func (s *tokenService) IssueTokens(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	return s.issue(ctx, user, randomToken(16))
+}
+
+// issue signs an access token and stores a refresh token of familyId
+// issued with it.
+func (s *tokenService) issue(ctx context.Context, user *models.User, familyId string) (*models.TokenPair, error) {
+	accessToken, err := s.jwt.GenerateToken(user.Id, user.Email, user.Phone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	refreshToken := randomToken(32)
+	err = s.tokenRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserId:          user.Id,
+		FamilyId:        familyId,
+		TokenHash:       hashToken(refreshToken),
+		AccessTokenId:   accessToken.Id,
+		AccessExpiresAt: accessToken.ExpiresAt.UTC(),
+		ExpiresAt:       time.Now().Add(s.refreshTTL).UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &models.TokenPair{
+		Token:        accessToken.Token,
+		AccessToken:  accessToken.Token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.jwt.TTL().Seconds()),
+	}, nil
+}
+
+func (s *tokenService) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, &apperror.InvalidInputError{Field: "refresh_token", Reason: "is required"}
+	}
+
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if stored == nil {
+		return nil, &apperror.UnauthorizedError{Reason: "invalid refresh token"}
+	}
+	if stored.RevokedAt != nil {
+		return nil, &apperror.UnauthorizedError{Reason: "refresh token revoked"}
+	}
+	if stored.UsedAt != nil {
+		return nil, s.revokeReused(ctx, stored.FamilyId)
+	}
+	if !time.Now().Before(stored.ExpiresAt) {
+		return nil, &apperror.UnauthorizedError{Reason: "refresh token expired"}
+	}
+
+	// a concurrent exchange of the same token counts as reuse as well
+	exchanged, err := s.tokenRepo.MarkRefreshTokenUsed(ctx, stored.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	if !exchanged {
+		return nil, s.revokeReused(ctx, stored.FamilyId)
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, stored.UserId)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, &apperror.UnauthorizedError{Reason: "user not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return s.issue(ctx, user, stored.FamilyId)
+}
+
+// revokeReused revokes the family of a refresh token used twice and returns
+// the error for the caller.
+func (s *tokenService) revokeReused(ctx context.Context, familyId string) error {
+	if err := s.revokeFamily(ctx, familyId); err != nil {
+		return err
+	}
+	return &apperror.UnauthorizedError{Reason: "refresh token reused, please login again"}
+}
+
+func (s *tokenService) revokeFamily(ctx context.Context, familyId string) error {
+	if err := s.tokenRepo.RevokeFamily(ctx, familyId); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return nil
+}
+
+func (s *tokenService) Logout(ctx context.Context, userId int64, accessToken models.RevokedToken, refreshToken string) error {
+	if accessToken.TokenId != "" {
+		if err := s.tokenRepo.RevokeAccessTokens(ctx, accessToken); err != nil {
+			return fmt.Errorf("failed to revoke access token: %w", err)
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	// someone else's refresh token is left alone
+	if stored == nil || stored.UserId != userId {
+		return nil
+	}
+
+	return s.revokeFamily(ctx, stored.FamilyId)
+}
+
+func (s *tokenService) GetRevokedTokens(ctx context.Context) ([]models.RevokedToken, error) {
+	tokens, err := s.tokenRepo.GetRevokedTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revoked tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// hashToken is how refresh tokens are stored. They are random, so a plain
+// SHA-256 is enough and lets a token be looked up by its hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,18 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"log"
 	"monorepo-ecommerce/micro-services/user/models"
 	"monorepo-ecommerce/micro-services/user/repository"
+	"monorepo-ecommerce/pkg/apperror"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
-	RegisterUser(email string, phone string, password string) (*models.User, error)
-	LoginUser(email string, phone string, password string) (*models.User, error)
+	RegisterUser(ctx context.Context, email string, phone string, password string) (*models.User, error)
+	LoginUser(ctx context.Context, email string, phone string, password string) (*models.User, error)
 }
 
 type userService struct {
@@ -23,13 +24,13 @@ func NewUserService(repo repository.UserRepository) UserService {
 	return &userService{repo: repo}
 }
 
-func (s *userService) RegisterUser(email string, phone string, password string) (user *models.User, err error) {
+func (s *userService) RegisterUser(ctx context.Context, email string, phone string, password string) (user *models.User, err error) {
 	if email == "" || phone == "" {
-		return nil, errors.New("email or phone is required")
+		return nil, &apperror.InvalidInputError{Field: "email", Reason: "email or phone is required"}
 	}
 
 	if password == "" {
-		return nil, errors.New("password is required")
+		return nil, &apperror.InvalidInputError{Field: "password", Reason: "password is required"}
 	}
 
 	// hashing password
@@ -45,7 +46,7 @@ func (s *userService) RegisterUser(email string, phone string, password string)
 		Password: string(hashedPassword),
 	}
 
-	user, err = s.repo.CreateUser(data)
+	user, err = s.repo.CreateUser(ctx, data)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +54,15 @@ func (s *userService) RegisterUser(email string, phone string, password string)
 	return user, nil
 }
 
-func (s *userService) LoginUser(email string, phone string, password string) (user *models.User, err error) {
-	user, err = s.repo.GetUserByEmailOrPhone(email, phone, password)
-	fmt.Println(user)
+func (s *userService) LoginUser(ctx context.Context, email string, phone string, password string) (user *models.User, err error) {
+	user, err = s.repo.GetUserByEmailOrPhone(ctx, email, phone, password)
+	if err != nil && !errors.Is(err, apperror.ErrUnauthorized) {
+		return nil, err
+	}
+
+	// wrong password and unknown user look the same to the caller
 	if err != nil || user == nil {
-		return nil, errors.New("user not found")
+		return nil, &apperror.UnauthorizedError{Reason: "user not found"}
 	}
 
 	return user, nil
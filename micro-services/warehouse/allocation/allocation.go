@@ -0,0 +1,370 @@
+// Package allocation decides which warehouses an order's lines are taken
+// from. A Strategy only plans: it works on a snapshot of the stock held by
+// the active warehouses and returns picks, which the warehouse service then
+// deducts and records.
+package allocation
+
+import (
+	"fmt"
+	"math"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"sort"
+	"strings"
+)
+
+const (
+	// FewestSplits ships from as few warehouses as possible.
+	FewestSplits = "fewest-splits"
+	// Priority drains warehouses in ascending priority.
+	Priority = "priority"
+	// Nearest drains the warehouses closest to the shipping address first.
+	Nearest = "nearest"
+	// Balance takes from the fullest warehouses, evening out stock levels.
+	Balance = "balance"
+)
+
+// Line is one order line to allocate.
+type Line struct {
+	ProductId int64
+	Quantity  int
+}
+
+// Candidate is an active warehouse with its stock by product id.
+type Candidate struct {
+	Warehouse models.Warehouse
+	Stock     map[int64]int
+}
+
+type Request struct {
+	Lines      []Line
+	Candidates []Candidate
+	// Destination is the shipping address, nil when the order has none.
+	Destination *models.Location
+}
+
+// Pick is a quantity of a product taken from one warehouse.
+type Pick struct {
+	ProductId   int64
+	WarehouseId int64
+	Quantity    int
+}
+
+type Strategy interface {
+	Name() string
+	// Allocate returns the picks covering every line, or an
+	// InsufficientStockError when the candidates cannot.
+	Allocate(req Request) ([]Pick, error)
+}
+
+var strategies = map[string]Strategy{
+	FewestSplits: fewestSplits{},
+	Priority:     priority{},
+	Nearest:      nearest{},
+	Balance:      balance{},
+}
+
+// New returns the strategy registered under name.
+func New(name string) (Strategy, error) {
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown allocation strategy %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+	return strategy, nil
+}
+
+// Names lists the registered strategies.
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stock is the working copy of the candidates' stock, reduced as lines are
+// planned so several lines of the same product do not overbook it.
+type stock map[int64]map[int64]int
+
+func newStock(candidates []Candidate) stock {
+	s := stock{}
+	for _, c := range candidates {
+		byProduct := map[int64]int{}
+		for productId, quantity := range c.Stock {
+			byProduct[productId] = quantity
+		}
+		s[c.Warehouse.Id] = byProduct
+	}
+	return s
+}
+
+func (s stock) available(warehouseId, productId int64) int {
+	return s[warehouseId][productId]
+}
+
+func (s stock) total(candidates []Candidate, productId int64) int {
+	total := 0
+	for _, c := range candidates {
+		total += s.available(c.Warehouse.Id, productId)
+	}
+	return total
+}
+
+func (s stock) take(warehouseId, productId int64, quantity int) Pick {
+	s[warehouseId][productId] -= quantity
+	return Pick{ProductId: productId, WarehouseId: warehouseId, Quantity: quantity}
+}
+
+// fill takes line from the candidates in the given order, draining each
+// before moving on.
+func fill(line Line, ordered []Candidate, s stock) []Pick {
+	var picks []Pick
+	remaining := line.Quantity
+	for _, c := range ordered {
+		if remaining == 0 {
+			break
+		}
+		quantity := min(remaining, s.available(c.Warehouse.Id, line.ProductId))
+		if quantity <= 0 {
+			continue
+		}
+		picks = append(picks, s.take(c.Warehouse.Id, line.ProductId, quantity))
+		remaining -= quantity
+	}
+	return picks
+}
+
+// checkStock reports the first line the candidates cannot cover.
+func checkStock(req Request) error {
+	s := newStock(req.Candidates)
+	needed := map[int64]int{}
+	for _, line := range req.Lines {
+		needed[line.ProductId] += line.Quantity
+		if available := s.total(req.Candidates, line.ProductId); available < needed[line.ProductId] {
+			return &apperror.InsufficientStockError{ProductId: line.ProductId, Requested: needed[line.ProductId], Available: available}
+		}
+	}
+	return nil
+}
+
+// byPriority orders candidates by ascending priority, then id.
+func byPriority(candidates []Candidate) []Candidate {
+	ordered := append([]Candidate(nil), candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].Warehouse, ordered[j].Warehouse
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.Id < b.Id
+	})
+	return ordered
+}
+
+type priority struct{}
+
+func (priority) Name() string { return Priority }
+
+func (priority) Allocate(req Request) ([]Pick, error) {
+	if err := checkStock(req); err != nil {
+		return nil, err
+	}
+
+	s := newStock(req.Candidates)
+	ordered := byPriority(req.Candidates)
+
+	var picks []Pick
+	for _, line := range req.Lines {
+		picks = append(picks, fill(line, ordered, s)...)
+	}
+	return picks, nil
+}
+
+type nearest struct{}
+
+func (nearest) Name() string { return Nearest }
+
+// Allocate drains warehouses by distance to the destination. Warehouses
+// without coordinates come last; without a destination it falls back to
+// priority order.
+func (nearest) Allocate(req Request) ([]Pick, error) {
+	if err := checkStock(req); err != nil {
+		return nil, err
+	}
+
+	s := newStock(req.Candidates)
+	ordered := byPriority(req.Candidates)
+	if req.Destination != nil {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return distance(req.Destination, ordered[i].Warehouse.Location) < distance(req.Destination, ordered[j].Warehouse.Location)
+		})
+	}
+
+	var picks []Pick
+	for _, line := range req.Lines {
+		picks = append(picks, fill(line, ordered, s)...)
+	}
+	return picks, nil
+}
+
+const earthRadiusKm = 6371.0
+
+// distance is the great-circle distance in kilometres, or +Inf when to has
+// no coordinates.
+func distance(from, to *models.Location) float64 {
+	if to == nil {
+		return math.Inf(1)
+	}
+
+	lat1, lat2 := radians(from.Latitude), radians(to.Latitude)
+	dLat := lat2 - lat1
+	dLng := radians(to.Longitude - from.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+type fewestSplits struct{}
+
+func (fewestSplits) Name() string { return FewestSplits }
+
+// Allocate ships the whole order from one warehouse when any can, otherwise
+// covers each line from a single warehouse where possible, preferring ones
+// already used, and splits the rest across the fullest warehouses.
+func (fewestSplits) Allocate(req Request) ([]Pick, error) {
+	if err := checkStock(req); err != nil {
+		return nil, err
+	}
+
+	s := newStock(req.Candidates)
+	ordered := byPriority(req.Candidates)
+
+	for _, c := range ordered {
+		if covers(c.Warehouse.Id, req.Lines, s) {
+			var picks []Pick
+			for _, line := range req.Lines {
+				picks = append(picks, s.take(c.Warehouse.Id, line.ProductId, line.Quantity))
+			}
+			return picks, nil
+		}
+	}
+
+	used := map[int64]bool{}
+	var picks []Pick
+	for _, line := range req.Lines {
+		// one warehouse for the line, used ones first, ties by priority
+		var single *Candidate
+		for i, c := range ordered {
+			if s.available(c.Warehouse.Id, line.ProductId) < line.Quantity {
+				continue
+			}
+			if single == nil || (used[c.Warehouse.Id] && !used[single.Warehouse.Id]) {
+				single = &ordered[i]
+			}
+		}
+		if single != nil {
+			used[single.Warehouse.Id] = true
+			picks = append(picks, s.take(single.Warehouse.Id, line.ProductId, line.Quantity))
+			continue
+		}
+
+		// otherwise the fullest warehouses need the fewest picks
+		fullest := append([]Candidate(nil), ordered...)
+		sort.SliceStable(fullest, func(i, j int) bool {
+			return s.available(fullest[i].Warehouse.Id, line.ProductId) > s.available(fullest[j].Warehouse.Id, line.ProductId)
+		})
+		for _, pick := range fill(line, fullest, s) {
+			used[pick.WarehouseId] = true
+			picks = append(picks, pick)
+		}
+	}
+	return picks, nil
+}
+
+func covers(warehouseId int64, lines []Line, s stock) bool {
+	needed := map[int64]int{}
+	for _, line := range lines {
+		needed[line.ProductId] += line.Quantity
+	}
+	for productId, quantity := range needed {
+		if s.available(warehouseId, productId) < quantity {
+			return false
+		}
+	}
+	return true
+}
+
+type balance struct{}
+
+func (balance) Name() string { return Balance }
+
+// Allocate takes each line from the fullest warehouses, lowering them
+// towards a common level so stock stays evenly spread.
+func (balance) Allocate(req Request) ([]Pick, error) {
+	if err := checkStock(req); err != nil {
+		return nil, err
+	}
+
+	s := newStock(req.Candidates)
+	ordered := byPriority(req.Candidates)
+
+	var picks []Pick
+	for _, line := range req.Lines {
+		fullest := append([]Candidate(nil), ordered...)
+		sort.SliceStable(fullest, func(i, j int) bool {
+			return s.available(fullest[i].Warehouse.Id, line.ProductId) > s.available(fullest[j].Warehouse.Id, line.ProductId)
+		})
+
+		levels := make([]int, len(fullest))
+		for i, c := range fullest {
+			levels[i] = s.available(c.Warehouse.Id, line.ProductId)
+		}
+
+		for i, quantity := range levelOff(levels, line.Quantity) {
+			if quantity > 0 {
+				picks = append(picks, s.take(fullest[i].Warehouse.Id, line.ProductId, quantity))
+			}
+		}
+	}
+	return picks, nil
+}
+
+// levelOff returns how much to take from each of levels, sorted descending,
+// so that quantity is taken and the highest levels end up as equal as
+// possible. Leftover units go to the first warehouses.
+func levelOff(levels []int, quantity int) []int {
+	takes := make([]int, len(levels))
+
+	// find the k fullest warehouses that have to give something
+	sum := 0
+	k := 0
+	for k < len(levels) {
+		sum += levels[k]
+		k++
+		next := 0
+		if k < len(levels) {
+			next = levels[k]
+		}
+		if sum-k*next >= quantity {
+			break
+		}
+	}
+
+	// lower the k warehouses to level, then take the remainder one by one
+	level := (sum - quantity) / k
+	taken := 0
+	for i := 0; i < k; i++ {
+		takes[i] = levels[i] - level
+		taken += takes[i]
+	}
+	for i := k - 1; taken > quantity; i-- {
+		takes[i]--
+		taken--
+	}
+
+	return takes
+}
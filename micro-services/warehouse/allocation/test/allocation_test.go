@@ -0,0 +1,184 @@
+package test
+
+import (
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// candidates are three warehouses with priorities 1-3: Jakarta, Surabaya
+// and Medan.
+func candidates(stock ...map[int64]int) []allocation.Candidate {
+	warehouses := []models.Warehouse{
+		{Id: 1, Priority: 1, Location: &models.Location{Latitude: -6.2088, Longitude: 106.8456}},
+		{Id: 2, Priority: 2, Location: &models.Location{Latitude: -7.2575, Longitude: 112.7521}},
+		{Id: 3, Priority: 3, Location: &models.Location{Latitude: 3.5952, Longitude: 98.6722}},
+	}
+
+	result := make([]allocation.Candidate, len(stock))
+	for i := range stock {
+		result[i] = allocation.Candidate{Warehouse: warehouses[i], Stock: stock[i]}
+	}
+	return result
+}
+
+func allocate(t *testing.T, name string, req allocation.Request) []allocation.Pick {
+	strategy, err := allocation.New(name)
+	assert.NoError(t, err)
+
+	picks, err := strategy.Allocate(req)
+	assert.NoError(t, err)
+	return picks
+}
+
+func TestPriority(t *testing.T) {
+	t.Run("should drain warehouses in priority order", func(t *testing.T) {
+		picks := allocate(t, allocation.Priority, allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 12}},
+			Candidates: candidates(map[int64]int{1: 5}, map[int64]int{1: 5}, map[int64]int{1: 5}),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 1, Quantity: 5},
+			{ProductId: 1, WarehouseId: 2, Quantity: 5},
+			{ProductId: 1, WarehouseId: 3, Quantity: 2},
+		}, picks)
+	})
+
+	t.Run("should not overbook a warehouse across lines of the same product", func(t *testing.T) {
+		picks := allocate(t, allocation.Priority, allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 4}, {ProductId: 1, Quantity: 4}},
+			Candidates: candidates(map[int64]int{1: 5}, map[int64]int{1: 5}),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 1, Quantity: 4},
+			{ProductId: 1, WarehouseId: 1, Quantity: 1},
+			{ProductId: 1, WarehouseId: 2, Quantity: 3},
+		}, picks)
+	})
+
+	t.Run("should report insufficient stock", func(t *testing.T) {
+		strategy, _ := allocation.New(allocation.Priority)
+
+		_, err := strategy.Allocate(allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 11}},
+			Candidates: candidates(map[int64]int{1: 5}, map[int64]int{1: 5}),
+		})
+
+		var insufficient *apperror.InsufficientStockError
+		assert.ErrorAs(t, err, &insufficient)
+		assert.Equal(t, 10, insufficient.Available)
+	})
+}
+
+func TestFewestSplits(t *testing.T) {
+	t.Run("should ship the whole order from one warehouse when possible", func(t *testing.T) {
+		picks := allocate(t, allocation.FewestSplits, allocation.Request{
+			Lines: []allocation.Line{{ProductId: 1, Quantity: 3}, {ProductId: 2, Quantity: 3}},
+			Candidates: candidates(
+				map[int64]int{1: 10},
+				map[int64]int{1: 3, 2: 3},
+			),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 2, Quantity: 3},
+			{ProductId: 2, WarehouseId: 2, Quantity: 3},
+		}, picks)
+	})
+
+	t.Run("should prefer a warehouse the order already uses", func(t *testing.T) {
+		picks := allocate(t, allocation.FewestSplits, allocation.Request{
+			Lines: []allocation.Line{{ProductId: 2, Quantity: 5}, {ProductId: 1, Quantity: 5}},
+			Candidates: candidates(
+				map[int64]int{1: 10},
+				map[int64]int{1: 10},
+				map[int64]int{2: 10},
+			),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 2, WarehouseId: 3, Quantity: 5},
+			{ProductId: 1, WarehouseId: 1, Quantity: 5},
+		}, picks)
+	})
+
+	t.Run("should split across the fullest warehouses", func(t *testing.T) {
+		picks := allocate(t, allocation.FewestSplits, allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 12}},
+			Candidates: candidates(map[int64]int{1: 4}, map[int64]int{1: 4}, map[int64]int{1: 9}),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 3, Quantity: 9},
+			{ProductId: 1, WarehouseId: 1, Quantity: 3},
+		}, picks)
+	})
+}
+
+func TestNearest(t *testing.T) {
+	lines := []allocation.Line{{ProductId: 1, Quantity: 6}}
+	stock := candidates(map[int64]int{1: 5}, map[int64]int{1: 5}, map[int64]int{1: 5})
+
+	t.Run("should drain the closest warehouse first", func(t *testing.T) {
+		// Malang is close to Surabaya, then Jakarta, then Medan
+		picks := allocate(t, allocation.Nearest, allocation.Request{
+			Lines:       lines,
+			Candidates:  stock,
+			Destination: &models.Location{Latitude: -7.9666, Longitude: 112.6326},
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 2, Quantity: 5},
+			{ProductId: 1, WarehouseId: 1, Quantity: 1},
+		}, picks)
+	})
+
+	t.Run("should fall back to priority without a destination", func(t *testing.T) {
+		picks := allocate(t, allocation.Nearest, allocation.Request{Lines: lines, Candidates: stock})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 1, Quantity: 5},
+			{ProductId: 1, WarehouseId: 2, Quantity: 1},
+		}, picks)
+	})
+}
+
+func TestBalance(t *testing.T) {
+	t.Run("should level the fullest warehouses", func(t *testing.T) {
+		picks := allocate(t, allocation.Balance, allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 15}},
+			Candidates: candidates(map[int64]int{1: 10}, map[int64]int{1: 30}, map[int64]int{1: 20}),
+		})
+
+		// 30 and 20 are lowered to 17 and 18
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 2, Quantity: 13},
+			{ProductId: 1, WarehouseId: 3, Quantity: 2},
+		}, picks)
+	})
+
+	t.Run("should take everything when the order needs it", func(t *testing.T) {
+		picks := allocate(t, allocation.Balance, allocation.Request{
+			Lines:      []allocation.Line{{ProductId: 1, Quantity: 6}},
+			Candidates: candidates(map[int64]int{1: 3}, map[int64]int{1: 3}),
+		})
+
+		assert.Equal(t, []allocation.Pick{
+			{ProductId: 1, WarehouseId: 1, Quantity: 3},
+			{ProductId: 1, WarehouseId: 2, Quantity: 3},
+		}, picks)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("should reject an unknown strategy", func(t *testing.T) {
+		_, err := allocation.New("random")
+
+		assert.ErrorContains(t, err, "balance, fewest-splits, nearest, priority")
+	})
+}
@@ -1 +1,118 @@
 package config
+
+import (
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/notifier"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/httpclient"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type Config struct {
+	Port                   int           `yaml:"port" env:"PORT"`
+	InternalPort           int           `yaml:"internal_port" env:"INTERNAL_PORT"`
+	DatabaseDriver         string        `yaml:"database_driver" env:"DATABASE_DRIVER"`
+	DatabasePath           string        `yaml:"database_path" env:"DATABASE_PATH"`
+	DatabaseURL            string        `yaml:"database_url" env:"DATABASE_URL" secret:"true"`
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ProductServiceURL      string        `yaml:"product_service_url" env:"PRODUCT_SERVICE_URL"`
+	OrderServiceURL        string        `yaml:"order_service_url" env:"ORDER_SERVICE_URL"`
+	UpstreamTimeout        time.Duration `yaml:"upstream_timeout" env:"UPSTREAM_TIMEOUT"`
+	StockSyncSchedule      string        `yaml:"stock_sync_schedule" env:"STOCK_SYNC_SCHEDULE"`
+	StockPushSchedule      string        `yaml:"stock_push_schedule" env:"STOCK_PUSH_SCHEDULE"`
+	StockSyncBatchSize     int           `yaml:"stock_sync_batch_size" env:"STOCK_SYNC_BATCH_SIZE"`
+	AllocationStrategy     string        `yaml:"allocation_strategy" env:"ALLOCATION_STRATEGY"`
+	ReplenishmentSchedule  string        `yaml:"replenishment_schedule" env:"REPLENISHMENT_SCHEDULE"`
+	ReplenishmentLookback  time.Duration `yaml:"replenishment_lookback" env:"REPLENISHMENT_LOOKBACK"`
+	ReplenishmentCoverDays int           `yaml:"replenishment_cover_days" env:"REPLENISHMENT_COVER_DAYS"`
+	LowStockNotifier       string        `yaml:"low_stock_notifier" env:"LOW_STOCK_NOTIFIER"`
+	LowStockWebhookURL     string        `yaml:"low_stock_webhook_url" env:"LOW_STOCK_WEBHOOK_URL" secret:"true"`
+}
+
+// maxStockSyncBatchSize is the largest batch the product service takes.
+const maxStockSyncBatchSize = 500
+
+func Default() Config {
+	return Config{
+		Port:                   7005,
+		InternalPort:           8005,
+		DatabaseDriver:         "sqlite",
+		DatabasePath:           "./../../data/warehouse.db",
+		ShutdownTimeout:        15 * time.Second,
+		ProductServiceURL:      "http://localhost:7002",
+		OrderServiceURL:        "http://localhost:8003",
+		UpstreamTimeout:        5 * time.Second,
+		StockSyncSchedule:      "@every 1h",
+		StockPushSchedule:      "@every 10s",
+		StockSyncBatchSize:     100,
+		AllocationStrategy:     allocation.Priority,
+		ReplenishmentSchedule:  "@every 1h",
+		ReplenishmentLookback:  14 * 24 * time.Hour,
+		ReplenishmentCoverDays: 7,
+		LowStockNotifier:       notifier.Log,
+	}
+}
+
+// Load returns the defaults overridden by CONFIG_FILE and the environment.
+func Load() (*Config, error) {
+	cfg := Default()
+	if err := configloader.Load(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var scheduleErr error
+	if _, err := cron.ParseStandard(c.StockSyncSchedule); err != nil {
+		scheduleErr = fmt.Errorf("stock_sync_schedule is invalid: %w", err)
+	}
+	var pushScheduleErr error
+	if _, err := cron.ParseStandard(c.StockPushSchedule); err != nil {
+		pushScheduleErr = fmt.Errorf("stock_push_schedule is invalid: %w", err)
+	}
+	var batchSizeErr error
+	if c.StockSyncBatchSize <= 0 || c.StockSyncBatchSize > maxStockSyncBatchSize {
+		batchSizeErr = fmt.Errorf("stock_sync_batch_size must be between 1 and %d, got %d", maxStockSyncBatchSize, c.StockSyncBatchSize)
+	}
+	var strategyErr error
+	if _, err := allocation.New(c.AllocationStrategy); err != nil {
+		strategyErr = fmt.Errorf("allocation_strategy is invalid: %w", err)
+	}
+
+	var replenishmentScheduleErr error
+	if _, err := cron.ParseStandard(c.ReplenishmentSchedule); err != nil {
+		replenishmentScheduleErr = fmt.Errorf("replenishment_schedule is invalid: %w", err)
+	}
+	var coverDaysErr error
+	if c.ReplenishmentCoverDays <= 0 {
+		coverDaysErr = fmt.Errorf("replenishment_cover_days must be positive, got %d", c.ReplenishmentCoverDays)
+	}
+	var notifierErr error
+	if _, err := notifier.New(c.LowStockNotifier, c.LowStockWebhookURL, httpclient.DefaultConfig()); err != nil {
+		notifierErr = fmt.Errorf("low_stock_notifier is invalid: %w", err)
+	}
+
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateInternalPort(c.Port, c.InternalPort),
+		database.NewConfig(c.DatabaseDriver, c.DatabasePath, c.DatabaseURL).Validate(),
+		configloader.ValidatePositive("shutdown_timeout", c.ShutdownTimeout),
+		configloader.ValidateURL("product_service_url", c.ProductServiceURL),
+		configloader.ValidateURL("order_service_url", c.OrderServiceURL),
+		configloader.ValidatePositive("upstream_timeout", c.UpstreamTimeout),
+		scheduleErr,
+		pushScheduleErr,
+		batchSizeErr,
+		strategyErr,
+		replenishmentScheduleErr,
+		configloader.ValidatePositive("replenishment_lookback", c.ReplenishmentLookback),
+		coverDaysErr,
+		notifierErr,
+	)
+}
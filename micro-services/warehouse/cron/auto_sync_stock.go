@@ -1,51 +1,42 @@
 package cron
 
 import (
+	"context"
 	"log"
-	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
 )
 
 type AutoSyncStock struct {
-	productRepo   repository.ProductRepository
-	stockRepo     repository.StockRepository
-	warehouseRepo repository.WarehouseRepository
+	reconciliationService service.ReconciliationService
 }
 
-func NewAutoSyncStockJob(productRepo repository.ProductRepository, stockRepo repository.StockRepository, warehouseRepo repository.WarehouseRepository) *AutoSyncStock {
-	return &AutoSyncStock{productRepo: productRepo, stockRepo: stockRepo, warehouseRepo: warehouseRepo}
+func NewAutoSyncStockJob(reconciliationService service.ReconciliationService) *AutoSyncStock {
+	return &AutoSyncStock{reconciliationService: reconciliationService}
 }
 
-func (job *AutoSyncStock) Run() {
-	products, err := job.productRepo.GetAllProducts()
+// Run reconciles the stock of every product, corrects the product service
+// where it does not add up and logs what it found. Totals are pushed as they
+// change, so a discrepancy points at a push that was lost, a restore that
+// failed or a change made outside the warehouse service.
+func (job *AutoSyncStock) Run(ctx context.Context) {
+	reconciliation, err := job.reconciliationService.Reconcile(ctx, models.ReconcileOptions{Apply: true})
 	if err != nil {
-		log.Printf("Error fetching products: %v", err)
+		log.Printf("Error reconciling stock: %v", err)
 		return
 	}
 
-	for _, product := range products {
-		warehouses, err := job.warehouseRepo.GetActiveWarehouses()
-		if err != nil {
-			log.Printf("Error fetching warehouses: %v", err)
-			return
+	for _, line := range reconciliation.Lines {
+		if line.Error != "" {
+			log.Printf("failed reconcile stock for product %d (%s): %s", line.ProductId, line.Cause, line.Error)
+			continue
 		}
-
-		totalStock := 0
-		for _, warehouse := range warehouses {
-			stock, err := job.stockRepo.GetStockByProductAndWarehouse(product.Id, warehouse.Id)
-			if err != nil {
-				log.Printf("failed to get stock for product %d in warehouse %d: %v", product.Id, warehouse.Id, err)
-				return
-			}
-
-			totalStock += stock.Quantity
-		}
-
-		// sync product stock
-		err = job.productRepo.UpdateTotalProductStock(product.Id, totalStock)
-		if err != nil {
-			log.Printf("failed forward update total product stock: %v", err)
-			return
-		}
-
+		log.Printf("stock of product %d does not add up (%s): product service has %d, warehouses have %d, pending orders hold %d (corrected: %t)",
+			line.ProductId, line.Cause, line.ProductStock, line.WarehouseStock, line.PendingStock, line.Corrected)
+	}
+	if !reconciliation.Complete {
+		log.Printf("Error reconciling stock, stopped early: %s", reconciliation.Error)
 	}
+	log.Printf("stock reconciliation %d checked %d products in %s: %d did not add up",
+		reconciliation.Id, reconciliation.Checked, reconciliation.FinishedAt.Sub(reconciliation.StartedAt), len(reconciliation.Lines))
 }
@@ -0,0 +1,29 @@
+package cron
+
+import (
+	"context"
+	"log"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+)
+
+type PushStockTotals struct {
+	stockSyncService service.StockSyncService
+}
+
+func NewPushStockTotalsJob(stockSyncService service.StockSyncService) *PushStockTotals {
+	return &PushStockTotals{stockSyncService: stockSyncService}
+}
+
+// Run pushes the totals that changed since the last run. Totals it could not
+// push stay queued for the next run.
+func (job *PushStockTotals) Run(ctx context.Context) {
+	push, err := job.stockSyncService.PushChangedTotals(ctx)
+	if err != nil {
+		log.Printf("Error pushing stock totals after %d products: %v", push.Pushed, err)
+		return
+	}
+
+	if len(push.Missing) > 0 {
+		log.Printf("skipped stock totals of unknown products %v", push.Missing)
+	}
+}
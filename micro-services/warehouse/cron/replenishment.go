@@ -0,0 +1,34 @@
+package cron
+
+import (
+	"context"
+	"log"
+	"monorepo-ecommerce/micro-services/warehouse/notifier"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+)
+
+type Replenishment struct {
+	replenishmentService service.ReplenishmentService
+	notifier             notifier.Notifier
+}
+
+func NewReplenishmentJob(replenishmentService service.ReplenishmentService, notifier notifier.Notifier) *Replenishment {
+	return &Replenishment{replenishmentService: replenishmentService, notifier: notifier}
+}
+
+// Run refreshes the replenishment suggestions and sends one low-stock event
+// per product that became low. A failed notification is logged and not
+// retried, the product stays low until its stock recovers.
+func (job *Replenishment) Run(ctx context.Context) {
+	run, err := job.replenishmentService.Replenish(ctx)
+	if err != nil {
+		log.Printf("Error running replenishment: %v", err)
+		return
+	}
+
+	for _, event := range run.LowStock {
+		if err := job.notifier.NotifyLowStock(ctx, event); err != nil {
+			log.Printf("failed notify low stock of product %d in warehouse %d: %v", event.ProductId, event.WarehouseId, err)
+		}
+	}
+}
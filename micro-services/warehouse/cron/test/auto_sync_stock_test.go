@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/cron"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestAutoSyncStock(t *testing.T) {
+	t.Run("should reconcile, correct and report without stopping on failures", func(t *testing.T) {
+		mockService := mocks.NewMockReconciliationService(gomock.NewController(t))
+		mockService.EXPECT().
+			Reconcile(gomock.Any(), models.ReconcileOptions{Apply: true}).
+			Return(&models.Reconciliation{
+				Id:      1,
+				Apply:   true,
+				Checked: 3,
+				Lines: []models.ReconciliationLine{
+					{ProductId: 3, ProductStock: 0, WarehouseStock: 30, ExpectedStock: 30, Cause: models.CauseProductBehind, Corrected: true},
+					{ProductId: 2, Cause: models.CauseUnchecked, Error: "database is locked"},
+				},
+				Error: "failed to fetch products after 3: connection refused",
+			}, nil)
+
+		cron.NewAutoSyncStockJob(mockService).Run(context.Background())
+	})
+
+	t.Run("should skip the run when pending orders cannot be read", func(t *testing.T) {
+		mockService := mocks.NewMockReconciliationService(gomock.NewController(t))
+		mockService.EXPECT().
+			Reconcile(gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("connection refused"))
+
+		cron.NewAutoSyncStockJob(mockService).Run(context.Background())
+	})
+}
+
+func TestPushStockTotals(t *testing.T) {
+	t.Run("should push the changed totals", func(t *testing.T) {
+		mockService := mocks.NewMockStockSyncService(gomock.NewController(t))
+		mockService.EXPECT().
+			PushChangedTotals(gomock.Any()).
+			Return(&models.StockPush{Pushed: 2, Missing: []int64{9}}, nil)
+
+		cron.NewPushStockTotalsJob(mockService).Run(context.Background())
+	})
+
+	t.Run("should leave failed totals for the next run", func(t *testing.T) {
+		mockService := mocks.NewMockStockSyncService(gomock.NewController(t))
+		mockService.EXPECT().
+			PushChangedTotals(gomock.Any()).
+			Return(&models.StockPush{}, errors.New("connection refused"))
+
+		cron.NewPushStockTotalsJob(mockService).Run(context.Background())
+	})
+}
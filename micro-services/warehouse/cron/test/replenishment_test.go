@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/cron"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+type recordingNotifier struct {
+	events []models.LowStockEvent
+	err    error
+}
+
+func (n *recordingNotifier) NotifyLowStock(ctx context.Context, event models.LowStockEvent) error {
+	n.events = append(n.events, event)
+	return n.err
+}
+
+func TestReplenishment(t *testing.T) {
+	t.Run("should notify every product that became low", func(t *testing.T) {
+		mockService := mocks.NewMockReplenishmentService(gomock.NewController(t))
+		mockService.EXPECT().
+			Replenish(gomock.Any()).
+			Return(&models.ReplenishmentRun{LowStock: []models.LowStockEvent{{ProductId: 1}, {ProductId: 2}}}, nil)
+
+		// a failed notification does not stop the others
+		notifier := &recordingNotifier{err: errors.New("webhook down")}
+		cron.NewReplenishmentJob(mockService, notifier).Run(context.Background())
+
+		assert.Len(t, notifier.events, 2)
+	})
+
+	t.Run("should not notify when the run fails", func(t *testing.T) {
+		mockService := mocks.NewMockReplenishmentService(gomock.NewController(t))
+		mockService.EXPECT().
+			Replenish(gomock.Any()).
+			Return(nil, errors.New("database is locked"))
+
+		notifier := &recordingNotifier{}
+		cron.NewReplenishmentJob(mockService, notifier).Run(context.Background())
+
+		assert.Empty(t, notifier.events)
+	})
+}
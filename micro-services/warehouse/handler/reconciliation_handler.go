@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ReconcileRequest struct {
+	Apply      bool    `json:"apply"`
+	ProductIds []int64 `json:"product_ids"`
+}
+
+type ReconciliationHandler struct {
+	ReconciliationService service.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService service.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{ReconciliationService: reconciliationService}
+}
+
+func (h *ReconciliationHandler) Reconcile(c echo.Context) error {
+	var req ReconcileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	options := models.ReconcileOptions{Apply: req.Apply, ProductIds: req.ProductIds}
+	reconciliation, err := h.ReconciliationService.Reconcile(c.Request().Context(), options)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, reconciliation)
+}
+
+func (h *ReconciliationHandler) ListReconciliations(c echo.Context) error {
+	limit, err := int64Param(c, "limit")
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	reconciliations, err := h.ReconciliationService.ListReconciliations(c.Request().Context(), int(limit))
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, reconciliations)
+}
+
+func (h *ReconciliationHandler) GetReconciliation(c echo.Context) error {
+	reconciliationId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid reconciliation Id"})
+	}
+
+	reconciliation, err := h.ReconciliationService.GetReconciliation(c.Request().Context(), reconciliationId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, reconciliation)
+}
+
+func RegisterReconciliationRoutes(e *echo.Echo, reconciliationService service.ReconciliationService) {
+	handler := NewReconciliationHandler(reconciliationService)
+	e.POST("/warehouse/stock/reconciliations", handler.Reconcile)
+	e.GET("/warehouse/stock/reconciliations", handler.ListReconciliations)
+	e.GET("/warehouse/stock/reconciliations/:id", handler.GetReconciliation)
+}
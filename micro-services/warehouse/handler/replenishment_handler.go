@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ThresholdRequest struct {
+	ProductId    int64 `json:"product_id"`
+	WarehouseId  int64 `json:"warehouse_id"`
+	ReorderPoint int   `json:"reorder_point"`
+	SafetyStock  int   `json:"safety_stock"`
+}
+
+type ReplenishmentHandler struct {
+	ReplenishmentService service.ReplenishmentService
+}
+
+func NewReplenishmentHandler(replenishmentService service.ReplenishmentService) *ReplenishmentHandler {
+	return &ReplenishmentHandler{ReplenishmentService: replenishmentService}
+}
+
+func (h *ReplenishmentHandler) SetThreshold(c echo.Context) error {
+	var req ThresholdRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	threshold, err := h.ReplenishmentService.SetThreshold(c.Request().Context(), models.StockThreshold{
+		ProductId:    req.ProductId,
+		WarehouseId:  req.WarehouseId,
+		ReorderPoint: req.ReorderPoint,
+		SafetyStock:  req.SafetyStock,
+	})
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, threshold)
+}
+
+func (h *ReplenishmentHandler) ListThresholds(c echo.Context) error {
+	var filter models.ThresholdFilter
+
+	var err error
+	if filter.ProductId, err = int64Param(c, "product_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	thresholds, err := h.ReplenishmentService.ListThresholds(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, thresholds)
+}
+
+func (h *ReplenishmentHandler) ListSuggestions(c echo.Context) error {
+	filter := models.SuggestionFilter{Kind: c.QueryParam("kind")}
+
+	var err error
+	if filter.ProductId, err = int64Param(c, "product_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	suggestions, err := h.ReplenishmentService.ListSuggestions(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, suggestions)
+}
+
+func RegisterReplenishmentRoutes(e *echo.Echo, replenishmentService service.ReplenishmentService) {
+	handler := NewReplenishmentHandler(replenishmentService)
+	e.PUT("/warehouse/stock/thresholds", handler.SetThreshold)
+	e.GET("/warehouse/stock/thresholds", handler.ListThresholds)
+	e.GET("/warehouse/replenishment", handler.ListSuggestions)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxImportBytes is the largest stock import accepted, multipart framing
+// included.
+const maxImportBytes = 5 << 20
+
+type StockImportHandler struct {
+	StockImportService service.StockImportService
+}
+
+func NewStockImportHandler(stockImportService service.StockImportService) *StockImportHandler {
+	return &StockImportHandler{StockImportService: stockImportService}
+}
+
+// ImportStock takes the CSV as the request body or as the file field of a
+// multipart form. A body over maxImportBytes is refused before it is parsed,
+// by its declared length or once that much was read. An import with invalid
+// rows is answered with 422 and the row errors, and nothing is applied.
+func (h *StockImportHandler) ImportStock(c echo.Context) error {
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return apperror.JSON(c, http.StatusBadRequest, &apperror.InvalidInputError{Field: "dry_run", Reason: "must be true or false"})
+		}
+	}
+
+	req := c.Request()
+	if req.ContentLength > maxImportBytes {
+		return importTooLarge(c)
+	}
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxImportBytes)
+	var file io.Reader = req.Body
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return importError(c, err, "Invalid request, expected a file field")
+		}
+		opened, err := header.Open()
+		if err != nil {
+			return apperror.JSON(c, http.StatusInternalServerError, err)
+		}
+		defer opened.Close()
+		file = opened
+	}
+
+	result, err := h.StockImportService.ImportStock(req.Context(), file, dryRun)
+	if err != nil {
+		return importError(c, err, "")
+	}
+	if !dryRun && !result.Applied {
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func importError(c echo.Context, err error, message string) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return importTooLarge(c)
+	}
+	if message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+	return apperror.JSON(c, http.StatusInternalServerError, err)
+}
+
+func importTooLarge(c echo.Context) error {
+	return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Import must not be larger than 5 MB"})
+}
+
+// ExportStock streams the stock as CSV. The response is written while the
+// rows are read, so an error after the first row only ends it early.
+func (h *StockImportHandler) ExportStock(c echo.Context) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="stock.csv"`)
+
+	err := h.StockImportService.ExportStock(c.Request().Context(), res)
+	if err != nil {
+		if !res.Committed {
+			res.Header().Del(echo.HeaderContentDisposition)
+			return apperror.JSON(c, http.StatusInternalServerError, err)
+		}
+		c.Logger().Errorf("stock export stopped early: %v", err)
+	}
+
+	return nil
+}
+
+func RegisterStockImportRoutes(e *echo.Echo, stockImportService service.StockImportService) {
+	handler := NewStockImportHandler(stockImportService)
+	e.POST("/warehouse/stock/import", handler.ImportStock)
+	e.GET("/warehouse/stock/export", handler.ExportStock)
+}
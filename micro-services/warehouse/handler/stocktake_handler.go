@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OpenStocktakeRequest struct {
+	WarehouseId     int64 `json:"warehouse_id"`
+	FreezeMovements bool  `json:"freeze_movements"`
+}
+
+type RecordCountsRequest struct {
+	Counts []models.StocktakeCount `json:"counts"`
+}
+
+type ApproveStocktakeRequest struct {
+	Reason string `json:"reason"`
+}
+
+type StocktakeHandler struct {
+	StocktakeService service.StocktakeService
+}
+
+func NewStocktakeHandler(stocktakeService service.StocktakeService) *StocktakeHandler {
+	return &StocktakeHandler{StocktakeService: stocktakeService}
+}
+
+func (h *StocktakeHandler) OpenStocktake(c echo.Context) error {
+	var req OpenStocktakeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	stocktake, err := h.StocktakeService.OpenStocktake(c.Request().Context(), req.WarehouseId, req.FreezeMovements)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, stocktake)
+}
+
+func (h *StocktakeHandler) ListStocktakes(c echo.Context) error {
+	filter := models.StocktakeFilter{Status: c.QueryParam("status")}
+
+	var err error
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	stocktakes, err := h.StocktakeService.ListStocktakes(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocktakes)
+}
+
+func (h *StocktakeHandler) GetStocktake(c echo.Context) error {
+	stocktakeId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid stocktake Id"})
+	}
+
+	stocktake, err := h.StocktakeService.GetStocktake(c.Request().Context(), stocktakeId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocktake)
+}
+
+func (h *StocktakeHandler) RecordCounts(c echo.Context) error {
+	stocktakeId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid stocktake Id"})
+	}
+
+	var req RecordCountsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	stocktake, err := h.StocktakeService.RecordCounts(c.Request().Context(), stocktakeId, req.Counts)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocktake)
+}
+
+func (h *StocktakeHandler) ApproveStocktake(c echo.Context) error {
+	stocktakeId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid stocktake Id"})
+	}
+
+	var req ApproveStocktakeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	stocktake, err := h.StocktakeService.ApproveStocktake(c.Request().Context(), stocktakeId, req.Reason)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocktake)
+}
+
+func (h *StocktakeHandler) CancelStocktake(c echo.Context) error {
+	stocktakeId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid stocktake Id"})
+	}
+
+	stocktake, err := h.StocktakeService.CancelStocktake(c.Request().Context(), stocktakeId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocktake)
+}
+
+func RegisterStocktakeRoutes(e *echo.Echo, stocktakeService service.StocktakeService) {
+	handler := NewStocktakeHandler(stocktakeService)
+	e.POST("/warehouse/stocktakes", handler.OpenStocktake)
+	e.GET("/warehouse/stocktakes", handler.ListStocktakes)
+	e.GET("/warehouse/stocktakes/:id", handler.GetStocktake)
+	e.POST("/warehouse/stocktakes/:id/counts", handler.RecordCounts)
+	e.POST("/warehouse/stocktakes/:id/approve", handler.ApproveStocktake)
+	e.POST("/warehouse/stocktakes/:id/cancel", handler.CancelStocktake)
+}
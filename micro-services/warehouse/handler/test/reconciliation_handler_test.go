@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monorepo-ecommerce/micro-services/warehouse/handler"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReconciliationService := mocks.NewMockReconciliationService(ctrl)
+	h := handler.NewReconciliationHandler(mockReconciliationService)
+	e := echo.New()
+
+	newContext := func(body handler.ReconcileRequest) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/reconciliations", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("should reconcile and return the run", func(t *testing.T) {
+		c, rec := newContext(handler.ReconcileRequest{Apply: true, ProductIds: []int64{3}})
+
+		mockReconciliationService.EXPECT().
+			Reconcile(gomock.Any(), models.ReconcileOptions{Apply: true, ProductIds: []int64{3}}).
+			Return(&models.Reconciliation{
+				Id:    1,
+				Apply: true,
+				Lines: []models.ReconciliationLine{{ProductId: 3, ProductStock: 12, WarehouseStock: 30, ExpectedStock: 30, Cause: models.CauseProductBehind, Corrected: true}},
+			}, nil)
+
+		err := h.Reconcile(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"cause":"product_behind"`)
+	})
+
+	t.Run("should return 400 for an invalid product id", func(t *testing.T) {
+		c, rec := newContext(handler.ReconcileRequest{ProductIds: []int64{-1}})
+
+		mockReconciliationService.EXPECT().
+			Reconcile(gomock.Any(), gomock.Any()).
+			Return(nil, &apperror.InvalidInputError{Field: "product_ids", Reason: "must be positive"})
+
+		err := h.Reconcile(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetReconciliation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReconciliationService := mocks.NewMockReconciliationService(ctrl)
+	h := handler.NewReconciliationHandler(mockReconciliationService)
+	e := echo.New()
+
+	newContext := func(id string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/reconciliations/"+id, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("should return 400 for an invalid id", func(t *testing.T) {
+		c, rec := newContext("abc")
+
+		err := h.GetReconciliation(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should return 404 for an unknown run", func(t *testing.T) {
+		c, rec := newContext("9")
+
+		mockReconciliationService.EXPECT().
+			GetReconciliation(gomock.Any(), int64(9)).
+			Return(nil, &apperror.NotFoundError{Resource: "reconciliation", Id: 9})
+
+		err := h.GetReconciliation(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monorepo-ecommerce/micro-services/warehouse/handler"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSetThreshold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReplenishmentService := mocks.NewMockReplenishmentService(ctrl)
+	h := handler.NewReplenishmentHandler(mockReplenishmentService)
+	e := echo.New()
+
+	newContext := func(body handler.ThresholdRequest) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPut, "/warehouse/stock/thresholds", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("should save the threshold", func(t *testing.T) {
+		c, rec := newContext(handler.ThresholdRequest{ProductId: 1, WarehouseId: 2, ReorderPoint: 10, SafetyStock: 5})
+
+		threshold := models.StockThreshold{ProductId: 1, WarehouseId: 2, ReorderPoint: 10, SafetyStock: 5}
+		mockReplenishmentService.EXPECT().
+			SetThreshold(gomock.Any(), threshold).
+			Return(&threshold, nil)
+
+		err := h.SetThreshold(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should return 400 for an invalid threshold", func(t *testing.T) {
+		c, rec := newContext(handler.ThresholdRequest{ProductId: 1, WarehouseId: 2, ReorderPoint: -1})
+
+		mockReplenishmentService.EXPECT().
+			SetThreshold(gomock.Any(), gomock.Any()).
+			Return(nil, &apperror.InvalidInputError{Field: "reorder_point", Reason: "must not be negative"})
+
+		err := h.SetThreshold(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestListSuggestions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockReplenishmentService := mocks.NewMockReplenishmentService(ctrl)
+	h := handler.NewReplenishmentHandler(mockReplenishmentService)
+	e := echo.New()
+
+	t.Run("should filter suggestions by query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/replenishment?product_id=2&kind=transfer", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockReplenishmentService.EXPECT().
+			ListSuggestions(gomock.Any(), models.SuggestionFilter{ProductId: 2, Kind: models.SuggestTransfer}).
+			Return([]models.ReplenishmentSuggestion{{ProductId: 2, WarehouseId: 1, Kind: models.SuggestTransfer, Quantity: 5}}, nil)
+
+		err := h.ListSuggestions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should return 400 for an invalid warehouse id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/replenishment?warehouse_id=abc", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ListSuggestions(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"monorepo-ecommerce/micro-services/warehouse/handler"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestImportStock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockImportService := mocks.NewMockStockImportService(ctrl)
+	h := handler.NewStockImportHandler(mockStockImportService)
+	e := echo.New()
+
+	const file = "warehouse,product,quantity,mode\n1,1,30,set\n"
+	readsFile := func(ctx context.Context, r io.Reader, dryRun bool) {
+		body, _ := io.ReadAll(r)
+		assert.Equal(t, file, string(body))
+	}
+
+	t.Run("should preview a dry run of the CSV body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import?dry_run=true", strings.NewReader(file))
+		req.Header.Set(echo.HeaderContentType, "text/csv")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStockImportService.EXPECT().
+			ImportStock(gomock.Any(), gomock.Any(), true).
+			Do(readsFile).
+			Return(&models.StockImport{DryRun: true, Rows: []models.StockImportRow{{Line: 2, Previous: 25, New: 30}}}, nil)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"new":30`)
+	})
+
+	t.Run("should read the file field of a multipart form", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "stock.csv")
+		part.Write([]byte(file))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStockImportService.EXPECT().
+			ImportStock(gomock.Any(), gomock.Any(), false).
+			Do(readsFile).
+			Return(&models.StockImport{Applied: true}, nil)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should return 422 with the row errors when nothing was applied", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import", strings.NewReader(file))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStockImportService.EXPECT().
+			ImportStock(gomock.Any(), gomock.Any(), false).
+			Return(&models.StockImport{Invalid: 1, Rows: []models.StockImportRow{{Line: 2, Errors: []string{"product 9 does not exist"}}}}, nil)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "product 9 does not exist")
+	})
+
+	t.Run("should return 400 for an invalid dry_run", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import?dry_run=maybe", strings.NewReader(file))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should return 413 for a body over 5 MB", func(t *testing.T) {
+		large := strings.Repeat("1,1,30,set\n", 500000)
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import", strings.NewReader(file+large))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("should return 413 for a multipart form over 5 MB without a length", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "stock.csv")
+		part.Write([]byte(file + strings.Repeat("1,1,30,set\n", 500000)))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/import", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		req.ContentLength = -1
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ImportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestExportStock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockImportService := mocks.NewMockStockImportService(ctrl)
+	h := handler.NewStockImportHandler(mockStockImportService)
+	e := echo.New()
+
+	t.Run("should stream the stock as CSV", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/export", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStockImportService.EXPECT().
+			ExportStock(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, w io.Writer) error {
+				_, err := io.WriteString(w, "warehouse,warehouse_name,product,quantity,mode\n1,Warehouse A,1,25,set\n")
+				return err
+			})
+
+		err := h.ExportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Body.String(), "1,Warehouse A,1,25,set")
+	})
+
+	t.Run("should return 500 when the export fails before the first row", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/export", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStockImportService.EXPECT().
+			ExportStock(gomock.Any(), gomock.Any()).
+			Return(errors.New("database is locked"))
+
+		err := h.ExportStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monorepo-ecommerce/micro-services/warehouse/handler"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestOpenStocktake(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStocktakeService := mocks.NewMockStocktakeService(ctrl)
+	h := handler.NewStocktakeHandler(mockStocktakeService)
+	e := echo.New()
+
+	t.Run("should open a frozen stocktake", func(t *testing.T) {
+		reqJSON, _ := json.Marshal(handler.OpenStocktakeRequest{WarehouseId: 1, FreezeMovements: true})
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stocktakes", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockStocktakeService.EXPECT().
+			OpenStocktake(gomock.Any(), int64(1), true).
+			Return(&models.Stocktake{Id: 1, WarehouseId: 1, Status: models.StocktakeOpen, FreezeMovements: true}, nil)
+
+		err := h.OpenStocktake(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
+
+func TestApproveStocktake(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStocktakeService := mocks.NewMockStocktakeService(ctrl)
+	h := handler.NewStocktakeHandler(mockStocktakeService)
+	e := echo.New()
+
+	newContext := func(id string, body handler.ApproveStocktakeRequest) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stocktakes/"+id+"/approve", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("should approve the stocktake", func(t *testing.T) {
+		c, rec := newContext("1", handler.ApproveStocktakeRequest{Reason: "cycle count"})
+
+		mockStocktakeService.EXPECT().
+			ApproveStocktake(gomock.Any(), int64(1), "cycle count").
+			Return(&models.Stocktake{Id: 1, Status: models.StocktakeApproved}, nil)
+
+		err := h.ApproveStocktake(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should return 409 when the stocktake is closed", func(t *testing.T) {
+		c, rec := newContext("1", handler.ApproveStocktakeRequest{Reason: "cycle count"})
+
+		mockStocktakeService.EXPECT().
+			ApproveStocktake(gomock.Any(), int64(1), "cycle count").
+			Return(nil, &apperror.ConflictError{Resource: "stocktake", Reason: "stocktake 1 is approved"})
+
+		err := h.ApproveStocktake(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("should return 400 for an invalid id", func(t *testing.T) {
+		c, rec := newContext("abc", handler.ApproveStocktakeRequest{Reason: "cycle count"})
+
+		err := h.ApproveStocktake(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monorepo-ecommerce/micro-services/warehouse/handler"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequestTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockTransferService := mocks.NewMockTransferService(ctrl)
+	h := handler.NewTransferHandler(mockTransferService)
+	e := echo.New()
+
+	t.Run("should create a requested transfer", func(t *testing.T) {
+		reqBody := handler.TransferRequest{ProductId: 1, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 10}
+		reqJSON, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/transfers", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockTransferService.EXPECT().
+			RequestTransfer(gomock.Any(), int64(1), int64(1), int64(2), 10).
+			Return(&models.TransferOrder{Id: 3, Status: models.TransferRequested}, nil)
+
+		err := h.RequestTransfer(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
+
+func TestReceiveTransfer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockTransferService := mocks.NewMockTransferService(ctrl)
+	h := handler.NewTransferHandler(mockTransferService)
+	e := echo.New()
+
+	newContext := func(id string, body handler.ReceiveTransferRequest) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/transfers/"+id+"/receive", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("should receive part of a transfer", func(t *testing.T) {
+		c, rec := newContext("3", handler.ReceiveTransferRequest{Quantity: 4})
+
+		mockTransferService.EXPECT().
+			ReceiveTransfer(gomock.Any(), int64(3), 4, false).
+			Return(&models.TransferOrder{Id: 3, Quantity: 10, ReceivedQuantity: 4, Status: models.TransferInTransit}, nil)
+
+		err := h.ReceiveTransfer(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should conflict on a transfer in the wrong state", func(t *testing.T) {
+		c, rec := newContext("3", handler.ReceiveTransferRequest{Quantity: 4})
+
+		mockTransferService.EXPECT().
+			ReceiveTransfer(gomock.Any(), int64(3), 4, false).
+			Return(nil, &apperror.ConflictError{Resource: "transfer", Reason: "transfer 3 is requested and cannot be received"})
+
+		err := h.ReceiveTransfer(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("should bad request when the id is not a number", func(t *testing.T) {
+		c, rec := newContext("abc", handler.ReceiveTransferRequest{Quantity: 4})
+
+		err := h.ReceiveTransfer(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
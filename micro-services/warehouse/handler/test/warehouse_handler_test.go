@@ -2,13 +2,18 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"monorepo-ecommerce/micro-services/warehouse/handler"
 	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/service"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -37,7 +42,7 @@ func TestAddStock(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			AddStock(reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
+			AddStock(gomock.Any(), reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
 			Return(nil)
 
 		err := h.AddStock(c)
@@ -79,7 +84,7 @@ func TestAddStock(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			AddStock(reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
+			AddStock(gomock.Any(), reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		err := h.AddStock(c)
@@ -111,7 +116,7 @@ func TestRemoveStock(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			RemoveStock(reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
+			RemoveStock(gomock.Any(), reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
 			Return(nil)
 
 		err := h.RemoveStock(c)
@@ -153,7 +158,7 @@ func TestRemoveStock(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			RemoveStock(reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
+			RemoveStock(gomock.Any(), reqBody.ProductId, reqBody.WarehouseId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		err := h.RemoveStock(c)
@@ -186,7 +191,7 @@ func TestTransferProduct(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			TransferProduct(reqBody.ProductId, reqBody.OriginWarehouseId, reqBody.DestinationWarehouseId, reqBody.Quantity).
+			TransferProduct(gomock.Any(), reqBody.ProductId, reqBody.OriginWarehouseId, reqBody.DestinationWarehouseId, reqBody.Quantity).
 			Return(nil)
 
 		err := h.TransferProduct(c)
@@ -229,7 +234,7 @@ func TestTransferProduct(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			TransferProduct(reqBody.ProductId, reqBody.OriginWarehouseId, reqBody.DestinationWarehouseId, reqBody.Quantity).
+			TransferProduct(gomock.Any(), reqBody.ProductId, reqBody.OriginWarehouseId, reqBody.DestinationWarehouseId, reqBody.Quantity).
 			Return(errors.New("failed"))
 
 		err := h.TransferProduct(c)
@@ -259,7 +264,7 @@ func TestActiveDeactiveWarehouse(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			ActiveDeactiveWarehouseStatus(reqBody.WarehouseId).
+			ActiveDeactiveWarehouseStatus(gomock.Any(), reqBody.WarehouseId).
 			Return(nil)
 
 		err := h.ActiveDeactiveWarehouse(c)
@@ -299,7 +304,7 @@ func TestActiveDeactiveWarehouse(t *testing.T) {
 		c := e.NewContext(req, rec)
 
 		mockWarehouseService.EXPECT().
-			ActiveDeactiveWarehouseStatus(reqBody.WarehouseId).
+			ActiveDeactiveWarehouseStatus(gomock.Any(), reqBody.WarehouseId).
 			Return(errors.New("failed"))
 
 		err := h.ActiveDeactiveWarehouse(c)
@@ -308,3 +313,277 @@ func TestActiveDeactiveWarehouse(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestUpdateWarehouse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockWarehouseService := mocks.NewMockWarehouseService(ctrl)
+	h := handler.NewWarehouseHandler(mockWarehouseService)
+	e := echo.New()
+
+	t.Run("should update the warehouse in the path", func(t *testing.T) {
+		latitude, longitude, capacity := -6.2, 106.8, 500
+		reqBody := handler.WarehouseDetailsRequest{
+			Name:      "Warehouse A",
+			Latitude:  &latitude,
+			Longitude: &longitude,
+			Capacity:  &capacity,
+		}
+
+		reqJSON, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPut, "/warehouses/1", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockWarehouseService.EXPECT().
+			UpdateWarehouse(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, warehouse models.Warehouse) (*models.Warehouse, error) {
+				assert.Equal(t, int64(1), warehouse.Id)
+				assert.Equal(t, latitude, warehouse.Location.Latitude)
+				assert.Equal(t, capacity, *warehouse.Capacity)
+				return &warehouse, nil
+			})
+
+		err := h.UpdateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should bad request when only one coordinate is given", func(t *testing.T) {
+		latitude := -6.2
+		reqJSON, _ := json.Marshal(handler.WarehouseDetailsRequest{Name: "Warehouse A", Latitude: &latitude})
+
+		req := httptest.NewRequest(http.MethodPut, "/warehouses/1", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		err := h.UpdateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should bad request when the id is not a number", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/warehouses/abc", bytes.NewBufferString("{}"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("abc")
+
+		err := h.UpdateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestDeactivateWarehouse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockWarehouseService := mocks.NewMockWarehouseService(ctrl)
+	h := handler.NewWarehouseHandler(mockWarehouseService)
+	e := echo.New()
+
+	t.Run("should pass the options through", func(t *testing.T) {
+		reqJSON, _ := json.Marshal(handler.DeactivateWarehouseRequest{TransferStock: true, AllocationPolicy: "drain"})
+
+		req := httptest.NewRequest(http.MethodPost, "/warehouses/1/deactivate", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockWarehouseService.EXPECT().
+			DeactivateWarehouse(gomock.Any(), int64(1), service.DeactivateOptions{TransferStock: true, AllocationPolicy: "drain"}).
+			Return(&service.Deactivation{Warehouse: &models.Warehouse{Id: 1, Status: "inactive"}}, nil)
+
+		err := h.DeactivateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should deactivate without a body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/warehouses/1/deactivate", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockWarehouseService.EXPECT().
+			DeactivateWarehouse(gomock.Any(), int64(1), service.DeactivateOptions{}).
+			Return(&service.Deactivation{Warehouse: &models.Warehouse{Id: 1, Status: "inactive"}}, nil)
+
+		err := h.DeactivateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should conflict when open allocations block it", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/warehouses/1/deactivate", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		mockWarehouseService.EXPECT().
+			DeactivateWarehouse(gomock.Any(), int64(1), gomock.Any()).
+			Return(nil, &apperror.ConflictError{Resource: "warehouse", Reason: "open allocations"})
+
+		err := h.DeactivateWarehouse(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestListMovements(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockWarehouseService := mocks.NewMockWarehouseService(ctrl)
+	h := handler.NewWarehouseHandler(mockWarehouseService)
+	e := echo.New()
+
+	t.Run("should pass the filters through", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/movements?product_id=1&warehouse_id=2&from=2024-05-01&to=2024-05-31", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		mockWarehouseService.EXPECT().
+			ListMovements(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error) {
+				assert.Equal(t, int64(1), filter.ProductId)
+				assert.Equal(t, int64(2), filter.WarehouseId)
+				assert.Equal(t, "2024-05-01", filter.From.Format(time.DateOnly))
+				// the whole last day is included
+				assert.Equal(t, "2024-06-01", filter.To.Format(time.DateOnly))
+				return []models.StockMovement{}, nil
+			})
+
+		err := h.ListMovements(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should bad request on an unreadable date", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/movements?from=yesterday", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := h.ListMovements(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestAddStockToLot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockWarehouseService := mocks.NewMockWarehouseService(ctrl)
+	h := handler.NewWarehouseHandler(mockWarehouseService)
+	e := echo.New()
+
+	newContext := func(body map[string]any) (echo.Context, *httptest.ResponseRecorder) {
+		reqJSON, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/warehouse/stock/add", bytes.NewBuffer(reqJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("should receive stock into the named lot", func(t *testing.T) {
+		c, rec := newContext(map[string]any{"product_id": 1, "warehouse_id": 2, "quantity": 10, "lot_number": "L1", "expiry_date": "2030-01-31"})
+
+		expiryDate := time.Date(2030, time.January, 31, 0, 0, 0, 0, time.UTC)
+		mockWarehouseService.EXPECT().
+			ReceiveLot(gomock.Any(), models.StockLot{ProductId: 1, WarehouseId: 2, LotNumber: "L1", ExpiryDate: &expiryDate}, 10).
+			Return(nil)
+
+		err := h.AddStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should bad request when the expiry date is not a date", func(t *testing.T) {
+		c, rec := newContext(map[string]any{"product_id": 1, "warehouse_id": 2, "quantity": 10, "lot_number": "L1", "expiry_date": "31/01/2030"})
+
+		err := h.AddStock(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestListExpiringLots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockWarehouseService := mocks.NewMockWarehouseService(ctrl)
+	h := handler.NewWarehouseHandler(mockWarehouseService)
+	e := echo.New()
+
+	newContext := func(query string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/warehouse/stock/lots/expiring?"+query, nil)
+		rec := httptest.NewRecorder()
+		return e.NewContext(req, rec), rec
+	}
+
+	t.Run("should list lots expiring within 30 days by default", func(t *testing.T) {
+		c, rec := newContext("warehouse_id=1")
+
+		mockWarehouseService.EXPECT().
+			ListExpiringLots(gomock.Any(), models.LotFilter{WarehouseId: 1}, 30).
+			Return([]models.StockLot{{Id: 1, LotNumber: "L1"}}, nil)
+
+		err := h.ListExpiringLots(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should bad request when days is not a number", func(t *testing.T) {
+		c, rec := newContext("days=soon")
+
+		err := h.ListExpiringLots(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "must be a number")
+	})
+
+	t.Run("should bad request when days is negative", func(t *testing.T) {
+		c, rec := newContext("days=-1")
+
+		err := h.ListExpiringLots(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "must not be negative")
+	})
+
+	t.Run("should take 0 days as expiring today", func(t *testing.T) {
+		c, rec := newContext("days=0")
+
+		mockWarehouseService.EXPECT().
+			ListExpiringLots(gomock.Any(), models.LotFilter{}, 0).
+			Return([]models.StockLot{}, nil)
+
+		err := h.ListExpiringLots(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type TransferRequest struct {
+	ProductId       int64 `json:"product_id"`
+	FromWarehouseId int64 `json:"from_warehouse_id"`
+	ToWarehouseId   int64 `json:"to_warehouse_id"`
+	Quantity        int   `json:"quantity"`
+}
+
+type ReceiveTransferRequest struct {
+	Quantity int `json:"quantity"`
+	// Final closes the transfer, writing off whatever did not arrive.
+	Final bool `json:"final"`
+}
+
+type TransferHandler struct {
+	TransferService service.TransferService
+}
+
+func NewTransferHandler(transferService service.TransferService) *TransferHandler {
+	return &TransferHandler{TransferService: transferService}
+}
+
+func (h *TransferHandler) RequestTransfer(c echo.Context) error {
+	var req TransferRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	transfer, err := h.TransferService.RequestTransfer(c.Request().Context(), req.ProductId, req.FromWarehouseId, req.ToWarehouseId, req.Quantity)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, transfer)
+}
+
+func (h *TransferHandler) ListTransfers(c echo.Context) error {
+	filter := models.TransferFilter{Status: c.QueryParam("status")}
+
+	var err error
+	if filter.ProductId, err = int64Param(c, "product_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	transfers, err := h.TransferService.ListTransfers(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, transfers)
+}
+
+func (h *TransferHandler) GetTransfer(c echo.Context) error {
+	transferId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid transfer Id"})
+	}
+
+	transfer, err := h.TransferService.GetTransfer(c.Request().Context(), transferId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+func (h *TransferHandler) DispatchTransfer(c echo.Context) error {
+	return h.act(c, h.TransferService.DispatchTransfer)
+}
+
+func (h *TransferHandler) MarkInTransit(c echo.Context) error {
+	return h.act(c, h.TransferService.MarkInTransit)
+}
+
+func (h *TransferHandler) CancelTransfer(c echo.Context) error {
+	return h.act(c, h.TransferService.CancelTransfer)
+}
+
+func (h *TransferHandler) ReceiveTransfer(c echo.Context) error {
+	transferId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid transfer Id"})
+	}
+
+	var req ReceiveTransferRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	transfer, err := h.TransferService.ReceiveTransfer(c.Request().Context(), transferId, req.Quantity, req.Final)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+func (h *TransferHandler) GetInTransitStock(c echo.Context) error {
+	stocks, err := h.TransferService.GetInTransitStock(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, stocks)
+}
+
+// act runs a state change that needs nothing but the transfer id.
+func (h *TransferHandler) act(c echo.Context, action func(ctx context.Context, transferId int64) (*models.TransferOrder, error)) error {
+	transferId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid transfer Id"})
+	}
+
+	transfer, err := action(c.Request().Context(), transferId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, transfer)
+}
+
+func RegisterTransferRoutes(e *echo.Echo, transferService service.TransferService) {
+	handler := NewTransferHandler(transferService)
+	e.POST("/warehouse/transfers", handler.RequestTransfer)
+	e.GET("/warehouse/transfers", handler.ListTransfers)
+	e.GET("/warehouse/transfers/in-transit", handler.GetInTransitStock)
+	e.GET("/warehouse/transfers/:id", handler.GetTransfer)
+	e.POST("/warehouse/transfers/:id/dispatch", handler.DispatchTransfer)
+	e.POST("/warehouse/transfers/:id/in-transit", handler.MarkInTransit)
+	e.POST("/warehouse/transfers/:id/receive", handler.ReceiveTransfer)
+	e.POST("/warehouse/transfers/:id/cancel", handler.CancelTransfer)
+}
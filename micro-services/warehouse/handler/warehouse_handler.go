@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"monorepo-ecommerce/micro-services/warehouse/models"
 	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -13,6 +17,35 @@ type WarehouseRequest struct {
 	Quantity    int   `json:"quantity"`
 }
 
+// AddStockRequest adds stock, optionally to a lot. Stock without a lot number
+// goes to the default lot.
+type AddStockRequest struct {
+	WarehouseRequest
+	LotNumber string `json:"lot_number"`
+	// ExpiryDate is YYYY-MM-DD, the last day the lot can be sold.
+	ExpiryDate string     `json:"expiry_date"`
+	ReceivedAt *time.Time `json:"received_at"`
+}
+
+func (r AddStockRequest) toLot() (models.StockLot, error) {
+	lot := models.StockLot{ProductId: r.ProductId, WarehouseId: r.WarehouseId, LotNumber: r.LotNumber}
+	if r.ReceivedAt != nil {
+		lot.ReceivedAt = *r.ReceivedAt
+	}
+	if r.ExpiryDate != "" {
+		expiryDate, err := time.Parse(time.DateOnly, r.ExpiryDate)
+		if err != nil {
+			return lot, &apperror.InvalidInputError{Field: "expiry_date", Reason: "must be YYYY-MM-DD"}
+		}
+		lot.ExpiryDate = &expiryDate
+	}
+	return lot, nil
+}
+
+type InitProductStockRequest struct {
+	ProductId int64 `json:"product_id"`
+}
+
 type TransferProductRequest struct {
 	OriginWarehouseId      int64 `json:"origin_warehouse_id"`
 	DestinationWarehouseId int64 `json:"destination_warehouse_id"`
@@ -25,8 +58,58 @@ type AvailabilityWarehouseRequest struct {
 }
 
 type ProceedOrderRequest struct {
-	OrderID int64                 `json:"order_id"`
-	Items   []ProductOrderDetails `json:"items"`
+	OrderID         int64                 `json:"order_id"`
+	Items           []ProductOrderDetails `json:"items"`
+	ShippingAddress *ShippingAddress      `json:"shipping_address"`
+	// Strategy overrides the configured allocation strategy.
+	Strategy string `json:"strategy"`
+}
+
+type ShippingAddress struct {
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// WarehouseDetailsRequest creates or updates a warehouse. Capacity left out
+// means no limit; status only applies on create.
+type WarehouseDetailsRequest struct {
+	Name           string   `json:"name"`
+	Address        string   `json:"address"`
+	Latitude       *float64 `json:"latitude"`
+	Longitude      *float64 `json:"longitude"`
+	Capacity       *int     `json:"capacity"`
+	Priority       int      `json:"priority"`
+	ContactName    string   `json:"contact_name"`
+	ContactPhone   string   `json:"contact_phone"`
+	OperatingHours string   `json:"operating_hours"`
+	Status         string   `json:"status"`
+}
+
+func (r WarehouseDetailsRequest) toWarehouse() (models.Warehouse, error) {
+	warehouse := models.Warehouse{
+		Name:           r.Name,
+		Status:         r.Status,
+		Priority:       r.Priority,
+		Address:        r.Address,
+		Capacity:       r.Capacity,
+		ContactName:    r.ContactName,
+		ContactPhone:   r.ContactPhone,
+		OperatingHours: r.OperatingHours,
+	}
+
+	if (r.Latitude == nil) != (r.Longitude == nil) {
+		return warehouse, &apperror.InvalidInputError{Field: "location", Reason: "latitude and longitude go together"}
+	}
+	if r.Latitude != nil {
+		warehouse.Location = &models.Location{Latitude: *r.Latitude, Longitude: *r.Longitude}
+	}
+
+	return warehouse, nil
+}
+
+type ReleaseOrderRequest struct {
+	OrderID int64 `json:"order_id"`
 }
 
 type ProductOrderDetails struct {
@@ -43,28 +126,51 @@ func NewWarehouseHandler(warehouseService service.WarehouseService) *WarehouseHa
 }
 
 func (h *WarehouseHandler) AddStock(c echo.Context) error {
-	var req WarehouseRequest
+	var req AddStockRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	err := h.WarehouseService.AddStock(req.ProductId, req.WarehouseId, req.Quantity)
+	var err error
+	if req.LotNumber == "" && req.ExpiryDate == "" && req.ReceivedAt == nil {
+		err = h.WarehouseService.AddStock(c.Request().Context(), req.ProductId, req.WarehouseId, req.Quantity)
+	} else {
+		lot, lotErr := req.toLot()
+		if lotErr != nil {
+			return apperror.JSON(c, http.StatusBadRequest, lotErr)
+		}
+		err = h.WarehouseService.ReceiveLot(c.Request().Context(), lot, req.Quantity)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Stock added successfully"})
 }
 
+func (h *WarehouseHandler) InitProductStock(c echo.Context) error {
+	var req InitProductStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	err := h.WarehouseService.InitProductStock(c.Request().Context(), req.ProductId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Stock rows created successfully"})
+}
+
 func (h *WarehouseHandler) RemoveStock(c echo.Context) error {
 	var req WarehouseRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	err := h.WarehouseService.RemoveStock(req.ProductId, req.WarehouseId, req.Quantity)
+	err := h.WarehouseService.RemoveStock(c.Request().Context(), req.ProductId, req.WarehouseId, req.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Stock removed successfully"})
@@ -76,9 +182,9 @@ func (h *WarehouseHandler) TransferProduct(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	err := h.WarehouseService.TransferProduct(req.ProductId, req.OriginWarehouseId, req.DestinationWarehouseId, req.Quantity)
+	err := h.WarehouseService.TransferProduct(c.Request().Context(), req.ProductId, req.OriginWarehouseId, req.DestinationWarehouseId, req.Quantity)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Product transfered successfully"})
@@ -90,12 +196,73 @@ func (h *WarehouseHandler) ActiveDeactiveWarehouse(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	err := h.WarehouseService.ActiveDeactiveWarehouseStatus(req.WarehouseId)
+	err := h.WarehouseService.ActiveDeactiveWarehouseStatus(c.Request().Context(), req.WarehouseId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Product transfered successfully"})
+	return c.JSON(http.StatusOK, map[string]string{"message": "Warehouse status updated successfully"})
+}
+
+type DeactivateWarehouseRequest struct {
+	// TransferStock moves the remaining stock to other active warehouses,
+	// TransferTo picks one instead of filling them by priority.
+	TransferStock bool  `json:"transfer_stock"`
+	TransferTo    int64 `json:"transfer_to"`
+	// AllocationPolicy is "block" (default) or "drain".
+	AllocationPolicy string `json:"allocation_policy"`
+}
+
+func (h *WarehouseHandler) ActivateWarehouse(c echo.Context) error {
+	warehouseId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid warehouse Id"})
+	}
+
+	warehouse, err := h.WarehouseService.ActivateWarehouse(c.Request().Context(), warehouseId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, warehouse)
+}
+
+func (h *WarehouseHandler) DeactivateWarehouse(c echo.Context) error {
+	warehouseId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid warehouse Id"})
+	}
+
+	// the body is optional, an empty one deactivates with the defaults
+	var req DeactivateWarehouseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	deactivation, err := h.WarehouseService.DeactivateWarehouse(c.Request().Context(), warehouseId, service.DeactivateOptions{
+		TransferStock:    req.TransferStock,
+		TransferTo:       req.TransferTo,
+		AllocationPolicy: req.AllocationPolicy,
+	})
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, deactivation)
+}
+
+func (h *WarehouseHandler) GetWarehouseEvents(c echo.Context) error {
+	warehouseId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid warehouse Id"})
+	}
+
+	events, err := h.WarehouseService.GetWarehouseEvents(c.Request().Context(), warehouseId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, events)
 }
 
 func (h *WarehouseHandler) ProceedOrder(c echo.Context) error {
@@ -111,19 +278,266 @@ func (h *WarehouseHandler) ProceedOrder(c echo.Context) error {
 			Quantity:  item.Quantity,
 		}
 	}
-	err := h.WarehouseService.ProceedOrder(req.OrderID, result)
+
+	// nearest needs coordinates, an address without them ships like any other
+	var destination *models.Location
+	if req.ShippingAddress != nil && req.ShippingAddress.Latitude != nil && req.ShippingAddress.Longitude != nil {
+		destination = &models.Location{Latitude: *req.ShippingAddress.Latitude, Longitude: *req.ShippingAddress.Longitude}
+	}
+
+	allocations, err := h.WarehouseService.ProceedOrder(c.Request().Context(), req.OrderID, result, destination, req.Strategy)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Order processed successfully", "allocations": allocations})
+}
+
+func (h *WarehouseHandler) ReleaseOrder(c echo.Context) error {
+	var req ReleaseOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	allocations, err := h.WarehouseService.ReleaseOrder(c.Request().Context(), req.OrderID)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"message": "Order stock released successfully", "allocations": allocations})
+}
+
+func (h *WarehouseHandler) CreateWarehouse(c echo.Context) error {
+	var req WarehouseDetailsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	warehouse, err := req.toWarehouse()
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	created, err := h.WarehouseService.CreateWarehouse(c.Request().Context(), warehouse)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, created)
+}
+
+func (h *WarehouseHandler) UpdateWarehouse(c echo.Context) error {
+	warehouseId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid warehouse Id"})
+	}
+
+	var req WarehouseDetailsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	warehouse, err := req.toWarehouse()
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	warehouse.Id = warehouseId
+
+	updated, err := h.WarehouseService.UpdateWarehouse(c.Request().Context(), warehouse)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, updated)
+}
+
+func (h *WarehouseHandler) GetWarehouse(c echo.Context) error {
+	warehouseId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid warehouse Id"})
+	}
+
+	warehouse, err := h.WarehouseService.GetWarehouse(c.Request().Context(), warehouseId)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, warehouse)
+}
+
+func (h *WarehouseHandler) ListWarehouses(c echo.Context) error {
+	warehouses, err := h.WarehouseService.ListWarehouses(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, warehouses)
+}
+
+// ListMovements serves the stock ledger, filtered by the product_id,
+// warehouse_id, from and to query parameters. Dates are RFC 3339 or
+// YYYY-MM-DD, a date for to includes that whole day.
+func (h *WarehouseHandler) ListMovements(c echo.Context) error {
+	var filter models.MovementFilter
+	var err error
+
+	if filter.ProductId, err = int64Param(c, "product_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.From, err = dateParam(c, "from", false); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	if filter.To, err = dateParam(c, "to", true); err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	limit, err := int64Param(c, "limit")
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+	filter.Limit = int(limit)
+
+	movements, err := h.WarehouseService.ListMovements(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, movements)
+}
+
+func (h *WarehouseHandler) ListLots(c echo.Context) error {
+	filter, err := lotFilter(c)
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	lots, err := h.WarehouseService.ListLots(c.Request().Context(), filter)
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, lots)
+}
+
+// ListExpiringLots lists lots expiring within ?days= days, 30 by default.
+func (h *WarehouseHandler) ListExpiringLots(c echo.Context) error {
+	filter, err := lotFilter(c)
+	if err != nil {
+		return apperror.JSON(c, http.StatusBadRequest, err)
+	}
+
+	days := defaultExpiringDays
+	if value := c.QueryParam("days"); value != "" {
+		if days, err = strconv.Atoi(value); err != nil {
+			return apperror.JSON(c, http.StatusBadRequest, &apperror.InvalidInputError{Field: "days", Reason: "must be a number"})
+		}
+		// 0 lists the lots expiring today
+		if days < 0 {
+			return apperror.JSON(c, http.StatusBadRequest, &apperror.InvalidInputError{Field: "days", Reason: "must not be negative"})
+		}
+	}
+
+	lots, err := h.WarehouseService.ListExpiringLots(c.Request().Context(), filter, days)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return apperror.JSON(c, http.StatusInternalServerError, err)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Order processed successfully"})
+	return c.JSON(http.StatusOK, lots)
+}
+
+const defaultExpiringDays = 30
+
+func lotFilter(c echo.Context) (models.LotFilter, error) {
+	var filter models.LotFilter
+	var err error
+	if filter.ProductId, err = int64Param(c, "product_id"); err != nil {
+		return filter, err
+	}
+	if filter.WarehouseId, err = int64Param(c, "warehouse_id"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func (h *WarehouseHandler) VerifyLedger(c echo.Context) error {
+	discrepancies, err := h.WarehouseService.VerifyLedger(c.Request().Context())
+	if err != nil {
+		return apperror.JSON(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"consistent": len(discrepancies) == 0, "discrepancies": discrepancies})
+}
+
+func int64Param(c echo.Context, name string) (int64, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, &apperror.InvalidInputError{Field: name, Reason: "must be a positive number"}
+	}
+	return parsed, nil
+}
+
+// dateParam parses an RFC 3339 time or a date. endOfDay moves a date to the
+// start of the next day, so an exclusive bound still covers it.
+func dateParam(c echo.Context, name string, endOfDay bool) (*time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, &apperror.InvalidInputError{Field: name, Reason: "must be RFC 3339 or YYYY-MM-DD"}
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return &parsed, nil
+}
+
+// actorHeader names who makes a request, recorded in the stock ledger.
+const actorHeader = "X-Actor"
+
+// ActorMiddleware puts the actor named by actorHeader on the request context.
+// It is registered once for every route of the service.
+func ActorMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if actor := c.Request().Header.Get(actorHeader); actor != "" {
+			req := c.Request()
+			c.SetRequest(req.WithContext(service.WithActor(req.Context(), actor)))
+		}
+		return next(c)
+	}
 }
 
 func RegisterWarehouseRoutes(e *echo.Echo, warehouseService service.WarehouseService) {
 	handler := NewWarehouseHandler(warehouseService)
 	e.POST("/warehouse/stock/add", handler.AddStock)
+	e.POST("/warehouse/stock/init", handler.InitProductStock)
 	e.POST("/warehouse/stock/remove", handler.RemoveStock)
 	e.POST("/warehouse/stock/transfer-product", handler.TransferProduct)
 	e.POST("/warehouse/stock/active-deactive", handler.ActiveDeactiveWarehouse)
 	e.POST("/warehouse/stock/proceed-order", handler.ProceedOrder)
+	e.POST("/warehouse/stock/release-order", handler.ReleaseOrder)
+	e.GET("/warehouse/stock/movements", handler.ListMovements)
+	e.GET("/warehouse/stock/movements/verify", handler.VerifyLedger)
+	e.GET("/warehouse/stock/lots", handler.ListLots)
+	e.GET("/warehouse/stock/lots/expiring", handler.ListExpiringLots)
+	e.POST("/warehouses", handler.CreateWarehouse)
+	e.GET("/warehouses", handler.ListWarehouses)
+	e.GET("/warehouses/:id", handler.GetWarehouse)
+	e.PUT("/warehouses/:id", handler.UpdateWarehouse)
+	e.POST("/warehouses/:id/activate", handler.ActivateWarehouse)
+	e.POST("/warehouses/:id/deactivate", handler.DeactivateWarehouse)
+	e.GET("/warehouses/:id/events", handler.GetWarehouseEvents)
 }
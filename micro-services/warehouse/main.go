@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"monorepo-ecommerce/micro-services/warehouse/config"
 	cj "monorepo-ecommerce/micro-services/warehouse/cron"
-	"monorepo-ecommerce/micro-services/warehouse/db"
 	"monorepo-ecommerce/micro-services/warehouse/handler"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	"monorepo-ecommerce/micro-services/warehouse/notifier"
 	"monorepo-ecommerce/micro-services/warehouse/repository"
 	"monorepo-ecommerce/micro-services/warehouse/service"
-	"net/http"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/httpclient"
+	"monorepo-ecommerce/pkg/lifecycle"
+	"monorepo-ecommerce/pkg/migration"
+	"monorepo-ecommerce/pkg/requestid"
+	"os"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -15,41 +25,114 @@ import (
 )
 
 func main() {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.Printf("Effective configuration: %s", configloader.Redact(cfg))
+
+	clientCfg := httpclient.DefaultConfig()
+	clientCfg.Timeout = cfg.UpstreamTimeout
+
+	runner := lifecycle.New(cfg.ShutdownTimeout)
+
 	// Initialize Database
-	dbConn := db.InitDatabase("./../../data/ecommerce.db")
-	db.RunMigrations(dbConn, "./migrations/init.sql")
-	defer dbConn.Close()
+	dbConn, err := database.Open(database.NewConfig(cfg.DatabaseDriver, cfg.DatabasePath, cfg.DatabaseURL))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Apply schema migrations, or only run the migrate subcommand
+	migrator := migration.New(dbConn.Writer(), "warehouse", migrations.For(dbConn.Dialect()))
+	migrator.Dialect = dbConn.Dialect()
+	if migration.IsCommand(os.Args) {
+		err := migration.RunCommand(context.Background(), migrator, os.Args[2:], os.Stdout)
+		dbConn.Close()
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	productClient := httpclient.New("product", cfg.ProductServiceURL, clientCfg)
+	productRepo := repository.NewProductRepository(productClient)
+	orderClient := httpclient.New("order", cfg.OrderServiceURL, clientCfg)
+	orderRepo := repository.NewOrderRepository(orderClient)
+	uow := repository.NewUnitOfWork(dbConn)
+	reconciliationService := service.NewReconciliationService(uow, productRepo, orderRepo, cfg.StockSyncBatchSize)
+
+	// Only run the reconcile subcommand
+	if isReconcileCommand(os.Args) {
+		err := runReconcileCommand(context.Background(), reconciliationService, os.Args[2:], os.Stdout)
+		dbConn.Close()
+		if err != nil {
+			log.Fatalf("Reconciliation failed: %v", err)
+		}
+		return
+	}
 
 	// Initialize Echo
 	e := echo.New()
 
 	// Use Middleware
+	e.Use(requestid.Middleware())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	e.Use(handler.ActorMiddleware)
+
+	// Endpoints for operators and the other services listen on the internal
+	// port, which is not published
+	internal := echo.New()
+	internal.HideBanner = true
+	internal.Use(requestid.Middleware())
+	internal.Use(middleware.Logger())
+	internal.Use(middleware.Recover())
+	internal.GET("/internal/db/stats", database.StatsHandler(dbConn))
 
 	// Initialize repository, service, and handler
 	warehouseRepo := repository.NewWarehouseRepository(dbConn)
 	stockRepo := repository.NewStockRepository(dbConn)
-	productRepo := repository.NewProductRepository("http://localhost:7002")
-	warehouseService := service.NewWarehouseService(warehouseRepo, stockRepo, productRepo)
+	warehouseService := service.NewWarehouseService(uow, warehouseRepo, stockRepo, productRepo, orderRepo, cfg.AllocationStrategy)
 	handler.RegisterWarehouseRoutes(e, warehouseService)
+	handler.RegisterStockImportRoutes(e, service.NewStockImportService(uow, warehouseRepo, stockRepo, productRepo, orderRepo))
+	handler.RegisterTransferRoutes(e, service.NewTransferService(uow, productRepo, orderRepo))
+	handler.RegisterStocktakeRoutes(e, service.NewStocktakeService(uow, productRepo, orderRepo))
+	replenishmentService := service.NewReplenishmentService(uow, cfg.ReplenishmentLookback, cfg.ReplenishmentCoverDays)
+	handler.RegisterReplenishmentRoutes(e, replenishmentService)
+	handler.RegisterReconciliationRoutes(e, reconciliationService)
+	lowStockNotifier, err := notifier.New(cfg.LowStockNotifier, cfg.LowStockWebhookURL, clientCfg)
+	if err != nil {
+		log.Fatalf("Failed to create low stock notifier: %v", err)
+	}
 
 	// Init cronjob
-	autoSyncStock := cj.NewAutoSyncStockJob(productRepo, stockRepo, warehouseRepo)
+	stockSyncService := service.NewStockSyncService(uow, productRepo, orderRepo, cfg.StockSyncBatchSize)
+	autoSyncStock := cj.NewAutoSyncStockJob(reconciliationService)
+	pushStockTotals := cj.NewPushStockTotalsJob(stockSyncService)
 	c := cron.New()
-	c.AddFunc("@every 2m", func() {
-		autoSyncStock.Run()
+	c.AddFunc(cfg.StockSyncSchedule, func() {
+		autoSyncStock.Run(runner.Context())
 	})
-	c.Start()
-
-	// Start server in goroutine
-	go func() {
-		log.Println("Starting server on port 7005...")
-		if err := e.Start(":7005"); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+	c.AddFunc(cfg.StockPushSchedule, func() {
+		pushStockTotals.Run(runner.Context())
+	})
+	replenishment := cj.NewReplenishmentJob(replenishmentService, lowStockNotifier)
+	c.AddFunc(cfg.ReplenishmentSchedule, func() {
+		replenishment.Run(runner.Context())
+	})
+	runner.Cron(c)
 
-	// Waiting program
-	select {}
+	// Run until SIGINT/SIGTERM, then drain and close the database last
+	runner.OnStop("database", func(ctx context.Context) error {
+		return dbConn.Close()
+	})
+	runner.HTTPServer(e, fmt.Sprintf(":%d", cfg.Port))
+	runner.HTTPServer(internal, fmt.Sprintf(":%d", cfg.InternalPort))
+	if err := runner.Run(context.Background()); err != nil {
+		log.Fatalf("Service stopped with error: %v", err)
+	}
 }
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT,
+    status TEXT
+);
+
+INSERT INTO warehouses (name, status)
+SELECT 'Warehouse A', 'active'
+WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE name = 'Warehouse A');
+
+INSERT INTO warehouses (name, status)
+SELECT 'Warehouse B', 'active'
+WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE name = 'Warehouse B');
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    -- product_id is owned by the product service and validated through its API
+    product_id BIGINT NOT NULL,
+    quantity INTEGER NOT NULL,
+    UNIQUE (warehouse_id, product_id)
+);
+
+-- Insert initial stock data for the products seeded by the product service
+-- (Product A = 1, Product B = 2, Product C = 3)
+INSERT INTO stocks (warehouse_id, product_id, quantity)
+SELECT w.id, s.product_id, s.quantity
+FROM warehouses w
+JOIN (VALUES
+    ('Warehouse A', 1, 25),
+    ('Warehouse B', 1, 25),
+    ('Warehouse A', 2, 5),
+    ('Warehouse B', 2, 15),
+    ('Warehouse A', 3, 10),
+    ('Warehouse B', 3, 20)
+) AS s (warehouse, product_id, quantity) ON s.warehouse = w.name
+ON CONFLICT (warehouse_id, product_id) DO NOTHING;
@@ -0,0 +1,21 @@
+-- lower priority values are allocated from first
+ALTER TABLE warehouses ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE warehouses ADD COLUMN latitude DOUBLE PRECISION;
+ALTER TABLE warehouses ADD COLUMN longitude DOUBLE PRECISION;
+
+UPDATE warehouses SET priority = 1, latitude = -6.2088, longitude = 106.8456 WHERE name = 'Warehouse A';
+UPDATE warehouses SET priority = 2, latitude = -7.2575, longitude = 112.7521 WHERE name = 'Warehouse B';
+
+CREATE TABLE IF NOT EXISTS stock_allocations (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    quantity INTEGER NOT NULL,
+    strategy TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'allocated',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_allocations_order ON stock_allocations (order_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_stock_allocations_order_line;
@@ -0,0 +1,10 @@
+ALTER TABLE warehouses ADD COLUMN address TEXT NOT NULL DEFAULT '';
+-- capacity is the number of units a warehouse can hold, NULL for no limit
+ALTER TABLE warehouses ADD COLUMN capacity INTEGER;
+ALTER TABLE warehouses ADD COLUMN contact_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE warehouses ADD COLUMN contact_phone TEXT NOT NULL DEFAULT '';
+-- opening and closing time as HH:MM-HH:MM, empty when unknown
+ALTER TABLE warehouses ADD COLUMN operating_hours TEXT NOT NULL DEFAULT '';
+
+UPDATE warehouses SET address = 'Jakarta', operating_hours = '08:00-17:00' WHERE name = 'Warehouse A';
+UPDATE warehouses SET address = 'Surabaya', operating_hours = '08:00-17:00' WHERE name = 'Warehouse B';
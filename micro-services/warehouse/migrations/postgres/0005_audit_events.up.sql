@@ -0,0 +1,13 @@
+-- who did what to a warehouse, kept for operators; detail is JSON
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    entity TEXT NOT NULL,
+    entity_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events (entity, entity_id);
+
+CREATE INDEX IF NOT EXISTS idx_stock_allocations_warehouse ON stock_allocations (warehouse_id, status);
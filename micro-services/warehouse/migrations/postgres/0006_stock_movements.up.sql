@@ -0,0 +1,20 @@
+-- append-only ledger; the quantities of a product in a warehouse add up to its stock
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    movement_type TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    reference_id TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_stock ON stock_movements (product_id, warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_created_at ON stock_movements (created_at);
+
+-- the stock held before the ledger existed becomes its opening balance
+INSERT INTO stock_movements (product_id, warehouse_id, movement_type, quantity, reference_id, actor)
+SELECT product_id, warehouse_id, 'adjustment', quantity, 'opening-balance', 'system'
+FROM stocks
+WHERE quantity <> 0;
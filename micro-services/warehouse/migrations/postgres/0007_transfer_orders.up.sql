@@ -0,0 +1,15 @@
+-- stock on its way between warehouses; quantity - received_quantity is in
+-- transit while the order is dispatched or in_transit
+CREATE TABLE IF NOT EXISTS transfer_orders (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    from_warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    to_warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    quantity INTEGER NOT NULL,
+    received_quantity INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'requested',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_orders_status ON transfer_orders (status);
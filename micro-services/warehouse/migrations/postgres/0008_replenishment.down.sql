@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_stock_allocations_created_at;
+DROP TABLE IF EXISTS replenishment_suggestions;
+DROP TABLE IF EXISTS stock_thresholds;
@@ -0,0 +1,30 @@
+-- reorder point and safety stock per product and warehouse; low_since is set
+-- while the stock position is at or below the reorder point
+CREATE TABLE IF NOT EXISTS stock_thresholds (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    reorder_point INTEGER NOT NULL,
+    safety_stock INTEGER NOT NULL DEFAULT 0,
+    low_since TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, warehouse_id)
+);
+
+-- suggestions of the latest replenishment run, replaced on every run
+CREATE TABLE IF NOT EXISTS replenishment_suggestions (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    from_warehouse_id BIGINT,
+    quantity INTEGER NOT NULL,
+    on_hand INTEGER NOT NULL,
+    in_transit INTEGER NOT NULL,
+    reorder_point INTEGER NOT NULL,
+    safety_stock INTEGER NOT NULL,
+    daily_velocity DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_allocations_created_at ON stock_allocations (created_at);
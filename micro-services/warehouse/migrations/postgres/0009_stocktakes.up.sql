@@ -0,0 +1,30 @@
+-- a count session for one warehouse; freeze_movements blocks stock changes
+-- of the warehouse while the session is open
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    status TEXT NOT NULL DEFAULT 'open',
+    freeze_movements BOOLEAN NOT NULL DEFAULT FALSE,
+    reason TEXT NOT NULL DEFAULT '',
+    opened_by TEXT NOT NULL DEFAULT '',
+    closed_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ
+);
+
+-- at most one open session per warehouse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes (warehouse_id) WHERE status = 'open';
+
+-- the counted quantity of a product next to the system quantity when it was counted
+CREATE TABLE IF NOT EXISTS stocktake_lines (
+    id BIGSERIAL PRIMARY KEY,
+    stocktake_id BIGINT NOT NULL REFERENCES stocktakes(id),
+    product_id BIGINT NOT NULL,
+    system_quantity INTEGER NOT NULL,
+    counted_quantity INTEGER NOT NULL,
+    counted_by TEXT NOT NULL DEFAULT '',
+    counted_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stocktake_id, product_id)
+);
+
+ALTER TABLE stock_movements ADD COLUMN reason TEXT NOT NULL DEFAULT '';
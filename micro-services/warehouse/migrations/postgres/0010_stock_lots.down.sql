@@ -0,0 +1,3 @@
+ALTER TABLE stock_movements DROP COLUMN lot_number;
+DROP INDEX IF EXISTS idx_stock_lots_expiry_date;
+DROP TABLE IF EXISTS stock_lots;
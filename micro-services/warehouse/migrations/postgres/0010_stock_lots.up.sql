@@ -0,0 +1,22 @@
+-- stock split by lot; the lots of a product in a warehouse add up to its stock
+-- row. Lot '' holds stock received without a lot number.
+CREATE TABLE IF NOT EXISTS stock_lots (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    lot_number TEXT NOT NULL DEFAULT '',
+    expiry_date DATE,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (product_id, warehouse_id, lot_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_lots_expiry_date ON stock_lots (expiry_date);
+
+-- the stock held before lots existed goes into the default lot
+INSERT INTO stock_lots (product_id, warehouse_id, lot_number, quantity)
+SELECT product_id, warehouse_id, '', quantity
+FROM stocks
+WHERE quantity <> 0;
+
+ALTER TABLE stock_movements ADD COLUMN lot_number TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- products whose total stock changed and is not yet confirmed by the product
+-- service; version goes up on every change, so a push only clears the change
+-- it read
+CREATE TABLE IF NOT EXISTS stock_total_outbox (
+    product_id BIGINT PRIMARY KEY,
+    version BIGINT NOT NULL DEFAULT 1,
+    changed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
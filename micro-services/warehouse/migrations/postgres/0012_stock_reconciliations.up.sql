@@ -0,0 +1,28 @@
+-- one comparison of product stock, warehouse stock and stock held by pending
+-- orders; apply tells whether discrepancies were corrected
+CREATE TABLE IF NOT EXISTS stock_reconciliations (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL DEFAULT '',
+    apply BOOLEAN NOT NULL DEFAULT FALSE,
+    checked INTEGER NOT NULL DEFAULT 0,
+    complete BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+
+-- a product whose stock did not add up, with the values at the time
+CREATE TABLE IF NOT EXISTS stock_reconciliation_lines (
+    id BIGSERIAL PRIMARY KEY,
+    reconciliation_id BIGINT NOT NULL REFERENCES stock_reconciliations(id),
+    product_id BIGINT NOT NULL,
+    product_stock INTEGER NOT NULL,
+    warehouse_stock INTEGER NOT NULL,
+    pending_stock INTEGER NOT NULL,
+    expected_stock INTEGER NOT NULL,
+    cause TEXT NOT NULL,
+    corrected BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reconciliation_lines_reconciliation_id ON stock_reconciliation_lines (reconciliation_id);
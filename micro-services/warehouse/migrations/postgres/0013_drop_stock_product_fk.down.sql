@@ -0,0 +1,2 @@
+-- there is no products table to point a foreign key at on PostgreSQL
+SELECT 1;
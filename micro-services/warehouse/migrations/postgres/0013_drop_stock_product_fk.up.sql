@@ -0,0 +1,2 @@
+-- stocks never referenced products on PostgreSQL; kept in step with SQLite.
+ALTER TABLE stocks DROP CONSTRAINT IF EXISTS stocks_product_id_fkey;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS stock_allocations;
+
+ALTER TABLE warehouses DROP COLUMN longitude;
+ALTER TABLE warehouses DROP COLUMN latitude;
+ALTER TABLE warehouses DROP COLUMN priority;
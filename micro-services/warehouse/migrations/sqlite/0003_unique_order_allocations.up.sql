@@ -0,0 +1,2 @@
+-- one row per order, product and warehouse, so a replayed order cannot be allocated twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_allocations_order_line ON stock_allocations (order_id, product_id, warehouse_id);
@@ -0,0 +1,5 @@
+ALTER TABLE warehouses DROP COLUMN operating_hours;
+ALTER TABLE warehouses DROP COLUMN contact_phone;
+ALTER TABLE warehouses DROP COLUMN contact_name;
+ALTER TABLE warehouses DROP COLUMN capacity;
+ALTER TABLE warehouses DROP COLUMN address;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_stock_allocations_warehouse;
+DROP TABLE IF EXISTS audit_events;
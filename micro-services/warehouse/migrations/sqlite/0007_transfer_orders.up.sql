@@ -0,0 +1,17 @@
+-- stock on its way between warehouses; quantity - received_quantity is in
+-- transit while the order is dispatched or in_transit
+CREATE TABLE IF NOT EXISTS transfer_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL,
+    from_warehouse_id INTEGER NOT NULL,
+    to_warehouse_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    received_quantity INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'requested',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (from_warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (to_warehouse_id) REFERENCES warehouses(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_orders_status ON transfer_orders (status);
@@ -0,0 +1,4 @@
+ALTER TABLE stock_movements DROP COLUMN reason;
+DROP TABLE IF EXISTS stocktake_lines;
+DROP INDEX IF EXISTS idx_stocktakes_open;
+DROP TABLE IF EXISTS stocktakes;
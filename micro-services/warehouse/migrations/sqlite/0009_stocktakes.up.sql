@@ -0,0 +1,32 @@
+-- a count session for one warehouse; freeze_movements blocks stock changes
+-- of the warehouse while the session is open
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    warehouse_id INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    freeze_movements BOOLEAN NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    opened_by TEXT NOT NULL DEFAULT '',
+    closed_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    closed_at DATETIME,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id)
+);
+
+-- at most one open session per warehouse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes (warehouse_id) WHERE status = 'open';
+
+-- the counted quantity of a product next to the system quantity when it was counted
+CREATE TABLE IF NOT EXISTS stocktake_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stocktake_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    system_quantity INTEGER NOT NULL,
+    counted_quantity INTEGER NOT NULL,
+    counted_by TEXT NOT NULL DEFAULT '',
+    counted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (stocktake_id) REFERENCES stocktakes(id),
+    UNIQUE (stocktake_id, product_id)
+);
+
+ALTER TABLE stock_movements ADD COLUMN reason TEXT NOT NULL DEFAULT '';
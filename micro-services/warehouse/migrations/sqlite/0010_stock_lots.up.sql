@@ -0,0 +1,23 @@
+-- stock split by lot; the lots of a product in a warehouse add up to its stock
+-- row. Lot '' holds stock received without a lot number.
+CREATE TABLE IF NOT EXISTS stock_lots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL,
+    warehouse_id INTEGER NOT NULL,
+    lot_number TEXT NOT NULL DEFAULT '',
+    expiry_date DATE,
+    received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    UNIQUE (product_id, warehouse_id, lot_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_lots_expiry_date ON stock_lots (expiry_date);
+
+-- the stock held before lots existed goes into the default lot
+INSERT INTO stock_lots (product_id, warehouse_id, lot_number, quantity)
+SELECT product_id, warehouse_id, '', quantity
+FROM stocks
+WHERE quantity <> 0;
+
+ALTER TABLE stock_movements ADD COLUMN lot_number TEXT NOT NULL DEFAULT '';
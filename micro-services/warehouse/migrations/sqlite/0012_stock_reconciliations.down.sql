@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_stock_reconciliation_lines_reconciliation_id;
+DROP TABLE IF EXISTS stock_reconciliation_lines;
+DROP TABLE IF EXISTS stock_reconciliations;
@@ -0,0 +1,29 @@
+-- one comparison of product stock, warehouse stock and stock held by pending
+-- orders; apply tells whether discrepancies were corrected
+CREATE TABLE IF NOT EXISTS stock_reconciliations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor TEXT NOT NULL DEFAULT '',
+    apply BOOLEAN NOT NULL DEFAULT 0,
+    checked INTEGER NOT NULL DEFAULT 0,
+    complete BOOLEAN NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL
+);
+
+-- a product whose stock did not add up, with the values at the time
+CREATE TABLE IF NOT EXISTS stock_reconciliation_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reconciliation_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    product_stock INTEGER NOT NULL,
+    warehouse_stock INTEGER NOT NULL,
+    pending_stock INTEGER NOT NULL,
+    expected_stock INTEGER NOT NULL,
+    cause TEXT NOT NULL,
+    corrected BOOLEAN NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (reconciliation_id) REFERENCES stock_reconciliations(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reconciliation_lines_reconciliation_id ON stock_reconciliation_lines (reconciliation_id);
@@ -0,0 +1,15 @@
+CREATE TABLE stocks_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    warehouse_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    FOREIGN KEY (product_id) REFERENCES products(id),
+    UNIQUE (warehouse_id, product_id)
+);
+
+INSERT INTO stocks_old (id, warehouse_id, product_id, quantity)
+SELECT id, warehouse_id, product_id, quantity FROM stocks;
+
+DROP TABLE stocks;
+ALTER TABLE stocks_old RENAME TO stocks;
@@ -0,0 +1,17 @@
+-- stocks.product_id is owned by the product service and validated through
+-- its API, so the foreign key to products is dropped. SQLite cannot drop a
+-- constraint, so the table is rebuilt without it.
+CREATE TABLE stocks_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    warehouse_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id),
+    UNIQUE (warehouse_id, product_id)
+);
+
+INSERT INTO stocks_new (id, warehouse_id, product_id, quantity)
+SELECT id, warehouse_id, product_id, quantity FROM stocks;
+
+DROP TABLE stocks;
+ALTER TABLE stocks_new RENAME TO stocks;
@@ -0,0 +1,14 @@
+-- 0001_init was written for the database shared with the product service,
+-- and seeds stock by product name. A warehouse database of its own gets a
+-- stand-in for the seeded products so it can run unchanged.
+CREATE TABLE IF NOT EXISTS products (
+    id INTEGER PRIMARY KEY,
+    name TEXT
+);
+
+INSERT INTO products (id, name)
+SELECT 1, 'Product A' WHERE NOT EXISTS (SELECT 1 FROM products WHERE name = 'Product A');
+INSERT INTO products (id, name)
+SELECT 2, 'Product B' WHERE NOT EXISTS (SELECT 1 FROM products WHERE name = 'Product B');
+INSERT INTO products (id, name)
+SELECT 3, 'Product C' WHERE NOT EXISTS (SELECT 1 FROM products WHERE name = 'Product C');
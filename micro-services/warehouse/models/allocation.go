@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	AllocationAllocated = "allocated"
+	AllocationReleased  = "released"
+)
+
+// StockAllocation records how much of an order line was taken from which
+// warehouse, so the stock can be returned there later.
+type StockAllocation struct {
+	Id          int64      `json:"id"`
+	OrderId     int64      `json:"order_id"`
+	ProductId   int64      `json:"product_id"`
+	WarehouseId int64      `json:"warehouse_id"`
+	Quantity    int        `json:"quantity"`
+	Strategy    string     `json:"strategy"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty"`
+}
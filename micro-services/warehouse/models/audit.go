@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	AuditWarehouseActivated   = "warehouse.activated"
+	AuditWarehouseDeactivated = "warehouse.deactivated"
+)
+
+// AuditEvent records a change made to an entity. Detail holds JSON describing
+// the change.
+type AuditEvent struct {
+	Id        int64     `json:"id"`
+	Entity    string    `json:"entity"`
+	EntityId  int64     `json:"entity_id"`
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// DefaultLot holds stock received without a lot number, including the stock
+// held before lots were tracked.
+const DefaultLot = ""
+
+// StockLot is the quantity of a product in a warehouse that shares a lot
+// number, expiry date and received date.
+type StockLot struct {
+	Id          int64  `json:"id"`
+	ProductId   int64  `json:"product_id"`
+	WarehouseId int64  `json:"warehouse_id"`
+	LotNumber   string `json:"lot_number"`
+	// ExpiryDate is the last day the lot can be sold, nil if it does not expire.
+	ExpiryDate *time.Time `json:"expiry_date,omitempty"`
+	ReceivedAt time.Time  `json:"received_at"`
+	Quantity   int        `json:"quantity"`
+	Expired    bool       `json:"expired"`
+}
+
+// IsExpired reports whether the lot can no longer be sold on today.
+func (l StockLot) IsExpired(today time.Time) bool {
+	return l.ExpiryDate != nil && l.ExpiryDate.Before(today)
+}
+
+type LotFilter struct {
+	ProductId   int64
+	WarehouseId int64
+}
+
+// Today is the date expiry is checked against, as midnight UTC.
+func Today() time.Time {
+	year, month, day := time.Now().UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Movement types of the stock ledger.
+const (
+	MovementReceipt         = "receipt"
+	MovementAdjustment      = "adjustment"
+	MovementTransferOut     = "transfer_out"
+	MovementTransferIn      = "transfer_in"
+	MovementOrderAllocation = "order_allocation"
+	MovementOrderReturn     = "order_return"
+)
+
+// StockMovement is one entry of the stock ledger. Quantity is the signed
+// change, so the movements of a product in a warehouse add up to its stock.
+type StockMovement struct {
+	Id          int64  `json:"id"`
+	ProductId   int64  `json:"product_id"`
+	WarehouseId int64  `json:"warehouse_id"`
+	Type        string `json:"type"`
+	Quantity    int    `json:"quantity"`
+	ReferenceId string `json:"reference_id"`
+	Actor       string `json:"actor"`
+	// LotNumber is the lot the quantity went into or came out of.
+	LotNumber string `json:"lot_number,omitempty"`
+	// Reason explains a manual adjustment, such as a stocktake variance.
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MovementFilter narrows a ledger query; zero fields do not filter. From is
+// inclusive and To exclusive.
+type MovementFilter struct {
+	ProductId   int64
+	WarehouseId int64
+	ReferenceId string
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+}
+
+// LedgerDiscrepancy is a stock row whose quantity differs from the sum of
+// its movements.
+type LedgerDiscrepancy struct {
+	ProductId   int64 `json:"product_id"`
+	WarehouseId int64 `json:"warehouse_id"`
+	Stock       int   `json:"stock"`
+	Ledger      int   `json:"ledger"`
+}
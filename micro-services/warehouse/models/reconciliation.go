@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Likely causes of a product whose stock in the product service is not the
+// warehouse stock less what pending orders hold.
+const (
+	// CauseOversold: pending orders hold more than the warehouses have.
+	CauseOversold = "oversold"
+	// CausePendingNotDeducted: the product stock equals the warehouse stock,
+	// as when a total was set without deducting what checkout deducted for
+	// pending orders.
+	CausePendingNotDeducted = "pending_not_deducted"
+	// CauseProductAhead: the product service shows more than expected, as
+	// after a lost push of a decrease or an order restored twice.
+	CauseProductAhead = "product_ahead"
+	// CauseProductBehind: the product service shows less than expected, as
+	// after a failed restore on cancellation or a lost push of an increase.
+	CauseProductBehind = "product_behind"
+	// CauseUnchecked: the product could not be compared, see the error.
+	CauseUnchecked = "unchecked"
+)
+
+// Reconciliation compares, per product, the stock of the product service
+// with the sellable stock of the active warehouses less the stock held by
+// pending orders, and lists the products where they differ.
+type Reconciliation struct {
+	Id    int64  `json:"id"`
+	Actor string `json:"actor"`
+	// Apply is set when discrepancies were corrected in the product service.
+	Apply   bool `json:"apply"`
+	Checked int  `json:"checked"`
+	// Complete is false when the run stopped before the last product; Error
+	// says why.
+	Complete   bool                 `json:"complete"`
+	Error      string               `json:"error,omitempty"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	Lines      []ReconciliationLine `json:"lines,omitempty"`
+}
+
+// ReconciliationLine is a product whose stock did not add up. ExpectedStock
+// is WarehouseStock less PendingStock, and never below zero.
+type ReconciliationLine struct {
+	Id             int64  `json:"id"`
+	ProductId      int64  `json:"product_id"`
+	ProductStock   int    `json:"product_stock"`
+	WarehouseStock int    `json:"warehouse_stock"`
+	PendingStock   int    `json:"pending_stock"`
+	ExpectedStock  int    `json:"expected_stock"`
+	Cause          string `json:"cause"`
+	// Corrected is set once the product service took ExpectedStock.
+	Corrected bool   `json:"corrected"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ReconcileOptions struct {
+	// Apply sets the product stock to the expected stock where they differ.
+	Apply bool
+	// ProductIds limits the run to these products; empty checks every product.
+	ProductIds []int64
+}
+
+// SellableStock is the stock the product service should show: what the
+// warehouses hold less what pending orders hold, never below zero. The
+// pushes and the reconciliation both use it, so they agree on the figure.
+func SellableStock(warehouseStock, pendingStock int) int {
+	return max(warehouseStock-pendingStock, 0)
+}
+
+// ReconciliationFor compares the three stock figures of a product and
+// returns the line to report, or nil when they add up.
+func ReconciliationFor(productId int64, productStock, warehouseStock, pendingStock int) *ReconciliationLine {
+	line := ReconciliationLine{
+		ProductId:      productId,
+		ProductStock:   productStock,
+		WarehouseStock: warehouseStock,
+		PendingStock:   pendingStock,
+		ExpectedStock:  SellableStock(warehouseStock, pendingStock),
+	}
+
+	switch {
+	case pendingStock > warehouseStock:
+		line.Cause = CauseOversold
+	case productStock == line.ExpectedStock:
+		return nil
+	case productStock == warehouseStock:
+		line.Cause = CausePendingNotDeducted
+	case productStock > line.ExpectedStock:
+		line.Cause = CauseProductAhead
+	default:
+		line.Cause = CauseProductBehind
+	}
+	return &line
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Replenishment suggestion kinds. A transfer moves surplus stock from
+// another warehouse, a reorder has to be bought in.
+const (
+	SuggestReorder  = "reorder"
+	SuggestTransfer = "transfer"
+)
+
+// StockThreshold is the reorder point and safety stock of a product in a
+// warehouse. Stock is low once on hand plus in transit falls to the reorder
+// point, and replenishment aims for the reorder point plus safety stock plus
+// the expected demand until the next delivery.
+type StockThreshold struct {
+	Id           int64 `json:"id"`
+	ProductId    int64 `json:"product_id"`
+	WarehouseId  int64 `json:"warehouse_id"`
+	ReorderPoint int   `json:"reorder_point"`
+	SafetyStock  int   `json:"safety_stock"`
+	// LowSince is when the stock last became low, nil while it is not.
+	LowSince  *time.Time `json:"low_since,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type ThresholdFilter struct {
+	ProductId   int64
+	WarehouseId int64
+}
+
+// ReplenishmentSuggestion is one way of bringing a low product in a
+// warehouse back to its target, with the figures it was computed from.
+type ReplenishmentSuggestion struct {
+	Id          int64  `json:"id"`
+	ProductId   int64  `json:"product_id"`
+	WarehouseId int64  `json:"warehouse_id"`
+	Kind        string `json:"kind"`
+	// FromWarehouseId is the warehouse to transfer from, nil for a reorder.
+	FromWarehouseId *int64 `json:"from_warehouse_id,omitempty"`
+	Quantity        int    `json:"quantity"`
+	OnHand          int    `json:"on_hand"`
+	InTransit       int    `json:"in_transit"`
+	ReorderPoint    int    `json:"reorder_point"`
+	SafetyStock     int    `json:"safety_stock"`
+	// DailyVelocity is the units allocated per day over the lookback window.
+	DailyVelocity float64   `json:"daily_velocity"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SuggestionFilter struct {
+	ProductId   int64
+	WarehouseId int64
+	Kind        string
+}
+
+// LowStockEvent is raised when a product in a warehouse becomes low.
+type LowStockEvent struct {
+	ProductId     int64     `json:"product_id"`
+	WarehouseId   int64     `json:"warehouse_id"`
+	OnHand        int       `json:"on_hand"`
+	InTransit     int       `json:"in_transit"`
+	ReorderPoint  int       `json:"reorder_point"`
+	SafetyStock   int       `json:"safety_stock"`
+	DailyVelocity float64   `json:"daily_velocity"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+// ReplenishmentRun is the outcome of one replenishment pass.
+type ReplenishmentRun struct {
+	Suggestions []ReplenishmentSuggestion `json:"suggestions"`
+	// LowStock lists the products that became low during the run.
+	LowStock []LowStockEvent `json:"low_stock"`
+}
+
+// AllocatedQuantity is the quantity of a product allocated from a warehouse.
+type AllocatedQuantity struct {
+	ProductId   int64 `json:"product_id"`
+	WarehouseId int64 `json:"warehouse_id"`
+	Quantity    int   `json:"quantity"`
+}
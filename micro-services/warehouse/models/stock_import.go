@@ -0,0 +1,33 @@
+package models
+
+// How a stock import row changes the stock of a product in a warehouse.
+const (
+	// ImportModeSet replaces the quantity, posted as an adjustment.
+	ImportModeSet = "set"
+	// ImportModeAdd adds to the quantity, posted as a receipt.
+	ImportModeAdd = "add"
+)
+
+// StockImportRow is a row of a stock import with the stock it leads to.
+// Warehouse is the name or id given in the file; Previous and New are the
+// quantity before and after the row, counting the rows above it.
+type StockImportRow struct {
+	Line        int      `json:"line"`
+	Warehouse   string   `json:"warehouse"`
+	WarehouseId int64    `json:"warehouse_id,omitempty"`
+	ProductId   int64    `json:"product_id,omitempty"`
+	Quantity    int      `json:"quantity"`
+	Mode        string   `json:"mode"`
+	Previous    int      `json:"previous"`
+	New         int      `json:"new"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// StockImport is the outcome of a stock import. Its rows are applied all
+// together or, on a dry run or when any row is invalid, not at all.
+type StockImport struct {
+	DryRun  bool             `json:"dry_run"`
+	Applied bool             `json:"applied"`
+	Invalid int              `json:"invalid"`
+	Rows    []StockImportRow `json:"rows"`
+}
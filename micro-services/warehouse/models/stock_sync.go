@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// StockChange marks a product whose total stock changed since it was last
+// pushed to the product service.
+type StockChange struct {
+	ProductId int64     `json:"product_id"`
+	Version   int64     `json:"version"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// StockPush is the outcome of pushing the queued totals.
+type StockPush struct {
+	Pushed int `json:"pushed"`
+	// Missing are products the product service does not know; they are
+	// taken off the outbox all the same.
+	Missing []int64 `json:"missing,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Stocktake states. Counts are recorded while a stocktake is open; approving
+// it posts the variances as adjustments.
+const (
+	StocktakeOpen      = "open"
+	StocktakeApproved  = "approved"
+	StocktakeCancelled = "cancelled"
+)
+
+// Stocktake is a count session for one warehouse.
+type Stocktake struct {
+	Id          int64  `json:"id"`
+	WarehouseId int64  `json:"warehouse_id"`
+	Status      string `json:"status"`
+	// FreezeMovements blocks every stock change of the warehouse while the
+	// stocktake is open.
+	FreezeMovements bool `json:"freeze_movements"`
+	// Reason is given on approval and recorded on every adjustment.
+	Reason    string          `json:"reason,omitempty"`
+	OpenedBy  string          `json:"opened_by"`
+	ClosedBy  string          `json:"closed_by,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	ClosedAt  *time.Time      `json:"closed_at,omitempty"`
+	Lines     []StocktakeLine `json:"lines,omitempty"`
+}
+
+// StocktakeLine is the counted quantity of a product. SystemQuantity is the
+// stock when it was counted, so movements made after the count do not show
+// up as variance.
+type StocktakeLine struct {
+	Id              int64 `json:"id"`
+	StocktakeId     int64 `json:"stocktake_id"`
+	ProductId       int64 `json:"product_id"`
+	SystemQuantity  int   `json:"system_quantity"`
+	CountedQuantity int   `json:"counted_quantity"`
+	// Variance is CountedQuantity - SystemQuantity.
+	Variance  int       `json:"variance"`
+	CountedBy string    `json:"counted_by"`
+	CountedAt time.Time `json:"counted_at"`
+}
+
+// StocktakeCount is a counted quantity of a product.
+type StocktakeCount struct {
+	ProductId       int64 `json:"product_id"`
+	CountedQuantity int   `json:"counted_quantity"`
+}
+
+type StocktakeFilter struct {
+	WarehouseId int64
+	Status      string
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Transfer order states. A requested transfer has not moved stock yet; from
+// dispatch until it is received or cancelled, the units not received are in
+// transit and belong to no warehouse.
+const (
+	TransferRequested  = "requested"
+	TransferDispatched = "dispatched"
+	TransferInTransit  = "in_transit"
+	TransferReceived   = "received"
+	TransferCancelled  = "cancelled"
+)
+
+type TransferOrder struct {
+	Id               int64     `json:"id"`
+	ProductId        int64     `json:"product_id"`
+	FromWarehouseId  int64     `json:"from_warehouse_id"`
+	ToWarehouseId    int64     `json:"to_warehouse_id"`
+	Quantity         int       `json:"quantity"`
+	ReceivedQuantity int       `json:"received_quantity"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// InTransit is the number of units dispatched but not received.
+func (t TransferOrder) InTransit() int {
+	if t.Status != TransferDispatched && t.Status != TransferInTransit {
+		return 0
+	}
+	return t.Quantity - t.ReceivedQuantity
+}
+
+type TransferFilter struct {
+	Status      string
+	ProductId   int64
+	WarehouseId int64
+}
+
+// InTransitStock is the quantity of a product on its way to a warehouse.
+type InTransitStock struct {
+	ProductId     int64 `json:"product_id"`
+	ToWarehouseId int64 `json:"to_warehouse_id"`
+	Quantity      int   `json:"quantity"`
+}
@@ -4,6 +4,18 @@ type Warehouse struct {
 	Id     int64  `json:"id"`
 	Name   string `json:"name"`
 	Status string `json:"status"` // Active or Inactive
+	// Priority ranks warehouses for allocation, lower values are drawn from first.
+	Priority int       `json:"priority"`
+	Location *Location `json:"location,omitempty"`
+	Address  string    `json:"address"`
+	// Capacity is the number of units the warehouse can hold, nil for no limit.
+	Capacity     *int   `json:"capacity,omitempty"`
+	ContactName  string `json:"contact_name"`
+	ContactPhone string `json:"contact_phone"`
+	// OperatingHours is the opening and closing time as HH:MM-HH:MM.
+	OperatingHours string `json:"operating_hours"`
+	// Stocks is the per-product stock, filled when listing warehouses.
+	Stocks []Stock `json:"stocks,omitempty"`
 }
 
 type Stock struct {
@@ -11,4 +23,24 @@ type Stock struct {
 	WarehouseId int64 `json:"warehouse_id"`
 	ProductId   int64 `json:"product_id"`
 	Quantity    int   `json:"quantity"`
-}
\ No newline at end of file
+	// Expired is the part of Quantity in lots past their expiry date.
+	Expired int `json:"expired,omitempty"`
+}
+
+// Sellable is the stock that can be allocated to orders.
+func (s Stock) Sellable() int {
+	return s.Quantity - s.Expired
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// StockTransfer is a quantity of a product moved between warehouses.
+type StockTransfer struct {
+	ProductId       int64 `json:"product_id"`
+	FromWarehouseId int64 `json:"from_warehouse_id"`
+	ToWarehouseId   int64 `json:"to_warehouse_id"`
+	Quantity        int   `json:"quantity"`
+}
@@ -0,0 +1,67 @@
+// Package notifier delivers low-stock events raised by the replenishment job.
+// The notifier is picked by configuration, so alerts can go to the log or be
+// posted to a webhook without touching the job.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/configloader"
+	"monorepo-ecommerce/pkg/httpclient"
+)
+
+const (
+	// Log writes low-stock events to the service log.
+	Log = "log"
+	// Webhook posts low-stock events as JSON to a URL.
+	Webhook = "webhook"
+)
+
+type Notifier interface {
+	NotifyLowStock(ctx context.Context, event models.LowStockEvent) error
+}
+
+// New returns the notifier registered under kind. webhookURL is only used,
+// and then required, by the webhook notifier.
+func New(kind string, webhookURL string, clientCfg httpclient.Config) (Notifier, error) {
+	switch kind {
+	case Log:
+		return NewLogNotifier(), nil
+	case Webhook:
+		if err := configloader.ValidateURL("low_stock_webhook_url", webhookURL); err != nil {
+			return nil, err
+		}
+		return NewWebhookNotifier(httpclient.New("low-stock-webhook", webhookURL, clientCfg)), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, expected %s or %s", kind, Log, Webhook)
+	}
+}
+
+type logNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+func (logNotifier) NotifyLowStock(ctx context.Context, event models.LowStockEvent) error {
+	log.Printf("Low stock: product %d in warehouse %d has %d on hand and %d in transit, reorder point %d",
+		event.ProductId, event.WarehouseId, event.OnHand, event.InTransit, event.ReorderPoint)
+	return nil
+}
+
+type webhookNotifier struct {
+	client *httpclient.Client
+}
+
+func NewWebhookNotifier(client *httpclient.Client) Notifier {
+	return &webhookNotifier{client: client}
+}
+
+func (n *webhookNotifier) NotifyLowStock(ctx context.Context, event models.LowStockEvent) error {
+	if err := n.client.Post(ctx, "", event, nil); err != nil {
+		return fmt.Errorf("failed post low stock event: %w", err)
+	}
+	return nil
+}
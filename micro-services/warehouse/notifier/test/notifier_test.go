@@ -0,0 +1,63 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/notifier"
+	"monorepo-ecommerce/pkg/httpclient"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should return the log notifier", func(t *testing.T) {
+		n, err := notifier.New(notifier.Log, "", httpclient.DefaultConfig())
+		assert.NoError(t, err)
+		assert.NoError(t, n.NotifyLowStock(context.Background(), models.LowStockEvent{ProductId: 1, WarehouseId: 1}))
+	})
+
+	t.Run("should require a webhook url", func(t *testing.T) {
+		_, err := notifier.New(notifier.Webhook, "", httpclient.DefaultConfig())
+		assert.ErrorContains(t, err, "low_stock_webhook_url")
+	})
+
+	t.Run("should reject an unknown notifier", func(t *testing.T) {
+		_, err := notifier.New("pager", "", httpclient.DefaultConfig())
+		assert.ErrorContains(t, err, "unknown notifier")
+	})
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Run("should post the event as json", func(t *testing.T) {
+		var received models.LowStockEvent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		n, err := notifier.New(notifier.Webhook, server.URL, httpclient.DefaultConfig())
+		assert.NoError(t, err)
+
+		event := models.LowStockEvent{ProductId: 2, WarehouseId: 1, OnHand: 3, ReorderPoint: 5}
+		assert.NoError(t, n.NotifyLowStock(context.Background(), event))
+		assert.Equal(t, event.ProductId, received.ProductId)
+		assert.Equal(t, event.OnHand, received.OnHand)
+	})
+
+	t.Run("should return an error when the webhook fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		n, err := notifier.New(notifier.Webhook, server.URL, httpclient.DefaultConfig())
+		assert.NoError(t, err)
+		assert.Error(t, n.NotifyLowStock(context.Background(), models.LowStockEvent{ProductId: 2}))
+	})
+}
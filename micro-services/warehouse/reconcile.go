@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"strconv"
+	"strings"
+)
+
+// isReconcileCommand reports whether the binary was started as
+// `warehouse reconcile [-apply] [-products 1,2,3]`.
+func isReconcileCommand(args []string) bool {
+	return len(args) > 1 && args[1] == "reconcile"
+}
+
+// runReconcileCommand runs one reconciliation with the flags in args, the
+// words after "reconcile", and writes the stored run as JSON to out.
+func runReconcileCommand(ctx context.Context, reconciliationService service.ReconciliationService, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "correct the product stock where it does not add up")
+	products := flags.String("products", "", "comma separated product ids to check instead of every product")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := models.ReconcileOptions{Apply: *apply}
+	for _, field := range strings.Split(*products, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		productId, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid product id %q", field)
+		}
+		options.ProductIds = append(options.ProductIds, productId)
+	}
+
+	reconciliation, err := reconciliationService.Reconcile(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reconciliation)
+}
@@ -0,0 +1,188 @@
+// Package replenishment decides which products run low in which warehouses
+// and how to top them up. Like allocation it only plans: it works on a
+// snapshot of thresholds, stock and recent demand, and the warehouse service
+// stores the result.
+package replenishment
+
+import (
+	"math"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"sort"
+)
+
+// Key is a product in a warehouse.
+type Key struct {
+	ProductId   int64
+	WarehouseId int64
+}
+
+type Input struct {
+	Thresholds []models.StockThreshold
+	// Warehouses are the active warehouses; thresholds of other warehouses
+	// are ignored and they never give stock away.
+	Warehouses []models.Warehouse
+	OnHand     map[Key]int
+	InTransit  map[Key]int
+	// Allocated is the quantity allocated over the last LookbackDays.
+	Allocated    map[Key]int
+	LookbackDays float64
+	// CoverDays is how many days of demand a top-up should last.
+	CoverDays int
+}
+
+// Position is the stock of a product in a warehouse measured against its
+// threshold.
+type Position struct {
+	Threshold     models.StockThreshold
+	OnHand        int
+	InTransit     int
+	DailyVelocity float64
+}
+
+// Low reports whether on hand plus in transit is at or below the reorder point.
+func (p Position) Low() bool {
+	return p.OnHand+p.InTransit <= p.Threshold.ReorderPoint
+}
+
+// Target is the position a top-up aims for: the reorder point and safety
+// stock, plus the demand expected over coverDays.
+func (p Position) Target(coverDays int) int {
+	return p.Threshold.ReorderPoint + p.Threshold.SafetyStock + int(math.Ceil(p.DailyVelocity*float64(coverDays)))
+}
+
+type Plan struct {
+	// Positions covers every threshold of an active warehouse.
+	Positions   []Position
+	Suggestions []models.ReplenishmentSuggestion
+}
+
+// Build measures every threshold and suggests how to refill the low ones.
+// Surplus in other active warehouses is transferred first, largest surplus
+// first, and whatever it cannot cover is reordered.
+func Build(in Input) Plan {
+	active := map[int64]models.Warehouse{}
+	for _, warehouse := range in.Warehouses {
+		active[warehouse.Id] = warehouse
+	}
+
+	positions := map[Key]Position{}
+	var plan Plan
+	for _, threshold := range in.Thresholds {
+		if _, ok := active[threshold.WarehouseId]; !ok {
+			continue
+		}
+		key := Key{ProductId: threshold.ProductId, WarehouseId: threshold.WarehouseId}
+		position := Position{
+			Threshold:     threshold,
+			OnHand:        in.OnHand[key],
+			InTransit:     in.InTransit[key],
+			DailyVelocity: in.velocity(key),
+		}
+		positions[key] = position
+		plan.Positions = append(plan.Positions, position)
+	}
+
+	// refill the lowest priority numbers first, they are drawn from first
+	sort.SliceStable(plan.Positions, func(i, j int) bool {
+		a, b := plan.Positions[i].Threshold, plan.Positions[j].Threshold
+		if a.ProductId != b.ProductId {
+			return a.ProductId < b.ProductId
+		}
+		return active[a.WarehouseId].Priority < active[b.WarehouseId].Priority
+	})
+
+	surplus := in.surplus(positions, active)
+	for _, position := range plan.Positions {
+		if !position.Low() {
+			continue
+		}
+		plan.Suggestions = append(plan.Suggestions, refill(position, in, surplus)...)
+	}
+
+	return plan
+}
+
+// velocity is the units allocated per day over the lookback window.
+func (in Input) velocity(key Key) float64 {
+	if in.LookbackDays <= 0 {
+		return 0
+	}
+	return float64(in.Allocated[key]) / in.LookbackDays
+}
+
+// surplus is the stock each active warehouse can spare: what it holds above
+// its own target, or above its expected demand when it has no threshold.
+func (in Input) surplus(positions map[Key]Position, active map[int64]models.Warehouse) map[Key]int {
+	surplus := map[Key]int{}
+	for key, onHand := range in.OnHand {
+		if _, ok := active[key.WarehouseId]; !ok {
+			continue
+		}
+
+		keep := int(math.Ceil(in.velocity(key) * float64(in.CoverDays)))
+		if position, ok := positions[key]; ok {
+			keep = position.Target(in.CoverDays) - position.InTransit
+		}
+		if onHand > keep {
+			surplus[key] = onHand - keep
+		}
+	}
+	return surplus
+}
+
+// refill suggests transfers out of surplus, reducing it, and a reorder for
+// the rest.
+func refill(position Position, in Input, surplus map[Key]int) []models.ReplenishmentSuggestion {
+	threshold := position.Threshold
+	needed := position.Target(in.CoverDays) - position.OnHand - position.InTransit
+
+	donors := make([]models.Warehouse, 0, len(in.Warehouses))
+	for _, warehouse := range in.Warehouses {
+		if warehouse.Id != threshold.WarehouseId && surplus[Key{ProductId: threshold.ProductId, WarehouseId: warehouse.Id}] > 0 {
+			donors = append(donors, warehouse)
+		}
+	}
+	sort.SliceStable(donors, func(i, j int) bool {
+		a := surplus[Key{ProductId: threshold.ProductId, WarehouseId: donors[i].Id}]
+		b := surplus[Key{ProductId: threshold.ProductId, WarehouseId: donors[j].Id}]
+		if a != b {
+			return a > b
+		}
+		return donors[i].Priority < donors[j].Priority
+	})
+
+	suggestion := func(kind string, quantity int) models.ReplenishmentSuggestion {
+		return models.ReplenishmentSuggestion{
+			ProductId:     threshold.ProductId,
+			WarehouseId:   threshold.WarehouseId,
+			Kind:          kind,
+			Quantity:      quantity,
+			OnHand:        position.OnHand,
+			InTransit:     position.InTransit,
+			ReorderPoint:  threshold.ReorderPoint,
+			SafetyStock:   threshold.SafetyStock,
+			DailyVelocity: position.DailyVelocity,
+		}
+	}
+
+	var suggestions []models.ReplenishmentSuggestion
+	for _, donor := range donors {
+		if needed <= 0 {
+			break
+		}
+		key := Key{ProductId: threshold.ProductId, WarehouseId: donor.Id}
+		quantity := min(needed, surplus[key])
+		surplus[key] -= quantity
+		needed -= quantity
+
+		from := donor.Id
+		transfer := suggestion(models.SuggestTransfer, quantity)
+		transfer.FromWarehouseId = &from
+		suggestions = append(suggestions, transfer)
+	}
+	if needed > 0 {
+		suggestions = append(suggestions, suggestion(models.SuggestReorder, needed))
+	}
+
+	return suggestions
+}
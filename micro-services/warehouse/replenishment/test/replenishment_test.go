@@ -0,0 +1,123 @@
+package test
+
+import (
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/replenishment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var warehouses = []models.Warehouse{
+	{Id: 1, Priority: 1},
+	{Id: 2, Priority: 2},
+	{Id: 3, Priority: 3},
+}
+
+func key(productId, warehouseId int64) replenishment.Key {
+	return replenishment.Key{ProductId: productId, WarehouseId: warehouseId}
+}
+
+func threshold(productId, warehouseId int64, reorderPoint, safetyStock int) models.StockThreshold {
+	return models.StockThreshold{ProductId: productId, WarehouseId: warehouseId, ReorderPoint: reorderPoint, SafetyStock: safetyStock}
+}
+
+func TestBuild(t *testing.T) {
+	t.Run("should leave stock above the reorder point alone", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds: []models.StockThreshold{threshold(1, 1, 10, 5)},
+			Warehouses: warehouses,
+			OnHand:     map[replenishment.Key]int{key(1, 1): 11},
+			CoverDays:  7,
+		})
+
+		assert.Len(t, plan.Positions, 1)
+		assert.False(t, plan.Positions[0].Low())
+		assert.Empty(t, plan.Suggestions)
+	})
+
+	t.Run("should count in-transit stock towards the position", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds: []models.StockThreshold{threshold(1, 1, 10, 5)},
+			Warehouses: warehouses,
+			OnHand:     map[replenishment.Key]int{key(1, 1): 4},
+			InTransit:  map[replenishment.Key]int{key(1, 1): 7},
+			CoverDays:  7,
+		})
+
+		assert.False(t, plan.Positions[0].Low())
+		assert.Empty(t, plan.Suggestions)
+	})
+
+	t.Run("should reorder up to the target including demand over the cover days", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds:   []models.StockThreshold{threshold(1, 1, 10, 5)},
+			Warehouses:   warehouses,
+			OnHand:       map[replenishment.Key]int{key(1, 1): 8},
+			Allocated:    map[replenishment.Key]int{key(1, 1): 28},
+			LookbackDays: 14,
+			CoverDays:    7,
+		})
+
+		// target = 10 + 5 + ceil(2 * 7) = 29
+		assert.Equal(t, []models.ReplenishmentSuggestion{{
+			ProductId: 1, WarehouseId: 1, Kind: models.SuggestReorder, Quantity: 21,
+			OnHand: 8, ReorderPoint: 10, SafetyStock: 5, DailyVelocity: 2,
+		}}, plan.Suggestions)
+	})
+
+	t.Run("should transfer from the largest surplus first and reorder the rest", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds: []models.StockThreshold{threshold(1, 1, 10, 10), threshold(1, 2, 5, 5)},
+			Warehouses: warehouses,
+			// warehouse 2 keeps its target of 10, warehouse 3 has no threshold
+			OnHand:    map[replenishment.Key]int{key(1, 1): 0, key(1, 2): 14, key(1, 3): 12},
+			CoverDays: 7,
+		})
+
+		assert.Len(t, plan.Suggestions, 3)
+		assert.Equal(t, models.SuggestTransfer, plan.Suggestions[0].Kind)
+		assert.Equal(t, int64(3), *plan.Suggestions[0].FromWarehouseId)
+		assert.Equal(t, 12, plan.Suggestions[0].Quantity)
+		assert.Equal(t, int64(2), *plan.Suggestions[1].FromWarehouseId)
+		assert.Equal(t, 4, plan.Suggestions[1].Quantity)
+		assert.Equal(t, models.SuggestReorder, plan.Suggestions[2].Kind)
+		assert.Nil(t, plan.Suggestions[2].FromWarehouseId)
+		assert.Equal(t, 4, plan.Suggestions[2].Quantity)
+	})
+
+	t.Run("should not hand the same surplus to two warehouses", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds: []models.StockThreshold{threshold(1, 1, 5, 0), threshold(1, 2, 5, 0)},
+			Warehouses: warehouses,
+			OnHand:     map[replenishment.Key]int{key(1, 3): 6},
+			CoverDays:  7,
+		})
+
+		// warehouse 1 comes first by priority, warehouse 2 gets the one
+		// unit left over and reorders the rest
+		assert.Len(t, plan.Suggestions, 3)
+		assert.Equal(t, int64(1), plan.Suggestions[0].WarehouseId)
+		assert.Equal(t, models.SuggestTransfer, plan.Suggestions[0].Kind)
+		assert.Equal(t, 5, plan.Suggestions[0].Quantity)
+		assert.Equal(t, int64(2), plan.Suggestions[1].WarehouseId)
+		assert.Equal(t, models.SuggestTransfer, plan.Suggestions[1].Kind)
+		assert.Equal(t, 1, plan.Suggestions[1].Quantity)
+		assert.Equal(t, models.SuggestReorder, plan.Suggestions[2].Kind)
+		assert.Equal(t, 4, plan.Suggestions[2].Quantity)
+	})
+
+	t.Run("should ignore thresholds and stock of inactive warehouses", func(t *testing.T) {
+		plan := replenishment.Build(replenishment.Input{
+			Thresholds: []models.StockThreshold{threshold(1, 1, 5, 0), threshold(1, 3, 5, 0)},
+			Warehouses: warehouses[:2],
+			OnHand:     map[replenishment.Key]int{key(1, 3): 50},
+			CoverDays:  7,
+		})
+
+		assert.Len(t, plan.Positions, 1)
+		assert.Equal(t, []models.ReplenishmentSuggestion{{
+			ProductId: 1, WarehouseId: 1, Kind: models.SuggestReorder, Quantity: 5, ReorderPoint: 5,
+		}}, plan.Suggestions)
+	})
+}
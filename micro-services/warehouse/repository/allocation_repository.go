@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type AllocationRepository interface {
+	CreateAllocation(ctx context.Context, allocation *models.StockAllocation) error
+	GetAllocationsByOrder(ctx context.Context, orderId int64) ([]models.StockAllocation, error)
+	// GetOpenAllocationsByWarehouse lists the allocations of a warehouse that
+	// belong to one of orderIds, the pending orders, and are not released. An
+	// allocation of an order that went through is done with.
+	GetOpenAllocationsByWarehouse(ctx context.Context, warehouseId int64, orderIds []int64) ([]models.StockAllocation, error)
+	ReleaseAllocation(ctx context.Context, allocationId int64) error
+	// GetAllocatedQuantities sums the quantity allocated per product and
+	// warehouse since the given time; released allocations do not count.
+	GetAllocatedQuantities(ctx context.Context, since time.Time) ([]models.AllocatedQuantity, error)
+}
+
+type allocationRepository struct {
+	db database.Querier
+}
+
+func NewAllocationRepository(db *database.DB) AllocationRepository {
+	return &allocationRepository{db: db}
+}
+
+func (r *allocationRepository) CreateAllocation(ctx context.Context, allocation *models.StockAllocation) error {
+	allocation.Status = models.AllocationAllocated
+	allocation.CreatedAt = time.Now()
+
+	query := "INSERT INTO stock_allocations (order_id, product_id, warehouse_id, quantity, strategy, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, allocation.OrderId, allocation.ProductId, allocation.WarehouseId, allocation.Quantity, allocation.Strategy, allocation.Status, allocation.CreatedAt)
+	if err != nil {
+		if database.IsUniqueViolation(err) {
+			return &apperror.ConflictError{Resource: "allocation", Reason: fmt.Sprintf("order %d is already allocated", allocation.OrderId)}
+		}
+		return err
+	}
+
+	allocation.Id = id
+	return nil
+}
+
+func (r *allocationRepository) GetAllocationsByOrder(ctx context.Context, orderId int64) ([]models.StockAllocation, error) {
+	return r.queryAllocations(ctx, "SELECT "+allocationColumns+" FROM stock_allocations WHERE order_id = ? ORDER BY id", orderId)
+}
+
+func (r *allocationRepository) GetOpenAllocationsByWarehouse(ctx context.Context, warehouseId int64, orderIds []int64) ([]models.StockAllocation, error) {
+	if len(orderIds) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(orderIds)+2)
+	args = append(args, warehouseId, models.AllocationAllocated)
+	for _, orderId := range orderIds {
+		args = append(args, orderId)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(orderIds)), ", ")
+	return r.queryAllocations(ctx, "SELECT "+allocationColumns+" FROM stock_allocations WHERE warehouse_id = ? AND status = ? AND order_id IN ("+placeholders+") ORDER BY id", args...)
+}
+
+const allocationColumns = "id, order_id, product_id, warehouse_id, quantity, strategy, status, created_at, released_at"
+
+func (r *allocationRepository) queryAllocations(ctx context.Context, query string, args ...any) ([]models.StockAllocation, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []models.StockAllocation
+	for rows.Next() {
+		var allocation models.StockAllocation
+		if err := rows.Scan(&allocation.Id, &allocation.OrderId, &allocation.ProductId, &allocation.WarehouseId, &allocation.Quantity,
+			&allocation.Strategy, &allocation.Status, &allocation.CreatedAt, &allocation.ReleasedAt); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	return allocations, rows.Err()
+}
+
+// ReleaseAllocation marks an allocated row released; a row released before
+// counts as not found, so stock is never returned twice.
+func (r *allocationRepository) ReleaseAllocation(ctx context.Context, allocationId int64) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE stock_allocations SET status = ?, released_at = ? WHERE id = ? AND status = ?",
+		models.AllocationReleased, time.Now(), allocationId, models.AllocationAllocated)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return &apperror.NotFoundError{Resource: "allocation", Id: allocationId}
+	}
+
+	return nil
+}
+
+func (r *allocationRepository) GetAllocatedQuantities(ctx context.Context, since time.Time) ([]models.AllocatedQuantity, error) {
+	query := `SELECT product_id, warehouse_id, SUM(quantity)
+              FROM stock_allocations
+              WHERE status = ? AND created_at >= ?
+              GROUP BY product_id, warehouse_id
+              ORDER BY product_id, warehouse_id`
+
+	// created_at is written in local time, compare in the same zone
+	rows, err := r.db.QueryContext(ctx, query, models.AllocationAllocated, since.Local())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quantities := []models.AllocatedQuantity{}
+	for rows.Next() {
+		var quantity models.AllocatedQuantity
+		if err := rows.Scan(&quantity.ProductId, &quantity.WarehouseId, &quantity.Quantity); err != nil {
+			return nil, err
+		}
+		quantities = append(quantities, quantity)
+	}
+
+	return quantities, rows.Err()
+}
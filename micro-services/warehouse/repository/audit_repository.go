@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/database"
+	"time"
+)
+
+type AuditRepository interface {
+	RecordEvent(ctx context.Context, event *models.AuditEvent) error
+	GetEvents(ctx context.Context, entity string, entityId int64) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db database.Querier
+}
+
+func NewAuditRepository(db *database.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) RecordEvent(ctx context.Context, event *models.AuditEvent) error {
+	event.CreatedAt = time.Now()
+	if event.Detail == "" {
+		event.Detail = "{}"
+	}
+
+	query := "INSERT INTO audit_events (entity, entity_id, action, detail, created_at) VALUES (?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, event.Entity, event.EntityId, event.Action, event.Detail, event.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	event.Id = id
+	return nil
+}
+
+func (r *auditRepository) GetEvents(ctx context.Context, entity string, entityId int64) ([]models.AuditEvent, error) {
+	query := "SELECT id, entity, entity_id, action, detail, created_at FROM audit_events WHERE entity = ? AND entity_id = ? ORDER BY id"
+	rows, err := r.db.QueryContext(ctx, query, entity, entityId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.Scan(&event.Id, &event.Entity, &event.EntityId, &event.Action, &event.Detail, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type LotRepository interface {
+	// EnsureLot creates the lot with no stock unless it exists, then fills
+	// lot with what is stored.
+	EnsureLot(ctx context.Context, lot *models.StockLot) error
+	GetLot(ctx context.Context, productId, warehouseId int64, lotNumber string) (*models.StockLot, error)
+	// GetLots lists lots holding stock, first expiry first; lots that do not
+	// expire come last.
+	GetLots(ctx context.Context, filter models.LotFilter) ([]models.StockLot, error)
+	// AdjustLot changes the quantity of a lot by delta, refusing to go below
+	// zero. Adding to a lot that does not exist creates it.
+	AdjustLot(ctx context.Context, productId, warehouseId int64, lotNumber string, delta int) error
+	// GetExpiringLots lists lots holding stock that expire before the given
+	// date, expired ones included.
+	GetExpiringLots(ctx context.Context, filter models.LotFilter, before time.Time) ([]models.StockLot, error)
+}
+
+type lotRepository struct {
+	db database.Querier
+}
+
+func NewLotRepository(db *database.DB) LotRepository {
+	return &lotRepository{db: db}
+}
+
+const lotColumns = "id, product_id, warehouse_id, lot_number, expiry_date, received_at, quantity"
+
+// fefoOrder sorts lots first expiry, first out.
+const fefoOrder = " ORDER BY CASE WHEN expiry_date IS NULL THEN 1 ELSE 0 END, expiry_date, received_at, id"
+
+func (r *lotRepository) EnsureLot(ctx context.Context, lot *models.StockLot) error {
+	if lot.ReceivedAt.IsZero() {
+		lot.ReceivedAt = time.Now()
+	}
+
+	query := `INSERT INTO stock_lots (product_id, warehouse_id, lot_number, expiry_date, received_at, quantity)
+              VALUES (?, ?, ?, ?, ?, 0)
+              ON CONFLICT (product_id, warehouse_id, lot_number) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, lot.ProductId, lot.WarehouseId, lot.LotNumber, lot.ExpiryDate, lot.ReceivedAt)
+	if err != nil {
+		return err
+	}
+
+	stored, err := r.GetLot(ctx, lot.ProductId, lot.WarehouseId, lot.LotNumber)
+	if err != nil {
+		return err
+	}
+
+	*lot = *stored
+	return nil
+}
+
+func (r *lotRepository) GetLot(ctx context.Context, productId, warehouseId int64, lotNumber string) (*models.StockLot, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+lotColumns+" FROM stock_lots WHERE product_id = ? AND warehouse_id = ? AND lot_number = ?", productId, warehouseId, lotNumber)
+	lot, err := scanLot(row, models.Today())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("lot %q of product %d in warehouse %d: %w", lotNumber, productId, warehouseId, &apperror.NotFoundError{Resource: "lot"})
+		}
+		return nil, err
+	}
+
+	return lot, nil
+}
+
+func (r *lotRepository) GetLots(ctx context.Context, filter models.LotFilter) ([]models.StockLot, error) {
+	conditions, args := lotConditions(filter)
+	return r.queryLots(ctx, "SELECT "+lotColumns+" FROM stock_lots WHERE "+strings.Join(conditions, " AND ")+fefoOrder, args...)
+}
+
+func (r *lotRepository) AdjustLot(ctx context.Context, productId, warehouseId int64, lotNumber string, delta int) error {
+	var result sql.Result
+	var err error
+	if delta >= 0 {
+		query := `INSERT INTO stock_lots (product_id, warehouse_id, lot_number, received_at, quantity)
+                  VALUES (?, ?, ?, ?, ?)
+                  ON CONFLICT (product_id, warehouse_id, lot_number) DO UPDATE SET quantity = stock_lots.quantity + excluded.quantity`
+		result, err = r.db.ExecContext(ctx, query, productId, warehouseId, lotNumber, time.Now(), delta)
+	} else {
+		query := "UPDATE stock_lots SET quantity = quantity + ? WHERE product_id = ? AND warehouse_id = ? AND lot_number = ? AND quantity + ? >= 0"
+		result, err = r.db.ExecContext(ctx, query, delta, productId, warehouseId, lotNumber, delta)
+	}
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		available := 0
+		if lot, err := r.GetLot(ctx, productId, warehouseId, lotNumber); err == nil {
+			available = lot.Quantity
+		}
+		return &apperror.InsufficientStockError{ProductId: productId, Requested: -delta, Available: available}
+	}
+
+	return nil
+}
+
+func (r *lotRepository) GetExpiringLots(ctx context.Context, filter models.LotFilter, before time.Time) ([]models.StockLot, error) {
+	conditions, args := lotConditions(filter)
+	conditions = append(conditions, "expiry_date IS NOT NULL", "expiry_date < ?")
+	args = append(args, before)
+	return r.queryLots(ctx, "SELECT "+lotColumns+" FROM stock_lots WHERE "+strings.Join(conditions, " AND ")+fefoOrder, args...)
+}
+
+// lotConditions filters lots holding stock.
+func lotConditions(filter models.LotFilter) ([]string, []any) {
+	conditions := []string{"quantity > 0"}
+	var args []any
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "warehouse_id = ?")
+		args = append(args, filter.WarehouseId)
+	}
+	return conditions, args
+}
+
+func (r *lotRepository) queryLots(ctx context.Context, query string, args ...any) ([]models.StockLot, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := models.Today()
+	lots := []models.StockLot{}
+	for rows.Next() {
+		lot, err := scanLot(rows, today)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, *lot)
+	}
+
+	return lots, rows.Err()
+}
+
+func scanLot(row scanner, today time.Time) (*models.StockLot, error) {
+	var lot models.StockLot
+	if err := row.Scan(&lot.Id, &lot.ProductId, &lot.WarehouseId, &lot.LotNumber, &lot.ExpiryDate, &lot.ReceivedAt, &lot.Quantity); err != nil {
+		return nil, err
+	}
+	lot.Expired = lot.IsExpired(today)
+	return &lot, nil
+}
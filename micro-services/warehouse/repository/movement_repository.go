@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type MovementRepository interface {
+	// RecordMovement appends to the ledger; it runs in the transaction that
+	// changes the stock.
+	RecordMovement(ctx context.Context, movement *models.StockMovement) error
+	GetMovements(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error)
+	// GetLedgerDiscrepancies compares every stock row with its movements.
+	GetLedgerDiscrepancies(ctx context.Context) ([]models.LedgerDiscrepancy, error)
+}
+
+type movementRepository struct {
+	db database.Querier
+}
+
+func NewMovementRepository(db *database.DB) MovementRepository {
+	return &movementRepository{db: db}
+}
+
+func (r *movementRepository) RecordMovement(ctx context.Context, movement *models.StockMovement) error {
+	// kept in UTC so date filters compare the same way on every backend
+	movement.CreatedAt = time.Now().UTC()
+
+	query := "INSERT INTO stock_movements (product_id, warehouse_id, movement_type, quantity, reference_id, actor, lot_number, reason, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, movement.ProductId, movement.WarehouseId, movement.Type, movement.Quantity,
+		movement.ReferenceId, movement.Actor, movement.LotNumber, movement.Reason, movement.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	movement.Id = id
+	return nil
+}
+
+func (r *movementRepository) GetMovements(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error) {
+	var conditions []string
+	var args []any
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "warehouse_id = ?")
+		args = append(args, filter.WarehouseId)
+	}
+	if filter.ReferenceId != "" {
+		conditions = append(conditions, "reference_id = ?")
+		args = append(args, filter.ReferenceId)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := "SELECT id, product_id, warehouse_id, movement_type, quantity, reference_id, actor, lot_number, reason, created_at FROM stock_movements"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		if err := rows.Scan(&movement.Id, &movement.ProductId, &movement.WarehouseId, &movement.Type, &movement.Quantity,
+			&movement.ReferenceId, &movement.Actor, &movement.LotNumber, &movement.Reason, &movement.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, rows.Err()
+}
+
+func (r *movementRepository) GetLedgerDiscrepancies(ctx context.Context) ([]models.LedgerDiscrepancy, error) {
+	query := `SELECT s.product_id, s.warehouse_id, s.quantity, COALESCE(SUM(m.quantity), 0)
+              FROM stocks s
+              LEFT JOIN stock_movements m ON m.product_id = s.product_id AND m.warehouse_id = s.warehouse_id
+              GROUP BY s.product_id, s.warehouse_id, s.quantity
+              HAVING s.quantity <> COALESCE(SUM(m.quantity), 0)
+              ORDER BY s.warehouse_id, s.product_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discrepancies := []models.LedgerDiscrepancy{}
+	for rows.Next() {
+		var discrepancy models.LedgerDiscrepancy
+		if err := rows.Scan(&discrepancy.ProductId, &discrepancy.WarehouseId, &discrepancy.Stock, &discrepancy.Ledger); err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/pkg/httpclient"
+)
+
+type OrderRepository interface {
+	// GetPendingStock returns, per product, the quantity held by orders that
+	// are not paid yet.
+	GetPendingStock(ctx context.Context) ([]PendingStock, error)
+	// GetPendingOrderIds lists the orders that are not paid or cancelled yet.
+	GetPendingOrderIds(ctx context.Context) ([]int64, error)
+}
+
+type orderRepository struct {
+	client *httpclient.Client
+}
+
+func NewOrderRepository(client *httpclient.Client) OrderRepository {
+	return &orderRepository{
+		client: client,
+	}
+}
+
+// PendingStock is the quantity of a product held by pending orders, which
+// checkout deducted from the product stock but the warehouses still hold.
+type PendingStock struct {
+	ProductId int64 `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+}
+
+func (r *orderRepository) GetPendingStock(ctx context.Context) ([]PendingStock, error) {
+	var pending []PendingStock
+	err := r.client.Get(ctx, "/orders/pending-stock", &pending)
+	if err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func (r *orderRepository) GetPendingOrderIds(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	err := r.client.Get(ctx, "/orders/pending-ids", &ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
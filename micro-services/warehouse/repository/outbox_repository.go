@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+// OutboxRepository keeps the products whose total stock still has to be
+// pushed to the product service.
+type OutboxRepository interface {
+	// MarkChanged queues the products; it runs in the transaction that
+	// changes their stock.
+	MarkChanged(ctx context.Context, productIds ...int64) error
+	// GetChanges returns up to limit queued products, oldest change first.
+	GetChanges(ctx context.Context, limit int) ([]models.StockChange, error)
+	// GetChange returns nil when the product is not queued.
+	GetChange(ctx context.Context, productId int64) (*models.StockChange, error)
+	// Acknowledge removes pushed products, keeping those that changed again
+	// since they were read.
+	Acknowledge(ctx context.Context, changes []models.StockChange) error
+}
+
+type outboxRepository struct {
+	db database.Querier
+}
+
+func NewOutboxRepository(db *database.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) MarkChanged(ctx context.Context, productIds ...int64) error {
+	query := `INSERT INTO stock_total_outbox (product_id, version, changed_at)
+              VALUES (?, 1, ?)
+              ON CONFLICT (product_id) DO UPDATE SET version = stock_total_outbox.version + 1, changed_at = excluded.changed_at`
+	for _, productId := range productIds {
+		if _, err := r.db.ExecContext(ctx, query, productId, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *outboxRepository) GetChanges(ctx context.Context, limit int) ([]models.StockChange, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT product_id, version, changed_at FROM stock_total_outbox ORDER BY changed_at, product_id LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.StockChange
+	for rows.Next() {
+		var change models.StockChange
+		if err := rows.Scan(&change.ProductId, &change.Version, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
+
+func (r *outboxRepository) GetChange(ctx context.Context, productId int64) (*models.StockChange, error) {
+	var change models.StockChange
+	row := r.db.QueryRowContext(ctx, "SELECT product_id, version, changed_at FROM stock_total_outbox WHERE product_id = ?", productId)
+	if err := row.Scan(&change.ProductId, &change.Version, &change.ChangedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func (r *outboxRepository) Acknowledge(ctx context.Context, changes []models.StockChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	conditions := make([]string, 0, len(changes))
+	args := make([]any, 0, 2*len(changes))
+	for _, change := range changes {
+		conditions = append(conditions, "(product_id = ? AND version = ?)")
+		args = append(args, change.ProductId, change.Version)
+	}
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM stock_total_outbox WHERE "+strings.Join(conditions, " OR "), args...)
+	return err
+}
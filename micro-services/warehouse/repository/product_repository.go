@@ -1,24 +1,33 @@
 package repository
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-
-	"github.com/parnurzeal/gorequest"
+	"monorepo-ecommerce/pkg/httpclient"
+	"strconv"
+	"strings"
 )
 
 type ProductRepository interface {
-	GetAllProducts() ([]Product, error)
-	UpdateTotalProductStock(productId int64, quantity int) error
+	// GetProducts returns up to limit products with an id above afterId, in
+	// id order.
+	GetProducts(ctx context.Context, afterId int64, limit int) ([]Product, error)
+	GetProductById(ctx context.Context, productId int64) (*Product, error)
+	// GetProductsByIds returns the products among productIds that exist. The
+	// product service looks up at most 500 ids at once.
+	GetProductsByIds(ctx context.Context, productIds []int64) ([]Product, error)
+	UpdateTotalProductStock(ctx context.Context, productId int64, quantity int) error
+	// UpdateTotalProductStocks sets the total stock of a batch of products.
+	UpdateTotalProductStocks(ctx context.Context, totals []StockTotal) (*StockTotalsResult, error)
 }
 
 type productRepository struct {
-	baseURL string
+	client *httpclient.Client
 }
 
-func NewProductRepository(baseURL string) ProductRepository {
+func NewProductRepository(client *httpclient.Client) ProductRepository {
 	return &productRepository{
-		baseURL: baseURL,
+		client: client,
 	}
 }
 
@@ -30,47 +39,69 @@ type Product struct {
 	Stock       int     `json:"stock"`
 }
 
-func (r *productRepository) GetAllProducts() ([]Product, error) {
-	url := fmt.Sprintf("%s/products", r.baseURL)
+// StockTotal is the total stock of a product as the product service takes it.
+type StockTotal struct {
+	ProductId int64 `json:"product_id"`
+	Stock     int   `json:"stock"`
+}
 
-	request := gorequest.New()
-	resp, body, errs := request.Get(url).
-		End()
+// StockTotalsResult lists the products of a batch the product service does
+// not know.
+type StockTotalsResult struct {
+	Updated int     `json:"updated"`
+	Missing []int64 `json:"missing"`
+}
 
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("failed to do a request: %v", errs)
+func (r *productRepository) GetProducts(ctx context.Context, afterId int64, limit int) ([]Product, error) {
+	var products []Product
+	err := r.client.Get(ctx, fmt.Sprintf("/products?after_id=%d&limit=%d", afterId, limit), &products)
+	if err != nil {
+		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("error occured: %v", resp.Status)
+	return products, nil
+}
+
+func (r *productRepository) GetProductById(ctx context.Context, productId int64) (*Product, error) {
+	var product Product
+	err := r.client.Get(ctx, fmt.Sprintf("/products/%d", productId), &product)
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (r *productRepository) GetProductsByIds(ctx context.Context, productIds []int64) ([]Product, error) {
+	ids := make([]string, 0, len(productIds))
+	for _, productId := range productIds {
+		ids = append(ids, strconv.FormatInt(productId, 10))
 	}
 
 	var products []Product
-	err := json.Unmarshal([]byte(body), &products)
+	err := r.client.Get(ctx, "/products?ids="+strings.Join(ids, ","), &products)
 	if err != nil {
-		return nil, fmt.Errorf("failed unmarshall JSON: %v", err)
+		return nil, err
 	}
 
 	return products, nil
 }
 
-func (r *productRepository) UpdateTotalProductStock(productId int64, quantity int) error {
-	url := fmt.Sprintf("%s/products/adjust-total-stock/%d", r.baseURL, productId)
-
+func (r *productRepository) UpdateTotalProductStock(ctx context.Context, productId int64, quantity int) error {
 	body := map[string]int{"quantity": quantity}
 
-	request := gorequest.New()
-	resp, _, errs := request.Post(url).
-		Send(body).
-		End()
+	// the total is absolute, so replaying it is harmless
+	return r.client.Post(ctx, fmt.Sprintf("/products/adjust-total-stock/%d", productId), body, nil, httpclient.Idempotent())
+}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to do a request: %v", errs)
-	}
+func (r *productRepository) UpdateTotalProductStocks(ctx context.Context, totals []StockTotal) (*StockTotalsResult, error) {
+	body := map[string][]StockTotal{"totals": totals}
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed deduct stock: %v", resp.Status)
+	var result StockTotalsResult
+	err := r.client.Post(ctx, "/products/stock-totals", body, &result, httpclient.Idempotent())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &result, nil
 }
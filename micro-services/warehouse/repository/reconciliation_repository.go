@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+)
+
+// ReconciliationRepository keeps the runs of the stock reconciliation and the
+// products each of them found out of line.
+type ReconciliationRepository interface {
+	// CreateReconciliation stores a run together with its lines.
+	CreateReconciliation(ctx context.Context, reconciliation *models.Reconciliation) error
+	GetReconciliationById(ctx context.Context, reconciliationId int64) (*models.Reconciliation, error)
+	// GetReconciliations returns up to limit runs, newest first, without
+	// their lines.
+	GetReconciliations(ctx context.Context, limit int) ([]models.Reconciliation, error)
+}
+
+type reconciliationRepository struct {
+	db database.Querier
+}
+
+func NewReconciliationRepository(db *database.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+const reconciliationColumns = "id, actor, apply, checked, complete, error, started_at, finished_at"
+
+func (r *reconciliationRepository) CreateReconciliation(ctx context.Context, reconciliation *models.Reconciliation) error {
+	query := "INSERT INTO stock_reconciliations (actor, apply, checked, complete, error, started_at, finished_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, reconciliation.Actor, reconciliation.Apply, reconciliation.Checked, reconciliation.Complete,
+		reconciliation.Error, reconciliation.StartedAt, reconciliation.FinishedAt)
+	if err != nil {
+		return err
+	}
+	reconciliation.Id = id
+
+	query = `INSERT INTO stock_reconciliation_lines (reconciliation_id, product_id, product_stock, warehouse_stock, pending_stock, expected_stock, cause, corrected, error)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for i := range reconciliation.Lines {
+		line := &reconciliation.Lines[i]
+		id, err := r.db.InsertReturningID(ctx, query, reconciliation.Id, line.ProductId, line.ProductStock, line.WarehouseStock, line.PendingStock,
+			line.ExpectedStock, line.Cause, line.Corrected, line.Error)
+		if err != nil {
+			return err
+		}
+		line.Id = id
+	}
+
+	return nil
+}
+
+func (r *reconciliationRepository) GetReconciliationById(ctx context.Context, reconciliationId int64) (*models.Reconciliation, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+reconciliationColumns+" FROM stock_reconciliations WHERE id = ?", reconciliationId)
+	reconciliation, err := scanReconciliation(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &apperror.NotFoundError{Resource: "reconciliation", Id: reconciliationId}
+		}
+		return nil, err
+	}
+
+	query := `SELECT id, product_id, product_stock, warehouse_stock, pending_stock, expected_stock, cause, corrected, error
+              FROM stock_reconciliation_lines WHERE reconciliation_id = ? ORDER BY product_id`
+	rows, err := r.db.QueryContext(ctx, query, reconciliationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reconciliation.Lines = []models.ReconciliationLine{}
+	for rows.Next() {
+		var line models.ReconciliationLine
+		if err := rows.Scan(&line.Id, &line.ProductId, &line.ProductStock, &line.WarehouseStock, &line.PendingStock,
+			&line.ExpectedStock, &line.Cause, &line.Corrected, &line.Error); err != nil {
+			return nil, err
+		}
+		reconciliation.Lines = append(reconciliation.Lines, line)
+	}
+
+	return reconciliation, rows.Err()
+}
+
+func (r *reconciliationRepository) GetReconciliations(ctx context.Context, limit int) ([]models.Reconciliation, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+reconciliationColumns+" FROM stock_reconciliations ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reconciliations := []models.Reconciliation{}
+	for rows.Next() {
+		reconciliation, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reconciliations = append(reconciliations, *reconciliation)
+	}
+
+	return reconciliations, rows.Err()
+}
+
+func scanReconciliation(row scanner) (*models.Reconciliation, error) {
+	var reconciliation models.Reconciliation
+	err := row.Scan(&reconciliation.Id, &reconciliation.Actor, &reconciliation.Apply, &reconciliation.Checked, &reconciliation.Complete,
+		&reconciliation.Error, &reconciliation.StartedAt, &reconciliation.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &reconciliation, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type ReplenishmentRepository interface {
+	// UpsertThreshold creates or replaces the threshold of a product in a
+	// warehouse, keeping whether it is low.
+	UpsertThreshold(ctx context.Context, threshold *models.StockThreshold) error
+	GetThresholds(ctx context.Context, filter models.ThresholdFilter) ([]models.StockThreshold, error)
+	// SetLowSince records when a threshold became low, nil once it is not.
+	SetLowSince(ctx context.Context, thresholdId int64, lowSince *time.Time) error
+	// ReplaceSuggestions drops the suggestions of the previous run.
+	ReplaceSuggestions(ctx context.Context, suggestions []models.ReplenishmentSuggestion) error
+	GetSuggestions(ctx context.Context, filter models.SuggestionFilter) ([]models.ReplenishmentSuggestion, error)
+}
+
+type replenishmentRepository struct {
+	db database.Querier
+}
+
+func NewReplenishmentRepository(db *database.DB) ReplenishmentRepository {
+	return &replenishmentRepository{db: db}
+}
+
+func (r *replenishmentRepository) UpsertThreshold(ctx context.Context, threshold *models.StockThreshold) error {
+	threshold.UpdatedAt = time.Now()
+
+	query := `INSERT INTO stock_thresholds (product_id, warehouse_id, reorder_point, safety_stock, updated_at)
+              VALUES (?, ?, ?, ?, ?)
+              ON CONFLICT (product_id, warehouse_id) DO UPDATE
+              SET reorder_point = excluded.reorder_point, safety_stock = excluded.safety_stock, updated_at = excluded.updated_at
+              RETURNING id, low_since`
+	return r.db.QueryRowContext(ctx, query, threshold.ProductId, threshold.WarehouseId, threshold.ReorderPoint,
+		threshold.SafetyStock, threshold.UpdatedAt).Scan(&threshold.Id, &threshold.LowSince)
+}
+
+func (r *replenishmentRepository) GetThresholds(ctx context.Context, filter models.ThresholdFilter) ([]models.StockThreshold, error) {
+	var conditions []string
+	var args []any
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "warehouse_id = ?")
+		args = append(args, filter.WarehouseId)
+	}
+
+	query := "SELECT id, product_id, warehouse_id, reorder_point, safety_stock, low_since, updated_at FROM stock_thresholds"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY product_id, warehouse_id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	thresholds := []models.StockThreshold{}
+	for rows.Next() {
+		var threshold models.StockThreshold
+		if err := rows.Scan(&threshold.Id, &threshold.ProductId, &threshold.WarehouseId, &threshold.ReorderPoint,
+			&threshold.SafetyStock, &threshold.LowSince, &threshold.UpdatedAt); err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	return thresholds, rows.Err()
+}
+
+func (r *replenishmentRepository) SetLowSince(ctx context.Context, thresholdId int64, lowSince *time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE stock_thresholds SET low_since = ? WHERE id = ?", lowSince, thresholdId)
+	return err
+}
+
+func (r *replenishmentRepository) ReplaceSuggestions(ctx context.Context, suggestions []models.ReplenishmentSuggestion) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM replenishment_suggestions"); err != nil {
+		return err
+	}
+
+	createdAt := time.Now()
+	query := `INSERT INTO replenishment_suggestions (product_id, warehouse_id, kind, from_warehouse_id, quantity, on_hand, in_transit,
+              reorder_point, safety_stock, daily_velocity, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for i := range suggestions {
+		suggestion := &suggestions[i]
+		suggestion.CreatedAt = createdAt
+		id, err := r.db.InsertReturningID(ctx, query, suggestion.ProductId, suggestion.WarehouseId, suggestion.Kind, suggestion.FromWarehouseId,
+			suggestion.Quantity, suggestion.OnHand, suggestion.InTransit, suggestion.ReorderPoint, suggestion.SafetyStock,
+			suggestion.DailyVelocity, suggestion.CreatedAt)
+		if err != nil {
+			return err
+		}
+		suggestion.Id = id
+	}
+
+	return nil
+}
+
+func (r *replenishmentRepository) GetSuggestions(ctx context.Context, filter models.SuggestionFilter) ([]models.ReplenishmentSuggestion, error) {
+	var conditions []string
+	var args []any
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "(warehouse_id = ? OR from_warehouse_id = ?)")
+		args = append(args, filter.WarehouseId, filter.WarehouseId)
+	}
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+
+	query := `SELECT id, product_id, warehouse_id, kind, from_warehouse_id, quantity, on_hand, in_transit,
+              reorder_point, safety_stock, daily_velocity, created_at FROM replenishment_suggestions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.ReplenishmentSuggestion{}
+	for rows.Next() {
+		var suggestion models.ReplenishmentSuggestion
+		if err := rows.Scan(&suggestion.Id, &suggestion.ProductId, &suggestion.WarehouseId, &suggestion.Kind, &suggestion.FromWarehouseId,
+			&suggestion.Quantity, &suggestion.OnHand, &suggestion.InTransit, &suggestion.ReorderPoint, &suggestion.SafetyStock,
+			&suggestion.DailyVelocity, &suggestion.CreatedAt); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
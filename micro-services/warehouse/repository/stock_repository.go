@@ -1,100 +1,212 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
 )
 
 type StockRepository interface {
-	AddStockToWarehouse(productId, warehouseId int64, quantity int) error
-	RemoveStockFromWarehouse(productId, warehouseId int64, quantity int) error
-	GetStockByProductAndWarehouse(productId, warehouseId int64) (*models.Stock, error)
-	UpdateStock(productID, warehouseID int64, newQuantity int) error
+	AddStockToWarehouse(ctx context.Context, productId, warehouseId int64, quantity int) error
+	RemoveStockFromWarehouse(ctx context.Context, productId, warehouseId int64, quantity int) error
+	// GetStockByProductAndWarehouse returns a zero stock when the warehouse
+	// has no row for the product yet.
+	GetStockByProductAndWarehouse(ctx context.Context, productId, warehouseId int64) (*models.Stock, error)
+	GetStocksByProduct(ctx context.Context, productId int64) ([]models.Stock, error)
+	// GetStocksByProducts returns the stock rows of several products at once.
+	GetStocksByProducts(ctx context.Context, productIds []int64) ([]models.Stock, error)
+	GetStocksByWarehouse(ctx context.Context, warehouseId int64) ([]models.Stock, error)
+	GetAllStocks(ctx context.Context) ([]models.Stock, error)
+	// EachStock calls fn for every stock row, by warehouse and product,
+	// without loading them all at once. An error from fn stops the iteration.
+	EachStock(ctx context.Context, fn func(stock models.Stock) error) error
+	// GetWarehouseStockTotal is the number of units a warehouse holds across products.
+	GetWarehouseStockTotal(ctx context.Context, warehouseId int64) (int, error)
+	UpdateStock(ctx context.Context, productID, warehouseID int64, newQuantity int) error
+	// CreateMissingStocksForProduct adds a zero row for the product to every
+	// warehouse that has none.
+	CreateMissingStocksForProduct(ctx context.Context, productId int64) error
+	// CreateMissingStocksForWarehouse adds a zero row to the warehouse for
+	// every product stocked anywhere else.
+	CreateMissingStocksForWarehouse(ctx context.Context, warehouseId int64) error
 }
 
 type stockRepository struct {
-	db *sql.DB
+	db database.Querier
 }
 
-func NewStockRepository(db *sql.DB) StockRepository {
+func NewStockRepository(db *database.DB) StockRepository {
 	return &stockRepository{db: db}
 }
 
-func (r *stockRepository) AddStockToWarehouse(productId, warehouseId int64, quantity int) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
+func (r *stockRepository) AddStockToWarehouse(ctx context.Context, productId, warehouseId int64, quantity int) error {
+	return r.adjustStock(ctx, productId, warehouseId, quantity)
+}
 
-	stock, err := r.GetStockByProductAndWarehouse(productId, warehouseId)
+func (r *stockRepository) RemoveStockFromWarehouse(ctx context.Context, productId, warehouseId int64, quantity int) error {
+	return r.adjustStock(ctx, productId, warehouseId, -quantity)
+}
+
+// adjustStock applies delta in one statement, so concurrent adjustments of
+// the same row cannot overwrite each other, and refuses to go below zero.
+// Adding stock creates the row when the warehouse has none for the product.
+func (r *stockRepository) adjustStock(ctx context.Context, productId, warehouseId int64, delta int) error {
+	var result sql.Result
+	var err error
+	if delta >= 0 {
+		query := `INSERT INTO stocks (warehouse_id, product_id, quantity)
+                  SELECT id, CAST(? AS BIGINT), CAST(? AS INTEGER) FROM warehouses WHERE id = ?
+                  ON CONFLICT (warehouse_id, product_id) DO UPDATE SET quantity = stocks.quantity + excluded.quantity`
+		result, err = r.db.ExecContext(ctx, query, productId, delta, warehouseId)
+	} else {
+		result, err = r.db.ExecContext(ctx, "UPDATE stocks SET quantity = quantity + ? WHERE warehouse_id = ? AND product_id = ? AND quantity + ? >= 0", delta, warehouseId, productId, delta)
+	}
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	newStock := stock.Quantity + quantity
-	_, err = tx.Exec("UPDATE stocks SET quantity = ? WHERE warehouse_id = ? AND product_id = ?", newStock, warehouseId, productId)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return err
+	if rowsAffected == 0 {
+		// tell a missing warehouse apart from too little stock
+		stock, err := r.GetStockByProductAndWarehouse(ctx, productId, warehouseId)
+		if err != nil {
+			return err
+		}
+		return &apperror.InsufficientStockError{ProductId: productId, Requested: -delta, Available: stock.Quantity}
 	}
 
 	return nil
 }
 
-func (r *stockRepository) RemoveStockFromWarehouse(productId, warehouseId int64, quantity int) error {
-	tx, err := r.db.Begin()
+func (r *stockRepository) GetStockByProductAndWarehouse(ctx context.Context, productId, warehouseId int64) (*models.Stock, error) {
+	stock := models.Stock{ProductId: productId, WarehouseId: warehouseId}
+	var id sql.NullInt64
+	var quantity sql.NullInt64
+
+	query := `SELECT s.id, s.quantity, ` + expiredColumn + `
+              FROM warehouses w
+              LEFT JOIN stocks s ON s.warehouse_id = w.id AND s.product_id = ?
+              WHERE w.id = ?`
+	err := r.db.QueryRowContext(ctx, query, models.Today(), productId, warehouseId).Scan(&id, &quantity, &stock.Expired)
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, &apperror.NotFoundError{Resource: "warehouse", Id: warehouseId}
+		}
+
+		return nil, err
 	}
 
-	stock, err := r.GetStockByProductAndWarehouse(productId, warehouseId)
-	if err != nil {
-		tx.Rollback()
-		return err
+	stock.Id = id.Int64
+	stock.Quantity = int(quantity.Int64)
+	return &stock, nil
+}
+
+// expiredColumn is the part of stock row s held in expired lots; it takes
+// today as its parameter.
+const expiredColumn = `(SELECT COALESCE(SUM(l.quantity), 0) FROM stock_lots l
+              WHERE l.product_id = s.product_id AND l.warehouse_id = s.warehouse_id AND l.expiry_date < ?)`
+
+const stockColumns = "s.id, s.product_id, s.warehouse_id, s.quantity, " + expiredColumn
+
+func (r *stockRepository) GetStocksByProduct(ctx context.Context, productId int64) ([]models.Stock, error) {
+	return r.queryStocks(ctx, "SELECT "+stockColumns+" FROM stocks s WHERE s.product_id = ?", productId)
+}
+
+func (r *stockRepository) GetStocksByProducts(ctx context.Context, productIds []int64) ([]models.Stock, error) {
+	if len(productIds) == 0 {
+		return nil, nil
 	}
 
-	newStock := stock.Quantity - quantity
-	_, err = tx.Exec("UPDATE stocks SET quantity = ? WHERE warehouse_id = ? AND product_id = ?", newStock, warehouseId, productId)
-	if err != nil {
-		tx.Rollback()
-		return err
+	args := make([]any, 0, len(productIds))
+	for _, productId := range productIds {
+		args = append(args, productId)
 	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(productIds)), ", ")
+	return r.queryStocks(ctx, "SELECT "+stockColumns+" FROM stocks s WHERE s.product_id IN ("+placeholders+") ORDER BY s.product_id, s.warehouse_id", args...)
+}
 
-	if err := tx.Commit(); err != nil {
-		return err
+func (r *stockRepository) GetStocksByWarehouse(ctx context.Context, warehouseId int64) ([]models.Stock, error) {
+	return r.queryStocks(ctx, "SELECT "+stockColumns+" FROM stocks s WHERE s.warehouse_id = ? ORDER BY s.product_id", warehouseId)
+}
+
+func (r *stockRepository) GetAllStocks(ctx context.Context) ([]models.Stock, error) {
+	return r.queryStocks(ctx, "SELECT "+stockColumns+" FROM stocks s ORDER BY s.warehouse_id, s.product_id")
+}
+
+func (r *stockRepository) EachStock(ctx context.Context, fn func(stock models.Stock) error) error {
+	return r.eachStock(ctx, fn, "SELECT "+stockColumns+" FROM stocks s ORDER BY s.warehouse_id, s.product_id")
+}
+
+func (r *stockRepository) GetWarehouseStockTotal(ctx context.Context, warehouseId int64) (int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(quantity), 0) FROM stocks WHERE warehouse_id = ?", warehouseId).Scan(&total)
+	return total, err
+}
+
+func (r *stockRepository) CreateMissingStocksForProduct(ctx context.Context, productId int64) error {
+	query := `INSERT INTO stocks (warehouse_id, product_id, quantity)
+              SELECT id, CAST(? AS BIGINT), 0 FROM warehouses WHERE true
+              ON CONFLICT (warehouse_id, product_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, productId)
+	return err
+}
+
+func (r *stockRepository) CreateMissingStocksForWarehouse(ctx context.Context, warehouseId int64) error {
+	query := `INSERT INTO stocks (warehouse_id, product_id, quantity)
+              SELECT DISTINCT CAST(? AS BIGINT), product_id, 0 FROM stocks WHERE warehouse_id <> ?
+              ON CONFLICT (warehouse_id, product_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, warehouseId, warehouseId)
+	return err
+}
+
+// queryStocks runs a query selecting stockColumns.
+func (r *stockRepository) queryStocks(ctx context.Context, query string, args ...any) ([]models.Stock, error) {
+	var stocks []models.Stock
+	err := r.eachStock(ctx, func(stock models.Stock) error {
+		stocks = append(stocks, stock)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return stocks, nil
 }
 
-func (r *stockRepository) GetStockByProductAndWarehouse(productId, warehouseId int64) (*models.Stock, error) {
-	var stock models.Stock
-	row := r.db.QueryRow("SELECT id, product_id, warehouse_id, quantity FROM stocks WHERE product_id = ? AND warehouse_id = ?", productId, warehouseId)
-	err := row.Scan(&stock.Id, &stock.ProductId, &stock.WarehouseId, &stock.Quantity)
+func (r *stockRepository) eachStock(ctx context.Context, fn func(stock models.Stock) error, query string, args ...any) error {
+	rows, err := r.db.QueryContext(ctx, query, append([]any{models.Today()}, args...)...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("stock with product Id %d and warehouse Id %d not found", productId, warehouseId)
-		}
+		return err
+	}
+	defer rows.Close()
 
-		return nil, err
+	for rows.Next() {
+		var stock models.Stock
+		if err := rows.Scan(&stock.Id, &stock.ProductId, &stock.WarehouseId, &stock.Quantity, &stock.Expired); err != nil {
+			return err
+		}
+		if err := fn(stock); err != nil {
+			return err
+		}
 	}
 
-	return &stock, nil
+	return rows.Err()
 }
 
-func (r *stockRepository) UpdateStock(productID, warehouseID int64, newQuantity int) error {
+func (r *stockRepository) UpdateStock(ctx context.Context, productID, warehouseID int64, newQuantity int) error {
 	query := `UPDATE stocks
               SET quantity = ? 
               WHERE product_id = ? AND warehouse_id = ?`
 
-	result, err := r.db.Exec(query, newQuantity, productID, warehouseID)
+	result, err := r.db.ExecContext(ctx, query, newQuantity, productID, warehouseID)
 	if err != nil {
 		return err
 	}
@@ -105,7 +217,7 @@ func (r *stockRepository) UpdateStock(productID, warehouseID int64, newQuantity
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("no stock record found to update")
+		return fmt.Errorf("product Id %d in warehouse Id %d: %w", productID, warehouseID, &apperror.NotFoundError{Resource: "stock"})
 	}
 
 	return nil
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type StocktakeRepository interface {
+	CreateStocktake(ctx context.Context, stocktake *models.Stocktake) error
+	GetStocktakeById(ctx context.Context, stocktakeId int64) (*models.Stocktake, error)
+	GetStocktakes(ctx context.Context, filter models.StocktakeFilter) ([]models.Stocktake, error)
+	// CloseStocktake saves the status, reason and closer of a stocktake that
+	// is still open.
+	CloseStocktake(ctx context.Context, stocktake *models.Stocktake) error
+	// RecordCount creates or replaces the count of a product.
+	RecordCount(ctx context.Context, line *models.StocktakeLine) error
+	GetLines(ctx context.Context, stocktakeId int64) ([]models.StocktakeLine, error)
+	// GetFrozenWarehouseIds lists the warehouses with an open stocktake that
+	// freezes movements.
+	GetFrozenWarehouseIds(ctx context.Context) ([]int64, error)
+}
+
+type stocktakeRepository struct {
+	db database.Querier
+}
+
+func NewStocktakeRepository(db *database.DB) StocktakeRepository {
+	return &stocktakeRepository{db: db}
+}
+
+const stocktakeColumns = "id, warehouse_id, status, freeze_movements, reason, opened_by, closed_by, created_at, closed_at"
+
+func (r *stocktakeRepository) CreateStocktake(ctx context.Context, stocktake *models.Stocktake) error {
+	stocktake.Status = models.StocktakeOpen
+	stocktake.CreatedAt = time.Now()
+
+	query := "INSERT INTO stocktakes (warehouse_id, status, freeze_movements, opened_by, created_at) VALUES (?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, stocktake.WarehouseId, stocktake.Status, stocktake.FreezeMovements, stocktake.OpenedBy, stocktake.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	stocktake.Id = id
+	return nil
+}
+
+func (r *stocktakeRepository) GetStocktakeById(ctx context.Context, stocktakeId int64) (*models.Stocktake, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+stocktakeColumns+" FROM stocktakes WHERE id = ?", stocktakeId)
+	stocktake, err := scanStocktake(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &apperror.NotFoundError{Resource: "stocktake", Id: stocktakeId}
+		}
+		return nil, err
+	}
+
+	return stocktake, nil
+}
+
+func (r *stocktakeRepository) GetStocktakes(ctx context.Context, filter models.StocktakeFilter) ([]models.Stocktake, error) {
+	var conditions []string
+	var args []any
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "warehouse_id = ?")
+		args = append(args, filter.WarehouseId)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := "SELECT " + stocktakeColumns + " FROM stocktakes"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := []models.Stocktake{}
+	for rows.Next() {
+		stocktake, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, *stocktake)
+	}
+
+	return stocktakes, rows.Err()
+}
+
+func (r *stocktakeRepository) CloseStocktake(ctx context.Context, stocktake *models.Stocktake) error {
+	closedAt := time.Now()
+
+	result, err := r.db.ExecContext(ctx, "UPDATE stocktakes SET status = ?, reason = ?, closed_by = ?, closed_at = ? WHERE id = ? AND status = ?",
+		stocktake.Status, stocktake.Reason, stocktake.ClosedBy, closedAt, stocktake.Id, models.StocktakeOpen)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return &apperror.ConflictError{Resource: "stocktake", Reason: fmt.Sprintf("stocktake %d is no longer open", stocktake.Id)}
+	}
+
+	stocktake.ClosedAt = &closedAt
+	return nil
+}
+
+func (r *stocktakeRepository) RecordCount(ctx context.Context, line *models.StocktakeLine) error {
+	line.CountedAt = time.Now()
+	line.Variance = line.CountedQuantity - line.SystemQuantity
+
+	query := `INSERT INTO stocktake_lines (stocktake_id, product_id, system_quantity, counted_quantity, counted_by, counted_at)
+              VALUES (?, ?, ?, ?, ?, ?)
+              ON CONFLICT (stocktake_id, product_id) DO UPDATE
+              SET system_quantity = excluded.system_quantity, counted_quantity = excluded.counted_quantity,
+                  counted_by = excluded.counted_by, counted_at = excluded.counted_at`
+	id, err := r.db.InsertReturningID(ctx, query, line.StocktakeId, line.ProductId, line.SystemQuantity, line.CountedQuantity, line.CountedBy, line.CountedAt)
+	if err != nil {
+		return err
+	}
+
+	line.Id = id
+	return nil
+}
+
+func (r *stocktakeRepository) GetLines(ctx context.Context, stocktakeId int64) ([]models.StocktakeLine, error) {
+	query := "SELECT id, stocktake_id, product_id, system_quantity, counted_quantity, counted_by, counted_at FROM stocktake_lines WHERE stocktake_id = ? ORDER BY product_id"
+	rows, err := r.db.QueryContext(ctx, query, stocktakeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.StocktakeLine{}
+	for rows.Next() {
+		var line models.StocktakeLine
+		if err := rows.Scan(&line.Id, &line.StocktakeId, &line.ProductId, &line.SystemQuantity, &line.CountedQuantity,
+			&line.CountedBy, &line.CountedAt); err != nil {
+			return nil, err
+		}
+		line.Variance = line.CountedQuantity - line.SystemQuantity
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+func (r *stocktakeRepository) GetFrozenWarehouseIds(ctx context.Context) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT warehouse_id FROM stocktakes WHERE status = ? AND freeze_movements = ?", models.StocktakeOpen, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouseIds []int64
+	for rows.Next() {
+		var warehouseId int64
+		if err := rows.Scan(&warehouseId); err != nil {
+			return nil, err
+		}
+		warehouseIds = append(warehouseIds, warehouseId)
+	}
+
+	return warehouseIds, rows.Err()
+}
+
+func scanStocktake(row scanner) (*models.Stocktake, error) {
+	var stocktake models.Stocktake
+	err := row.Scan(&stocktake.Id, &stocktake.WarehouseId, &stocktake.Status, &stocktake.FreezeMovements, &stocktake.Reason,
+		&stocktake.OpenedBy, &stocktake.ClosedBy, &stocktake.CreatedAt, &stocktake.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &stocktake, nil
+}
@@ -0,0 +1,30 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"monorepo-ecommerce/pkg/migration"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("should keep 0001 as it was first applied", func(t *testing.T) {
+		all, err := migration.Load(migrations.For(database.SQLite))
+		assert.NoError(t, err)
+
+		// databases migrated before the split recorded this checksum
+		assert.Equal(t, "06f7b512ee783592992e16fde2db4e469e714222f870406f95b60d3db81efac9", all[0].Checksum)
+	})
+
+	t.Run("should hold stock of products it has never seen", func(t *testing.T) {
+		db := dbtest.Open(t, "warehouse", migrations.For)
+
+		_, err := db.ExecContext(context.Background(), "INSERT INTO stocks (warehouse_id, product_id, quantity) VALUES (1, 99, 5)")
+
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,343 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openDB returns a migrated warehouse database; it seeds Warehouse A (1) and
+// Warehouse B (2) with 25 units each of product 1.
+func openDB(t *testing.T) *database.DB {
+	return dbtest.Open(t, "warehouse", migrations.For)
+}
+
+func quantity(t *testing.T, stockRepo repository.StockRepository, productId, warehouseId int64) int {
+	stock, err := stockRepo.GetStockByProductAndWarehouse(context.Background(), productId, warehouseId)
+	assert.NoError(t, err)
+	return stock.Quantity
+}
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should commit every step together", func(t *testing.T) {
+		db := openDB(t)
+		stockRepo := repository.NewStockRepository(db)
+
+		err := repository.NewUnitOfWork(db).WithTx(ctx, func(repos repository.Repositories) error {
+			if err := repos.Stock.RemoveStockFromWarehouse(ctx, 1, 1, 10); err != nil {
+				return err
+			}
+			return repos.Stock.AddStockToWarehouse(ctx, 1, 2, 10)
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 15, quantity(t, stockRepo, 1, 1))
+		assert.Equal(t, 35, quantity(t, stockRepo, 1, 2))
+	})
+
+	t.Run("should roll back earlier steps when a later one fails", func(t *testing.T) {
+		db := openDB(t)
+		stockRepo := repository.NewStockRepository(db)
+
+		err := repository.NewUnitOfWork(db).WithTx(ctx, func(repos repository.Repositories) error {
+			if err := repos.Stock.RemoveStockFromWarehouse(ctx, 1, 1, 10); err != nil {
+				return err
+			}
+			// reads inside the transaction see its own writes
+			if got := quantity(t, repos.Stock, 1, 1); got != 15 {
+				return errors.New("read outside the transaction")
+			}
+			return repos.Stock.AddStockToWarehouse(ctx, 1, 99, 10)
+		})
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+		assert.Equal(t, 25, quantity(t, stockRepo, 1, 1))
+	})
+
+	t.Run("should read committed data without waiting for a running transaction", func(t *testing.T) {
+		db := openDB(t)
+		uow := repository.NewUnitOfWork(db)
+
+		err := uow.WithTx(ctx, func(repos repository.Repositories) error {
+			if err := repos.Stock.RemoveStockFromWarehouse(ctx, 1, 1, 10); err != nil {
+				return err
+			}
+			assert.Equal(t, 25, quantity(t, uow.Read().Stock, 1, 1))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 15, quantity(t, uow.Read().Stock, 1, 1))
+	})
+}
+
+func TestStockRepository(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	stockRepo := repository.NewStockRepository(db)
+
+	t.Run("should refuse to go below zero", func(t *testing.T) {
+		err := stockRepo.RemoveStockFromWarehouse(ctx, 1, 1, 26)
+
+		var insufficient *apperror.InsufficientStockError
+		assert.True(t, errors.As(err, &insufficient))
+		assert.Equal(t, 25, insufficient.Available)
+		assert.Equal(t, 25, quantity(t, stockRepo, 1, 1))
+	})
+
+	t.Run("should create the row when adding a new product", func(t *testing.T) {
+		assert.NoError(t, stockRepo.AddStockToWarehouse(ctx, 42, 1, 3))
+		assert.NoError(t, stockRepo.AddStockToWarehouse(ctx, 42, 1, 4))
+
+		assert.Equal(t, 7, quantity(t, stockRepo, 42, 1))
+	})
+
+	t.Run("should read a missing row as zero", func(t *testing.T) {
+		stock, err := stockRepo.GetStockByProductAndWarehouse(ctx, 43, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, stock.Quantity)
+	})
+
+	t.Run("should refuse to remove from a missing row", func(t *testing.T) {
+		err := stockRepo.RemoveStockFromWarehouse(ctx, 43, 2, 1)
+
+		var insufficient *apperror.InsufficientStockError
+		assert.True(t, errors.As(err, &insufficient))
+		assert.Equal(t, 0, insufficient.Available)
+	})
+
+	t.Run("should report an unknown warehouse", func(t *testing.T) {
+		assert.ErrorIs(t, stockRepo.AddStockToWarehouse(ctx, 1, 99, 1), apperror.ErrNotFound)
+
+		_, err := stockRepo.GetStockByProductAndWarehouse(ctx, 1, 99)
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+
+	t.Run("should create zero rows for a new product or warehouse", func(t *testing.T) {
+		assert.NoError(t, stockRepo.CreateMissingStocksForProduct(ctx, 44))
+		stocks, err := stockRepo.GetStocksByProduct(ctx, 44)
+		assert.NoError(t, err)
+		assert.Len(t, stocks, 2)
+
+		warehouse := models.Warehouse{Name: "Warehouse C", Status: "active"}
+		assert.NoError(t, repository.NewWarehouseRepository(db).CreateWarehouse(ctx, &warehouse))
+		assert.NoError(t, stockRepo.CreateMissingStocksForWarehouse(ctx, warehouse.Id))
+		stocks, err = stockRepo.GetStocksByWarehouse(ctx, warehouse.Id)
+		assert.NoError(t, err)
+		// products 1, 2, 3, 42 and 44, none of them stocked
+		assert.Len(t, stocks, 5)
+		for _, stock := range stocks {
+			assert.Equal(t, 0, stock.Quantity)
+		}
+
+		// existing rows are left alone
+		assert.NoError(t, stockRepo.CreateMissingStocksForProduct(ctx, 42))
+		assert.Equal(t, 7, quantity(t, stockRepo, 42, 1))
+	})
+}
+
+func TestWarehouseRepository(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+
+	t.Run("should update status by id", func(t *testing.T) {
+		assert.NoError(t, warehouseRepo.UpdateWarehouseStatus(ctx, 2, "inactive"))
+
+		warehouse, err := warehouseRepo.GetWarehouseById(ctx, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "inactive", warehouse.Status)
+	})
+
+	t.Run("should report unknown warehouse", func(t *testing.T) {
+		err := warehouseRepo.UpdateWarehouseStatus(ctx, 99, "inactive")
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+func TestAllocationRepository(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	allocationRepo := repository.NewAllocationRepository(db)
+
+	allocation := models.StockAllocation{OrderId: 7, ProductId: 1, WarehouseId: 2, Quantity: 5, Strategy: "priority"}
+	assert.NoError(t, allocationRepo.CreateAllocation(ctx, &allocation))
+
+	t.Run("should list the allocations of an order", func(t *testing.T) {
+		allocations, err := allocationRepo.GetAllocationsByOrder(ctx, 7)
+
+		assert.NoError(t, err)
+		assert.Len(t, allocations, 1)
+		assert.Equal(t, allocation.Id, allocations[0].Id)
+		assert.Equal(t, models.AllocationAllocated, allocations[0].Status)
+		assert.Nil(t, allocations[0].ReleasedAt)
+	})
+
+	t.Run("should refuse a second row for the same order line", func(t *testing.T) {
+		duplicate := models.StockAllocation{OrderId: 7, ProductId: 1, WarehouseId: 2, Quantity: 5, Strategy: "priority"}
+
+		assert.ErrorIs(t, allocationRepo.CreateAllocation(ctx, &duplicate), apperror.ErrConflict)
+	})
+
+	t.Run("should list as open only the allocations of the given orders", func(t *testing.T) {
+		other := models.StockAllocation{OrderId: 8, ProductId: 1, WarehouseId: 2, Quantity: 1, Strategy: "priority"}
+		assert.NoError(t, allocationRepo.CreateAllocation(ctx, &other))
+
+		open, err := allocationRepo.GetOpenAllocationsByWarehouse(ctx, 2, []int64{8, 9})
+		assert.NoError(t, err)
+		assert.Len(t, open, 1)
+		assert.Equal(t, other.Id, open[0].Id)
+
+		open, err = allocationRepo.GetOpenAllocationsByWarehouse(ctx, 2, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, open)
+	})
+
+	t.Run("should release an allocation only once", func(t *testing.T) {
+		assert.NoError(t, allocationRepo.ReleaseAllocation(ctx, allocation.Id))
+		assert.ErrorIs(t, allocationRepo.ReleaseAllocation(ctx, allocation.Id), apperror.ErrNotFound)
+
+		allocations, err := allocationRepo.GetAllocationsByOrder(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, models.AllocationReleased, allocations[0].Status)
+		assert.NotNil(t, allocations[0].ReleasedAt)
+	})
+}
+
+func TestWarehouseRepository_GetActiveWarehouses(t *testing.T) {
+	warehouses, err := repository.NewWarehouseRepository(openDB(t)).GetActiveWarehouses(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, warehouses, 2)
+	assert.Equal(t, "Warehouse A", warehouses[0].Name)
+	assert.Equal(t, 1, warehouses[0].Priority)
+	assert.NotNil(t, warehouses[0].Location)
+}
+
+func TestWarehouseRepository_Metadata(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+
+	capacity := 300
+	warehouse := models.Warehouse{
+		Name:           "Warehouse C",
+		Status:         "active",
+		Priority:       3,
+		Location:       &models.Location{Latitude: -6.9175, Longitude: 107.6191},
+		Address:        "Bandung",
+		Capacity:       &capacity,
+		ContactName:    "Dewi",
+		ContactPhone:   "+62 22 555 0100",
+		OperatingHours: "09:00-18:00",
+	}
+	assert.NoError(t, warehouseRepo.CreateWarehouse(ctx, &warehouse))
+
+	t.Run("should read back a created warehouse", func(t *testing.T) {
+		found, err := warehouseRepo.GetWarehouseById(ctx, warehouse.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, warehouse, *found)
+	})
+
+	t.Run("should update metadata but not status", func(t *testing.T) {
+		updated := warehouse
+		updated.Capacity = nil
+		updated.Address = "Bandung, Jawa Barat"
+		updated.Status = "inactive"
+		assert.NoError(t, warehouseRepo.UpdateWarehouse(ctx, &updated))
+
+		found, err := warehouseRepo.GetWarehouseById(ctx, warehouse.Id)
+		assert.NoError(t, err)
+		assert.Nil(t, found.Capacity)
+		assert.Equal(t, "Bandung, Jawa Barat", found.Address)
+		assert.Equal(t, "active", found.Status)
+	})
+
+	t.Run("should report unknown warehouse on update", func(t *testing.T) {
+		err := warehouseRepo.UpdateWarehouse(ctx, &models.Warehouse{Id: 99, Name: "Nowhere"})
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+
+	t.Run("should list every warehouse by priority", func(t *testing.T) {
+		assert.NoError(t, warehouseRepo.UpdateWarehouseStatus(ctx, 1, "inactive"))
+
+		warehouses, err := warehouseRepo.GetWarehouses(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, warehouses, 3)
+		assert.Equal(t, "Jakarta", warehouses[0].Address)
+		assert.Equal(t, "Warehouse C", warehouses[2].Name)
+	})
+
+	t.Run("should total the stock of a warehouse", func(t *testing.T) {
+		stocks, err := stockRepo.GetStocksByWarehouse(ctx, 1)
+		assert.NoError(t, err)
+		sum := 0
+		for _, stock := range stocks {
+			sum += stock.Quantity
+		}
+
+		total, err := stockRepo.GetWarehouseStockTotal(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, sum, total)
+
+		total, err = stockRepo.GetWarehouseStockTotal(ctx, warehouse.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, total)
+	})
+}
+
+func TestMovementRepository(t *testing.T) {
+	ctx := context.Background()
+	movementRepo := repository.NewMovementRepository(openDB(t))
+
+	movement := models.StockMovement{ProductId: 1, WarehouseId: 2, Type: models.MovementReceipt, Quantity: 4, ReferenceId: "po-17", Actor: "ops"}
+	assert.NoError(t, movementRepo.RecordMovement(ctx, &movement))
+
+	t.Run("should filter by product and warehouse", func(t *testing.T) {
+		movements, err := movementRepo.GetMovements(ctx, models.MovementFilter{ProductId: 1, WarehouseId: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, movements, 2)
+		assert.Equal(t, "opening-balance", movements[0].ReferenceId)
+		assert.Equal(t, movement.Id, movements[1].Id)
+		assert.Equal(t, "po-17", movements[1].ReferenceId)
+	})
+
+	t.Run("should filter by date", func(t *testing.T) {
+		from := movement.CreatedAt
+		to := movement.CreatedAt.Add(time.Second)
+
+		movements, err := movementRepo.GetMovements(ctx, models.MovementFilter{From: &from, To: &to})
+		assert.NoError(t, err)
+		assert.Len(t, movements, 1)
+
+		// the opening balances were written by the migration, before it
+		movements, err = movementRepo.GetMovements(ctx, models.MovementFilter{To: &from})
+		assert.NoError(t, err)
+		assert.Len(t, movements, 6)
+		assert.NotContains(t, movements, movement)
+	})
+
+	t.Run("should report the stock rows the ledger does not explain", func(t *testing.T) {
+		discrepancies, err := movementRepo.GetLedgerDiscrepancies(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.LedgerDiscrepancy{{ProductId: 1, WarehouseId: 2, Stock: 25, Ledger: 29}}, discrepancies)
+	})
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
+	"strings"
+	"time"
+)
+
+type TransferRepository interface {
+	CreateTransfer(ctx context.Context, transfer *models.TransferOrder) error
+	GetTransferById(ctx context.Context, transferId int64) (*models.TransferOrder, error)
+	GetTransfers(ctx context.Context, filter models.TransferFilter) ([]models.TransferOrder, error)
+	// UpdateTransfer saves the status and received quantity of a transfer
+	// that is still in fromStatus.
+	UpdateTransfer(ctx context.Context, transfer *models.TransferOrder, fromStatus string) error
+	GetInTransitStock(ctx context.Context) ([]models.InTransitStock, error)
+}
+
+type transferRepository struct {
+	db database.Querier
+}
+
+func NewTransferRepository(db *database.DB) TransferRepository {
+	return &transferRepository{db: db}
+}
+
+const transferColumns = "id, product_id, from_warehouse_id, to_warehouse_id, quantity, received_quantity, status, created_at, updated_at"
+
+func (r *transferRepository) CreateTransfer(ctx context.Context, transfer *models.TransferOrder) error {
+	transfer.Status = models.TransferRequested
+	transfer.ReceivedQuantity = 0
+	transfer.CreatedAt = time.Now()
+	transfer.UpdatedAt = transfer.CreatedAt
+
+	query := "INSERT INTO transfer_orders (product_id, from_warehouse_id, to_warehouse_id, quantity, received_quantity, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	id, err := r.db.InsertReturningID(ctx, query, transfer.ProductId, transfer.FromWarehouseId, transfer.ToWarehouseId, transfer.Quantity,
+		transfer.ReceivedQuantity, transfer.Status, transfer.CreatedAt, transfer.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	transfer.Id = id
+	return nil
+}
+
+func (r *transferRepository) GetTransferById(ctx context.Context, transferId int64) (*models.TransferOrder, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+transferColumns+" FROM transfer_orders WHERE id = ?", transferId)
+	transfer, err := scanTransfer(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &apperror.NotFoundError{Resource: "transfer", Id: transferId}
+		}
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func (r *transferRepository) GetTransfers(ctx context.Context, filter models.TransferFilter) ([]models.TransferOrder, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.ProductId != 0 {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, filter.ProductId)
+	}
+	if filter.WarehouseId != 0 {
+		conditions = append(conditions, "(from_warehouse_id = ? OR to_warehouse_id = ?)")
+		args = append(args, filter.WarehouseId, filter.WarehouseId)
+	}
+
+	query := "SELECT " + transferColumns + " FROM transfer_orders"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []models.TransferOrder{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+func (r *transferRepository) UpdateTransfer(ctx context.Context, transfer *models.TransferOrder, fromStatus string) error {
+	transfer.UpdatedAt = time.Now()
+
+	result, err := r.db.ExecContext(ctx, "UPDATE transfer_orders SET status = ?, received_quantity = ?, updated_at = ? WHERE id = ? AND status = ?",
+		transfer.Status, transfer.ReceivedQuantity, transfer.UpdatedAt, transfer.Id, fromStatus)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return &apperror.ConflictError{Resource: "transfer", Reason: fmt.Sprintf("transfer %d is no longer %s", transfer.Id, fromStatus)}
+	}
+
+	return nil
+}
+
+func (r *transferRepository) GetInTransitStock(ctx context.Context) ([]models.InTransitStock, error) {
+	query := `SELECT product_id, to_warehouse_id, SUM(quantity - received_quantity)
+              FROM transfer_orders
+              WHERE status IN (?, ?)
+              GROUP BY product_id, to_warehouse_id
+              ORDER BY product_id, to_warehouse_id`
+
+	rows, err := r.db.QueryContext(ctx, query, models.TransferDispatched, models.TransferInTransit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocks := []models.InTransitStock{}
+	for rows.Next() {
+		var stock models.InTransitStock
+		if err := rows.Scan(&stock.ProductId, &stock.ToWarehouseId, &stock.Quantity); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, stock)
+	}
+
+	return stocks, rows.Err()
+}
+
+func scanTransfer(row scanner) (*models.TransferOrder, error) {
+	var transfer models.TransferOrder
+	err := row.Scan(&transfer.Id, &transfer.ProductId, &transfer.FromWarehouseId, &transfer.ToWarehouseId, &transfer.Quantity,
+		&transfer.ReceivedQuantity, &transfer.Status, &transfer.CreatedAt, &transfer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
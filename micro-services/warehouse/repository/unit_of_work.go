@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"monorepo-ecommerce/pkg/database"
+)
+
+// Repositories are the repositories bound to one transaction.
+type Repositories struct {
+	// Warehouse holds the warehouses and their metadata.
+	Warehouse WarehouseRepository
+	// Stock holds the quantity of each product per warehouse.
+	Stock StockRepository
+	// Allocation records which warehouses an order was taken from.
+	Allocation AllocationRepository
+	// Audit records changes made to warehouses.
+	Audit AuditRepository
+	// Movement is the stock ledger.
+	Movement MovementRepository
+	// Transfer holds transfer orders between warehouses.
+	Transfer TransferRepository
+	// Replenishment holds stock thresholds and replenishment suggestions.
+	Replenishment ReplenishmentRepository
+	// Stocktake holds stocktakes and their counted lines.
+	Stocktake StocktakeRepository
+	// Lot holds the stock of each lot and its expiry date.
+	Lot LotRepository
+	// Outbox queues products whose total stock changed.
+	Outbox OutboxRepository
+	// Reconciliation stores stock reconciliation reports.
+	Reconciliation ReconciliationRepository
+}
+
+type UnitOfWork interface {
+	// WithTx runs fn with repositories sharing one transaction, which is
+	// committed when fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(repos Repositories) error) error
+	// Read returns repositories outside any transaction, whose queries go to
+	// the reader pool. It is meant for reads that need no consistent snapshot
+	// and must not wait for the writer.
+	Read() Repositories
+}
+
+type unitOfWork struct {
+	db *database.DB
+}
+
+func NewUnitOfWork(db *database.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) WithTx(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.db.WithTx(ctx, func(tx *database.Tx) error {
+		return fn(repositories(tx))
+	})
+}
+
+func (u *unitOfWork) Read() Repositories {
+	return repositories(u.db)
+}
+
+func repositories(db database.Querier) Repositories {
+	return Repositories{
+		Warehouse:      &warehouseRepository{db: db},
+		Stock:          &stockRepository{db: db},
+		Allocation:     &allocationRepository{db: db},
+		Audit:          &auditRepository{db: db},
+		Movement:       &movementRepository{db: db},
+		Transfer:       &transferRepository{db: db},
+		Replenishment:  &replenishmentRepository{db: db},
+		Stocktake:      &stocktakeRepository{db: db},
+		Lot:            &lotRepository{db: db},
+		Outbox:         &outboxRepository{db: db},
+		Reconciliation: &reconciliationRepository{db: db},
+	}
+}
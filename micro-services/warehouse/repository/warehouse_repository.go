@@ -1,46 +1,99 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database"
 )
 
 type WarehouseRepository interface {
-	UpdateWarehouseStatus(warehouseId int64, status string) error
-	GetActiveWarehouses() ([]models.Warehouse, error)
-	GetWarehouseById(warehouseId int64) (*models.Warehouse, error)
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	UpdateWarehouseStatus(ctx context.Context, warehouseId int64, status string) error
+	GetWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	GetActiveWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	GetWarehouseById(ctx context.Context, warehouseId int64) (*models.Warehouse, error)
 }
 
 type warehouseRepository struct {
-	db *sql.DB
+	db database.Querier
 }
 
-func NewWarehouseRepository(db *sql.DB) WarehouseRepository {
+func NewWarehouseRepository(db *database.DB) WarehouseRepository {
 	return &warehouseRepository{db: db}
 }
 
-func (r *warehouseRepository) UpdateWarehouseStatus(warehouseId int64, status string) error {
-	tx, err := r.db.Begin()
+func (r *warehouseRepository) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	latitude, longitude := locationColumns(warehouse.Location)
+	query := `INSERT INTO warehouses (name, status, priority, latitude, longitude, address, capacity, contact_name, contact_phone, operating_hours)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	id, err := r.db.InsertReturningID(ctx, query, warehouse.Name, warehouse.Status, warehouse.Priority, latitude, longitude,
+		warehouse.Address, warehouse.Capacity, warehouse.ContactName, warehouse.ContactPhone, warehouse.OperatingHours)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE warehouses SET status = ? WHERE warehouse_id = ?", status, warehouseId)
+	warehouse.Id = id
+	return nil
+}
+
+// UpdateWarehouse replaces the metadata of a warehouse; its status is only
+// changed through UpdateWarehouseStatus.
+func (r *warehouseRepository) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	latitude, longitude := locationColumns(warehouse.Location)
+	query := `UPDATE warehouses
+              SET name = ?, priority = ?, latitude = ?, longitude = ?, address = ?, capacity = ?, contact_name = ?, contact_phone = ?, operating_hours = ?
+              WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, warehouse.Name, warehouse.Priority, latitude, longitude, warehouse.Address,
+		warehouse.Capacity, warehouse.ContactName, warehouse.ContactPhone, warehouse.OperatingHours, warehouse.Id)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
 		return err
 	}
 
+	if rowsAffected == 0 {
+		return &apperror.NotFoundError{Resource: "warehouse", Id: warehouse.Id}
+	}
+
 	return nil
 }
 
-func (r *warehouseRepository) GetActiveWarehouses() ([]models.Warehouse, error) {
-	rows, err := r.db.Query("SELECT id, name, status FROM warehouses WHERE status = ?", "active")
+func (r *warehouseRepository) UpdateWarehouseStatus(ctx context.Context, warehouseId int64, status string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE warehouses SET status = ? WHERE id = ?", status, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return &apperror.NotFoundError{Resource: "warehouse", Id: warehouseId}
+	}
+
+	return nil
+}
+
+func (r *warehouseRepository) GetWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	return r.queryWarehouses(ctx, "SELECT "+warehouseColumns+" FROM warehouses ORDER BY priority, id")
+}
+
+func (r *warehouseRepository) GetActiveWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	return r.queryWarehouses(ctx, "SELECT "+warehouseColumns+" FROM warehouses WHERE status = ? ORDER BY priority, id", "active")
+}
+
+func (r *warehouseRepository) queryWarehouses(ctx context.Context, query string, args ...any) ([]models.Warehouse, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,27 +101,59 @@ func (r *warehouseRepository) GetActiveWarehouses() ([]models.Warehouse, error)
 
 	var warehouses []models.Warehouse
 	for rows.Next() {
-		var warehouse models.Warehouse
-		if err := rows.Scan(&warehouse.Id, &warehouse.Name, &warehouse.Status); err != nil {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
 			return nil, err
 		}
-		warehouses = append(warehouses, warehouse)
+		warehouses = append(warehouses, *warehouse)
 	}
 
-	return warehouses, nil
+	return warehouses, rows.Err()
 }
 
-func (r *warehouseRepository) GetWarehouseById(warehouseId int64) (*models.Warehouse, error) {
-	var warehouse models.Warehouse
-	row := r.db.QueryRow("SELECT id, name, status FROM warehouses WHERE id = ?", warehouseId)
-	err := row.Scan(&warehouse.Id, &warehouse.Name, &warehouse.Status)
+func (r *warehouseRepository) GetWarehouseById(ctx context.Context, warehouseId int64) (*models.Warehouse, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+warehouseColumns+" FROM warehouses WHERE id = ?", warehouseId)
+	warehouse, err := scanWarehouse(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("warehouse Id %d not found", warehouseId)
+			return nil, &apperror.NotFoundError{Resource: "warehouse", Id: warehouseId}
 		}
 
 		return nil, err
 	}
 
+	return warehouse, nil
+}
+
+const warehouseColumns = "id, name, status, priority, latitude, longitude, address, capacity, contact_name, contact_phone, operating_hours"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWarehouse(row scanner) (*models.Warehouse, error) {
+	var warehouse models.Warehouse
+	var latitude, longitude sql.NullFloat64
+	var capacity sql.NullInt64
+	if err := row.Scan(&warehouse.Id, &warehouse.Name, &warehouse.Status, &warehouse.Priority, &latitude, &longitude,
+		&warehouse.Address, &capacity, &warehouse.ContactName, &warehouse.ContactPhone, &warehouse.OperatingHours); err != nil {
+		return nil, err
+	}
+
+	if latitude.Valid && longitude.Valid {
+		warehouse.Location = &models.Location{Latitude: latitude.Float64, Longitude: longitude.Float64}
+	}
+	if capacity.Valid {
+		units := int(capacity.Int64)
+		warehouse.Capacity = &units
+	}
+
 	return &warehouse, nil
 }
+
+func locationColumns(location *models.Location) (*float64, *float64) {
+	if location == nil {
+		return nil, nil
+	}
+	return &location.Latitude, &location.Longitude
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"time"
+)
+
+// defaultReconciliationLimit is the number of runs listed when no limit is
+// given.
+const defaultReconciliationLimit = 20
+
+// ReconciliationService compares the stock of the product service with what
+// the warehouses hold less what pending orders hold. Checkout deducts the
+// product stock while the warehouses only ship on payment, so the two only
+// add up once the pending orders are taken into account.
+type ReconciliationService interface {
+	// Reconcile checks the given products, or every product page by page, and
+	// stores the run with the products that did not add up. With Apply, the
+	// product service takes the expected stock of those products. A page or
+	// product that fails is reported on the run and skipped.
+	Reconcile(ctx context.Context, options models.ReconcileOptions) (*models.Reconciliation, error)
+	GetReconciliation(ctx context.Context, reconciliationId int64) (*models.Reconciliation, error)
+	// ListReconciliations returns up to limit runs, newest first.
+	ListReconciliations(ctx context.Context, limit int) ([]models.Reconciliation, error)
+}
+
+type reconciliationService struct {
+	uow         repository.UnitOfWork
+	productRepo repository.ProductRepository
+	orderRepo   repository.OrderRepository
+	// batchSize is the number of products checked at once.
+	batchSize int
+}
+
+func NewReconciliationService(uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, batchSize int) ReconciliationService {
+	return &reconciliationService{
+		uow:         uow,
+		productRepo: productRepo,
+		orderRepo:   orderRepo,
+		batchSize:   batchSize,
+	}
+}
+
+func (s *reconciliationService) Reconcile(ctx context.Context, options models.ReconcileOptions) (*models.Reconciliation, error) {
+	for _, productId := range options.ProductIds {
+		if productId <= 0 {
+			return nil, &apperror.InvalidInputError{Field: "product_ids", Reason: "must be positive"}
+		}
+	}
+
+	reconciliation := &models.Reconciliation{Actor: actorFromContext(ctx), Apply: options.Apply, StartedAt: time.Now().UTC()}
+
+	// read before the warehouse totals, so an order paid in between is at
+	// worst counted twice and shows up as a discrepancy on the next run only
+	pending, err := pendingStock(ctx, s.orderRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options.ProductIds) > 0 {
+		s.reconcileProducts(ctx, options.ProductIds, pending, reconciliation)
+	} else {
+		s.reconcileAll(ctx, pending, reconciliation)
+	}
+	reconciliation.FinishedAt = time.Now().UTC()
+
+	err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		return repos.Reconciliation.CreateReconciliation(ctx, reconciliation)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation: %w", err)
+	}
+
+	return reconciliation, nil
+}
+
+// reconcileAll pages through every product of the product service.
+func (s *reconciliationService) reconcileAll(ctx context.Context, pending map[int64]int, reconciliation *models.Reconciliation) {
+	var afterId int64
+	for {
+		products, err := s.productRepo.GetProducts(ctx, afterId, s.batchSize)
+		if err != nil {
+			reconciliation.Error = fmt.Sprintf("failed to fetch products after %d: %v", afterId, err)
+			return
+		}
+
+		s.reconcilePage(ctx, products, pending, reconciliation)
+		if len(products) < s.batchSize {
+			reconciliation.Complete = true
+			return
+		}
+		afterId = products[len(products)-1].Id
+	}
+}
+
+// reconcileProducts fetches the given products one by one and checks them a
+// batch at a time.
+func (s *reconciliationService) reconcileProducts(ctx context.Context, productIds []int64, pending map[int64]int, reconciliation *models.Reconciliation) {
+	var products []repository.Product
+	for _, productId := range productIds {
+		product, err := s.productRepo.GetProductById(ctx, productId)
+		if err != nil {
+			reconciliation.Lines = append(reconciliation.Lines, models.ReconciliationLine{
+				ProductId: productId,
+				Cause:     models.CauseUnchecked,
+				Error:     fmt.Sprintf("failed to fetch product: %v", err),
+			})
+			continue
+		}
+		products = append(products, *product)
+	}
+
+	for start := 0; start < len(products); start += s.batchSize {
+		s.reconcilePage(ctx, products[start:min(start+s.batchSize, len(products))], pending, reconciliation)
+	}
+	reconciliation.Complete = true
+}
+
+// reconcilePage checks one page of products, creating the stock rows new
+// products still miss, and with Apply corrects those that did not add up.
+func (s *reconciliationService) reconcilePage(ctx context.Context, products []repository.Product, pending map[int64]int, reconciliation *models.Reconciliation) {
+	if len(products) == 0 {
+		return
+	}
+
+	productIds := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIds = append(productIds, product.Id)
+	}
+
+	var totals []repository.StockTotal
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		for _, productId := range productIds {
+			if err := repos.Stock.CreateMissingStocksForProduct(ctx, productId); err != nil {
+				return fmt.Errorf("failed to create stock rows for product %d: %w", productId, err)
+			}
+		}
+
+		var err error
+		totals, err = warehouseTotals(ctx, repos, productIds)
+		return err
+	})
+	if err != nil {
+		for _, product := range products {
+			reconciliation.Lines = append(reconciliation.Lines, models.ReconciliationLine{
+				ProductId:    product.Id,
+				ProductStock: product.Stock,
+				PendingStock: pending[product.Id],
+				Cause:        models.CauseUnchecked,
+				Error:        err.Error(),
+			})
+		}
+		return
+	}
+	reconciliation.Checked += len(products)
+
+	first := len(reconciliation.Lines)
+	var corrections []repository.StockTotal
+	for i, product := range products {
+		line := models.ReconciliationFor(product.Id, product.Stock, totals[i].Stock, pending[product.Id])
+		if line == nil {
+			continue
+		}
+		reconciliation.Lines = append(reconciliation.Lines, *line)
+		corrections = append(corrections, repository.StockTotal{ProductId: product.Id, Stock: line.ExpectedStock})
+	}
+	if !reconciliation.Apply || len(corrections) == 0 {
+		return
+	}
+
+	lines := reconciliation.Lines[first:]
+	result, err := s.productRepo.UpdateTotalProductStocks(ctx, corrections)
+	if err != nil {
+		for i := range lines {
+			lines[i].Error = fmt.Sprintf("failed forward stock total: %v", err)
+		}
+		return
+	}
+
+	missing := map[int64]bool{}
+	for _, productId := range result.Missing {
+		missing[productId] = true
+	}
+	for i := range lines {
+		if missing[lines[i].ProductId] {
+			lines[i].Error = "product no longer exists"
+			continue
+		}
+		lines[i].Corrected = true
+	}
+}
+
+func (s *reconciliationService) GetReconciliation(ctx context.Context, reconciliationId int64) (*models.Reconciliation, error) {
+	reconciliation, err := s.uow.Read().Reconciliation.GetReconciliationById(ctx, reconciliationId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reconciliation: %w", err)
+	}
+
+	return reconciliation, nil
+}
+
+func (s *reconciliationService) ListReconciliations(ctx context.Context, limit int) ([]models.Reconciliation, error) {
+	if limit < 0 {
+		return nil, &apperror.InvalidInputError{Field: "limit", Reason: "must not be negative"}
+	}
+	if limit == 0 {
+		limit = defaultReconciliationLimit
+	}
+
+	reconciliations, err := s.uow.Read().Reconciliation.GetReconciliations(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliations: %w", err)
+	}
+
+	return reconciliations, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/replenishment"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"time"
+)
+
+// ReplenishmentService keeps reorder points per product and warehouse and
+// turns recent allocation velocity into replenishment suggestions.
+type ReplenishmentService interface {
+	SetThreshold(ctx context.Context, threshold models.StockThreshold) (*models.StockThreshold, error)
+	ListThresholds(ctx context.Context, filter models.ThresholdFilter) ([]models.StockThreshold, error)
+	// ListSuggestions returns the suggestions of the latest run.
+	ListSuggestions(ctx context.Context, filter models.SuggestionFilter) ([]models.ReplenishmentSuggestion, error)
+	// Replenish measures every threshold, replaces the suggestions and
+	// reports the products that became low since the previous run.
+	Replenish(ctx context.Context) (*models.ReplenishmentRun, error)
+}
+
+type replenishmentService struct {
+	uow       repository.UnitOfWork
+	lookback  time.Duration
+	coverDays int
+}
+
+// NewReplenishmentService measures velocity over lookback and sizes top-ups
+// to last coverDays.
+func NewReplenishmentService(uow repository.UnitOfWork, lookback time.Duration, coverDays int) ReplenishmentService {
+	return &replenishmentService{uow: uow, lookback: lookback, coverDays: coverDays}
+}
+
+func (s *replenishmentService) SetThreshold(ctx context.Context, threshold models.StockThreshold) (*models.StockThreshold, error) {
+	if threshold.ProductId <= 0 {
+		return nil, &apperror.InvalidInputError{Field: "product_id", Reason: "is required"}
+	}
+	if threshold.ReorderPoint < 0 {
+		return nil, &apperror.InvalidInputError{Field: "reorder_point", Reason: "must not be negative"}
+	}
+	if threshold.SafetyStock < 0 {
+		return nil, &apperror.InvalidInputError{Field: "safety_stock", Reason: "must not be negative"}
+	}
+
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		if _, err := repos.Warehouse.GetWarehouseById(ctx, threshold.WarehouseId); err != nil {
+			return err
+		}
+		return repos.Replenishment.UpsertThreshold(ctx, &threshold)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set threshold: %w", err)
+	}
+
+	return &threshold, nil
+}
+
+func (s *replenishmentService) ListThresholds(ctx context.Context, filter models.ThresholdFilter) ([]models.StockThreshold, error) {
+	thresholds, err := s.uow.Read().Replenishment.GetThresholds(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list thresholds: %w", err)
+	}
+
+	return thresholds, nil
+}
+
+func (s *replenishmentService) ListSuggestions(ctx context.Context, filter models.SuggestionFilter) ([]models.ReplenishmentSuggestion, error) {
+	if filter.Kind != "" && filter.Kind != models.SuggestReorder && filter.Kind != models.SuggestTransfer {
+		return nil, &apperror.InvalidInputError{Field: "kind", Reason: fmt.Sprintf("must be %s or %s", models.SuggestReorder, models.SuggestTransfer)}
+	}
+
+	suggestions, err := s.uow.Read().Replenishment.GetSuggestions(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replenishment suggestions: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+func (s *replenishmentService) Replenish(ctx context.Context) (*models.ReplenishmentRun, error) {
+	now := time.Now()
+	run := &models.ReplenishmentRun{Suggestions: []models.ReplenishmentSuggestion{}, LowStock: []models.LowStockEvent{}}
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		input, err := s.snapshot(ctx, repos, now)
+		if err != nil {
+			return err
+		}
+
+		plan := replenishment.Build(*input)
+		for _, position := range plan.Positions {
+			threshold := position.Threshold
+			switch {
+			case position.Low() && threshold.LowSince == nil:
+				if err := repos.Replenishment.SetLowSince(ctx, threshold.Id, &now); err != nil {
+					return err
+				}
+				run.LowStock = append(run.LowStock, models.LowStockEvent{
+					ProductId:     threshold.ProductId,
+					WarehouseId:   threshold.WarehouseId,
+					OnHand:        position.OnHand,
+					InTransit:     position.InTransit,
+					ReorderPoint:  threshold.ReorderPoint,
+					SafetyStock:   threshold.SafetyStock,
+					DailyVelocity: position.DailyVelocity,
+					DetectedAt:    now,
+				})
+			case !position.Low() && threshold.LowSince != nil:
+				if err := repos.Replenishment.SetLowSince(ctx, threshold.Id, nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		if plan.Suggestions != nil {
+			run.Suggestions = plan.Suggestions
+		}
+		return repos.Replenishment.ReplaceSuggestions(ctx, run.Suggestions)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run replenishment: %w", err)
+	}
+
+	return run, nil
+}
+
+// snapshot reads the thresholds with the stock, in-transit quantities and
+// allocations they are measured against.
+func (s *replenishmentService) snapshot(ctx context.Context, repos repository.Repositories, now time.Time) (*replenishment.Input, error) {
+	thresholds, err := repos.Replenishment.GetThresholds(ctx, models.ThresholdFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	warehouses, err := repos.Warehouse.GetActiveWarehouses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &replenishment.Input{
+		Thresholds:   thresholds,
+		Warehouses:   warehouses,
+		OnHand:       map[replenishment.Key]int{},
+		InTransit:    map[replenishment.Key]int{},
+		Allocated:    map[replenishment.Key]int{},
+		LookbackDays: s.lookback.Hours() / 24,
+		CoverDays:    s.coverDays,
+	}
+
+	products := map[int64]bool{}
+	for _, threshold := range thresholds {
+		if products[threshold.ProductId] {
+			continue
+		}
+		products[threshold.ProductId] = true
+
+		stocks, err := repos.Stock.GetStocksByProduct(ctx, threshold.ProductId)
+		if err != nil {
+			return nil, err
+		}
+		for _, stock := range stocks {
+			input.OnHand[replenishment.Key{ProductId: stock.ProductId, WarehouseId: stock.WarehouseId}] = stock.Sellable()
+		}
+	}
+
+	inTransit, err := repos.Transfer.GetInTransitStock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stock := range inTransit {
+		input.InTransit[replenishment.Key{ProductId: stock.ProductId, WarehouseId: stock.ToWarehouseId}] = stock.Quantity
+	}
+
+	allocated, err := repos.Allocation.GetAllocatedQuantities(ctx, now.Add(-s.lookback))
+	if err != nil {
+		return nil, err
+	}
+	for _, quantity := range allocated {
+		input.Allocated[replenishment.Key{ProductId: quantity.ProductId, WarehouseId: quantity.WarehouseId}] = quantity.Quantity
+	}
+
+	return input, nil
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"strconv"
+	"strings"
+)
+
+// maxImportRows is the largest number of rows one stock import takes.
+const maxImportRows = 10000
+
+// importReference marks ledger entries posted by a stock import.
+const importReference = "import"
+
+// errImportRolledBack rolls back a stock import that is only previewed.
+var errImportRolledBack = errors.New("stock import rolled back")
+
+// productLookupBatch is the number of products an import looks up in the
+// product service at once.
+const productLookupBatch = 500
+
+// importColumns are the header names each column of a stock import may go
+// by. Products have no SKU, so the product column holds the product id.
+var importColumns = map[string][]string{
+	"warehouse": {"warehouse", "warehouse_id"},
+	"product":   {"product", "product_id"},
+	"quantity":  {"quantity"},
+	"mode":      {"mode"},
+}
+
+// exportHeader is the header of a stock export. Its rows set the quantity, so
+// an edited export can be imported again; warehouse_name is for reading only.
+var exportHeader = []string{"warehouse", "warehouse_name", "product", "quantity", "mode"}
+
+// StockImportService loads and dumps the stock of every warehouse as CSV.
+type StockImportService interface {
+	// ImportStock reads CSV rows of warehouse (id or name), product id,
+	// quantity and mode (set or add) and applies them in one transaction.
+	// Every row is checked and reported with the quantity it leads to; when
+	// dryRun is set or any row is invalid, nothing is applied.
+	ImportStock(ctx context.Context, r io.Reader, dryRun bool) (*models.StockImport, error)
+	// ExportStock writes every stock row as CSV to w while reading it.
+	ExportStock(ctx context.Context, w io.Writer) error
+}
+
+type stockImportService struct {
+	uow           repository.UnitOfWork
+	warehouseRepo repository.WarehouseRepository
+	stockRepo     repository.StockRepository
+	productRepo   repository.ProductRepository
+	orderRepo     repository.OrderRepository
+}
+
+func NewStockImportService(uow repository.UnitOfWork, warehouseRepo repository.WarehouseRepository, stockRepo repository.StockRepository, productRepo repository.ProductRepository, orderRepo repository.OrderRepository) StockImportService {
+	return &stockImportService{
+		uow:           uow,
+		warehouseRepo: warehouseRepo,
+		stockRepo:     stockRepo,
+		productRepo:   productRepo,
+		orderRepo:     orderRepo,
+	}
+}
+
+func (s *stockImportService) ImportStock(ctx context.Context, r io.Reader, dryRun bool) (*models.StockImport, error) {
+	rows, err := readImportRows(r)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.StockImport{DryRun: dryRun, Rows: rows}
+
+	if err := s.checkProducts(ctx, result.Rows); err != nil {
+		return nil, err
+	}
+
+	var changed []int64
+	err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		warehouses, err := repos.Warehouse.GetWarehouses(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get warehouses: %w", err)
+		}
+
+		seen := map[int64]bool{}
+		for i := range result.Rows {
+			row := &result.Rows[i]
+			resolveWarehouse(row, warehouses)
+			if len(row.Errors) > 0 {
+				continue
+			}
+
+			delta, err := applyImportRow(ctx, repos, row)
+			if err != nil {
+				// refused before anything was written, so the transaction goes on
+				if !errors.Is(err, apperror.ErrConflict) && !errors.Is(err, apperror.ErrInvalidInput) {
+					return fmt.Errorf("failed to import line %d: %w", row.Line, err)
+				}
+				row.Errors = append(row.Errors, err.Error())
+				continue
+			}
+			if delta != 0 && !seen[row.ProductId] {
+				seen[row.ProductId] = true
+				changed = append(changed, row.ProductId)
+			}
+		}
+
+		for _, row := range result.Rows {
+			if len(row.Errors) > 0 {
+				result.Invalid++
+			}
+		}
+		if dryRun || result.Invalid > 0 {
+			return errImportRolledBack
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		return nil, fmt.Errorf("failed to import stock: %w", err)
+	}
+	result.Applied = err == nil
+	if !result.Applied {
+		return result, nil
+	}
+
+	for _, productId := range changed {
+		syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, productId)
+	}
+
+	return result, nil
+}
+
+// readImportRows parses the CSV and checks each row on its own. Rows with
+// errors are kept, so every problem of the file is reported at once.
+func readImportRows(r io.Reader) ([]models.StockImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &apperror.InvalidInputError{Field: "file", Reason: "is empty"}
+	}
+	if err != nil {
+		return nil, importReadError(err)
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.StockImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, importReadError(err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, &apperror.InvalidInputError{Field: "file", Reason: fmt.Sprintf("must not have more than %d rows", maxImportRows)}
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, parseImportRow(line, record, columns))
+	}
+	if len(rows) == 0 {
+		return nil, &apperror.InvalidInputError{Field: "file", Reason: "has no rows"}
+	}
+
+	return rows, nil
+}
+
+func importReadError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &apperror.InvalidInputError{Field: "file", Reason: parseErr.Error()}
+	}
+	return fmt.Errorf("failed to read stock import: %w", err)
+}
+
+// importHeader maps each column to its position in the header. Columns it
+// does not know are ignored.
+func importHeader(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		// spreadsheets often save UTF-8 with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, aliases := range importColumns {
+			for _, alias := range aliases {
+				if name != alias {
+					continue
+				}
+				if _, ok := columns[column]; ok {
+					return nil, &apperror.InvalidInputError{Field: "file", Reason: fmt.Sprintf("has more than one %s column", column)}
+				}
+				columns[column] = i
+			}
+		}
+	}
+
+	for _, column := range []string{"warehouse", "product", "quantity", "mode"} {
+		if _, ok := columns[column]; !ok {
+			return nil, &apperror.InvalidInputError{Field: "file", Reason: fmt.Sprintf("has no %s column", column)}
+		}
+	}
+	return columns, nil
+}
+
+func parseImportRow(line int, record []string, columns map[string]int) models.StockImportRow {
+	field := func(column string) string {
+		if i := columns[column]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := models.StockImportRow{Line: line, Warehouse: field("warehouse"), Mode: strings.ToLower(field("mode"))}
+	if row.Warehouse == "" {
+		row.Errors = append(row.Errors, "warehouse is required")
+	}
+
+	productId, err := strconv.ParseInt(field("product"), 10, 64)
+	if err != nil || productId <= 0 {
+		row.Errors = append(row.Errors, fmt.Sprintf("product %q is not a product id", field("product")))
+	} else {
+		row.ProductId = productId
+	}
+
+	if row.Mode != models.ImportModeSet && row.Mode != models.ImportModeAdd {
+		row.Errors = append(row.Errors, fmt.Sprintf("mode %q must be set or add", row.Mode))
+	}
+
+	quantity, err := strconv.Atoi(field("quantity"))
+	switch {
+	case err != nil:
+		row.Errors = append(row.Errors, fmt.Sprintf("quantity %q is not a whole number", field("quantity")))
+	case quantity < 0:
+		row.Errors = append(row.Errors, "quantity must not be negative")
+	case quantity == 0 && row.Mode == models.ImportModeAdd:
+		row.Errors = append(row.Errors, "quantity must be positive to add")
+	default:
+		row.Quantity = quantity
+	}
+
+	return row
+}
+
+// checkProducts looks the products of the import up in the product service,
+// a batch at a time, and reports the rows of products it does not know.
+func (s *stockImportService) checkProducts(ctx context.Context, rows []models.StockImportRow) error {
+	var productIds []int64
+	known := map[int64]bool{}
+	for _, row := range rows {
+		if row.ProductId == 0 {
+			continue
+		}
+		if _, ok := known[row.ProductId]; !ok {
+			known[row.ProductId] = false
+			productIds = append(productIds, row.ProductId)
+		}
+	}
+
+	for start := 0; start < len(productIds); start += productLookupBatch {
+		products, err := s.productRepo.GetProductsByIds(ctx, productIds[start:min(start+productLookupBatch, len(productIds))])
+		if err != nil {
+			return fmt.Errorf("failed to validate products: %w", err)
+		}
+		for _, product := range products {
+			known[product.Id] = true
+		}
+	}
+
+	for i := range rows {
+		if rows[i].ProductId != 0 && !known[rows[i].ProductId] {
+			rows[i].Errors = append(rows[i].Errors, fmt.Sprintf("product %d does not exist", rows[i].ProductId))
+		}
+	}
+	return nil
+}
+
+// resolveWarehouse finds the warehouse of a row by id or, failing that, by
+// its name, ignoring case.
+func resolveWarehouse(row *models.StockImportRow, warehouses []models.Warehouse) {
+	if row.Warehouse == "" {
+		return
+	}
+
+	if warehouseId, err := strconv.ParseInt(row.Warehouse, 10, 64); err == nil {
+		for _, warehouse := range warehouses {
+			if warehouse.Id == warehouseId {
+				row.WarehouseId = warehouseId
+				return
+			}
+		}
+	}
+
+	var matches []int64
+	for _, warehouse := range warehouses {
+		if strings.EqualFold(warehouse.Name, row.Warehouse) {
+			matches = append(matches, warehouse.Id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		row.Errors = append(row.Errors, fmt.Sprintf("warehouse %q does not exist", row.Warehouse))
+	case 1:
+		row.WarehouseId = matches[0]
+	default:
+		row.Errors = append(row.Errors, fmt.Sprintf("warehouse name %q is not unique, use its id", row.Warehouse))
+	}
+}
+
+// applyImportRow posts a row to the ledger and returns the change in stock.
+// The transaction already holds the rows above it, so the stock read is the
+// one the row applies to.
+func applyImportRow(ctx context.Context, repos repository.Repositories, row *models.StockImportRow) (int, error) {
+	stock, err := repos.Stock.GetStockByProductAndWarehouse(ctx, row.ProductId, row.WarehouseId)
+	if err != nil {
+		return 0, err
+	}
+
+	movement := models.StockMovement{
+		ProductId:   row.ProductId,
+		WarehouseId: row.WarehouseId,
+		Type:        models.MovementReceipt,
+		Quantity:    row.Quantity,
+		ReferenceId: importReference,
+	}
+	if row.Mode == models.ImportModeSet {
+		movement.Type = models.MovementAdjustment
+		movement.Quantity = row.Quantity - stock.Quantity
+		movement.Reason = fmt.Sprintf("stock import line %d", row.Line)
+	}
+	row.Previous = stock.Quantity
+	row.New = stock.Quantity + movement.Quantity
+
+	if movement.Quantity == 0 {
+		return 0, nil
+	}
+	if movement.Quantity > 0 {
+		if err := checkCapacity(ctx, repos, row.WarehouseId, movement.Quantity); err != nil {
+			return 0, err
+		}
+	}
+	if err := applyMovement(ctx, repos, movement); err != nil {
+		return 0, err
+	}
+
+	return movement.Quantity, nil
+}
+
+func (s *stockImportService) ExportStock(ctx context.Context, w io.Writer) error {
+	warehouses, err := s.warehouseRepo.GetWarehouses(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get warehouses: %w", err)
+	}
+	names := map[int64]string{}
+	for _, warehouse := range warehouses {
+		names[warehouse.Id] = warehouse.Name
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportHeader); err != nil {
+		return err
+	}
+	err = s.stockRepo.EachStock(ctx, func(stock models.Stock) error {
+		return writer.Write([]string{
+			strconv.FormatInt(stock.WarehouseId, 10),
+			names[stock.WarehouseId],
+			strconv.FormatInt(stock.ProductId, 10),
+			strconv.Itoa(stock.Quantity),
+			models.ImportModeSet,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export stock: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
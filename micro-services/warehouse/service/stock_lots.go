@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+)
+
+// takeLots splits a removal over the lots it comes out of. Order allocations
+// skip expired lots, every other removal takes them first.
+func takeLots(ctx context.Context, repos repository.Repositories, movement models.StockMovement) ([]models.StockMovement, error) {
+	lots, err := repos.Lot.GetLots(ctx, models.LotFilter{ProductId: movement.ProductId, WarehouseId: movement.WarehouseId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lots of product %d: %w", movement.ProductId, err)
+	}
+
+	requested := -movement.Quantity
+	remaining := requested
+	var parts []models.StockMovement
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		if movement.LotNumber != models.DefaultLot && lot.LotNumber != movement.LotNumber {
+			continue
+		}
+		if lot.Expired && movement.Type == models.MovementOrderAllocation {
+			continue
+		}
+
+		quantity := min(remaining, lot.Quantity)
+		part := movement
+		part.Quantity = -quantity
+		part.LotNumber = lot.LotNumber
+		parts = append(parts, part)
+		remaining -= quantity
+	}
+
+	if remaining > 0 {
+		return nil, &apperror.InsufficientStockError{ProductId: movement.ProductId, Requested: requested, Available: requested - remaining}
+	}
+	return parts, nil
+}
+
+// lotQuantity is a quantity of one lot.
+type lotQuantity struct {
+	LotNumber string
+	Quantity  int
+}
+
+func lotsOf(movements []models.StockMovement) []lotQuantity {
+	lots := make([]lotQuantity, 0, len(movements))
+	for _, movement := range movements {
+		lots = append(lots, lotQuantity{LotNumber: movement.LotNumber, Quantity: -movement.Quantity})
+	}
+	return lots
+}
+
+// pendingLots reads from the ledger which lots the movements of reference
+// took out of fromWarehouseId, less what they already put into
+// intoWarehouseId, in the order they were taken.
+func pendingLots(ctx context.Context, repos repository.Repositories, reference string, productId, fromWarehouseId, intoWarehouseId int64) ([]lotQuantity, error) {
+	movements, err := repos.Movement.GetMovements(ctx, models.MovementFilter{ProductId: productId, ReferenceId: reference})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get movements of %s: %w", reference, err)
+	}
+
+	var lots []lotQuantity
+	index := map[string]int{}
+	booked := map[string]int{}
+	for _, movement := range movements {
+		switch {
+		case movement.Quantity < 0 && movement.WarehouseId == fromWarehouseId:
+			if _, ok := index[movement.LotNumber]; !ok {
+				index[movement.LotNumber] = len(lots)
+				lots = append(lots, lotQuantity{LotNumber: movement.LotNumber})
+			}
+			lots[index[movement.LotNumber]].Quantity -= movement.Quantity
+		case movement.Quantity > 0 && movement.WarehouseId == intoWarehouseId:
+			booked[movement.LotNumber] += movement.Quantity
+		}
+	}
+
+	pending := lots[:0]
+	for _, lot := range lots {
+		lot.Quantity -= booked[lot.LotNumber]
+		if lot.Quantity > 0 {
+			pending = append(pending, lot)
+		}
+	}
+	return pending, nil
+}
+
+// bookLots adds movement.Quantity to movement.WarehouseId, spread over lots
+// in order and keeping the expiry dates they have in fromWarehouseId. What
+// the lots do not cover, such as stock moved before lots were tracked, goes
+// into the default lot.
+func bookLots(ctx context.Context, repos repository.Repositories, movement models.StockMovement, fromWarehouseId int64, lots []lotQuantity) error {
+	remaining := movement.Quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		if err := copyLot(ctx, repos, movement.ProductId, fromWarehouseId, movement.WarehouseId, lot.LotNumber); err != nil {
+			return err
+		}
+
+		part := movement
+		part.Quantity = min(remaining, lot.Quantity)
+		part.LotNumber = lot.LotNumber
+		if err := applyMovement(ctx, repos, part); err != nil {
+			return err
+		}
+		remaining -= part.Quantity
+	}
+
+	if remaining > 0 {
+		part := movement
+		part.Quantity = remaining
+		part.LotNumber = models.DefaultLot
+		return applyMovement(ctx, repos, part)
+	}
+	return nil
+}
+
+// copyLot creates a lot in toWarehouseId with the dates it has in
+// fromWarehouseId.
+func copyLot(ctx context.Context, repos repository.Repositories, productId, fromWarehouseId, toWarehouseId int64, lotNumber string) error {
+	if fromWarehouseId == toWarehouseId || lotNumber == models.DefaultLot {
+		return nil
+	}
+
+	source, err := repos.Lot.GetLot(ctx, productId, fromWarehouseId, lotNumber)
+	if err != nil {
+		return err
+	}
+
+	return ensureLot(ctx, repos, &models.StockLot{
+		ProductId:   productId,
+		WarehouseId: toWarehouseId,
+		LotNumber:   lotNumber,
+		ExpiryDate:  source.ExpiryDate,
+		ReceivedAt:  source.ReceivedAt,
+	})
+}
+
+// ensureLot creates lot unless it exists, and refuses a lot number that is
+// already stored with another expiry date.
+func ensureLot(ctx context.Context, repos repository.Repositories, lot *models.StockLot) error {
+	expiryDate := lot.ExpiryDate
+	if err := repos.Lot.EnsureLot(ctx, lot); err != nil {
+		return err
+	}
+
+	if expiryDate != nil && (lot.ExpiryDate == nil || !lot.ExpiryDate.Equal(*expiryDate)) {
+		return &apperror.ConflictError{
+			Resource: "lot",
+			Reason:   fmt.Sprintf("lot %q of product %d in warehouse %d has another expiry date", lot.LotNumber, lot.ProductId, lot.WarehouseId),
+		}
+	}
+	return nil
+}
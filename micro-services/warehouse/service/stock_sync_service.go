@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+)
+
+// StockSyncService keeps the stock the product service shows in line with
+// the sellable stock of the active warehouses less what pending orders hold.
+type StockSyncService interface {
+	// PushChangedTotals sends the totals of the products queued in the
+	// outbox, a batch at a time, and takes them off the outbox.
+	PushChangedTotals(ctx context.Context) (*models.StockPush, error)
+}
+
+type stockSyncService struct {
+	uow         repository.UnitOfWork
+	productRepo repository.ProductRepository
+	orderRepo   repository.OrderRepository
+	// batchSize is the number of products pushed at once.
+	batchSize int
+}
+
+func NewStockSyncService(uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, batchSize int) StockSyncService {
+	return &stockSyncService{uow: uow, productRepo: productRepo, orderRepo: orderRepo, batchSize: batchSize}
+}
+
+func (s *stockSyncService) PushChangedTotals(ctx context.Context) (*models.StockPush, error) {
+	var push models.StockPush
+	for {
+		repos := s.uow.Read()
+		changes, err := repos.Outbox.GetChanges(ctx, s.batchSize)
+		if err != nil {
+			return &push, fmt.Errorf("failed to read stock outbox: %w", err)
+		}
+		if len(changes) == 0 {
+			return &push, nil
+		}
+
+		pending, err := pendingStock(ctx, s.orderRepo)
+		if err != nil {
+			return &push, err
+		}
+		productIds := make([]int64, 0, len(changes))
+		for _, change := range changes {
+			productIds = append(productIds, change.ProductId)
+		}
+		totals, err := sellableTotals(ctx, repos, productIds, pending)
+		if err != nil {
+			return &push, fmt.Errorf("failed to get stock totals: %w", err)
+		}
+
+		result, err := s.productRepo.UpdateTotalProductStocks(ctx, totals)
+		if err != nil {
+			return &push, fmt.Errorf("failed forward stock totals: %w", err)
+		}
+
+		err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+			return repos.Outbox.Acknowledge(ctx, changes)
+		})
+		if err != nil {
+			return &push, fmt.Errorf("failed to clear stock outbox: %w", err)
+		}
+
+		push.Pushed += len(changes) - len(result.Missing)
+		push.Missing = append(push.Missing, result.Missing...)
+		if len(changes) < s.batchSize {
+			return &push, nil
+		}
+	}
+}
+
+// pendingStock returns, per product, the quantity held by pending orders. It
+// is read before the warehouse stock, so an order paid in between is at worst
+// deducted twice until the next push.
+func pendingStock(ctx context.Context, orderRepo repository.OrderRepository) (map[int64]int, error) {
+	pending, err := orderRepo.GetPendingStock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending stock: %w", err)
+	}
+
+	byProduct := map[int64]int{}
+	for _, item := range pending {
+		byProduct[item.ProductId] += item.Quantity
+	}
+	return byProduct, nil
+}
+
+// sellableTotals is the stock the product service should show for each
+// product, in the order given: its warehouse total less what pending orders
+// hold.
+func sellableTotals(ctx context.Context, repos repository.Repositories, productIds []int64, pending map[int64]int) ([]repository.StockTotal, error) {
+	totals, err := warehouseTotals(ctx, repos, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range totals {
+		totals[i].Stock = models.SellableStock(totals[i].Stock, pending[totals[i].ProductId])
+	}
+	return totals, nil
+}
+
+// warehouseTotals sums the sellable stock of the active warehouses for each
+// product, in the order given.
+func warehouseTotals(ctx context.Context, repos repository.Repositories, productIds []int64) ([]repository.StockTotal, error) {
+	warehouses, err := repos.Warehouse.GetActiveWarehouses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active warehouses: %w", err)
+	}
+
+	stocks, err := repos.Stock.GetStocksByProducts(ctx, productIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock: %w", err)
+	}
+	byProduct := map[int64][]models.Stock{}
+	for _, stock := range stocks {
+		byProduct[stock.ProductId] = append(byProduct[stock.ProductId], stock)
+	}
+
+	totals := make([]repository.StockTotal, 0, len(productIds))
+	for _, productId := range productIds {
+		totals = append(totals, repository.StockTotal{ProductId: productId, Stock: sumActive(warehouses, byProduct[productId])})
+	}
+	return totals, nil
+}
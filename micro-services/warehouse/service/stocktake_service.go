@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"strings"
+)
+
+// StocktakeService runs cycle counts: a stocktake is opened for a warehouse,
+// counts are recorded per product, and approving it posts the variances
+// against the system quantity as adjustment movements.
+type StocktakeService interface {
+	// OpenStocktake starts counting a warehouse. With freezeMovements, no
+	// stock of the warehouse changes until the stocktake is closed.
+	OpenStocktake(ctx context.Context, warehouseId int64, freezeMovements bool) (*models.Stocktake, error)
+	GetStocktake(ctx context.Context, stocktakeId int64) (*models.Stocktake, error)
+	ListStocktakes(ctx context.Context, filter models.StocktakeFilter) ([]models.Stocktake, error)
+	// RecordCounts stores counted quantities next to the current stock; a
+	// product counted again replaces its earlier count.
+	RecordCounts(ctx context.Context, stocktakeId int64, counts []models.StocktakeCount) (*models.Stocktake, error)
+	// ApproveStocktake posts every non-zero variance as an adjustment with
+	// reason. Products that were not counted are left alone.
+	ApproveStocktake(ctx context.Context, stocktakeId int64, reason string) (*models.Stocktake, error)
+	CancelStocktake(ctx context.Context, stocktakeId int64) (*models.Stocktake, error)
+}
+
+type stocktakeService struct {
+	uow         repository.UnitOfWork
+	productRepo repository.ProductRepository
+	orderRepo   repository.OrderRepository
+}
+
+func NewStocktakeService(uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository) StocktakeService {
+	return &stocktakeService{
+		uow:         uow,
+		productRepo: productRepo,
+		orderRepo:   orderRepo,
+	}
+}
+
+func (s *stocktakeService) OpenStocktake(ctx context.Context, warehouseId int64, freezeMovements bool) (*models.Stocktake, error) {
+	stocktake := models.Stocktake{WarehouseId: warehouseId, FreezeMovements: freezeMovements, OpenedBy: actorFromContext(ctx)}
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		if _, err := repos.Warehouse.GetWarehouseById(ctx, warehouseId); err != nil {
+			return err
+		}
+
+		open, err := repos.Stocktake.GetStocktakes(ctx, models.StocktakeFilter{WarehouseId: warehouseId, Status: models.StocktakeOpen})
+		if err != nil {
+			return err
+		}
+		if len(open) > 0 {
+			return &apperror.ConflictError{Resource: "stocktake", Reason: fmt.Sprintf("warehouse %d is already counted by stocktake %d", warehouseId, open[0].Id)}
+		}
+
+		return repos.Stocktake.CreateStocktake(ctx, &stocktake)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stocktake: %w", err)
+	}
+
+	return &stocktake, nil
+}
+
+func (s *stocktakeService) GetStocktake(ctx context.Context, stocktakeId int64) (*models.Stocktake, error) {
+	stocktake, err := loadStocktake(ctx, s.uow.Read(), stocktakeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stocktake: %w", err)
+	}
+
+	return stocktake, nil
+}
+
+func (s *stocktakeService) ListStocktakes(ctx context.Context, filter models.StocktakeFilter) ([]models.Stocktake, error) {
+	stocktakes, err := s.uow.Read().Stocktake.GetStocktakes(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stocktakes: %w", err)
+	}
+
+	return stocktakes, nil
+}
+
+func (s *stocktakeService) RecordCounts(ctx context.Context, stocktakeId int64, counts []models.StocktakeCount) (*models.Stocktake, error) {
+	if len(counts) == 0 {
+		return nil, &apperror.InvalidInputError{Field: "counts", Reason: "must not be empty"}
+	}
+	for _, count := range counts {
+		if count.ProductId <= 0 {
+			return nil, &apperror.InvalidInputError{Field: "product_id", Reason: "is required"}
+		}
+		if count.CountedQuantity < 0 {
+			return nil, &apperror.InvalidInputError{Field: "counted_quantity", Reason: "must not be negative"}
+		}
+	}
+
+	var stocktake *models.Stocktake
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		var err error
+		stocktake, err = repos.Stocktake.GetStocktakeById(ctx, stocktakeId)
+		if err != nil {
+			return err
+		}
+		if stocktake.Status != models.StocktakeOpen {
+			return notOpen(stocktake)
+		}
+
+		for _, count := range counts {
+			stock, err := repos.Stock.GetStockByProductAndWarehouse(ctx, count.ProductId, stocktake.WarehouseId)
+			if err != nil {
+				return fmt.Errorf("failed to get stock of product %d: %w", count.ProductId, err)
+			}
+
+			line := models.StocktakeLine{
+				StocktakeId:     stocktake.Id,
+				ProductId:       count.ProductId,
+				SystemQuantity:  stock.Quantity,
+				CountedQuantity: count.CountedQuantity,
+				CountedBy:       actorFromContext(ctx),
+			}
+			if err := repos.Stocktake.RecordCount(ctx, &line); err != nil {
+				return err
+			}
+		}
+
+		stocktake.Lines, err = repos.Stocktake.GetLines(ctx, stocktake.Id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record counts: %w", err)
+	}
+
+	return stocktake, nil
+}
+
+func (s *stocktakeService) ApproveStocktake(ctx context.Context, stocktakeId int64, reason string) (*models.Stocktake, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, &apperror.InvalidInputError{Field: "reason", Reason: "is required"}
+	}
+
+	var adjusted []int64
+	stocktake, err := s.close(ctx, stocktakeId, "approve", func(repos repository.Repositories, stocktake *models.Stocktake) error {
+		stocktake.Status = models.StocktakeApproved
+		stocktake.Reason = reason
+		// close first, so a frozen warehouse takes the adjustments
+		if err := repos.Stocktake.CloseStocktake(ctx, stocktake); err != nil {
+			return err
+		}
+
+		for _, line := range stocktake.Lines {
+			if line.Variance == 0 {
+				continue
+			}
+
+			err := applyMovement(ctx, repos, models.StockMovement{
+				ProductId:   line.ProductId,
+				WarehouseId: stocktake.WarehouseId,
+				Type:        models.MovementAdjustment,
+				Quantity:    line.Variance,
+				ReferenceId: stocktakeReference(stocktake.Id),
+				Reason:      reason,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to adjust product %d: %w", line.ProductId, err)
+			}
+			adjusted = append(adjusted, line.ProductId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, productId := range adjusted {
+		syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, productId)
+	}
+
+	return stocktake, nil
+}
+
+func (s *stocktakeService) CancelStocktake(ctx context.Context, stocktakeId int64) (*models.Stocktake, error) {
+	return s.close(ctx, stocktakeId, "cancel", func(repos repository.Repositories, stocktake *models.Stocktake) error {
+		stocktake.Status = models.StocktakeCancelled
+		return repos.Stocktake.CloseStocktake(ctx, stocktake)
+	})
+}
+
+// close loads an open stocktake with its lines and runs step on it in one
+// transaction.
+func (s *stocktakeService) close(ctx context.Context, stocktakeId int64, action string, step func(repos repository.Repositories, stocktake *models.Stocktake) error) (*models.Stocktake, error) {
+	var stocktake *models.Stocktake
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		var err error
+		stocktake, err = loadStocktake(ctx, repos, stocktakeId)
+		if err != nil {
+			return err
+		}
+		if stocktake.Status != models.StocktakeOpen {
+			return notOpen(stocktake)
+		}
+
+		stocktake.ClosedBy = actorFromContext(ctx)
+		return step(repos, stocktake)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s stocktake: %w", action, err)
+	}
+
+	return stocktake, nil
+}
+
+func loadStocktake(ctx context.Context, repos repository.Repositories, stocktakeId int64) (*models.Stocktake, error) {
+	stocktake, err := repos.Stocktake.GetStocktakeById(ctx, stocktakeId)
+	if err != nil {
+		return nil, err
+	}
+
+	stocktake.Lines, err = repos.Stocktake.GetLines(ctx, stocktakeId)
+	if err != nil {
+		return nil, err
+	}
+	return stocktake, nil
+}
+
+func notOpen(stocktake *models.Stocktake) error {
+	return &apperror.ConflictError{Resource: "stocktake", Reason: fmt.Sprintf("stocktake %d is %s", stocktake.Id, stocktake.Status)}
+}
+
+func stocktakeReference(stocktakeId int64) string {
+	return fmt.Sprintf("stocktake:%d", stocktakeId)
+}
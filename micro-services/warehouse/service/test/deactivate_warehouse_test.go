@@ -0,0 +1,116 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDeactivateWarehouse_Database deactivates Warehouse A of the seed data,
+// which holds 25, 5 and 10 units of products 1, 2 and 3.
+func TestDeactivateWarehouse_Database(t *testing.T) {
+	ctx := context.Background()
+
+	type fixture struct {
+		*dbFixture
+		service service.WarehouseService
+	}
+	newFixture := func(t *testing.T, pendingOrders ...int64) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.recordTotals()
+		f.service = f.warehouseService(fakeOrderRepository{pendingOrders: pendingOrders})
+		return f
+	}
+
+	t.Run("should recompute totals without the deactivated stock", func(t *testing.T) {
+		f := newFixture(t)
+
+		deactivation, err := f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "inactive", deactivation.Warehouse.Status)
+		assert.Empty(t, deactivation.Transfers)
+		assert.Equal(t, map[int64]int{1: 25, 2: 15, 3: 20}, f.totals)
+
+		events, err := f.service.GetWarehouseEvents(ctx, 1)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, models.AuditWarehouseDeactivated, events[0].Action)
+	})
+
+	t.Run("should move the remaining stock to the other warehouses", func(t *testing.T) {
+		f := newFixture(t)
+
+		deactivation, err := f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{TransferStock: true})
+
+		assert.NoError(t, err)
+		assert.Len(t, deactivation.Transfers, 3)
+		assert.Equal(t, map[int64]int{1: 50, 2: 20, 3: 30}, f.totals)
+		moved, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 50, moved.Quantity)
+		left, err := f.stockRepo.GetWarehouseStockTotal(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, left)
+	})
+
+	t.Run("should not count the allocations of orders that went through", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.service.ProceedOrder(ctx, 1, []service.ProductOrderDetails{{ProductId: 1, Quantity: 5}}, nil, "")
+		assert.NoError(t, err)
+
+		deactivation, err := f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "inactive", deactivation.Warehouse.Status)
+		assert.Empty(t, deactivation.OpenAllocations)
+		assert.NoError(t, f.service.ActiveDeactiveWarehouseStatus(ctx, 1))
+		assert.NoError(t, f.service.ActiveDeactiveWarehouseStatus(ctx, 1))
+	})
+
+	t.Run("should block on allocations of pending orders unless draining", func(t *testing.T) {
+		f := newFixture(t, 1)
+		_, err := f.service.ProceedOrder(ctx, 1, []service.ProductOrderDetails{{ProductId: 1, Quantity: 5}}, nil, "")
+		assert.NoError(t, err)
+
+		_, err = f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{})
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+
+		deactivation, err := f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{AllocationPolicy: service.AllocationsDrain})
+		assert.NoError(t, err)
+		assert.Len(t, deactivation.OpenAllocations, 1)
+
+		// releasing a drained allocation returns the stock where it came from
+		_, err = f.service.ReleaseOrder(ctx, 1)
+		assert.NoError(t, err)
+		stock, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 25, stock.Quantity)
+	})
+
+	t.Run("should put the stock back into the pool on activation", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{})
+		assert.NoError(t, err)
+
+		warehouse, err := f.service.ActivateWarehouse(ctx, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "active", warehouse.Status)
+		assert.Equal(t, map[int64]int{1: 50, 2: 20, 3: 30}, f.totals)
+	})
+
+	t.Run("should refuse to transfer into an inactive warehouse", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.service.DeactivateWarehouse(ctx, 2, service.DeactivateOptions{})
+		assert.NoError(t, err)
+
+		_, err = f.service.DeactivateWarehouse(ctx, 1, service.DeactivateOptions{TransferStock: true, TransferTo: 2})
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/database"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+// dbFixture is a migrated warehouse database holding the seed data, for the
+// service tests to build the services they need on. The product service is a
+// mock that expects nothing until a test stubs it.
+type dbFixture struct {
+	db          *database.DB
+	uow         repository.UnitOfWork
+	stockRepo   repository.StockRepository
+	ledger      repository.MovementRepository
+	ctrl        *gomock.Controller
+	productRepo *mocks.MockProductRepository
+	// totals is the last stock pushed for each product, once recordTotals
+	// stubbed the pushes.
+	totals map[int64]int
+}
+
+func newDBFixture(t *testing.T) *dbFixture {
+	db := dbtest.Open(t, "warehouse", migrations.For)
+	ctrl := gomock.NewController(t)
+	return &dbFixture{
+		db:          db,
+		uow:         repository.NewUnitOfWork(db),
+		stockRepo:   repository.NewStockRepository(db),
+		ledger:      repository.NewMovementRepository(db),
+		ctrl:        ctrl,
+		productRepo: mocks.NewMockProductRepository(ctrl),
+		totals:      map[int64]int{},
+	}
+}
+
+// recordTotals accepts every stock pushed to the product service into totals.
+func (f *dbFixture) recordTotals() {
+	f.productRepo.EXPECT().
+		UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, productId int64, total int) error {
+			f.totals[productId] = total
+			return nil
+		}).
+		AnyTimes()
+}
+
+// knowProducts has the product service know every product asked for.
+func (f *dbFixture) knowProducts() {
+	f.productRepo.EXPECT().
+		GetProductById(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, productId int64) (*repository.Product, error) {
+			return &repository.Product{Id: productId}, nil
+		}).
+		AnyTimes()
+}
+
+// warehouseService is the warehouse service on the fixture database, seeing
+// the pending orders of orderRepo.
+func (f *dbFixture) warehouseService(orderRepo repository.OrderRepository) service.WarehouseService {
+	return service.NewWarehouseService(f.uow, repository.NewWarehouseRepository(f.db), f.stockRepo, f.productRepo, orderRepo, allocation.Priority)
+}
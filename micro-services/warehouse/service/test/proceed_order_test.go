@@ -0,0 +1,65 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProceedOrder_Database runs ProceedOrder against the migrated seed data:
+// Warehouse A and B hold 25 each of product 1 and 5 and 15 of product 2.
+func TestProceedOrder_Database(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func(t *testing.T) (service.WarehouseService, repository.StockRepository) {
+		db := dbtest.Open(t, "warehouse", migrations.For)
+		stockRepo := repository.NewStockRepository(db)
+		return service.NewWarehouseService(repository.NewUnitOfWork(db), repository.NewWarehouseRepository(db), stockRepo, nil, nil, allocation.Priority), stockRepo
+	}
+
+	t.Run("should leave every line untouched when one is short", func(t *testing.T) {
+		warehouseService, stockRepo := newService(t)
+
+		_, err := warehouseService.ProceedOrder(ctx, 1, []service.ProductOrderDetails{
+			{ProductId: 1, Quantity: 10},
+			{ProductId: 2, Quantity: 21},
+		}, nil, "")
+
+		assert.ErrorIs(t, err, apperror.ErrInsufficientStock)
+		stock, err := stockRepo.GetStockByProductAndWarehouse(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 25, stock.Quantity)
+	})
+
+	t.Run("should deduct only once for concurrent forwards of one order", func(t *testing.T) {
+		warehouseService, stockRepo := newService(t)
+		items := []service.ProductOrderDetails{{ProductId: 1, Quantity: 30}}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = warehouseService.ProceedOrder(ctx, 2, items, nil, "")
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		first, _ := stockRepo.GetStockByProductAndWarehouse(ctx, 1, 1)
+		second, _ := stockRepo.GetStockByProductAndWarehouse(ctx, 1, 2)
+		assert.Equal(t, 0, first.Quantity)
+		assert.Equal(t, 20, second.Quantity)
+	})
+}
@@ -0,0 +1,236 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReconciliationFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		productStock   int
+		warehouseStock int
+		pendingStock   int
+		cause          string
+		expectedStock  int
+	}{
+		{name: "should add up with pending orders deducted", productStock: 45, warehouseStock: 50, pendingStock: 5},
+		{name: "should blame a total that ignored pending orders", productStock: 50, warehouseStock: 50, pendingStock: 5, cause: models.CausePendingNotDeducted, expectedStock: 45},
+		{name: "should flag pending orders holding more than the warehouses", productStock: 0, warehouseStock: 20, pendingStock: 30, cause: models.CauseOversold, expectedStock: 0},
+		{name: "should flag a product stock above the expected stock", productStock: 48, warehouseStock: 50, pendingStock: 5, cause: models.CauseProductAhead, expectedStock: 45},
+		{name: "should flag a product stock below the expected stock", productStock: 40, warehouseStock: 50, pendingStock: 5, cause: models.CauseProductBehind, expectedStock: 45},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := models.ReconciliationFor(1, tt.productStock, tt.warehouseStock, tt.pendingStock)
+
+			if tt.cause == "" {
+				assert.Nil(t, line)
+				return
+			}
+			assert.Equal(t, tt.cause, line.Cause)
+			assert.Equal(t, tt.expectedStock, line.ExpectedStock)
+		})
+	}
+}
+
+// TestReconciliationService_Database reconciles the seed data, where the
+// active warehouses hold 50 of product 1, 20 of product 2 and 30 of product 3.
+func TestReconciliationService_Database(t *testing.T) {
+	ctx := context.Background()
+
+	type fixture struct {
+		*dbFixture
+		service   service.ReconciliationService
+		orderRepo *mocks.MockOrderRepository
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.orderRepo = mocks.NewMockOrderRepository(f.ctrl)
+		f.service = service.NewReconciliationService(f.uow, f.productRepo, f.orderRepo, 2)
+		return f
+	}
+	// pending orders hold 5 of product 1 and 30 of product 2
+	expectPending := func(f fixture) {
+		f.orderRepo.EXPECT().
+			GetPendingStock(gomock.Any()).
+			Return([]repository.PendingStock{{ProductId: 1, Quantity: 5}, {ProductId: 2, Quantity: 30}}, nil)
+	}
+
+	t.Run("should report every cause without correcting", func(t *testing.T) {
+		f := newFixture(t)
+		expectPending(f)
+		f.productRepo.EXPECT().
+			GetProducts(gomock.Any(), int64(0), 2).
+			Return([]repository.Product{{Id: 1, Stock: 50}, {Id: 2, Stock: 0}}, nil)
+		// product 4 is new to the warehouses and gets zero rows
+		f.productRepo.EXPECT().
+			GetProducts(gomock.Any(), int64(2), 2).
+			Return([]repository.Product{{Id: 3, Stock: 35}, {Id: 4, Stock: 0}}, nil)
+		f.productRepo.EXPECT().
+			GetProducts(gomock.Any(), int64(4), 2).
+			Return(nil, nil)
+
+		reconciliation, err := f.service.Reconcile(ctx, models.ReconcileOptions{})
+
+		assert.NoError(t, err)
+		assert.True(t, reconciliation.Complete)
+		assert.Equal(t, 4, reconciliation.Checked)
+		assert.Equal(t, "system", reconciliation.Actor)
+		if assert.Len(t, reconciliation.Lines, 3) {
+			assert.Equal(t, models.ReconciliationLine{Id: reconciliation.Lines[0].Id, ProductId: 1, ProductStock: 50, WarehouseStock: 50, PendingStock: 5, ExpectedStock: 45, Cause: models.CausePendingNotDeducted}, reconciliation.Lines[0])
+			assert.Equal(t, models.CauseOversold, reconciliation.Lines[1].Cause)
+			assert.Equal(t, models.CauseProductAhead, reconciliation.Lines[2].Cause)
+			for _, line := range reconciliation.Lines {
+				assert.False(t, line.Corrected)
+			}
+		}
+	})
+
+	t.Run("should correct the product stock and store the run", func(t *testing.T) {
+		f := newFixture(t)
+		expectPending(f)
+		f.productRepo.EXPECT().GetProductById(gomock.Any(), int64(3)).Return(&repository.Product{Id: 3, Stock: 12}, nil)
+		f.productRepo.EXPECT().GetProductById(gomock.Any(), int64(1)).Return(&repository.Product{Id: 1, Stock: 50}, nil)
+		f.productRepo.EXPECT().
+			UpdateTotalProductStocks(gomock.Any(), []repository.StockTotal{{ProductId: 3, Stock: 30}, {ProductId: 1, Stock: 45}}).
+			Return(&repository.StockTotalsResult{Updated: 1, Missing: []int64{1}}, nil)
+
+		reconciliation, err := f.service.Reconcile(ctx, models.ReconcileOptions{Apply: true, ProductIds: []int64{3, 1}})
+		assert.NoError(t, err)
+		assert.True(t, reconciliation.Complete)
+
+		stored, err := f.service.GetReconciliation(ctx, reconciliation.Id)
+		assert.NoError(t, err)
+		assert.True(t, stored.Apply)
+		assert.Equal(t, 2, stored.Checked)
+		if assert.Len(t, stored.Lines, 2) {
+			assert.Equal(t, int64(1), stored.Lines[0].ProductId)
+			assert.False(t, stored.Lines[0].Corrected)
+			assert.Equal(t, "product no longer exists", stored.Lines[0].Error)
+			assert.Equal(t, int64(3), stored.Lines[1].ProductId)
+			assert.Equal(t, models.CauseProductBehind, stored.Lines[1].Cause)
+			assert.True(t, stored.Lines[1].Corrected)
+		}
+
+		list, err := f.service.ListReconciliations(ctx, 0)
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Empty(t, list[0].Lines)
+	})
+
+	t.Run("should report what it could not check or correct", func(t *testing.T) {
+		f := newFixture(t)
+		expectPending(f)
+		f.productRepo.EXPECT().
+			GetProducts(gomock.Any(), int64(0), 2).
+			Return([]repository.Product{{Id: 1, Stock: 45}, {Id: 3, Stock: 12}}, nil)
+		f.productRepo.EXPECT().
+			GetProducts(gomock.Any(), int64(3), 2).
+			Return(nil, errors.New("connection refused"))
+		f.productRepo.EXPECT().
+			UpdateTotalProductStocks(gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("connection refused"))
+
+		reconciliation, err := f.service.Reconcile(ctx, models.ReconcileOptions{Apply: true})
+
+		assert.NoError(t, err)
+		assert.False(t, reconciliation.Complete)
+		assert.Contains(t, reconciliation.Error, "connection refused")
+		assert.Equal(t, 2, reconciliation.Checked)
+		if assert.Len(t, reconciliation.Lines, 1) {
+			assert.Equal(t, int64(3), reconciliation.Lines[0].ProductId)
+			assert.False(t, reconciliation.Lines[0].Corrected)
+			assert.Contains(t, reconciliation.Lines[0].Error, "connection refused")
+		}
+	})
+
+	t.Run("should not run without the pending stock", func(t *testing.T) {
+		f := newFixture(t)
+		f.orderRepo.EXPECT().GetPendingStock(gomock.Any()).Return(nil, errors.New("connection refused"))
+
+		_, err := f.service.Reconcile(ctx, models.ReconcileOptions{})
+		assert.Error(t, err)
+
+		list, err := f.service.ListReconciliations(ctx, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+	})
+
+	t.Run("should return not found for an unknown run", func(t *testing.T) {
+		f := newFixture(t)
+
+		_, err := f.service.GetReconciliation(ctx, 99)
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+// TestReconciliationService_AfterPush checks that what the warehouses push
+// is what the reconciliation expects, so a reconcile after a push finds
+// nothing to correct.
+func TestReconciliationService_AfterPush(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t, "warehouse", migrations.For)
+	uow := repository.NewUnitOfWork(db)
+
+	// the product service keeps whatever it is sent
+	stocks := map[int64]int{}
+	productRepo := mocks.NewMockProductRepository(gomock.NewController(t))
+	productRepo.EXPECT().
+		GetProductById(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, productId int64) (*repository.Product, error) {
+			return &repository.Product{Id: productId, Stock: stocks[productId]}, nil
+		}).
+		AnyTimes()
+	productRepo.EXPECT().
+		UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, productId int64, stock int) error {
+			stocks[productId] = stock
+			return nil
+		}).
+		AnyTimes()
+	productRepo.EXPECT().
+		UpdateTotalProductStocks(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, totals []repository.StockTotal) (*repository.StockTotalsResult, error) {
+			for _, total := range totals {
+				stocks[total.ProductId] = total.Stock
+			}
+			return &repository.StockTotalsResult{Updated: len(totals)}, nil
+		}).
+		AnyTimes()
+
+	// pending orders hold 5 of product 1 and 4 of product 3
+	orderRepo := fakeOrderRepository{pending: []repository.PendingStock{{ProductId: 1, Quantity: 5}, {ProductId: 3, Quantity: 4}}}
+	warehouseService := service.NewWarehouseService(uow, repository.NewWarehouseRepository(db), repository.NewStockRepository(db), productRepo, orderRepo, allocation.Priority)
+	stockSyncService := service.NewStockSyncService(uow, productRepo, orderRepo, 2)
+	reconciliationService := service.NewReconciliationService(uow, productRepo, orderRepo, 2)
+
+	// product 1 is pushed right after the change, product 3 by the push job
+	assert.NoError(t, warehouseService.AddStock(ctx, 1, 1, 5))
+	assert.NoError(t, repository.NewOutboxRepository(db).MarkChanged(ctx, 3))
+	_, err := stockSyncService.PushChangedTotals(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int64]int{1: 50, 3: 26}, stocks)
+
+	for i := 0; i < 2; i++ {
+		reconciliation, err := reconciliationService.Reconcile(ctx, models.ReconcileOptions{Apply: true, ProductIds: []int64{1, 3}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, reconciliation.Checked)
+		assert.Empty(t, reconciliation.Lines)
+	}
+	assert.Equal(t, map[int64]int{1: 50, 3: 26}, stocks)
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReplenishmentService_Database works on the seed data: product 1 holds
+// 25 units in Warehouse A and B, product 2 holds 5 in A and 15 in B.
+func TestReplenishmentService_Database(t *testing.T) {
+	ctx := context.Background()
+
+	type fixture struct {
+		service     service.ReplenishmentService
+		allocations repository.AllocationRepository
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := newDBFixture(t)
+		return fixture{
+			service:     service.NewReplenishmentService(f.uow, 14*24*time.Hour, 7),
+			allocations: repository.NewAllocationRepository(f.db),
+		}
+	}
+	setThreshold := func(t *testing.T, f fixture, productId, warehouseId int64, reorderPoint, safetyStock int) {
+		_, err := f.service.SetThreshold(ctx, models.StockThreshold{ProductId: productId, WarehouseId: warehouseId, ReorderPoint: reorderPoint, SafetyStock: safetyStock})
+		assert.NoError(t, err)
+	}
+
+	t.Run("should suggest a transfer from surplus and raise low stock once", func(t *testing.T) {
+		f := newFixture(t)
+		setThreshold(t, f, 2, 1, 5, 5)
+
+		run, err := f.service.Replenish(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, run.LowStock, 1)
+		assert.Equal(t, int64(2), run.LowStock[0].ProductId)
+		assert.Equal(t, 5, run.LowStock[0].OnHand)
+
+		assert.Len(t, run.Suggestions, 1)
+		assert.Equal(t, models.SuggestTransfer, run.Suggestions[0].Kind)
+		assert.Equal(t, int64(2), *run.Suggestions[0].FromWarehouseId)
+		assert.Equal(t, 5, run.Suggestions[0].Quantity)
+
+		// still low, but already reported
+		run, err = f.service.Replenish(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, run.LowStock)
+
+		suggestions, err := f.service.ListSuggestions(ctx, models.SuggestionFilter{WarehouseId: 1})
+		assert.NoError(t, err)
+		assert.Len(t, suggestions, 1)
+
+		thresholds, err := f.service.ListThresholds(ctx, models.ThresholdFilter{ProductId: 2})
+		assert.NoError(t, err)
+		assert.Len(t, thresholds, 1)
+		assert.NotNil(t, thresholds[0].LowSince)
+	})
+
+	t.Run("should size the top-up by recent allocation velocity", func(t *testing.T) {
+		f := newFixture(t)
+		setThreshold(t, f, 1, 1, 25, 0)
+		// 14 units over the 14 day lookback is one a day
+		assert.NoError(t, f.allocations.CreateAllocation(ctx, &models.StockAllocation{OrderId: 1, ProductId: 1, WarehouseId: 1, Quantity: 14, Strategy: "priority"}))
+
+		run, err := f.service.Replenish(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, run.Suggestions, 1)
+		assert.Equal(t, 1.0, run.Suggestions[0].DailyVelocity)
+		assert.Equal(t, 7, run.Suggestions[0].Quantity)
+	})
+
+	t.Run("should clear low stock and suggestions once stock recovers", func(t *testing.T) {
+		f := newFixture(t)
+		setThreshold(t, f, 2, 1, 5, 0)
+		_, err := f.service.Replenish(ctx)
+		assert.NoError(t, err)
+
+		setThreshold(t, f, 2, 1, 4, 0)
+		run, err := f.service.Replenish(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, run.Suggestions)
+
+		thresholds, err := f.service.ListThresholds(ctx, models.ThresholdFilter{ProductId: 2, WarehouseId: 1})
+		assert.NoError(t, err)
+		assert.Nil(t, thresholds[0].LowSince)
+
+		suggestions, err := f.service.ListSuggestions(ctx, models.SuggestionFilter{})
+		assert.NoError(t, err)
+		assert.Empty(t, suggestions)
+	})
+
+	t.Run("should reject invalid thresholds", func(t *testing.T) {
+		f := newFixture(t)
+
+		_, err := f.service.SetThreshold(ctx, models.StockThreshold{ProductId: 1, WarehouseId: 1, ReorderPoint: -1})
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+
+		_, err = f.service.SetThreshold(ctx, models.StockThreshold{ProductId: 1, WarehouseId: 99, ReorderPoint: 5})
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+		_, err = f.service.ListSuggestions(ctx, models.SuggestionFilter{Kind: "sell"})
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+}
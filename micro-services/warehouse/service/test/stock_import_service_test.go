@@ -0,0 +1,160 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// TestStockImportService_Database imports into the seed data, where Warehouse
+// A (1) holds 25 of product 1, 5 of product 2 and 10 of product 3, and
+// Warehouse B (2) 25, 15 and 20. Product 9 does not exist.
+func TestStockImportService_Database(t *testing.T) {
+	ctx := service.WithActor(context.Background(), "importer")
+
+	type fixture struct {
+		*dbFixture
+		service   service.StockImportService
+		stocktake service.StocktakeService
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.recordTotals()
+		f.productRepo.EXPECT().
+			GetProductsByIds(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, productIds []int64) ([]repository.Product, error) {
+				var products []repository.Product
+				for _, productId := range productIds {
+					if productId != 9 {
+						products = append(products, repository.Product{Id: productId})
+					}
+				}
+				return products, nil
+			}).
+			AnyTimes()
+
+		f.service = service.NewStockImportService(f.uow, repository.NewWarehouseRepository(f.db), f.stockRepo, f.productRepo, fakeOrderRepository{})
+		f.stocktake = service.NewStocktakeService(f.uow, f.productRepo, fakeOrderRepository{})
+		return f
+	}
+	quantity := func(t *testing.T, f fixture, productId, warehouseId int64) int {
+		stock, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, productId, warehouseId)
+		assert.NoError(t, err)
+		return stock.Quantity
+	}
+	const rows = "warehouse,product,quantity,mode\n" +
+		"Warehouse A,1,30,set\n" +
+		"2,3,5,add\n" +
+		"warehouse a,1,2,ADD\n"
+
+	t.Run("should apply every row in order and push the changed totals", func(t *testing.T) {
+		f := newFixture(t)
+
+		result, err := f.service.ImportStock(ctx, strings.NewReader(rows), false)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Applied)
+		assert.Equal(t, 0, result.Invalid)
+		assert.Equal(t, []models.StockImportRow{
+			{Line: 2, Warehouse: "Warehouse A", WarehouseId: 1, ProductId: 1, Quantity: 30, Mode: models.ImportModeSet, Previous: 25, New: 30},
+			{Line: 3, Warehouse: "2", WarehouseId: 2, ProductId: 3, Quantity: 5, Mode: models.ImportModeAdd, Previous: 20, New: 25},
+			{Line: 4, Warehouse: "warehouse a", WarehouseId: 1, ProductId: 1, Quantity: 2, Mode: models.ImportModeAdd, Previous: 30, New: 32},
+		}, result.Rows)
+		assert.Equal(t, 32, quantity(t, f, 1, 1))
+		assert.Equal(t, 25, quantity(t, f, 3, 2))
+		assert.Equal(t, map[int64]int{1: 57, 3: 35}, f.totals)
+
+		movements, err := f.ledger.GetMovements(ctx, models.MovementFilter{ProductId: 1, WarehouseId: 1})
+		assert.NoError(t, err)
+		adjustment := movements[len(movements)-2]
+		assert.Equal(t, models.MovementAdjustment, adjustment.Type)
+		assert.Equal(t, 5, adjustment.Quantity)
+		assert.Equal(t, "stock import line 2", adjustment.Reason)
+		assert.Equal(t, "importer", adjustment.Actor)
+		assert.Equal(t, models.MovementReceipt, movements[len(movements)-1].Type)
+	})
+
+	t.Run("should preview a dry run without changing stock", func(t *testing.T) {
+		f := newFixture(t)
+
+		result, err := f.service.ImportStock(ctx, strings.NewReader(rows), true)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Applied)
+		assert.Equal(t, 32, result.Rows[2].New)
+		assert.Equal(t, 25, quantity(t, f, 1, 1))
+		assert.Empty(t, f.totals)
+	})
+
+	t.Run("should report every invalid row and apply none", func(t *testing.T) {
+		f := newFixture(t)
+		file := "product,warehouse,quantity,mode,note\n" +
+			"1,1,40,set,fine on its own\n" +
+			"1,Warehouse C,5,add\n" +
+			"9,1,5,add\n" +
+			"2,1,-1,set\n" +
+			"x,1,5,swap\n"
+
+		result, err := f.service.ImportStock(ctx, strings.NewReader(file), false)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Applied)
+		assert.Equal(t, 4, result.Invalid)
+		assert.Empty(t, result.Rows[0].Errors)
+		assert.Equal(t, []string{`warehouse "Warehouse C" does not exist`}, result.Rows[1].Errors)
+		assert.Equal(t, []string{"product 9 does not exist"}, result.Rows[2].Errors)
+		assert.Equal(t, []string{"quantity must not be negative"}, result.Rows[3].Errors)
+		assert.Len(t, result.Rows[4].Errors, 2)
+		assert.Equal(t, 25, quantity(t, f, 1, 1))
+	})
+
+	t.Run("should report rows refused by a frozen warehouse", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.stocktake.OpenStocktake(ctx, 1, true)
+		assert.NoError(t, err)
+
+		result, err := f.service.ImportStock(ctx, strings.NewReader(rows), false)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Applied)
+		assert.Equal(t, 2, result.Invalid)
+		assert.Contains(t, result.Rows[0].Errors[0], "frozen")
+		assert.Empty(t, result.Rows[1].Errors)
+		assert.Equal(t, 20, quantity(t, f, 3, 2))
+	})
+
+	t.Run("should refuse a file without the required columns", func(t *testing.T) {
+		f := newFixture(t)
+
+		_, err := f.service.ImportStock(ctx, strings.NewReader("warehouse,product,quantity\n1,1,5\n"), false)
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+
+		_, err = f.service.ImportStock(ctx, strings.NewReader(""), false)
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+
+	t.Run("should export stock that imports back unchanged", func(t *testing.T) {
+		f := newFixture(t)
+
+		var export bytes.Buffer
+		assert.NoError(t, f.service.ExportStock(ctx, &export))
+
+		lines := strings.Split(strings.TrimSpace(export.String()), "\n")
+		assert.Len(t, lines, 7)
+		assert.Equal(t, "warehouse,warehouse_name,product,quantity,mode", lines[0])
+		assert.Equal(t, "1,Warehouse A,1,25,set", lines[1])
+
+		result, err := f.service.ImportStock(ctx, &export, false)
+		assert.NoError(t, err)
+		assert.True(t, result.Applied)
+		assert.Empty(t, f.totals)
+	})
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/migrations"
+	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/database/dbtest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStockLedger_Database(t *testing.T) {
+	ctx := service.WithActor(context.Background(), "ops@example.com")
+
+	db := dbtest.Open(t, "warehouse", migrations.For)
+	productRepo := mocks.NewMockProductRepository(gomock.NewController(t))
+	productRepo.EXPECT().GetProductById(gomock.Any(), gomock.Any()).Return(&repository.Product{Id: 1}, nil).AnyTimes()
+	productRepo.EXPECT().UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	warehouseService := service.NewWarehouseService(repository.NewUnitOfWork(db), repository.NewWarehouseRepository(db), repository.NewStockRepository(db), productRepo, fakeOrderRepository{}, allocation.Priority)
+
+	assert.NoError(t, warehouseService.AddStock(ctx, 1, 1, 10))
+	assert.NoError(t, warehouseService.RemoveStock(ctx, 1, 1, 3))
+	assert.NoError(t, warehouseService.TransferProduct(ctx, 1, 1, 2, 4))
+	_, err := warehouseService.ProceedOrder(ctx, 9, []service.ProductOrderDetails{{ProductId: 1, Quantity: 30}}, nil, "")
+	assert.NoError(t, err)
+	_, err = warehouseService.ReleaseOrder(ctx, 9)
+	assert.NoError(t, err)
+
+	t.Run("should record every change with its type and reference", func(t *testing.T) {
+		movements, err := warehouseService.ListMovements(ctx, models.MovementFilter{ProductId: 1, WarehouseId: 1})
+		assert.NoError(t, err)
+
+		var types []string
+		for _, movement := range movements {
+			types = append(types, movement.Type)
+		}
+		assert.Equal(t, []string{
+			models.MovementAdjustment, // opening balance
+			models.MovementReceipt,
+			models.MovementAdjustment,
+			models.MovementTransferOut,
+			models.MovementOrderAllocation,
+			models.MovementOrderReturn,
+		}, types)
+		assert.Equal(t, "order:9", movements[4].ReferenceId)
+		assert.Equal(t, -28, movements[4].Quantity)
+		assert.Equal(t, "ops@example.com", movements[4].Actor)
+	})
+
+	t.Run("should derive the current stock from the ledger", func(t *testing.T) {
+		discrepancies, err := warehouseService.VerifyLedger(ctx)
+
+		assert.NoError(t, err)
+		assert.Empty(t, discrepancies)
+	})
+
+	t.Run("should report stock changed outside the ledger", func(t *testing.T) {
+		assert.NoError(t, repository.NewStockRepository(db).UpdateStock(ctx, 2, 1, 99))
+
+		discrepancies, err := warehouseService.VerifyLedger(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []models.LedgerDiscrepancy{{ProductId: 2, WarehouseId: 1, Stock: 99, Ledger: 5}}, discrepancies)
+	})
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStockLots_Database receives lots of product 1 into Warehouse A, which
+// already holds 25 of it in the default lot, as does Warehouse B.
+func TestStockLots_Database(t *testing.T) {
+	ctx := context.Background()
+	today := models.Today()
+	inDays := func(days int) *time.Time {
+		date := today.AddDate(0, 0, days)
+		return &date
+	}
+
+	type fixture struct {
+		*dbFixture
+		service   service.WarehouseService
+		transfers service.TransferService
+		lotRepo   repository.LotRepository
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.recordTotals()
+		f.knowProducts()
+		f.service = f.warehouseService(fakeOrderRepository{})
+		f.transfers = service.NewTransferService(f.uow, f.productRepo, fakeOrderRepository{})
+		f.lotRepo = repository.NewLotRepository(f.db)
+		return f
+	}
+	lotQuantities := func(t *testing.T, f fixture, warehouseId int64) map[string]int {
+		lots, err := f.lotRepo.GetLots(ctx, models.LotFilter{ProductId: 1, WarehouseId: warehouseId})
+		assert.NoError(t, err)
+		quantities := map[string]int{}
+		for _, lot := range lots {
+			quantities[lot.LotNumber] = lot.Quantity
+		}
+		return quantities
+	}
+
+	t.Run("should allocate the lot expiring first and release back into it", func(t *testing.T) {
+		f := newFixture(t)
+
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L2", ExpiryDate: inDays(20)}, 5))
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L1", ExpiryDate: inDays(10)}, 5))
+
+		_, err := f.service.ProceedOrder(ctx, 1, []service.ProductOrderDetails{{ProductId: 1, Quantity: 7}}, nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"L2": 3, models.DefaultLot: 25}, lotQuantities(t, f, 1))
+
+		_, err = f.service.ReleaseOrder(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"L1": 5, "L2": 5, models.DefaultLot: 25}, lotQuantities(t, f, 1))
+	})
+
+	t.Run("should leave expired lots out of sellable stock", func(t *testing.T) {
+		f := newFixture(t)
+
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "OLD", ExpiryDate: inDays(1)}, 4))
+		assert.Equal(t, 54, f.totals[1])
+
+		_, err := f.db.ExecContext(ctx, "UPDATE stock_lots SET expiry_date = ? WHERE lot_number = 'OLD'", *inDays(-1))
+		assert.NoError(t, err)
+
+		stock, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 29, stock.Quantity)
+		assert.Equal(t, 4, stock.Expired)
+		assert.Equal(t, 25, stock.Sellable())
+
+		assert.NoError(t, f.service.AddStock(ctx, 1, 1, 1))
+		assert.Equal(t, 51, f.totals[1])
+
+		_, err = f.service.ProceedOrder(ctx, 1, []service.ProductOrderDetails{{ProductId: 1, Quantity: 2}}, nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"OLD": 4, models.DefaultLot: 24}, lotQuantities(t, f, 1))
+	})
+
+	t.Run("should keep the expiry date when stock moves between warehouses", func(t *testing.T) {
+		f := newFixture(t)
+
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L1", ExpiryDate: inDays(10)}, 5))
+
+		assert.NoError(t, f.service.TransferProduct(ctx, 1, 1, 2, 3))
+
+		lot, err := f.lotRepo.GetLot(ctx, 1, 2, "L1")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, lot.Quantity)
+		assert.True(t, inDays(10).Equal(*lot.ExpiryDate))
+
+		transfer, err := f.transfers.RequestTransfer(ctx, 1, 2, 1, 2)
+		assert.NoError(t, err)
+		_, err = f.transfers.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		_, err = f.transfers.ReceiveTransfer(ctx, transfer.Id, 2, false)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"L1": 1, models.DefaultLot: 25}, lotQuantities(t, f, 2))
+		assert.Equal(t, map[string]int{"L1": 4, models.DefaultLot: 25}, lotQuantities(t, f, 1))
+	})
+
+	t.Run("should list lots expiring within the window", func(t *testing.T) {
+		f := newFixture(t)
+
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "SOON", ExpiryDate: inDays(10)}, 5))
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "LATER", ExpiryDate: inDays(20)}, 5))
+
+		lots, err := f.service.ListExpiringLots(ctx, models.LotFilter{}, 10)
+		assert.NoError(t, err)
+		assert.Len(t, lots, 1)
+		assert.Equal(t, "SOON", lots[0].LotNumber)
+	})
+
+	t.Run("should refuse a lot that is already expired or has another expiry date", func(t *testing.T) {
+		f := newFixture(t)
+
+		err := f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L1", ExpiryDate: inDays(-1)}, 5)
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+
+		assert.NoError(t, f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L1", ExpiryDate: inDays(10)}, 5))
+		err = f.service.ReceiveLot(ctx, models.StockLot{ProductId: 1, WarehouseId: 1, LotNumber: "L1", ExpiryDate: inDays(11)}, 5)
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// TestStockSyncService_Database syncs the seed data, where the active
+// warehouses hold 50 of product 1, 20 of product 2 and 30 of product 3.
+func TestStockSyncService_Database(t *testing.T) {
+	ctx := context.Background()
+
+	type fixture struct {
+		*dbFixture
+		service   service.StockSyncService
+		warehouse service.WarehouseService
+		outbox    repository.OutboxRepository
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.knowProducts()
+		f.service = service.NewStockSyncService(f.uow, f.productRepo, fakeOrderRepository{}, 2)
+		f.warehouse = f.warehouseService(fakeOrderRepository{})
+		f.outbox = repository.NewOutboxRepository(f.db)
+		return f
+	}
+
+	t.Run("should take a pushed total off the outbox", func(t *testing.T) {
+		f := newFixture(t)
+		f.productRepo.EXPECT().UpdateTotalProductStock(gomock.Any(), int64(1), 55).Return(nil)
+
+		assert.NoError(t, f.warehouse.AddStock(ctx, 1, 1, 5))
+
+		changes, err := f.outbox.GetChanges(ctx, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("should succeed and push later when the immediate push fails", func(t *testing.T) {
+		f := newFixture(t)
+		f.productRepo.EXPECT().UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).Times(2)
+
+		// the stock changed, so a retry by the caller would book it twice
+		assert.NoError(t, f.warehouse.AddStock(ctx, 1, 1, 5))
+		assert.NoError(t, f.warehouse.RemoveStock(ctx, 3, 2, 4))
+
+		changes, err := f.outbox.GetChanges(ctx, 10)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+
+		f.productRepo.EXPECT().
+			UpdateTotalProductStocks(gomock.Any(), []repository.StockTotal{{ProductId: 1, Stock: 55}, {ProductId: 3, Stock: 26}}).
+			Return(&repository.StockTotalsResult{Updated: 2}, nil)
+
+		push, err := f.service.PushChangedTotals(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, push.Pushed)
+
+		push, err = f.service.PushChangedTotals(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, push.Pushed)
+	})
+
+	t.Run("should keep a product queued when it changed again after it was read", func(t *testing.T) {
+		f := newFixture(t)
+
+		assert.NoError(t, f.outbox.MarkChanged(ctx, 1))
+		read, err := f.outbox.GetChanges(ctx, 10)
+		assert.NoError(t, err)
+		assert.NoError(t, f.outbox.MarkChanged(ctx, 1))
+
+		assert.NoError(t, f.outbox.Acknowledge(ctx, read))
+
+		changes, err := f.outbox.GetChanges(ctx, 10)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, int64(2), changes[0].Version)
+	})
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStocktakeService_Database counts Warehouse A of the seed data, which
+// holds 25 of product 1, 5 of product 2 and 10 of product 3.
+func TestStocktakeService_Database(t *testing.T) {
+	ctx := service.WithActor(context.Background(), "auditor")
+
+	type fixture struct {
+		*dbFixture
+		service   service.StocktakeService
+		warehouse service.WarehouseService
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.recordTotals()
+		f.knowProducts()
+		f.service = service.NewStocktakeService(f.uow, f.productRepo, fakeOrderRepository{})
+		f.warehouse = f.warehouseService(fakeOrderRepository{})
+		return f
+	}
+	quantity := func(t *testing.T, f fixture, productId int64) int {
+		stock, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, productId, 1)
+		assert.NoError(t, err)
+		return stock.Quantity
+	}
+
+	t.Run("should post variances as adjustments with the reason", func(t *testing.T) {
+		f := newFixture(t)
+
+		stocktake, err := f.service.OpenStocktake(ctx, 1, true)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StocktakeOpen, stocktake.Status)
+		assert.Equal(t, "auditor", stocktake.OpenedBy)
+
+		stocktake, err = f.service.RecordCounts(ctx, stocktake.Id, []models.StocktakeCount{
+			{ProductId: 1, CountedQuantity: 23},
+			{ProductId: 2, CountedQuantity: 5},
+			{ProductId: 3, CountedQuantity: 12},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, stocktake.Lines, 3)
+		assert.Equal(t, -2, stocktake.Lines[0].Variance)
+		assert.Equal(t, 0, stocktake.Lines[1].Variance)
+		assert.Equal(t, 2, stocktake.Lines[2].Variance)
+
+		stocktake, err = f.service.ApproveStocktake(ctx, stocktake.Id, "cycle count")
+		assert.NoError(t, err)
+		assert.Equal(t, models.StocktakeApproved, stocktake.Status)
+		assert.NotNil(t, stocktake.ClosedAt)
+
+		assert.Equal(t, 23, quantity(t, f, 1))
+		assert.Equal(t, 5, quantity(t, f, 2))
+		assert.Equal(t, 12, quantity(t, f, 3))
+		assert.Equal(t, map[int64]int{1: 48, 3: 32}, f.totals)
+
+		movements, err := f.ledger.GetMovements(ctx, models.MovementFilter{WarehouseId: 1})
+		assert.NoError(t, err)
+		adjustments := movements[len(movements)-2:]
+		for _, movement := range adjustments {
+			assert.Equal(t, models.MovementAdjustment, movement.Type)
+			assert.Equal(t, "stocktake:1", movement.ReferenceId)
+			assert.Equal(t, "cycle count", movement.Reason)
+			assert.Equal(t, "auditor", movement.Actor)
+		}
+
+		discrepancies, err := f.ledger.GetLedgerDiscrepancies(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, discrepancies)
+	})
+
+	t.Run("should freeze movements of the warehouse while counting", func(t *testing.T) {
+		f := newFixture(t)
+
+		stocktake, err := f.service.OpenStocktake(ctx, 1, true)
+		assert.NoError(t, err)
+
+		err = f.warehouse.AddStock(ctx, 1, 1, 5)
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+
+		// orders are allocated from the other warehouses
+		allocations, err := f.warehouse.ProceedOrder(ctx, 1, []service.ProductOrderDetails{{ProductId: 1, Quantity: 5}}, nil, "")
+		assert.NoError(t, err)
+		assert.Len(t, allocations, 1)
+		assert.Equal(t, int64(2), allocations[0].WarehouseId)
+
+		_, err = f.service.CancelStocktake(ctx, stocktake.Id)
+		assert.NoError(t, err)
+		assert.NoError(t, f.warehouse.AddStock(ctx, 1, 1, 5))
+	})
+
+	t.Run("should measure variance against the stock when counted", func(t *testing.T) {
+		f := newFixture(t)
+
+		stocktake, err := f.service.OpenStocktake(ctx, 1, false)
+		assert.NoError(t, err)
+
+		_, err = f.service.RecordCounts(ctx, stocktake.Id, []models.StocktakeCount{{ProductId: 1, CountedQuantity: 20}})
+		assert.NoError(t, err)
+
+		// a receipt after the count is not part of the variance
+		assert.NoError(t, f.warehouse.AddStock(ctx, 1, 1, 5))
+
+		_, err = f.service.ApproveStocktake(ctx, stocktake.Id, "shrinkage")
+		assert.NoError(t, err)
+		assert.Equal(t, 25, quantity(t, f, 1))
+	})
+
+	t.Run("should allow one open stocktake per warehouse", func(t *testing.T) {
+		f := newFixture(t)
+
+		_, err := f.service.OpenStocktake(ctx, 1, false)
+		assert.NoError(t, err)
+
+		_, err = f.service.OpenStocktake(ctx, 1, true)
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+
+		_, err = f.service.OpenStocktake(ctx, 2, false)
+		assert.NoError(t, err)
+
+		_, err = f.service.OpenStocktake(ctx, 99, false)
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+
+	t.Run("should refuse closed stocktakes and approvals without a reason", func(t *testing.T) {
+		f := newFixture(t)
+
+		stocktake, err := f.service.OpenStocktake(ctx, 1, false)
+		assert.NoError(t, err)
+
+		_, err = f.service.ApproveStocktake(ctx, stocktake.Id, " ")
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+
+		_, err = f.service.RecordCounts(ctx, stocktake.Id, []models.StocktakeCount{{ProductId: 1, CountedQuantity: -1}})
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+
+		_, err = f.service.CancelStocktake(ctx, stocktake.Id)
+		assert.NoError(t, err)
+
+		_, err = f.service.RecordCounts(ctx, stocktake.Id, []models.StocktakeCount{{ProductId: 1, CountedQuantity: 3}})
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+
+		_, err = f.service.ApproveStocktake(ctx, stocktake.Id, "late")
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTransferService_Database moves product 1 from Warehouse A to B of the
+// seed data, which hold 25 units each.
+func TestTransferService_Database(t *testing.T) {
+	ctx := context.Background()
+
+	type fixture struct {
+		*dbFixture
+		service service.TransferService
+	}
+	newFixture := func(t *testing.T) fixture {
+		f := fixture{dbFixture: newDBFixture(t)}
+		f.recordTotals()
+		f.service = service.NewTransferService(f.uow, f.productRepo, fakeOrderRepository{})
+		return f
+	}
+	quantity := func(t *testing.T, f fixture, warehouseId int64) int {
+		stock, err := f.stockRepo.GetStockByProductAndWarehouse(ctx, 1, warehouseId)
+		assert.NoError(t, err)
+		return stock.Quantity
+	}
+
+	t.Run("should keep dispatched stock out of every warehouse until received", func(t *testing.T) {
+		f := newFixture(t)
+
+		transfer, err := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferRequested, transfer.Status)
+		assert.Equal(t, 25, quantity(t, f, 1))
+
+		transfer, err = f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, 15, quantity(t, f, 1))
+		assert.Equal(t, 25, quantity(t, f, 2))
+		assert.Equal(t, 40, f.totals[1])
+
+		_, err = f.service.MarkInTransit(ctx, transfer.Id)
+		assert.NoError(t, err)
+		inTransit, err := f.service.GetInTransitStock(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.InTransitStock{{ProductId: 1, ToWarehouseId: 2, Quantity: 10}}, inTransit)
+
+		transfer, err = f.service.ReceiveTransfer(ctx, transfer.Id, 4, false)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferInTransit, transfer.Status)
+		assert.Equal(t, 6, transfer.InTransit())
+		assert.Equal(t, 29, quantity(t, f, 2))
+
+		transfer, err = f.service.ReceiveTransfer(ctx, transfer.Id, 6, false)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferReceived, transfer.Status)
+		assert.Equal(t, 35, quantity(t, f, 2))
+		assert.Equal(t, 50, f.totals[1])
+
+		discrepancies, err := f.ledger.GetLedgerDiscrepancies(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, discrepancies)
+	})
+
+	t.Run("should write off what a final receipt did not bring", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, _ := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		_, err := f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+
+		transfer, err = f.service.ReceiveTransfer(ctx, transfer.Id, 7, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferReceived, transfer.Status)
+		assert.Equal(t, 0, transfer.InTransit())
+		assert.Equal(t, 32, quantity(t, f, 2))
+	})
+
+	t.Run("should refuse to receive more than is in transit", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, _ := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		_, err := f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+
+		_, err = f.service.ReceiveTransfer(ctx, transfer.Id, 11, false)
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+		assert.Equal(t, 25, quantity(t, f, 2))
+	})
+
+	t.Run("should return dispatched stock to the origin on cancel", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, _ := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		_, err := f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+
+		transfer, err = f.service.CancelTransfer(ctx, transfer.Id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferCancelled, transfer.Status)
+		assert.Equal(t, 25, quantity(t, f, 1))
+		assert.Equal(t, 50, f.totals[1])
+	})
+
+	t.Run("should refuse to cancel a partly received transfer", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, _ := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		_, err := f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		_, err = f.service.ReceiveTransfer(ctx, transfer.Id, 3, false)
+		assert.NoError(t, err)
+
+		_, err = f.service.CancelTransfer(ctx, transfer.Id)
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+
+	t.Run("should refuse to dispatch twice", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, _ := f.service.RequestTransfer(ctx, 1, 1, 2, 10)
+		_, err := f.service.DispatchTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+
+		_, err = f.service.DispatchTransfer(ctx, transfer.Id)
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+		assert.Equal(t, 15, quantity(t, f, 1))
+	})
+
+	t.Run("should refuse a dispatch beyond the origin stock", func(t *testing.T) {
+		f := newFixture(t)
+		transfer, err := f.service.RequestTransfer(ctx, 1, 1, 2, 30)
+		assert.NoError(t, err)
+
+		_, err = f.service.DispatchTransfer(ctx, transfer.Id)
+
+		assert.ErrorIs(t, err, apperror.ErrInsufficientStock)
+		found, err := f.service.GetTransfer(ctx, transfer.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TransferRequested, found.Status)
+	})
+}
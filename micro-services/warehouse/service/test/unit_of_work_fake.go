@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"math"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+)
+
+// fakeUnitOfWork hands the mocked repositories to fn without a transaction.
+// Ledger entries are kept in memory, no warehouse is frozen, all stock is in
+// the default lot and the outbox stays empty.
+type fakeUnitOfWork struct {
+	repos     repository.Repositories
+	movements *fakeMovementRepository
+}
+
+func newFakeUnitOfWork(warehouseRepo repository.WarehouseRepository, stockRepo repository.StockRepository, allocationRepo repository.AllocationRepository) *fakeUnitOfWork {
+	movements := &fakeMovementRepository{}
+	return &fakeUnitOfWork{
+		repos:     repository.Repositories{Warehouse: warehouseRepo, Stock: stockRepo, Allocation: allocationRepo, Movement: movements, Stocktake: fakeStocktakeRepository{}, Lot: fakeLotRepository{}, Outbox: fakeOutboxRepository{}},
+		movements: movements,
+	}
+}
+
+func (u *fakeUnitOfWork) WithTx(ctx context.Context, fn func(repos repository.Repositories) error) error {
+	return fn(u.repos)
+}
+
+func (u *fakeUnitOfWork) Read() repository.Repositories {
+	return u.repos
+}
+
+type fakeMovementRepository struct {
+	recorded []models.StockMovement
+}
+
+func (r *fakeMovementRepository) RecordMovement(ctx context.Context, movement *models.StockMovement) error {
+	movement.Id = int64(len(r.recorded) + 1)
+	r.recorded = append(r.recorded, *movement)
+	return nil
+}
+
+func (r *fakeMovementRepository) GetMovements(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	for _, movement := range r.recorded {
+		if filter.ProductId != 0 && movement.ProductId != filter.ProductId {
+			continue
+		}
+		if filter.WarehouseId != 0 && movement.WarehouseId != filter.WarehouseId {
+			continue
+		}
+		if filter.ReferenceId != "" && movement.ReferenceId != filter.ReferenceId {
+			continue
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+func (r *fakeMovementRepository) GetLedgerDiscrepancies(ctx context.Context) ([]models.LedgerDiscrepancy, error) {
+	return nil, nil
+}
+
+// fakeStocktakeRepository only answers which warehouses are frozen.
+type fakeStocktakeRepository struct {
+	repository.StocktakeRepository
+}
+
+func (fakeStocktakeRepository) GetFrozenWarehouseIds(ctx context.Context) ([]int64, error) {
+	return nil, nil
+}
+
+// fakeLotRepository keeps all stock in one default lot with no limit; the
+// mocked stock repository decides whether there is enough.
+type fakeLotRepository struct {
+	repository.LotRepository
+}
+
+func (fakeLotRepository) EnsureLot(ctx context.Context, lot *models.StockLot) error {
+	return nil
+}
+
+func (fakeLotRepository) GetLot(ctx context.Context, productId, warehouseId int64, lotNumber string) (*models.StockLot, error) {
+	return &models.StockLot{ProductId: productId, WarehouseId: warehouseId, LotNumber: lotNumber}, nil
+}
+
+func (fakeLotRepository) GetLots(ctx context.Context, filter models.LotFilter) ([]models.StockLot, error) {
+	return []models.StockLot{{ProductId: filter.ProductId, WarehouseId: filter.WarehouseId, LotNumber: models.DefaultLot, Quantity: math.MaxInt}}, nil
+}
+
+func (fakeLotRepository) AdjustLot(ctx context.Context, productId, warehouseId int64, lotNumber string, delta int) error {
+	return nil
+}
+
+// fakeOutboxRepository queues nothing, so every total is pushed right away.
+type fakeOutboxRepository struct {
+	repository.OutboxRepository
+}
+
+func (fakeOutboxRepository) MarkChanged(ctx context.Context, productIds ...int64) error {
+	return nil
+}
+
+func (fakeOutboxRepository) GetChange(ctx context.Context, productId int64) (*models.StockChange, error) {
+	return nil, nil
+}
+
+// fakeOrderRepository reports the pending orders and the stock they hold it
+// was given; the zero value has none.
+type fakeOrderRepository struct {
+	pending       []repository.PendingStock
+	pendingOrders []int64
+}
+
+func (r fakeOrderRepository) GetPendingStock(ctx context.Context) ([]repository.PendingStock, error) {
+	return r.pending, nil
+}
+
+func (r fakeOrderRepository) GetPendingOrderIds(ctx context.Context) ([]int64, error) {
+	return r.pendingOrders, nil
+}
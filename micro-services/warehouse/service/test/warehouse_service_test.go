@@ -1,10 +1,14 @@
 package test
 
 import (
+	"context"
 	"errors"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
 	mocks "monorepo-ecommerce/micro-services/warehouse/mocks/mock_micro-services/warehouse/repository"
 	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
 	"monorepo-ecommerce/micro-services/warehouse/service"
+	"monorepo-ecommerce/pkg/apperror"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,7 +22,7 @@ func TestWarehouseService_AddStock(t *testing.T) {
 	mockProductRepo := mocks.NewMockProductRepository(ctrl)
 	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
 
-	warehouseService := service.NewWarehouseService(mockWarehouseRepo, mockStockRepo, mockProductRepo)
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
 
 	productID := int64(1)
 	warehouseID := int64(1)
@@ -33,39 +37,93 @@ func TestWarehouseService_AddStock(t *testing.T) {
 			},
 		}
 
-		stock := &models.Stock{Quantity: 20}
+		stock := &models.Stock{ProductId: productID, WarehouseId: warehouseID, Quantity: 20}
+
+		mockProductRepo.EXPECT().
+			GetProductById(gomock.Any(), productID).
+			Return(&repository.Product{Id: productID}, nil)
+
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), warehouseID).
+			Return(&models.Warehouse{Id: warehouseID, Status: "active"}, nil)
 
 		mockStockRepo.EXPECT().
-			AddStockToWarehouse(gomock.Any(), gomock.Any(), gomock.Any()).
+			AddStockToWarehouse(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil)
 
 		mockWarehouseRepo.EXPECT().
-			GetActiveWarehouses().
+			GetActiveWarehouses(gomock.Any()).
 			Return(warehouses, nil)
 
 		mockStockRepo.EXPECT().
-			GetStockByProductAndWarehouse(gomock.Any(), gomock.Any()).
-			Return(stock, nil)
+			GetStocksByProducts(gomock.Any(), []int64{productID}).
+			Return([]models.Stock{*stock}, nil)
 
 		mockProductRepo.EXPECT().
-			UpdateTotalProductStock(gomock.Any(), gomock.Any()).
+			UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil)
 
-		err := warehouseService.AddStock(productID, warehouseID, quantity)
+		err := warehouseService.AddStock(context.Background(), productID, warehouseID, quantity)
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("should failed to add stock to warehouse", func(t *testing.T) {
+		mockProductRepo.EXPECT().
+			GetProductById(gomock.Any(), productID).
+			Return(&repository.Product{Id: productID}, nil)
+
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), warehouseID).
+			Return(&models.Warehouse{Id: warehouseID, Status: "active"}, nil)
+
 		mockStockRepo.EXPECT().
-			AddStockToWarehouse(productID, warehouseID, quantity).
+			AddStockToWarehouse(gomock.Any(), productID, warehouseID, quantity).
 			Return(errors.New("database error"))
 
-		err := warehouseService.AddStock(productID, warehouseID, quantity)
+		err := warehouseService.AddStock(context.Background(), productID, warehouseID, quantity)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to add stock to warehouse")
 	})
+
+	t.Run("should reject unknown product", func(t *testing.T) {
+		mockProductRepo.EXPECT().
+			GetProductById(gomock.Any(), productID).
+			Return(nil, &apperror.NotFoundError{Resource: "product", Id: productID})
+
+		err := warehouseService.AddStock(context.Background(), productID, warehouseID, quantity)
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+
+	t.Run("should refuse stock beyond the warehouse capacity", func(t *testing.T) {
+		capacity := 100
+
+		mockProductRepo.EXPECT().
+			GetProductById(gomock.Any(), productID).
+			Return(&repository.Product{Id: productID}, nil)
+
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), warehouseID).
+			Return(&models.Warehouse{Id: warehouseID, Status: "active", Capacity: &capacity}, nil)
+
+		mockStockRepo.EXPECT().
+			GetWarehouseStockTotal(gomock.Any(), warehouseID).
+			Return(95, nil)
+
+		err := warehouseService.AddStock(context.Background(), productID, warehouseID, quantity)
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+
+	t.Run("should reject a quantity that is not positive", func(t *testing.T) {
+		for _, quantity := range []int{0, -5} {
+			err := warehouseService.AddStock(context.Background(), productID, warehouseID, quantity)
+
+			assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+		}
+	})
 }
 
 func TestWarehouseService_RemoveStock(t *testing.T) {
@@ -76,7 +134,7 @@ func TestWarehouseService_RemoveStock(t *testing.T) {
 	mockProductRepo := mocks.NewMockProductRepository(ctrl)
 	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
 
-	warehouseService := service.NewWarehouseService(mockWarehouseRepo, mockStockRepo, mockProductRepo)
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
 
 	productID := int64(1)
 	warehouseID := int64(1)
@@ -87,39 +145,47 @@ func TestWarehouseService_RemoveStock(t *testing.T) {
 			{Id: warehouseID, Status: "active"},
 		}
 
-		stock := &models.Stock{Quantity: 15}
+		stock := &models.Stock{ProductId: productID, WarehouseId: warehouseID, Quantity: 15}
 
 		mockStockRepo.EXPECT().
-			RemoveStockFromWarehouse(gomock.Any(), gomock.Any(), gomock.Any()).
+			RemoveStockFromWarehouse(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil)
 
 		mockWarehouseRepo.EXPECT().
-			GetActiveWarehouses().
+			GetActiveWarehouses(gomock.Any()).
 			Return(warehouses, nil)
 
 		mockStockRepo.EXPECT().
-			GetStockByProductAndWarehouse(gomock.Any(), gomock.Any()).
-			Return(stock, nil)
+			GetStocksByProducts(gomock.Any(), []int64{productID}).
+			Return([]models.Stock{*stock}, nil)
 
 		mockProductRepo.EXPECT().
-			UpdateTotalProductStock(gomock.Any(), gomock.Any()).
+			UpdateTotalProductStock(gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil)
 
-		err := warehouseService.RemoveStock(productID, warehouseID, quantity)
+		err := warehouseService.RemoveStock(context.Background(), productID, warehouseID, quantity)
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("should failed to remove stock from warehouse", func(t *testing.T) {
 		mockStockRepo.EXPECT().
-			RemoveStockFromWarehouse(productID, warehouseID, quantity).
+			RemoveStockFromWarehouse(gomock.Any(), productID, warehouseID, quantity).
 			Return(errors.New("database error"))
 
-		err := warehouseService.RemoveStock(productID, warehouseID, quantity)
+		err := warehouseService.RemoveStock(context.Background(), productID, warehouseID, quantity)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to remove stock from warehouse")
 	})
+
+	t.Run("should reject a quantity that is not positive", func(t *testing.T) {
+		for _, quantity := range []int{0, -5} {
+			err := warehouseService.RemoveStock(context.Background(), productID, warehouseID, quantity)
+
+			assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+		}
+	})
 }
 
 func TestWarehouseService_TransferProduct(t *testing.T) {
@@ -129,7 +195,7 @@ func TestWarehouseService_TransferProduct(t *testing.T) {
 	mockProductRepo := mocks.NewMockProductRepository(ctrl)
 	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
 
-	warehouseService := service.NewWarehouseService(mockWarehouseRepo, mockStockRepo, mockProductRepo)
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
 
 	productID := int64(1)
 	fromWarehouseID := int64(1)
@@ -137,27 +203,366 @@ func TestWarehouseService_TransferProduct(t *testing.T) {
 	quantity := 10
 
 	t.Run("should success transfer product", func(t *testing.T) {
+		warehouses := []models.Warehouse{
+			{Id: fromWarehouseID, Status: "active"},
+			{Id: toWarehouseID, Status: "active"},
+		}
+
 		mockStockRepo.EXPECT().
-			RemoveStockFromWarehouse(gomock.Any(), gomock.Any(), gomock.Any()).
+			RemoveStockFromWarehouse(gomock.Any(), productID, fromWarehouseID, quantity).
 			Return(nil)
 
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), toWarehouseID).
+			Return(&models.Warehouse{Id: toWarehouseID, Status: "active"}, nil)
+
 		mockStockRepo.EXPECT().
-			AddStockToWarehouse(gomock.Any(), gomock.Any(), gomock.Any()).
+			AddStockToWarehouse(gomock.Any(), productID, toWarehouseID, quantity).
 			Return(nil)
 
-		err := warehouseService.TransferProduct(productID, fromWarehouseID, toWarehouseID, quantity)
+		mockWarehouseRepo.EXPECT().
+			GetActiveWarehouses(gomock.Any()).
+			Return(warehouses, nil)
+
+		mockStockRepo.EXPECT().
+			GetStocksByProducts(gomock.Any(), []int64{productID}).
+			Return([]models.Stock{
+				{ProductId: productID, WarehouseId: fromWarehouseID, Quantity: 10},
+				{ProductId: productID, WarehouseId: toWarehouseID, Quantity: 10},
+			}, nil)
+
+		mockProductRepo.EXPECT().
+			UpdateTotalProductStock(gomock.Any(), productID, 20).
+			Return(nil)
+
+		err := warehouseService.TransferProduct(context.Background(), productID, fromWarehouseID, toWarehouseID, quantity)
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("should failed to remove stock from source warehouse", func(t *testing.T) {
 		mockStockRepo.EXPECT().
-			RemoveStockFromWarehouse(gomock.Any(), gomock.Any(), gomock.Any()).
+			RemoveStockFromWarehouse(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(errors.New("insufficient stock"))
 
-		err := warehouseService.TransferProduct(productID, fromWarehouseID, toWarehouseID, quantity)
+		err := warehouseService.TransferProduct(context.Background(), productID, fromWarehouseID, toWarehouseID, quantity)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to remove stock from source warehouse")
 	})
+	t.Run("should failed to add stock to destination warehouse", func(t *testing.T) {
+		mockStockRepo.EXPECT().
+			RemoveStockFromWarehouse(gomock.Any(), productID, fromWarehouseID, quantity).
+			Return(nil)
+
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), toWarehouseID).
+			Return(&models.Warehouse{Id: toWarehouseID, Status: "active"}, nil)
+
+		mockStockRepo.EXPECT().
+			AddStockToWarehouse(gomock.Any(), productID, toWarehouseID, quantity).
+			Return(&apperror.NotFoundError{Resource: "stock"})
+
+		err := warehouseService.TransferProduct(context.Background(), productID, fromWarehouseID, toWarehouseID, quantity)
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+		assert.Contains(t, err.Error(), "failed to add stock to destination warehouse")
+	})
+
+	t.Run("should reject transfer to the same warehouse", func(t *testing.T) {
+		err := warehouseService.TransferProduct(context.Background(), productID, fromWarehouseID, fromWarehouseID, quantity)
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+
+	t.Run("should reject a quantity that is not positive", func(t *testing.T) {
+		for _, quantity := range []int{0, -5} {
+			err := warehouseService.TransferProduct(context.Background(), productID, fromWarehouseID, toWarehouseID, quantity)
+
+			assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+		}
+	})
+}
+
+func TestWarehouseService_ProceedOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockRepo := mocks.NewMockStockRepository(ctrl)
+	mockProductRepo := mocks.NewMockProductRepository(ctrl)
+	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
+	mockAllocationRepo := mocks.NewMockAllocationRepository(ctrl)
+
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, mockAllocationRepo), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
+
+	orderID := int64(7)
+	items := []service.ProductOrderDetails{{ProductId: 1, Quantity: 30}}
+	warehouses := []models.Warehouse{
+		{Id: 1, Status: "active", Priority: 1},
+		{Id: 2, Status: "active", Priority: 2},
+	}
+	stocks := []models.Stock{
+		{ProductId: 1, WarehouseId: 1, Quantity: 25},
+		{ProductId: 1, WarehouseId: 2, Quantity: 25},
+		// warehouse 3 is inactive and must not be drawn from
+		{ProductId: 1, WarehouseId: 3, Quantity: 100},
+	}
+
+	t.Run("should deduct and record the plan of the default strategy", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(nil, nil)
+		mockWarehouseRepo.EXPECT().GetActiveWarehouses(gomock.Any()).Return(warehouses, nil)
+		mockStockRepo.EXPECT().GetStocksByProduct(gomock.Any(), int64(1)).Return(stocks, nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(1), 25).Return(nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(2), 5).Return(nil)
+		mockAllocationRepo.EXPECT().CreateAllocation(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		allocations, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, "")
+
+		assert.NoError(t, err)
+		assert.Len(t, allocations, 2)
+		assert.Equal(t, int64(2), allocations[1].WarehouseId)
+		assert.Equal(t, 5, allocations[1].Quantity)
+		assert.Equal(t, allocation.Priority, allocations[1].Strategy)
+	})
+
+	t.Run("should use the strategy named by the order", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(nil, nil)
+		mockWarehouseRepo.EXPECT().GetActiveWarehouses(gomock.Any()).Return(warehouses, nil)
+		mockStockRepo.EXPECT().GetStocksByProduct(gomock.Any(), int64(1)).Return(stocks, nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(1), 15).Return(nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(2), 15).Return(nil)
+		mockAllocationRepo.EXPECT().CreateAllocation(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+		allocations, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, allocation.Balance)
+
+		assert.NoError(t, err)
+		assert.Equal(t, allocation.Balance, allocations[0].Strategy)
+	})
+
+	t.Run("should fail without touching stock when it is insufficient", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(nil, nil)
+		mockWarehouseRepo.EXPECT().GetActiveWarehouses(gomock.Any()).Return(warehouses, nil)
+		mockStockRepo.EXPECT().GetStocksByProduct(gomock.Any(), int64(1)).Return(stocks, nil)
+
+		_, err := warehouseService.ProceedOrder(context.Background(), orderID, []service.ProductOrderDetails{{ProductId: 1, Quantity: 51}}, nil, "")
+
+		assert.ErrorIs(t, err, apperror.ErrInsufficientStock)
+	})
+
+	t.Run("should reject an unknown strategy", func(t *testing.T) {
+		_, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, "random")
+
+		assert.ErrorIs(t, err, apperror.ErrInvalidInput)
+	})
+
+	t.Run("should merge lines of one product taken from the same warehouse", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(nil, nil)
+		mockWarehouseRepo.EXPECT().GetActiveWarehouses(gomock.Any()).Return(warehouses, nil)
+		mockStockRepo.EXPECT().GetStocksByProduct(gomock.Any(), int64(1)).Return(stocks, nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(1), 8).Return(nil)
+		mockAllocationRepo.EXPECT().CreateAllocation(gomock.Any(), gomock.Any()).Return(nil)
+
+		allocations, err := warehouseService.ProceedOrder(context.Background(), orderID, []service.ProductOrderDetails{{ProductId: 1, Quantity: 3}, {ProductId: 1, Quantity: 5}}, nil, "")
+
+		assert.NoError(t, err)
+		assert.Len(t, allocations, 1)
+		assert.Equal(t, 8, allocations[0].Quantity)
+	})
+
+	recorded := []models.StockAllocation{
+		{Id: 1, OrderId: orderID, ProductId: 1, WarehouseId: 1, Quantity: 25, Status: models.AllocationAllocated},
+		{Id: 2, OrderId: orderID, ProductId: 1, WarehouseId: 2, Quantity: 5, Status: models.AllocationAllocated},
+	}
+
+	t.Run("should replay an order that is already allocated", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(recorded, nil)
+
+		allocations, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, recorded, allocations)
+	})
+
+	t.Run("should replay when a concurrent forward wins the race", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(nil, nil)
+		mockWarehouseRepo.EXPECT().GetActiveWarehouses(gomock.Any()).Return(warehouses, nil)
+		mockStockRepo.EXPECT().GetStocksByProduct(gomock.Any(), int64(1)).Return(stocks, nil)
+		mockStockRepo.EXPECT().RemoveStockFromWarehouse(gomock.Any(), int64(1), int64(1), 25).Return(nil)
+		mockAllocationRepo.EXPECT().CreateAllocation(gomock.Any(), gomock.Any()).
+			Return(&apperror.ConflictError{Resource: "allocation", Reason: "order 7 is already allocated"})
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return(recorded, nil)
+
+		allocations, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, recorded, allocations)
+	})
+
+	t.Run("should refuse an order whose stock was released", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), orderID).Return([]models.StockAllocation{
+			{Id: 1, OrderId: orderID, ProductId: 1, WarehouseId: 1, Quantity: 25, Status: models.AllocationReleased},
+		}, nil)
+
+		_, err := warehouseService.ProceedOrder(context.Background(), orderID, items, nil, "")
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+}
+
+func TestWarehouseService_ReleaseOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockRepo := mocks.NewMockStockRepository(ctrl)
+	mockProductRepo := mocks.NewMockProductRepository(ctrl)
+	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
+	mockAllocationRepo := mocks.NewMockAllocationRepository(ctrl)
+
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, mockAllocationRepo), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
+
+	t.Run("should return stock to the warehouses it came from", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), int64(7)).Return([]models.StockAllocation{
+			{Id: 1, OrderId: 7, ProductId: 1, WarehouseId: 1, Quantity: 25, Status: models.AllocationAllocated},
+			{Id: 2, OrderId: 7, ProductId: 1, WarehouseId: 2, Quantity: 5, Status: models.AllocationReleased},
+		}, nil)
+		mockAllocationRepo.EXPECT().ReleaseAllocation(gomock.Any(), int64(1)).Return(nil)
+		mockStockRepo.EXPECT().AddStockToWarehouse(gomock.Any(), int64(1), int64(1), 25).Return(nil)
+
+		released, err := warehouseService.ReleaseOrder(context.Background(), 7)
+
+		assert.NoError(t, err)
+		assert.Len(t, released, 1)
+		assert.Equal(t, models.AllocationReleased, released[0].Status)
+	})
+
+	t.Run("should report an order without allocations", func(t *testing.T) {
+		mockAllocationRepo.EXPECT().GetAllocationsByOrder(gomock.Any(), int64(8)).Return(nil, nil)
+
+		_, err := warehouseService.ReleaseOrder(context.Background(), 8)
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+	})
+}
+
+func TestWarehouseService_CreateWarehouse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockRepo := mocks.NewMockStockRepository(ctrl)
+	mockProductRepo := mocks.NewMockProductRepository(ctrl)
+	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
+
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
+
+	t.Run("should create an active warehouse", func(t *testing.T) {
+		mockWarehouseRepo.EXPECT().
+			CreateWarehouse(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, warehouse *models.Warehouse) error {
+				warehouse.Id = 3
+				return nil
+			})
+
+		mockStockRepo.EXPECT().
+			CreateMissingStocksForWarehouse(gomock.Any(), int64(3)).
+			Return(nil)
+
+		created, err := warehouseService.CreateWarehouse(context.Background(), models.Warehouse{Name: "Warehouse C", OperatingHours: "07:30-21:00"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), created.Id)
+		assert.Equal(t, "active", created.Status)
+	})
+
+	t.Run("should reject invalid details", func(t *testing.T) {
+		negative := -1
+		cases := map[string]models.Warehouse{
+			"name":            {},
+			"capacity":        {Name: "C", Capacity: &negative},
+			"priority":        {Name: "C", Priority: -1},
+			"latitude":        {Name: "C", Location: &models.Location{Latitude: 91}},
+			"operating_hours": {Name: "C", OperatingHours: "8-17"},
+			"status":          {Name: "C", Status: "closed"},
+		}
+
+		for field, warehouse := range cases {
+			_, err := warehouseService.CreateWarehouse(context.Background(), warehouse)
+
+			var invalid *apperror.InvalidInputError
+			assert.True(t, errors.As(err, &invalid), field)
+			assert.Equal(t, field, invalid.Field)
+		}
+	})
+}
+
+func TestWarehouseService_UpdateWarehouse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockRepo := mocks.NewMockStockRepository(ctrl)
+	mockProductRepo := mocks.NewMockProductRepository(ctrl)
+	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
+
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
+
+	capacity := 40
+
+	t.Run("should keep the status of the warehouse", func(t *testing.T) {
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), int64(2)).
+			Return(&models.Warehouse{Id: 2, Status: "inactive"}, nil)
+
+		mockStockRepo.EXPECT().
+			GetWarehouseStockTotal(gomock.Any(), int64(2)).
+			Return(25, nil)
+
+		mockWarehouseRepo.EXPECT().
+			UpdateWarehouse(gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		updated, err := warehouseService.UpdateWarehouse(context.Background(), models.Warehouse{Id: 2, Name: "Warehouse B", Status: "active", Capacity: &capacity})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "inactive", updated.Status)
+	})
+
+	t.Run("should refuse a capacity below the stock held", func(t *testing.T) {
+		mockWarehouseRepo.EXPECT().
+			GetWarehouseById(gomock.Any(), int64(2)).
+			Return(&models.Warehouse{Id: 2, Status: "active"}, nil)
+
+		mockStockRepo.EXPECT().
+			GetWarehouseStockTotal(gomock.Any(), int64(2)).
+			Return(50, nil)
+
+		_, err := warehouseService.UpdateWarehouse(context.Background(), models.Warehouse{Id: 2, Name: "Warehouse B", Capacity: &capacity})
+
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+	})
+}
+
+func TestWarehouseService_ListWarehouses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockStockRepo := mocks.NewMockStockRepository(ctrl)
+	mockProductRepo := mocks.NewMockProductRepository(ctrl)
+	mockWarehouseRepo := mocks.NewMockWarehouseRepository(ctrl)
+
+	warehouseService := service.NewWarehouseService(newFakeUnitOfWork(mockWarehouseRepo, mockStockRepo, nil), mockWarehouseRepo, mockStockRepo, mockProductRepo, fakeOrderRepository{}, allocation.Priority)
+
+	t.Run("should attach the stock of each warehouse", func(t *testing.T) {
+		mockWarehouseRepo.EXPECT().
+			GetWarehouses(gomock.Any()).
+			Return([]models.Warehouse{{Id: 1}, {Id: 2}, {Id: 3}}, nil)
+
+		mockStockRepo.EXPECT().
+			GetAllStocks(gomock.Any()).
+			Return([]models.Stock{
+				{ProductId: 1, WarehouseId: 1, Quantity: 25},
+				{ProductId: 2, WarehouseId: 1, Quantity: 5},
+				{ProductId: 1, WarehouseId: 2, Quantity: 25},
+			}, nil)
+
+		warehouses, err := warehouseService.ListWarehouses(context.Background())
+
+		assert.NoError(t, err)
+		assert.Len(t, warehouses[0].Stocks, 2)
+		assert.Len(t, warehouses[1].Stocks, 1)
+		assert.Empty(t, warehouses[2].Stocks)
+	})
 }
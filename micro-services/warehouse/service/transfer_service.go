@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"monorepo-ecommerce/micro-services/warehouse/models"
+	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+)
+
+// TransferService moves stock between warehouses in two phases: dispatch
+// takes it out of the origin, receipt puts it into the destination, and in
+// between it is in transit and not sellable anywhere.
+type TransferService interface {
+	RequestTransfer(ctx context.Context, productId, fromWarehouseId, toWarehouseId int64, quantity int) (*models.TransferOrder, error)
+	GetTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error)
+	ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.TransferOrder, error)
+	DispatchTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error)
+	MarkInTransit(ctx context.Context, transferId int64) (*models.TransferOrder, error)
+	// ReceiveTransfer books quantity into the destination. A partial receipt
+	// leaves the rest in transit unless final, which writes it off.
+	ReceiveTransfer(ctx context.Context, transferId int64, quantity int, final bool) (*models.TransferOrder, error)
+	CancelTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error)
+	GetInTransitStock(ctx context.Context) ([]models.InTransitStock, error)
+}
+
+type transferService struct {
+	uow         repository.UnitOfWork
+	productRepo repository.ProductRepository
+	orderRepo   repository.OrderRepository
+}
+
+func NewTransferService(uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository) TransferService {
+	return &transferService{
+		uow:         uow,
+		productRepo: productRepo,
+		orderRepo:   orderRepo,
+	}
+}
+
+func (s *transferService) RequestTransfer(ctx context.Context, productId, fromWarehouseId, toWarehouseId int64, quantity int) (*models.TransferOrder, error) {
+	if quantity <= 0 {
+		return nil, &apperror.InvalidInputError{Field: "quantity", Reason: "must be positive"}
+	}
+	if fromWarehouseId == toWarehouseId {
+		return nil, &apperror.InvalidInputError{Field: "to_warehouse_id", Reason: "must differ from from_warehouse_id"}
+	}
+
+	transfer := models.TransferOrder{ProductId: productId, FromWarehouseId: fromWarehouseId, ToWarehouseId: toWarehouseId, Quantity: quantity}
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		destination, err := repos.Warehouse.GetWarehouseById(ctx, toWarehouseId)
+		if err != nil {
+			return err
+		}
+		if destination.Status != "active" {
+			return &apperror.InvalidInputError{Field: "to_warehouse_id", Reason: fmt.Sprintf("warehouse %d is not active", toWarehouseId)}
+		}
+
+		if _, err := repos.Warehouse.GetWarehouseById(ctx, fromWarehouseId); err != nil {
+			return err
+		}
+
+		return repos.Transfer.CreateTransfer(ctx, &transfer)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request transfer: %w", err)
+	}
+
+	return &transfer, nil
+}
+
+func (s *transferService) GetTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error) {
+	transfer, err := s.uow.Read().Transfer.GetTransferById(ctx, transferId)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch transfer: %w", err)
+	}
+
+	return transfer, nil
+}
+
+func (s *transferService) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.TransferOrder, error) {
+	transfers, err := s.uow.Read().Transfer.GetTransfers(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// DispatchTransfer takes the stock out of the origin warehouse.
+func (s *transferService) DispatchTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error) {
+	transfer, err := s.advance(ctx, transferId, "dispatch", func(repos repository.Repositories, transfer *models.TransferOrder) error {
+		if transfer.Status != models.TransferRequested {
+			return invalidTransition(transfer, "dispatched")
+		}
+
+		err := applyMovement(ctx, repos, models.StockMovement{
+			ProductId:   transfer.ProductId,
+			WarehouseId: transfer.FromWarehouseId,
+			Type:        models.MovementTransferOut,
+			Quantity:    -transfer.Quantity,
+			ReferenceId: transferReference(transfer.Id),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove stock from source warehouse: %w", err)
+		}
+
+		transfer.Status = models.TransferDispatched
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, transfer.ProductId)
+	return transfer, nil
+}
+
+// MarkInTransit records that the carrier has the goods; no stock moves.
+func (s *transferService) MarkInTransit(ctx context.Context, transferId int64) (*models.TransferOrder, error) {
+	return s.advance(ctx, transferId, "mark in transit", func(repos repository.Repositories, transfer *models.TransferOrder) error {
+		if transfer.Status != models.TransferDispatched {
+			return invalidTransition(transfer, "in transit")
+		}
+
+		transfer.Status = models.TransferInTransit
+		return nil
+	})
+}
+
+func (s *transferService) ReceiveTransfer(ctx context.Context, transferId int64, quantity int, final bool) (*models.TransferOrder, error) {
+	if quantity < 0 || (quantity == 0 && !final) {
+		return nil, &apperror.InvalidInputError{Field: "quantity", Reason: "must be positive"}
+	}
+
+	transfer, err := s.advance(ctx, transferId, "receive", func(repos repository.Repositories, transfer *models.TransferOrder) error {
+		if transfer.Status != models.TransferDispatched && transfer.Status != models.TransferInTransit {
+			return invalidTransition(transfer, "received")
+		}
+		if remaining := transfer.InTransit(); quantity > remaining {
+			return &apperror.InvalidInputError{Field: "quantity", Reason: fmt.Sprintf("only %d units are in transit", remaining)}
+		}
+
+		if quantity > 0 {
+			if err := checkCapacity(ctx, repos, transfer.ToWarehouseId, quantity); err != nil {
+				return err
+			}
+			lots, err := pendingLots(ctx, repos, transferReference(transfer.Id), transfer.ProductId, transfer.FromWarehouseId, transfer.ToWarehouseId)
+			if err != nil {
+				return err
+			}
+			err = bookLots(ctx, repos, models.StockMovement{
+				ProductId:   transfer.ProductId,
+				WarehouseId: transfer.ToWarehouseId,
+				Type:        models.MovementTransferIn,
+				Quantity:    quantity,
+				ReferenceId: transferReference(transfer.Id),
+			}, transfer.FromWarehouseId, lots)
+			if err != nil {
+				return fmt.Errorf("failed to add stock to destination warehouse: %w", err)
+			}
+		}
+
+		transfer.ReceivedQuantity += quantity
+		transfer.Status = models.TransferInTransit
+		if final || transfer.ReceivedQuantity == transfer.Quantity {
+			transfer.Status = models.TransferReceived
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, transfer.ProductId)
+	return transfer, nil
+}
+
+// CancelTransfer stops a transfer before anything was received; dispatched
+// stock goes back to the origin.
+func (s *transferService) CancelTransfer(ctx context.Context, transferId int64) (*models.TransferOrder, error) {
+	returned := false
+	transfer, err := s.advance(ctx, transferId, "cancel", func(repos repository.Repositories, transfer *models.TransferOrder) error {
+		switch {
+		case transfer.Status == models.TransferRequested:
+		case transfer.InTransit() > 0 && transfer.ReceivedQuantity == 0:
+			lots, err := pendingLots(ctx, repos, transferReference(transfer.Id), transfer.ProductId, transfer.FromWarehouseId, transfer.FromWarehouseId)
+			if err != nil {
+				return err
+			}
+			err = bookLots(ctx, repos, models.StockMovement{
+				ProductId:   transfer.ProductId,
+				WarehouseId: transfer.FromWarehouseId,
+				Type:        models.MovementTransferIn,
+				Quantity:    transfer.Quantity,
+				ReferenceId: transferReference(transfer.Id),
+			}, transfer.FromWarehouseId, lots)
+			if err != nil {
+				return fmt.Errorf("failed to return stock to source warehouse: %w", err)
+			}
+			returned = true
+		case transfer.ReceivedQuantity > 0 && transfer.Status != models.TransferReceived:
+			return &apperror.ConflictError{Resource: "transfer", Reason: fmt.Sprintf("transfer %d is partly received, receive the rest as final instead", transfer.Id)}
+		default:
+			return invalidTransition(transfer, "cancelled")
+		}
+
+		transfer.Status = models.TransferCancelled
+		return nil
+	})
+	if err != nil || !returned {
+		return transfer, err
+	}
+
+	syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, transfer.ProductId)
+	return transfer, nil
+}
+
+func (s *transferService) GetInTransitStock(ctx context.Context) ([]models.InTransitStock, error) {
+	stocks, err := s.uow.Read().Transfer.GetInTransitStock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch in-transit stock: %w", err)
+	}
+
+	return stocks, nil
+}
+
+// advance loads a transfer, lets step change it and saves it, all in one
+// transaction. The save only succeeds if nobody moved the transfer on in the
+// meantime.
+func (s *transferService) advance(ctx context.Context, transferId int64, action string, step func(repos repository.Repositories, transfer *models.TransferOrder) error) (*models.TransferOrder, error) {
+	var transfer *models.TransferOrder
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		var err error
+		transfer, err = repos.Transfer.GetTransferById(ctx, transferId)
+		if err != nil {
+			return err
+		}
+
+		fromStatus := transfer.Status
+		if err := step(repos, transfer); err != nil {
+			return err
+		}
+
+		return repos.Transfer.UpdateTransfer(ctx, transfer, fromStatus)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s transfer: %w", action, err)
+	}
+
+	return transfer, nil
+}
+
+func invalidTransition(transfer *models.TransferOrder, to string) error {
+	return &apperror.ConflictError{Resource: "transfer", Reason: fmt.Sprintf("transfer %d is %s and cannot be %s", transfer.Id, transfer.Status, to)}
+}
+
+func transferReference(transferId int64) string {
+	return fmt.Sprintf("transfer-order:%d", transferId)
+}
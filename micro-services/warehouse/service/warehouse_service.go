@@ -1,31 +1,63 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"monorepo-ecommerce/micro-services/warehouse/allocation"
+	"monorepo-ecommerce/micro-services/warehouse/models"
 	"monorepo-ecommerce/micro-services/warehouse/repository"
+	"monorepo-ecommerce/pkg/apperror"
+	"regexp"
+	"strings"
 )
 
 type WarehouseService interface {
-	AddStock(productId, warehouseID int64, quantity int) error
-	RemoveStock(productId, warehouseID int64, quantity int) error
-	GetTotalStock(productId int64) (int, error)
-	TransferProduct(productId int64, fromWarehouseId int64, toWarehouseId int64, quantity int) error
-	ActiveDeactiveWarehouseStatus(warehouseId int64) error
-	ProceedOrder(orderID int64, items []ProductOrderDetails) error
+	CreateWarehouse(ctx context.Context, warehouse models.Warehouse) (*models.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, warehouse models.Warehouse) (*models.Warehouse, error)
+	GetWarehouse(ctx context.Context, warehouseId int64) (*models.Warehouse, error)
+	ListWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	AddStock(ctx context.Context, productId, warehouseID int64, quantity int) error
+	// ReceiveLot adds stock to a lot, creating the lot on its first receipt.
+	ReceiveLot(ctx context.Context, lot models.StockLot, quantity int) error
+	ListLots(ctx context.Context, filter models.LotFilter) ([]models.StockLot, error)
+	// ListExpiringLots lists lots in stock that expire within the next withinDays
+	// days, including those already expired.
+	ListExpiringLots(ctx context.Context, filter models.LotFilter, withinDays int) ([]models.StockLot, error)
+	InitProductStock(ctx context.Context, productId int64) error
+	RemoveStock(ctx context.Context, productId, warehouseID int64, quantity int) error
+	GetTotalStock(ctx context.Context, productId int64) (int, error)
+	TransferProduct(ctx context.Context, productId int64, fromWarehouseId int64, toWarehouseId int64, quantity int) error
+	ActiveDeactiveWarehouseStatus(ctx context.Context, warehouseId int64) error
+	ActivateWarehouse(ctx context.Context, warehouseId int64) (*models.Warehouse, error)
+	DeactivateWarehouse(ctx context.Context, warehouseId int64, options DeactivateOptions) (*Deactivation, error)
+	GetWarehouseEvents(ctx context.Context, warehouseId int64) ([]models.AuditEvent, error)
+	ListMovements(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error)
+	VerifyLedger(ctx context.Context) ([]models.LedgerDiscrepancy, error)
+	ProceedOrder(ctx context.Context, orderID int64, items []ProductOrderDetails, destination *models.Location, strategy string) ([]models.StockAllocation, error)
+	ReleaseOrder(ctx context.Context, orderID int64) ([]models.StockAllocation, error)
 }
 
 type warehouseService struct {
+	uow           repository.UnitOfWork
 	warehouseRepo repository.WarehouseRepository
 	stockRepo     repository.StockRepository
 	productRepo   repository.ProductRepository
+	orderRepo     repository.OrderRepository
+	// allocationStrategy is used when an order does not name one.
+	allocationStrategy string
 }
 
-func NewWarehouseService(warehouseRepo repository.WarehouseRepository, stockRepo repository.StockRepository, productRepo repository.ProductRepository) WarehouseService {
+func NewWarehouseService(uow repository.UnitOfWork, warehouseRepo repository.WarehouseRepository, stockRepo repository.StockRepository, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, allocationStrategy string) WarehouseService {
 	return &warehouseService{
-		warehouseRepo: warehouseRepo,
-		stockRepo:     stockRepo,
-		productRepo:   productRepo,
+		uow:                uow,
+		warehouseRepo:      warehouseRepo,
+		stockRepo:          stockRepo,
+		productRepo:        productRepo,
+		orderRepo:          orderRepo,
+		allocationStrategy: allocationStrategy,
 	}
 }
 
@@ -34,159 +66,1003 @@ type ProductOrderDetails struct {
 	Quantity  int
 }
 
-func (s *warehouseService) AddStock(productId, warehouseId int64, quantity int) error {
-	err := s.stockRepo.AddStockToWarehouse(productId, warehouseId, quantity)
+func (s *warehouseService) AddStock(ctx context.Context, productId, warehouseId int64, quantity int) error {
+	return s.ReceiveLot(ctx, models.StockLot{ProductId: productId, WarehouseId: warehouseId}, quantity)
+}
+
+func (s *warehouseService) ReceiveLot(ctx context.Context, lot models.StockLot, quantity int) error {
+	if quantity <= 0 {
+		return &apperror.InvalidInputError{Field: "quantity", Reason: "must be positive"}
+	}
+	if lot.IsExpired(models.Today()) {
+		return &apperror.InvalidInputError{Field: "expiry_date", Reason: "is in the past"}
+	}
+
+	// products live in the product service, so the id is checked through its API
+	_, err := s.productRepo.GetProductById(ctx, lot.ProductId)
+	if err != nil {
+		return fmt.Errorf("failed to validate product: %w", err)
+	}
+
+	err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		if err := checkCapacity(ctx, repos, lot.WarehouseId, quantity); err != nil {
+			return err
+		}
+		if lot.LotNumber != models.DefaultLot {
+			if err := ensureLot(ctx, repos, &lot); err != nil {
+				return err
+			}
+		}
+		return applyMovement(ctx, repos, models.StockMovement{
+			ProductId:   lot.ProductId,
+			WarehouseId: lot.WarehouseId,
+			Type:        models.MovementReceipt,
+			Quantity:    quantity,
+			LotNumber:   lot.LotNumber,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add stock to warehouse: %w", err)
+	}
+
+	s.syncTotalStock(ctx, lot.ProductId)
+	return nil
+}
+
+func (s *warehouseService) ListLots(ctx context.Context, filter models.LotFilter) ([]models.StockLot, error) {
+	lots, err := s.uow.Read().Lot.GetLots(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lots: %w", err)
+	}
+
+	return lots, nil
+}
+
+func (s *warehouseService) ListExpiringLots(ctx context.Context, filter models.LotFilter, withinDays int) ([]models.StockLot, error) {
+	if withinDays < 0 {
+		return nil, &apperror.InvalidInputError{Field: "days", Reason: "must not be negative"}
+	}
+
+	// a lot expiring on the last day of the window is included
+	lots, err := s.uow.Read().Lot.GetExpiringLots(ctx, filter, models.Today().AddDate(0, 0, withinDays+1))
 	if err != nil {
-		return fmt.Errorf("failed to add stock to warehouse: %v", err)
+		return nil, fmt.Errorf("failed to list expiring lots: %w", err)
 	}
 
-	// update product stock
-	totalStock, err := s.GetTotalStock(productId)
+	return lots, nil
+}
+
+// checkCapacity refuses to put quantity more units into a warehouse than its
+// capacity allows. It runs in the transaction that adds the stock.
+func checkCapacity(ctx context.Context, repos repository.Repositories, warehouseId int64, quantity int) error {
+	warehouse, err := repos.Warehouse.GetWarehouseById(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed to fetch total stock: %v", err)
+		return err
+	}
+	if warehouse.Capacity == nil {
+		return nil
 	}
 
-	err = s.productRepo.UpdateTotalProductStock(productId, totalStock)
+	used, err := repos.Stock.GetWarehouseStockTotal(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed forward update total product stock: %v", err)
+		return err
+	}
+
+	if used+quantity > *warehouse.Capacity {
+		return &apperror.ConflictError{
+			Resource: "warehouse",
+			Reason:   fmt.Sprintf("warehouse %d can hold %d units, it holds %d and %d more were requested", warehouseId, *warehouse.Capacity, used, quantity),
+		}
 	}
 
 	return nil
 }
 
-func (s *warehouseService) RemoveStock(productId, warehouseId int64, quantity int) error {
-	err := s.stockRepo.RemoveStockFromWarehouse(productId, warehouseId, quantity)
+// applyMovement changes the stock by movement.Quantity and appends the
+// movement to the ledger, both in the caller's transaction. A warehouse
+// frozen for a stocktake refuses the change.
+func applyMovement(ctx context.Context, repos repository.Repositories, movement models.StockMovement) error {
+	_, err := applyMovementLots(ctx, repos, movement)
+	return err
+}
+
+// applyMovementLots is applyMovement returning the ledger entries, one per
+// lot touched. Stock is added to movement.LotNumber; removed stock comes out
+// of that lot when one is named and first expiry, first out otherwise.
+func applyMovementLots(ctx context.Context, repos repository.Repositories, movement models.StockMovement) ([]models.StockMovement, error) {
+	frozen, err := frozenWarehouses(ctx, repos)
 	if err != nil {
-		return fmt.Errorf("failed to remove stock from warehouse: %v", err)
+		return nil, err
+	}
+	if frozen[movement.WarehouseId] {
+		return nil, &apperror.ConflictError{Resource: "warehouse", Reason: fmt.Sprintf("warehouse %d is frozen for a stocktake", movement.WarehouseId)}
 	}
 
-	// update product stock
-	totalStock, err := s.GetTotalStock(productId)
+	if movement.Quantity >= 0 {
+		err = repos.Stock.AddStockToWarehouse(ctx, movement.ProductId, movement.WarehouseId, movement.Quantity)
+	} else {
+		err = repos.Stock.RemoveStockFromWarehouse(ctx, movement.ProductId, movement.WarehouseId, -movement.Quantity)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to fetch total stock: %v", err)
+		return nil, err
+	}
+
+	movement.Actor = actorFromContext(ctx)
+	parts := []models.StockMovement{movement}
+	if movement.Quantity < 0 {
+		if parts, err = takeLots(ctx, repos, movement); err != nil {
+			return nil, err
+		}
 	}
 
-	err = s.productRepo.UpdateTotalProductStock(productId, totalStock)
+	for i := range parts {
+		if err := repos.Lot.AdjustLot(ctx, parts[i].ProductId, parts[i].WarehouseId, parts[i].LotNumber, parts[i].Quantity); err != nil {
+			return nil, err
+		}
+		if err := repos.Movement.RecordMovement(ctx, &parts[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := repos.Outbox.MarkChanged(ctx, movement.ProductId); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// frozenWarehouses are the warehouses whose stock may not change while they
+// are counted.
+func frozenWarehouses(ctx context.Context, repos repository.Repositories) (map[int64]bool, error) {
+	warehouseIds, err := repos.Stocktake.GetFrozenWarehouseIds(ctx)
 	if err != nil {
-		return fmt.Errorf("failed forward update total product stock: %v", err)
+		return nil, fmt.Errorf("failed to get frozen warehouses: %w", err)
+	}
+
+	frozen := map[int64]bool{}
+	for _, warehouseId := range warehouseIds {
+		frozen[warehouseId] = true
+	}
+	return frozen, nil
+}
+
+func orderReference(orderID int64) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+type actorKey struct{}
+
+// WithActor names who is changing stock, for the ledger.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromContext is the actor set by WithActor, or "system" for changes
+// nobody asked for, such as scheduled jobs.
+func actorFromContext(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	return "system"
+}
+
+// ListMovements returns ledger entries, oldest first.
+func (s *warehouseService) ListMovements(ctx context.Context, filter models.MovementFilter) ([]models.StockMovement, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &apperror.InvalidInputError{Field: "to", Reason: "must be after from"}
+	}
+
+	movements, err := s.uow.Read().Movement.GetMovements(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch stock movements: %w", err)
+	}
+
+	return movements, nil
+}
+
+// VerifyLedger lists the stock rows that do not match their movements; it is
+// empty while the ledger explains every quantity.
+func (s *warehouseService) VerifyLedger(ctx context.Context) ([]models.LedgerDiscrepancy, error) {
+	discrepancies, err := s.uow.Read().Movement.GetLedgerDiscrepancies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed verify stock ledger: %w", err)
+	}
+
+	return discrepancies, nil
+}
+
+// InitProductStock gives a new product a zero stock row in every warehouse.
+func (s *warehouseService) InitProductStock(ctx context.Context, productId int64) error {
+	_, err := s.productRepo.GetProductById(ctx, productId)
+	if err != nil {
+		return fmt.Errorf("failed to validate product: %w", err)
+	}
+
+	err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		return repos.Stock.CreateMissingStocksForProduct(ctx, productId)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stock rows: %w", err)
 	}
 
 	return nil
 }
 
-func (s *warehouseService) GetTotalStock(productId int64) (int, error) {
-	warehouses, err := s.warehouseRepo.GetActiveWarehouses()
+func (s *warehouseService) RemoveStock(ctx context.Context, productId, warehouseId int64, quantity int) error {
+	if quantity <= 0 {
+		return &apperror.InvalidInputError{Field: "quantity", Reason: "must be positive"}
+	}
+
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		return applyMovement(ctx, repos, models.StockMovement{ProductId: productId, WarehouseId: warehouseId, Type: models.MovementAdjustment, Quantity: -quantity})
+	})
 	if err != nil {
-		return 0, fmt.Errorf("failed to get active warehouses: %v", err)
+		return fmt.Errorf("failed to remove stock from warehouse: %w", err)
 	}
 
-	totalStock := 0
+	s.syncTotalStock(ctx, productId)
+	return nil
+}
+
+// syncTotalStock pushes the sellable stock of productId to the product
+// service.
+func (s *warehouseService) syncTotalStock(ctx context.Context, productId int64) {
+	syncTotalStock(ctx, s.uow, s.productRepo, s.orderRepo, productId)
+}
+
+func (s *warehouseService) GetTotalStock(ctx context.Context, productId int64) (int, error) {
+	return totalStock(ctx, s.warehouseRepo, s.stockRepo, productId)
+}
+
+// syncTotalStock forwards the sellable stock of a product, the sum over
+// active warehouses less what pending orders hold, to the product service and
+// takes the product off the outbox. It runs after the stock change committed, so a failure is only
+// logged: the product stays queued and the push job delivers it, while
+// failing the caller would make it retry a change that already happened.
+func syncTotalStock(ctx context.Context, uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, productId int64) {
+	if err := pushTotalStock(ctx, uow, productRepo, orderRepo, productId); err != nil {
+		log.Printf("failed push total stock of product %d, left to the push job: %v", productId, err)
+	}
+}
+
+func pushTotalStock(ctx context.Context, uow repository.UnitOfWork, productRepo repository.ProductRepository, orderRepo repository.OrderRepository, productId int64) error {
+	pending, err := pendingStock(ctx, orderRepo)
+	if err != nil {
+		return err
+	}
+
+	repos := uow.Read()
+	change, err := repos.Outbox.GetChange(ctx, productId)
+	if err != nil {
+		return fmt.Errorf("failed to fetch total stock: %w", err)
+	}
+	totals, err := sellableTotals(ctx, repos, []int64{productId}, pending)
+	if err != nil {
+		return fmt.Errorf("failed to fetch total stock: %w", err)
+	}
+
+	err = productRepo.UpdateTotalProductStock(ctx, productId, totals[0].Stock)
+	if err != nil {
+		return fmt.Errorf("failed forward update total product stock: %w", err)
+	}
+
+	if change == nil {
+		return nil
+	}
+	err = uow.WithTx(ctx, func(repos repository.Repositories) error {
+		return repos.Outbox.Acknowledge(ctx, []models.StockChange{*change})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear stock outbox: %w", err)
+	}
+
+	return nil
+}
+
+func totalStock(ctx context.Context, warehouseRepo repository.WarehouseRepository, stockRepo repository.StockRepository, productId int64) (int, error) {
+	warehouses, err := warehouseRepo.GetActiveWarehouses(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active warehouses: %w", err)
+	}
+
+	stocks, err := stockRepo.GetStocksByProduct(ctx, productId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stock for product %d: %w", productId, err)
+	}
+
+	return sumActive(warehouses, stocks), nil
+}
+
+// sumActive adds up the stock held by the given active warehouses. A
+// warehouse without a row for the product holds none.
+func sumActive(warehouses []models.Warehouse, stocks []models.Stock) int {
+	active := map[int64]bool{}
 	for _, warehouse := range warehouses {
-		stock, err := s.stockRepo.GetStockByProductAndWarehouse(productId, warehouse.Id)
+		active[warehouse.Id] = true
+	}
+
+	total := 0
+	for _, stock := range stocks {
+		if active[stock.WarehouseId] {
+			total += stock.Sellable()
+		}
+	}
+	return total
+}
+
+func (s *warehouseService) TransferProduct(ctx context.Context, productID int64, fromWarehouseID int64, toWarehouseID int64, quantity int) error {
+	if quantity <= 0 {
+		return &apperror.InvalidInputError{Field: "quantity", Reason: "must be positive"}
+	}
+	if fromWarehouseID == toWarehouseID {
+		return &apperror.InvalidInputError{Field: "to_warehouse_id", Reason: "must differ from from_warehouse_id"}
+	}
+
+	// both legs commit together or not at all
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		reference := fmt.Sprintf("transfer:%d-%d", fromWarehouseID, toWarehouseID)
+		taken, err := applyMovementLots(ctx, repos, models.StockMovement{ProductId: productID, WarehouseId: fromWarehouseID, Type: models.MovementTransferOut, Quantity: -quantity, ReferenceId: reference})
+		if err != nil {
+			return fmt.Errorf("failed to remove stock from source warehouse: %w", err)
+		}
+
+		err = checkCapacity(ctx, repos, toWarehouseID, quantity)
+		if err == nil {
+			in := models.StockMovement{ProductId: productID, WarehouseId: toWarehouseID, Type: models.MovementTransferIn, Quantity: quantity, ReferenceId: reference}
+			err = bookLots(ctx, repos, in, fromWarehouseID, lotsOf(taken))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add stock to destination warehouse: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the total only changes when one side is inactive, but it is cheap to keep in sync
+	s.syncTotalStock(ctx, productID)
+	return nil
+}
+
+func (s *warehouseService) CreateWarehouse(ctx context.Context, warehouse models.Warehouse) (*models.Warehouse, error) {
+	if warehouse.Status == "" {
+		warehouse.Status = "active"
+	}
+	if warehouse.Status != "active" && warehouse.Status != "inactive" {
+		return nil, &apperror.InvalidInputError{Field: "status", Reason: "must be active or inactive"}
+	}
+	if err := validateWarehouse(warehouse); err != nil {
+		return nil, err
+	}
+
+	warehouse.Stocks = nil
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		if err := repos.Warehouse.CreateWarehouse(ctx, &warehouse); err != nil {
+			return err
+		}
+		return repos.Stock.CreateMissingStocksForWarehouse(ctx, warehouse.Id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create warehouse: %w", err)
+	}
+
+	return &warehouse, nil
+}
+
+// UpdateWarehouse replaces the metadata of a warehouse. The status is kept,
+// and the capacity cannot drop below the stock the warehouse holds.
+func (s *warehouseService) UpdateWarehouse(ctx context.Context, warehouse models.Warehouse) (*models.Warehouse, error) {
+	if err := validateWarehouse(warehouse); err != nil {
+		return nil, err
+	}
+
+	warehouse.Stocks = nil
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		current, err := repos.Warehouse.GetWarehouseById(ctx, warehouse.Id)
 		if err != nil {
-			return 0, fmt.Errorf("failed to get stock for product %d in warehouse %d: %v", productId, warehouse.Id, err)
+			return err
+		}
+		warehouse.Status = current.Status
+
+		if warehouse.Capacity != nil {
+			used, err := repos.Stock.GetWarehouseStockTotal(ctx, warehouse.Id)
+			if err != nil {
+				return err
+			}
+			if used > *warehouse.Capacity {
+				return &apperror.ConflictError{
+					Resource: "warehouse",
+					Reason:   fmt.Sprintf("warehouse %d holds %d units, more than the new capacity of %d", warehouse.Id, used, *warehouse.Capacity),
+				}
+			}
 		}
-		totalStock += stock.Quantity
+
+		return repos.Warehouse.UpdateWarehouse(ctx, &warehouse)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update warehouse: %w", err)
 	}
 
-	return totalStock, nil
+	return &warehouse, nil
 }
 
-func (s *warehouseService) TransferProduct(productID int64, fromWarehouseID int64, toWarehouseID int64, quantity int) error {
-	// Deduct stock from origin warehouse
-	err := s.RemoveStock(productID, fromWarehouseID, quantity)
+func (s *warehouseService) GetWarehouse(ctx context.Context, warehouseId int64) (*models.Warehouse, error) {
+	warehouse, err := s.warehouseRepo.GetWarehouseById(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed to remove stock from source warehouse: %v", err)
+		return nil, fmt.Errorf("failed fetch warehouse: %w", err)
 	}
 
-	// Add stock from destination warehouse
-	err = s.AddStock(productID, toWarehouseID, quantity)
+	warehouse.Stocks, err = s.stockRepo.GetStocksByWarehouse(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed to add stock to destination warehouse: %v", err)
+		return nil, fmt.Errorf("failed fetch warehouse stock: %w", err)
+	}
+
+	return warehouse, nil
+}
+
+// ListWarehouses returns every warehouse, active or not, with its stock per
+// product.
+func (s *warehouseService) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	warehouses, err := s.warehouseRepo.GetWarehouses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch warehouses: %w", err)
+	}
+
+	stocks, err := s.stockRepo.GetAllStocks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch warehouse stock: %w", err)
+	}
+
+	byWarehouse := map[int64][]models.Stock{}
+	for _, stock := range stocks {
+		byWarehouse[stock.WarehouseId] = append(byWarehouse[stock.WarehouseId], stock)
+	}
+	for i := range warehouses {
+		warehouses[i].Stocks = byWarehouse[warehouses[i].Id]
+	}
+
+	return warehouses, nil
+}
+
+var operatingHours = regexp.MustCompile(`^([01]\d|2[0-3]):[0-5]\d-([01]\d|2[0-3]):[0-5]\d$`)
+
+func validateWarehouse(warehouse models.Warehouse) error {
+	if strings.TrimSpace(warehouse.Name) == "" {
+		return &apperror.InvalidInputError{Field: "name", Reason: "name is required"}
+	}
+	if warehouse.Priority < 0 {
+		return &apperror.InvalidInputError{Field: "priority", Reason: "must not be negative"}
+	}
+	if warehouse.Capacity != nil && *warehouse.Capacity < 0 {
+		return &apperror.InvalidInputError{Field: "capacity", Reason: "must not be negative"}
+	}
+	if location := warehouse.Location; location != nil {
+		if location.Latitude < -90 || location.Latitude > 90 {
+			return &apperror.InvalidInputError{Field: "latitude", Reason: "must be between -90 and 90"}
+		}
+		if location.Longitude < -180 || location.Longitude > 180 {
+			return &apperror.InvalidInputError{Field: "longitude", Reason: "must be between -180 and 180"}
+		}
+	}
+	if warehouse.OperatingHours != "" && !operatingHours.MatchString(warehouse.OperatingHours) {
+		return &apperror.InvalidInputError{Field: "operating_hours", Reason: "must look like 08:00-17:00"}
 	}
 
 	return nil
 }
 
-func (s *warehouseService) ActiveDeactiveWarehouseStatus(warehouseId int64) error {
-	warehouse, err := s.warehouseRepo.GetWarehouseById(warehouseId)
+// ActiveDeactiveWarehouseStatus flips the status of a warehouse, deactivating
+// with the default options.
+func (s *warehouseService) ActiveDeactiveWarehouseStatus(ctx context.Context, warehouseId int64) error {
+	warehouse, err := s.warehouseRepo.GetWarehouseById(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed fetch warehouse: %v", err)
+		return fmt.Errorf("failed fetch warehouse: %w", err)
 	}
 
 	if warehouse.Status == "active" {
-		err = s.ActivateWarehouse(warehouse.Id)
+		_, err = s.DeactivateWarehouse(ctx, warehouse.Id, DeactivateOptions{})
+	} else {
+		_, err = s.ActivateWarehouse(ctx, warehouse.Id)
+	}
+
+	return err
+}
+
+// ActivateWarehouse puts a warehouse back into the stock pool. Activating an
+// active warehouse changes nothing.
+func (s *warehouseService) ActivateWarehouse(ctx context.Context, warehouseId int64) (*models.Warehouse, error) {
+	var warehouse *models.Warehouse
+	var products []int64
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		var err error
+		warehouse, err = repos.Warehouse.GetWarehouseById(ctx, warehouseId)
+		if err != nil || warehouse.Status == "active" {
+			return err
+		}
+
+		if err := repos.Warehouse.UpdateWarehouseStatus(ctx, warehouseId, "active"); err != nil {
+			return err
+		}
+		warehouse.Status = "active"
+
+		products, err = stockedProducts(ctx, repos, warehouseId)
 		if err != nil {
-			return fmt.Errorf("failed activated warehouse: %v", err)
+			return err
 		}
-	} else {
-		err = s.DeactivateWarehouse(warehouse.Id)
+		if err := repos.Outbox.MarkChanged(ctx, products...); err != nil {
+			return err
+		}
+
+		return recordWarehouseEvent(ctx, repos, warehouseId, models.AuditWarehouseActivated, map[string]any{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate warehouse: %w", err)
+	}
+
+	s.syncTotalStocks(ctx, products)
+
+	return warehouse, nil
+}
+
+const (
+	// AllocationsBlock refuses to deactivate a warehouse with open
+	// allocations, those of orders that are still pending.
+	AllocationsBlock = "block"
+	// AllocationsDrain deactivates anyway; when their orders go through
+	// open allocations still ship from the warehouse, when they are
+	// cancelled the stock is released back into it.
+	AllocationsDrain = "drain"
+)
+
+type DeactivateOptions struct {
+	// TransferStock moves the remaining stock to other active warehouses.
+	TransferStock bool
+	// TransferTo receives all of it when set, otherwise the active
+	// warehouses take it in priority order as far as their capacity allows.
+	TransferTo int64
+	// AllocationPolicy is AllocationsBlock (the default) or AllocationsDrain.
+	AllocationPolicy string
+}
+
+type Deactivation struct {
+	Warehouse *models.Warehouse `json:"warehouse"`
+	// OpenAllocations are the allocations left to drain.
+	OpenAllocations []models.StockAllocation `json:"open_allocations"`
+	Transfers       []models.StockTransfer   `json:"transfers"`
+}
+
+// DeactivateWarehouse takes a warehouse out of the stock pool, moving its
+// stock elsewhere when asked, and recomputes the total stock of the products
+// it held. Deactivating an inactive warehouse changes nothing.
+func (s *warehouseService) DeactivateWarehouse(ctx context.Context, warehouseId int64, options DeactivateOptions) (*Deactivation, error) {
+	if options.AllocationPolicy == "" {
+		options.AllocationPolicy = AllocationsBlock
+	}
+	if options.AllocationPolicy != AllocationsBlock && options.AllocationPolicy != AllocationsDrain {
+		return nil, &apperror.InvalidInputError{Field: "allocation_policy", Reason: "must be block or drain"}
+	}
+	if options.TransferTo != 0 && !options.TransferStock {
+		return nil, &apperror.InvalidInputError{Field: "transfer_to", Reason: "needs transfer_stock"}
+	}
+	if options.TransferTo == warehouseId {
+		return nil, &apperror.InvalidInputError{Field: "transfer_to", Reason: "must differ from the deactivated warehouse"}
+	}
+
+	// read before the transaction, which must not wait on the order service
+	pendingOrders, err := s.orderRepo.GetPendingOrderIds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate warehouse: failed to get pending orders: %w", err)
+	}
+
+	var result Deactivation
+	var products []int64
+	err = s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		warehouse, err := repos.Warehouse.GetWarehouseById(ctx, warehouseId)
 		if err != nil {
-			return fmt.Errorf("failed deactivated warehouse: %v", err)
+			return err
+		}
+		result.Warehouse = warehouse
+		if warehouse.Status == "inactive" {
+			return nil
+		}
+
+		result.OpenAllocations, err = repos.Allocation.GetOpenAllocationsByWarehouse(ctx, warehouseId, pendingOrders)
+		if err != nil {
+			return err
+		}
+		if len(result.OpenAllocations) > 0 && options.AllocationPolicy == AllocationsBlock {
+			return &apperror.ConflictError{
+				Resource: "warehouse",
+				Reason:   fmt.Sprintf("warehouse %d has %d open allocations, release them or deactivate with the drain policy", warehouseId, len(result.OpenAllocations)),
+			}
+		}
+
+		products, err = stockedProducts(ctx, repos, warehouseId)
+		if err != nil {
+			return err
+		}
+		if err := repos.Outbox.MarkChanged(ctx, products...); err != nil {
+			return err
+		}
+
+		if options.TransferStock {
+			result.Transfers, err = moveStock(ctx, repos, warehouseId, options.TransferTo)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := repos.Warehouse.UpdateWarehouseStatus(ctx, warehouseId, "inactive"); err != nil {
+			return err
 		}
+		warehouse.Status = "inactive"
+
+		return recordWarehouseEvent(ctx, repos, warehouseId, models.AuditWarehouseDeactivated, map[string]any{
+			"allocation_policy": options.AllocationPolicy,
+			"open_allocations":  len(result.OpenAllocations),
+			"transfers":         result.Transfers,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate warehouse: %w", err)
 	}
 
-	return nil
+	s.syncTotalStocks(ctx, products)
+
+	return &result, nil
 }
 
-func (s *warehouseService) ActivateWarehouse(warehouseId int64) error {
-	err := s.warehouseRepo.UpdateWarehouseStatus(warehouseId, "active")
+// stockedProducts lists the products a warehouse holds any units of.
+func stockedProducts(ctx context.Context, repos repository.Repositories, warehouseId int64) ([]int64, error) {
+	stocks, err := repos.Stock.GetStocksByWarehouse(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed to activate warehouse: %v", err)
+		return nil, err
 	}
 
-	return nil
+	var products []int64
+	for _, stock := range stocks {
+		if stock.Quantity > 0 {
+			products = append(products, stock.ProductId)
+		}
+	}
+	return products, nil
 }
 
-func (s *warehouseService) DeactivateWarehouse(warehouseId int64) error {
-	err := s.warehouseRepo.UpdateWarehouseStatus(warehouseId, "inactive")
+// moveStock empties a warehouse into transferTo, or into the other active
+// warehouses by priority when it is zero. A destination without room is
+// skipped.
+func moveStock(ctx context.Context, repos repository.Repositories, warehouseId, transferTo int64) ([]models.StockTransfer, error) {
+	var targets []models.Warehouse
+	if transferTo != 0 {
+		target, err := repos.Warehouse.GetWarehouseById(ctx, transferTo)
+		if err != nil {
+			return nil, err
+		}
+		if target.Status != "active" {
+			return nil, &apperror.InvalidInputError{Field: "transfer_to", Reason: fmt.Sprintf("warehouse %d is not active", transferTo)}
+		}
+		targets = append(targets, *target)
+	} else {
+		active, err := repos.Warehouse.GetActiveWarehouses(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range active {
+			if target.Id != warehouseId {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	// room left per target, -1 when it has no capacity limit
+	room := map[int64]int{}
+	for _, target := range targets {
+		room[target.Id] = -1
+		if target.Capacity != nil {
+			used, err := repos.Stock.GetWarehouseStockTotal(ctx, target.Id)
+			if err != nil {
+				return nil, err
+			}
+			room[target.Id] = max(*target.Capacity-used, 0)
+		}
+	}
+
+	stocks, err := repos.Stock.GetStocksByWarehouse(ctx, warehouseId)
 	if err != nil {
-		return fmt.Errorf("failed to deactivate warehouse: %v", err)
+		return nil, err
 	}
 
-	return nil
+	var transfers []models.StockTransfer
+	for _, stock := range stocks {
+		remaining := stock.Quantity
+		for _, target := range targets {
+			if remaining == 0 {
+				break
+			}
+			quantity := remaining
+			if room[target.Id] >= 0 {
+				quantity = min(quantity, room[target.Id])
+			}
+			if quantity == 0 {
+				continue
+			}
+
+			reference := fmt.Sprintf("deactivate:%d", warehouseId)
+			taken, err := applyMovementLots(ctx, repos, models.StockMovement{ProductId: stock.ProductId, WarehouseId: warehouseId, Type: models.MovementTransferOut, Quantity: -quantity, ReferenceId: reference})
+			if err != nil {
+				return nil, err
+			}
+			in := models.StockMovement{ProductId: stock.ProductId, WarehouseId: target.Id, Type: models.MovementTransferIn, Quantity: quantity, ReferenceId: reference}
+			if err := bookLots(ctx, repos, in, warehouseId, lotsOf(taken)); err != nil {
+				return nil, err
+			}
+
+			if room[target.Id] >= 0 {
+				room[target.Id] -= quantity
+			}
+			remaining -= quantity
+			transfers = append(transfers, models.StockTransfer{ProductId: stock.ProductId, FromWarehouseId: warehouseId, ToWarehouseId: target.Id, Quantity: quantity})
+		}
+
+		if remaining > 0 {
+			return nil, &apperror.ConflictError{
+				Resource: "warehouse",
+				Reason:   fmt.Sprintf("no active warehouse can take the remaining %d units of product %d", remaining, stock.ProductId),
+			}
+		}
+	}
+
+	return transfers, nil
 }
 
-func (s *warehouseService) ProceedOrder(orderID int64, products []ProductOrderDetails) error {
-	// Retrieve all active warehouses
-	warehouses, err := s.warehouseRepo.GetActiveWarehouses()
+func recordWarehouseEvent(ctx context.Context, repos repository.Repositories, warehouseId int64, action string, detail map[string]any) error {
+	encoded, err := json.Marshal(detail)
 	if err != nil {
 		return err
 	}
 
-	for _, product := range products {
-		remainingQuantity := product.Quantity
+	return repos.Audit.RecordEvent(ctx, &models.AuditEvent{Entity: "warehouse", EntityId: warehouseId, Action: action, Detail: string(encoded)})
+}
+
+// syncTotalStocks recomputes the total stock of each product.
+func (s *warehouseService) syncTotalStocks(ctx context.Context, products []int64) {
+	for _, productId := range products {
+		s.syncTotalStock(ctx, productId)
+	}
+}
+
+// GetWarehouseEvents lists the audit events of a warehouse, oldest first.
+func (s *warehouseService) GetWarehouseEvents(ctx context.Context, warehouseId int64) ([]models.AuditEvent, error) {
+	repos := s.uow.Read()
+	if _, err := repos.Warehouse.GetWarehouseById(ctx, warehouseId); err != nil {
+		return nil, fmt.Errorf("failed fetch warehouse events: %w", err)
+	}
+
+	events, err := repos.Audit.GetEvents(ctx, "warehouse", warehouseId)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch warehouse events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ProceedOrder plans the order with the named strategy, or the default one,
+// deducts the picks and records them as allocations in one transaction, so
+// either every line is allocated or nothing changes. It is idempotent per
+// order: when the order is already allocated, the recorded allocations are
+// returned and no stock is deducted again.
+func (s *warehouseService) ProceedOrder(ctx context.Context, orderID int64, products []ProductOrderDetails, destination *models.Location, strategy string) ([]models.StockAllocation, error) {
+	if strategy == "" {
+		strategy = s.allocationStrategy
+	}
+	allocator, err := allocation.New(strategy)
+	if err != nil {
+		return nil, &apperror.InvalidInputError{Field: "strategy", Reason: err.Error()}
+	}
+
+	lines := make([]allocation.Line, len(products))
+	for i, product := range products {
+		if product.Quantity <= 0 {
+			return nil, &apperror.InvalidInputError{Field: "quantity", Reason: fmt.Sprintf("must be positive for product %d", product.ProductId)}
+		}
+		lines[i] = allocation.Line{ProductId: product.ProductId, Quantity: product.Quantity}
+	}
+
+	allocations, err := s.allocateOrder(ctx, orderID, lines, destination, allocator)
+
+	// a concurrent forward of the same order committed first, replay its result
+	var conflict *apperror.ConflictError
+	if errors.As(err, &conflict) && conflict.Resource == "allocation" {
+		allocations, err = s.allocateOrder(ctx, orderID, lines, destination, allocator)
+	}
+
+	return allocations, err
+}
 
-		// Iterate through active warehouses to fulfill the product's stock
-		for _, warehouse := range warehouses {
-			stock, err := s.stockRepo.GetStockByProductAndWarehouse(product.ProductId, warehouse.Id)
+func (s *warehouseService) allocateOrder(ctx context.Context, orderID int64, lines []allocation.Line, destination *models.Location, allocator allocation.Strategy) ([]models.StockAllocation, error) {
+	var allocations []models.StockAllocation
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		existing, err := repos.Allocation.GetAllocationsByOrder(ctx, orderID)
+		if err != nil {
+			return fmt.Errorf("failed to get allocations: %w", err)
+		}
+		if len(existing) > 0 {
+			allocations, err = replayAllocations(orderID, existing)
+			return err
+		}
+
+		candidates, err := loadCandidates(ctx, repos, lines)
+		if err != nil {
+			return err
+		}
+
+		picks, err := allocator.Allocate(allocation.Request{Lines: lines, Candidates: candidates, Destination: destination})
+		if err != nil {
+			return err
+		}
+
+		for _, pick := range mergePicks(picks) {
+			err := applyMovement(ctx, repos, models.StockMovement{
+				ProductId:   pick.ProductId,
+				WarehouseId: pick.WarehouseId,
+				Type:        models.MovementOrderAllocation,
+				Quantity:    -pick.Quantity,
+				ReferenceId: orderReference(orderID),
+			})
 			if err != nil {
-				return err
+				return fmt.Errorf("failed to deduct stock from warehouse %d: %w", pick.WarehouseId, err)
 			}
 
-			if stock.Quantity >= remainingQuantity {
-				// Deduct remainingQuantity from this warehouse
-				err = s.stockRepo.UpdateStock(product.ProductId, warehouse.Id, stock.Quantity-remainingQuantity)
-				if err != nil {
-					return err
-				}
-				remainingQuantity = 0
-				break
-			} else if stock.Quantity > 0 {
-				// Deduct as much as possible and continue to the next warehouse
-				remainingQuantity -= stock.Quantity
-				err = s.stockRepo.UpdateStock(product.ProductId, warehouse.Id, 0)
-				if err != nil {
-					return err
-				}
+			record := models.StockAllocation{
+				OrderId:     orderID,
+				ProductId:   pick.ProductId,
+				WarehouseId: pick.WarehouseId,
+				Quantity:    pick.Quantity,
+				Strategy:    allocator.Name(),
 			}
+			if err := repos.Allocation.CreateAllocation(ctx, &record); err != nil {
+				return fmt.Errorf("failed to record allocation: %w", err)
+			}
+			allocations = append(allocations, record)
 		}
 
-		// If there is still remaining quantity, return an error for this product
-		if remainingQuantity > 0 {
-			return errors.New("insufficient stock for product_id: " + string(product.ProductId))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allocations, nil
+}
+
+// replayAllocations answers a repeated ProceedOrder with the allocations
+// recorded the first time. An order whose stock was released has been
+// cancelled and cannot be allocated again.
+func replayAllocations(orderID int64, existing []models.StockAllocation) ([]models.StockAllocation, error) {
+	for _, record := range existing {
+		if record.Status == models.AllocationAllocated {
+			return existing, nil
 		}
 	}
 
-	return nil
+	return nil, &apperror.ConflictError{Resource: "order", Reason: fmt.Sprintf("order %d was released and cannot be allocated again", orderID)}
+}
+
+// mergePicks adds up picks of the same product and warehouse, which several
+// lines of one product can produce, keeping the order of first appearance.
+func mergePicks(picks []allocation.Pick) []allocation.Pick {
+	type key struct{ productId, warehouseId int64 }
+
+	index := map[key]int{}
+	var merged []allocation.Pick
+	for _, pick := range picks {
+		k := key{pick.ProductId, pick.WarehouseId}
+		if i, ok := index[k]; ok {
+			merged[i].Quantity += pick.Quantity
+			continue
+		}
+		index[k] = len(merged)
+		merged = append(merged, pick)
+	}
+
+	return merged
+}
+
+// loadCandidates snapshots the stock the active warehouses hold of the
+// ordered products. Warehouses frozen for a stocktake are left out.
+func loadCandidates(ctx context.Context, repos repository.Repositories, lines []allocation.Line) ([]allocation.Candidate, error) {
+	warehouses, err := repos.Warehouse.GetActiveWarehouses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active warehouses: %w", err)
+	}
+
+	frozen, err := frozenWarehouses(ctx, repos)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]allocation.Candidate, 0, len(warehouses))
+	byWarehouse := map[int64]map[int64]int{}
+	for _, warehouse := range warehouses {
+		if frozen[warehouse.Id] {
+			continue
+		}
+		candidate := allocation.Candidate{Warehouse: warehouse, Stock: map[int64]int{}}
+		candidates = append(candidates, candidate)
+		byWarehouse[warehouse.Id] = candidate.Stock
+	}
+
+	loaded := map[int64]bool{}
+	for _, line := range lines {
+		if loaded[line.ProductId] {
+			continue
+		}
+		loaded[line.ProductId] = true
+
+		stocks, err := repos.Stock.GetStocksByProduct(ctx, line.ProductId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stock for product %d: %w", line.ProductId, err)
+		}
+		for _, stock := range stocks {
+			// inactive warehouses are not candidates
+			if byProduct, ok := byWarehouse[stock.WarehouseId]; ok {
+				byProduct[stock.ProductId] = stock.Sellable()
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// ReleaseOrder returns the allocated stock of an order to the warehouses it
+// was taken from and returns the released allocations. Allocations released
+// before are skipped.
+func (s *warehouseService) ReleaseOrder(ctx context.Context, orderID int64) ([]models.StockAllocation, error) {
+	var released []models.StockAllocation
+	err := s.uow.WithTx(ctx, func(repos repository.Repositories) error {
+		allocations, err := repos.Allocation.GetAllocationsByOrder(ctx, orderID)
+		if err != nil {
+			return fmt.Errorf("failed to get allocations: %w", err)
+		}
+		if len(allocations) == 0 {
+			return fmt.Errorf("order %d: %w", orderID, &apperror.NotFoundError{Resource: "allocation"})
+		}
+
+		for _, record := range allocations {
+			if record.Status != models.AllocationAllocated {
+				continue
+			}
+
+			if err := repos.Allocation.ReleaseAllocation(ctx, record.Id); err != nil {
+				return fmt.Errorf("failed to release allocation %d: %w", record.Id, err)
+			}
+			// the stock goes back into the lots it was taken from
+			lots, err := pendingLots(ctx, repos, orderReference(orderID), record.ProductId, record.WarehouseId, record.WarehouseId)
+			if err != nil {
+				return err
+			}
+			err = bookLots(ctx, repos, models.StockMovement{
+				ProductId:   record.ProductId,
+				WarehouseId: record.WarehouseId,
+				Type:        models.MovementOrderReturn,
+				Quantity:    record.Quantity,
+				ReferenceId: orderReference(orderID),
+			}, record.WarehouseId, lots)
+			if err != nil {
+				return fmt.Errorf("failed to return stock to warehouse %d: %w", record.WarehouseId, err)
+			}
+
+			record.Status = models.AllocationReleased
+			released = append(released, record)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return released, nil
 }
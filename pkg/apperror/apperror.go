@@ -0,0 +1,128 @@
+// Package apperror is the catalogue of domain errors shared by every service.
+// Each kind has a sentinel for errors.Is and a typed error for errors.As, and
+// both survive an HTTP hop through Body and FromResponse.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Code string
+
+const (
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeConflict            Code = "CONFLICT"
+	CodeInsufficientStock   Code = "INSUFFICIENT_STOCK"
+	CodeUnauthorized        Code = "UNAUTHORIZED"
+	CodeUpstreamUnavailable Code = "UPSTREAM_UNAVAILABLE"
+	CodeInvalidInput        Code = "INVALID_INPUT"
+)
+
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrInvalidInput        = errors.New("invalid input")
+)
+
+type NotFoundError struct {
+	Resource string `json:"resource"`
+	Id       int64  `json:"id,omitempty"`
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Id != 0 {
+		return fmt.Sprintf("%s with Id %d not found", e.Resource, e.Id)
+	}
+	return fmt.Sprintf("%s not found", e.Resource)
+}
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+type ConflictError struct {
+	Resource string `json:"resource"`
+	Reason   string `json:"reason"`
+}
+
+func (e *ConflictError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s conflict", e.Resource)
+	}
+	return e.Reason
+}
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+type InsufficientStockError struct {
+	ProductId int64 `json:"product_id"`
+	Requested int   `json:"requested"`
+	Available int   `json:"available"`
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product_id: %d (requested %d, available %d)", e.ProductId, e.Requested, e.Available)
+}
+
+func (e *InsufficientStockError) Is(target error) bool { return target == ErrInsufficientStock }
+
+type UnauthorizedError struct {
+	Reason string `json:"reason"`
+}
+
+func (e *UnauthorizedError) Error() string {
+	if e.Reason == "" {
+		return "unauthorized"
+	}
+	return e.Reason
+}
+
+func (e *UnauthorizedError) Is(target error) bool { return target == ErrUnauthorized }
+
+type UpstreamUnavailableError struct {
+	Service string `json:"service"`
+	Cause   string `json:"cause,omitempty"`
+	Err     error  `json:"-"`
+}
+
+func NewUpstreamUnavailable(service string, err error) *UpstreamUnavailableError {
+	e := &UpstreamUnavailableError{Service: service, Err: err}
+	if err != nil {
+		e.Cause = err.Error()
+	}
+	return e
+}
+
+func (e *UpstreamUnavailableError) Error() string {
+	if e.Cause == "" {
+		return fmt.Sprintf("%s service unavailable", e.Service)
+	}
+	return fmt.Sprintf("%s service unavailable: %s", e.Service, e.Cause)
+}
+
+func (e *UpstreamUnavailableError) Is(target error) bool { return target == ErrUpstreamUnavailable }
+
+func (e *UpstreamUnavailableError) Unwrap() error { return e.Err }
+
+type InvalidInputError struct {
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e *InvalidInputError) Error() string { return e.Reason }
+
+func (e *InvalidInputError) Is(target error) bool { return target == ErrInvalidInput }
+
+// ResponseError is an upstream failure that does not belong to the catalogue,
+// kept with its status so callers can still log or branch on it.
+type ResponseError struct {
+	Service    string
+	StatusCode int
+	Message    string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s service returned %d: %s", e.Service, e.StatusCode, e.Message)
+}
@@ -0,0 +1,140 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Body is the wire format of an error response.
+type Body struct {
+	Code    Code            `json:"code,omitempty"`
+	Error   string          `json:"error"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// StatusCode maps err to its HTTP status, or fallback when err is not part of
+// the catalogue.
+func StatusCode(err error, fallback int) int {
+	var respErr *ResponseError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrInsufficientStock):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.As(err, &respErr):
+		return respErr.StatusCode
+	}
+
+	return fallback
+}
+
+func ToBody(err error) Body {
+	body := Body{Error: err.Error()}
+
+	var (
+		notFound     *NotFoundError
+		conflict     *ConflictError
+		insufficient *InsufficientStockError
+		unauthorized *UnauthorizedError
+		upstream     *UpstreamUnavailableError
+		invalid      *InvalidInputError
+		details      any
+	)
+	switch {
+	case errors.As(err, &notFound):
+		body.Code, details = CodeNotFound, notFound
+	case errors.As(err, &conflict):
+		body.Code, details = CodeConflict, conflict
+	case errors.As(err, &insufficient):
+		body.Code, details = CodeInsufficientStock, insufficient
+	case errors.As(err, &unauthorized):
+		body.Code, details = CodeUnauthorized, unauthorized
+	case errors.As(err, &upstream):
+		body.Code, details = CodeUpstreamUnavailable, upstream
+	case errors.As(err, &invalid):
+		body.Code, details = CodeInvalidInput, invalid
+	default:
+		return body
+	}
+
+	body.Details, _ = json.Marshal(details)
+	return body
+}
+
+// JSON answers the request with err as a structured body.
+func JSON(c echo.Context, fallbackStatus int, err error) error {
+	return c.JSON(StatusCode(err, fallbackStatus), ToBody(err))
+}
+
+// FromResponse turns a non-2xx response from service back into a typed error.
+// Bodies without a catalogue code are classified by status code.
+func FromResponse(service string, statusCode int, body string) error {
+	if statusCode >= 200 && statusCode < 300 {
+		return nil
+	}
+
+	var decoded Body
+	if err := json.Unmarshal([]byte(body), &decoded); err == nil && decoded.Code != "" {
+		if typed := fromBody(decoded); typed != nil {
+			return typed
+		}
+	}
+
+	message := decoded.Error
+	if message == "" {
+		message = strings.TrimSpace(body)
+	}
+
+	switch statusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{Resource: service + " resource"}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &UnauthorizedError{Reason: message}
+	case http.StatusConflict:
+		return &ConflictError{Resource: service, Reason: message}
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &UpstreamUnavailableError{Service: service, Cause: message}
+	}
+
+	return &ResponseError{Service: service, StatusCode: statusCode, Message: message}
+}
+
+func fromBody(body Body) error {
+	var target error
+	switch body.Code {
+	case CodeNotFound:
+		target = &NotFoundError{}
+	case CodeConflict:
+		target = &ConflictError{}
+	case CodeInsufficientStock:
+		target = &InsufficientStockError{}
+	case CodeUnauthorized:
+		target = &UnauthorizedError{}
+	case CodeUpstreamUnavailable:
+		target = &UpstreamUnavailableError{}
+	case CodeInvalidInput:
+		target = &InvalidInputError{}
+	default:
+		return nil
+	}
+
+	if len(body.Details) > 0 {
+		if err := json.Unmarshal(body.Details, target); err != nil {
+			return nil
+		}
+	}
+
+	return target
+}
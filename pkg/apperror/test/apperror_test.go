@@ -0,0 +1,109 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/pkg/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCode(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", &apperror.NotFoundError{Resource: "product", Id: 1}, http.StatusNotFound},
+		{"conflict", &apperror.ConflictError{Resource: "user"}, http.StatusConflict},
+		{"insufficient stock", &apperror.InsufficientStockError{ProductId: 1}, http.StatusUnprocessableEntity},
+		{"unauthorized", &apperror.UnauthorizedError{}, http.StatusUnauthorized},
+		{"upstream unavailable", apperror.NewUpstreamUnavailable("product", errors.New("dial tcp")), http.StatusServiceUnavailable},
+		{"invalid input", &apperror.InvalidInputError{Reason: "quantity is required"}, http.StatusBadRequest},
+		{"wrapped", fmt.Errorf("failed fetch product: %w", &apperror.NotFoundError{Resource: "product"}), http.StatusNotFound},
+		{"unknown", errors.New("boom"), http.StatusTeapot},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, apperror.StatusCode(tc.err, http.StatusTeapot))
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	e := echo.New()
+
+	t.Run("should keep typed details across the wire", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+		sent := fmt.Errorf("failed deduct: %w", &apperror.InsufficientStockError{ProductId: 2, Requested: 5, Available: 3})
+		assert.NoError(t, apperror.JSON(c, http.StatusInternalServerError, sent))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		err := apperror.FromResponse("product", rec.Code, rec.Body.String())
+
+		assert.True(t, errors.Is(err, apperror.ErrInsufficientStock))
+		var insufficient *apperror.InsufficientStockError
+		assert.True(t, errors.As(err, &insufficient))
+		assert.Equal(t, int64(2), insufficient.ProductId)
+		assert.Equal(t, 5, insufficient.Requested)
+		assert.Equal(t, 3, insufficient.Available)
+	})
+
+	t.Run("should keep upstream cause", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+		assert.NoError(t, apperror.JSON(c, http.StatusInternalServerError, apperror.NewUpstreamUnavailable("warehouse", errors.New("connection refused"))))
+
+		err := apperror.FromResponse("shop", rec.Code, rec.Body.String())
+
+		var upstream *apperror.UpstreamUnavailableError
+		assert.True(t, errors.As(err, &upstream))
+		assert.Equal(t, "warehouse", upstream.Service)
+		assert.Equal(t, "connection refused", upstream.Cause)
+	})
+
+	t.Run("should answer unknown errors with fallback status and no code", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+		assert.NoError(t, apperror.JSON(c, http.StatusInternalServerError, errors.New("boom")))
+
+		var body apperror.Body
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Empty(t, body.Code)
+		assert.Equal(t, "boom", body.Error)
+	})
+}
+
+func TestFromResponse(t *testing.T) {
+	t.Run("should return nil on success", func(t *testing.T) {
+		assert.NoError(t, apperror.FromResponse("product", http.StatusOK, `{"message":"ok"}`))
+	})
+
+	t.Run("should classify bodies without code by status", func(t *testing.T) {
+		err := apperror.FromResponse("product", http.StatusNotFound, "not here")
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+
+		err = apperror.FromResponse("product", http.StatusBadGateway, "")
+		assert.True(t, errors.Is(err, apperror.ErrUpstreamUnavailable))
+	})
+
+	t.Run("should keep unclassified failures as response errors", func(t *testing.T) {
+		err := apperror.FromResponse("product", http.StatusInternalServerError, `{"error":"database is locked"}`)
+
+		var respErr *apperror.ResponseError
+		assert.True(t, errors.As(err, &respErr))
+		assert.Equal(t, http.StatusInternalServerError, respErr.StatusCode)
+		assert.Equal(t, "database is locked", respErr.Message)
+	})
+}
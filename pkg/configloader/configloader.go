@@ -0,0 +1,236 @@
+// Package configloader fills a typed configuration struct from layered sources:
+// the defaults already set on the struct, then an optional YAML file, then
+// environment variables. Fields opt in with `yaml:"..."` and `env:"..."` tags,
+// and `secret:"true"` hides a field from Redact.
+package configloader
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the optional YAML file path.
+const FileEnv = "CONFIG_FILE"
+
+// Validator is implemented by configs that check themselves after loading.
+type Validator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load layers the YAML file named by CONFIG_FILE and the environment over the
+// defaults held by cfg, which must be a pointer to a struct, then validates it.
+func Load(cfg any) error {
+	return LoadFrom(cfg, os.Getenv(FileEnv), os.LookupEnv)
+}
+
+// LoadFrom is Load with an explicit file path and environment lookup.
+func LoadFrom(cfg any, path string, lookup func(string) (string, bool)) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(v.Elem(), lookup); err != nil {
+		return err
+	}
+
+	if validator, ok := cfg.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func applyEnv(v reflect.Value, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := applyEnv(fv, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
+
+// Redact renders cfg as sorted key=value pairs keyed by yaml name, masking
+// secret fields, so the effective config can be logged at startup.
+func Redact(cfg any) string {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	pairs := map[string]string{}
+	collect(v, "", pairs)
+
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+pairs[k])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func collect(v reflect.Value, prefix string, pairs map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		key := prefix + name
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			collect(fv, key+".", pairs)
+			continue
+		}
+
+		if field.Tag.Get("secret") == "true" {
+			if fv.IsZero() {
+				pairs[key] = `""`
+			} else {
+				pairs[key] = "******"
+			}
+			continue
+		}
+
+		pairs[key] = fmt.Sprint(fv.Interface())
+	}
+}
+
+// ValidatePort checks that port is a usable TCP port.
+func ValidatePort(name string, port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%s must be between 1 and 65535, got %d", name, port)
+	}
+	return nil
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL.
+func ValidateURL(name string, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", name, raw)
+	}
+	return nil
+}
+
+// ValidateRequired checks that a string setting is not empty.
+func ValidateRequired(name string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	return nil
+}
+
+// ValidatePositive checks that a duration setting is greater than zero.
+func ValidatePositive(name string, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, d)
+	}
+	return nil
+}
+
+// ValidateInternalPort checks that the internal listener has a usable port of
+// its own.
+func ValidateInternalPort(port int, internalPort int) error {
+	if err := ValidatePort("internal_port", internalPort); err != nil {
+		return err
+	}
+	if internalPort == port {
+		return fmt.Errorf("internal_port must differ from port %d", port)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"errors"
+	"monorepo-ecommerce/pkg/configloader"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type upstream struct {
+	URL     string        `yaml:"url" env:"UPSTREAM_URL"`
+	Timeout time.Duration `yaml:"timeout" env:"UPSTREAM_TIMEOUT"`
+}
+
+type testConfig struct {
+	Port     int      `yaml:"port" env:"PORT"`
+	Debug    bool     `yaml:"debug" env:"DEBUG"`
+	Secret   string   `yaml:"secret" env:"SECRET" secret:"true"`
+	Upstream upstream `yaml:"upstream"`
+}
+
+func (c *testConfig) Validate() error {
+	return errors.Join(
+		configloader.ValidatePort("port", c.Port),
+		configloader.ValidateURL("upstream.url", c.Upstream.URL),
+	)
+}
+
+func defaults() testConfig {
+	return testConfig{
+		Port:     7000,
+		Secret:   "default-secret",
+		Upstream: upstream{URL: "http://localhost:7002", Timeout: time.Second},
+	}
+}
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadFrom(t *testing.T) {
+	t.Run("should keep defaults without file or env", func(t *testing.T) {
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, "", env(nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, defaults(), cfg)
+	})
+
+	t.Run("should layer env over file over defaults", func(t *testing.T) {
+		path := writeFile(t, "port: 8000\nupstream:\n  url: http://file:7002\n  timeout: 3s\n")
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, path, env(map[string]string{"PORT": "9000", "DEBUG": "true"}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9000, cfg.Port)
+		assert.True(t, cfg.Debug)
+		assert.Equal(t, "http://file:7002", cfg.Upstream.URL)
+		assert.Equal(t, 3*time.Second, cfg.Upstream.Timeout)
+		assert.Equal(t, "default-secret", cfg.Secret)
+	})
+
+	t.Run("should read nested fields from env", func(t *testing.T) {
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, "", env(map[string]string{"UPSTREAM_TIMEOUT": "250ms"}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 250*time.Millisecond, cfg.Upstream.Timeout)
+	})
+
+	t.Run("should reject malformed env values", func(t *testing.T) {
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, "", env(map[string]string{"PORT": "abc"}))
+
+		assert.ErrorContains(t, err, "PORT")
+	})
+
+	t.Run("should fail when the file is missing", func(t *testing.T) {
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, filepath.Join(t.TempDir(), "missing.yaml"), env(nil))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should report every validation failure", func(t *testing.T) {
+		cfg := defaults()
+
+		err := configloader.LoadFrom(&cfg, "", env(map[string]string{"PORT": "0", "UPSTREAM_URL": "localhost"}))
+
+		assert.ErrorContains(t, err, "port")
+		assert.ErrorContains(t, err, "upstream.url")
+	})
+}
+
+func TestRedact(t *testing.T) {
+	cfg := defaults()
+
+	out := configloader.Redact(&cfg)
+
+	assert.Equal(t, "debug=false port=7000 secret=****** upstream.timeout=1s upstream.url=http://localhost:7002", out)
+	assert.NotContains(t, out, "default-secret")
+}
+
+func TestValidateInternalPort(t *testing.T) {
+	t.Run("should take a port of its own", func(t *testing.T) {
+		assert.NoError(t, configloader.ValidateInternalPort(7001, 8001))
+	})
+
+	t.Run("should refuse the public port", func(t *testing.T) {
+		assert.ErrorContains(t, configloader.ValidateInternalPort(7001, 7001), "internal_port must differ")
+	})
+
+	t.Run("should refuse an unusable port", func(t *testing.T) {
+		assert.ErrorContains(t, configloader.ValidateInternalPort(7001, 0), "internal_port must be between")
+	})
+}
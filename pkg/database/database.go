@@ -0,0 +1,306 @@
+// Package database opens a service's database, either a SQLite file or a
+// PostgreSQL server chosen by configuration. The SQLite file is tuned for
+// concurrent use: WAL journaling, a busy timeout and foreign keys are
+// enabled, all writes go through a single connection that takes the write
+// lock up front, reads use a separate pool, and writes that still hit
+// SQLITE_BUSY are retried. Repositories write their queries with ? placeholders,
+// which are rewritten for the dialect in use.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+type Config struct {
+	Dialect Dialect
+	// Path is the SQLite file.
+	Path string
+	// DSN is the PostgreSQL connection string.
+	DSN string
+	// BusyTimeout is how long SQLite itself waits for a lock.
+	BusyTimeout time.Duration
+	// MaxReadConns sizes the read pool.
+	MaxReadConns int
+	// MaxOpenConns sizes the single PostgreSQL pool.
+	MaxOpenConns int
+	// BusyRetries is how often a write or transaction is retried once
+	// BusyTimeout is exhausted.
+	BusyRetries  int
+	RetryBackoff time.Duration
+}
+
+func DefaultConfig(path string) Config {
+	return Config{
+		Dialect:      SQLite,
+		Path:         path,
+		BusyTimeout:  5 * time.Second,
+		MaxReadConns: 4,
+		MaxOpenConns: 10,
+		BusyRetries:  3,
+		RetryBackoff: 50 * time.Millisecond,
+	}
+}
+
+// NewConfig returns the defaults for driver, which is "sqlite" or "postgres",
+// as set in a service's database_driver, database_path and database_url.
+func NewConfig(driver string, path string, dsn string) Config {
+	cfg := DefaultConfig(path)
+	cfg.Dialect = Dialect(driver)
+	cfg.DSN = dsn
+	return cfg
+}
+
+func (c Config) Validate() error {
+	switch c.Dialect {
+	case SQLite:
+		if strings.TrimSpace(c.Path) == "" {
+			return errors.New("database_path is required for sqlite")
+		}
+	case Postgres:
+		if strings.TrimSpace(c.DSN) == "" {
+			return errors.New("database_url is required for postgres")
+		}
+	default:
+		return fmt.Errorf("database_driver must be %q or %q, got %q", SQLite, Postgres, c.Dialect)
+	}
+	return nil
+}
+
+// Querier is what repositories run statements on: the DB itself, or a
+// transaction opened by a unit of work.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	// InsertReturningID runs an INSERT with RETURNING id appended and
+	// returns the new id.
+	InsertReturningID(ctx context.Context, query string, args ...any) (int64, error)
+}
+
+type DB struct {
+	writer *sql.DB
+	reader *sql.DB
+	cfg    Config
+
+	busyRetries atomic.Int64
+}
+
+// Open opens the database described by cfg. For SQLite that is a writer and
+// a reader pool for cfg.Path, creating the file if needed; for PostgreSQL one
+// pool serves both.
+func Open(cfg Config) (*DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Dialect == Postgres {
+		return openPostgres(cfg)
+	}
+
+	writer, err := sql.Open("sqlite3", dsn(cfg, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed open database: %w", err)
+	}
+	// one writer per process, other processes are held off by the lock
+	writer.SetMaxOpenConns(1)
+
+	// the writer creates the file and switches it to WAL before readers open it
+	if err := writer.Ping(); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed connect database: %w", err)
+	}
+
+	reader, err := sql.Open("sqlite3", dsn(cfg, true))
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed open database: %w", err)
+	}
+	reader.SetMaxOpenConns(cfg.MaxReadConns)
+	reader.SetMaxIdleConns(cfg.MaxReadConns)
+
+	return &DB{writer: writer, reader: reader, cfg: cfg}, nil
+}
+
+func openPostgres(cfg Config) (*DB, error) {
+	pool, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed open database: %w", err)
+	}
+	pool.SetMaxOpenConns(cfg.MaxOpenConns)
+	pool.SetMaxIdleConns(cfg.MaxOpenConns)
+
+	if err := pool.Ping(); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed connect database: %w", err)
+	}
+
+	// the server handles concurrent writers, so there is no separate writer
+	return &DB{writer: pool, reader: pool, cfg: cfg}, nil
+}
+
+func dsn(cfg Config, readOnly bool) string {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", fmt.Sprint(cfg.BusyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "on")
+	params.Set("_synchronous", "NORMAL")
+	if readOnly {
+		params.Set("_query_only", "on")
+	} else {
+		// take the write lock at BEGIN so a transaction never fails upgrading it
+		params.Set("_txlock", "immediate")
+	}
+
+	return "file:" + cfg.Path + "?" + params.Encode()
+}
+
+// Writer is the single write connection, for code that needs a *sql.DB such
+// as the migration runner. Queries on it are not rebound.
+func (d *DB) Writer() *sql.DB {
+	return d.writer
+}
+
+// Reader is the read pool.
+func (d *DB) Reader() *sql.DB {
+	return d.reader
+}
+
+func (d *DB) Dialect() Dialect {
+	return d.cfg.Dialect
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.reader.QueryContext(ctx, Rebind(d.cfg.Dialect, query), args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.reader.QueryRowContext(ctx, Rebind(d.cfg.Dialect, query), args...)
+}
+
+// ExecContext runs a single write, retrying while the file is busy.
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var result sql.Result
+	err := d.retry(ctx, func() error {
+		var err error
+		result, err = d.writer.ExecContext(ctx, Rebind(d.cfg.Dialect, query), args...)
+		return err
+	})
+	return result, err
+}
+
+// InsertReturningID runs a single insert on the writer, retrying while the
+// file is busy.
+func (d *DB) InsertReturningID(ctx context.Context, query string, args ...any) (int64, error) {
+	var id int64
+	err := d.retry(ctx, func() error {
+		return d.writer.QueryRowContext(ctx, Rebind(d.cfg.Dialect, query+" RETURNING id"), args...).Scan(&id)
+	})
+	return id, err
+}
+
+// WithTx runs fn in a write transaction and commits it. The whole
+// transaction is retried when SQLite reports the file busy or PostgreSQL
+// reports a serialization failure.
+func (d *DB) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return d.retry(ctx, func() error {
+		tx, err := d.writer.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(&Tx{tx: tx, dialect: d.cfg.Dialect}); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// InTx runs fn in q when q already is a transaction, or in a new transaction
+// when q is the DB, so a repository method stays atomic on its own and joins
+// the caller's transaction inside a unit of work.
+func InTx(ctx context.Context, q Querier, fn func(q Querier) error) error {
+	db, ok := q.(*DB)
+	if !ok {
+		return fn(q)
+	}
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		return fn(tx)
+	})
+}
+
+func (d *DB) retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= d.cfg.BusyRetries && IsBusy(err); attempt++ {
+		d.busyRetries.Add(1)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d.cfg.RetryBackoff * time.Duration(attempt)):
+		}
+
+		err = fn()
+	}
+	return err
+}
+
+type Stats struct {
+	Writer      sql.DBStats `json:"writer"`
+	Reader      sql.DBStats `json:"reader"`
+	BusyRetries int64       `json:"busy_retries"`
+}
+
+func (d *DB) Stats() Stats {
+	return Stats{
+		Writer:      d.writer.Stats(),
+		Reader:      d.reader.Stats(),
+		BusyRetries: d.busyRetries.Load(),
+	}
+}
+
+// Close closes the read pool first, then the writer, which checkpoints the WAL.
+func (d *DB) Close() error {
+	if d.reader == d.writer {
+		return d.writer.Close()
+	}
+	return errors.Join(d.reader.Close(), d.writer.Close())
+}
+
+// IsBusy reports whether err is SQLite giving up on a lock, or PostgreSQL
+// aborting a transaction that can simply be run again.
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// IsUniqueViolation reports whether err is a unique constraint failing.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package database
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatsHandler serves the pool statistics of db as JSON.
+func StatsHandler(db *DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, db.Stats())
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"monorepo-ecommerce/pkg/database"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	processes = 6
+	workers   = 4
+	updates   = 25
+)
+
+func openTuned(t *testing.T, path string) *database.DB {
+	db, err := database.Open(database.DefaultConfig(path))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func createCounter(t *testing.T, db *sql.DB) {
+	_, err := db.Exec("CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER NOT NULL); INSERT INTO counters (id, value) VALUES (1, 0);")
+	assert.NoError(t, err)
+}
+
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// increment is the read-modify-write pattern the stock repositories use.
+func increment(ctx context.Context, tx execQuerier) error {
+	var value int
+	if err := tx.QueryRowContext(ctx, "SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "UPDATE counters SET value = ? WHERE id = 1", value+1)
+	return err
+}
+
+// hammer runs processes*workers goroutines, each using the handle of its
+// process, and returns how many updates failed.
+func hammer(handles []func(context.Context) error) int64 {
+	var failures atomic.Int64
+	var wg sync.WaitGroup
+	for _, update := range handles {
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(update func(context.Context) error) {
+				defer wg.Done()
+				for i := 0; i < updates; i++ {
+					if err := update(context.Background()); err != nil {
+						failures.Add(1)
+					}
+				}
+			}(update)
+		}
+	}
+	wg.Wait()
+	return failures.Load()
+}
+
+func TestStress(t *testing.T) {
+	t.Run("should reproduce lock errors with default settings", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "shared.db")
+
+		var handles []func(context.Context) error
+		for p := 0; p < processes; p++ {
+			// one handle per process, opened the way db.InitDatabase used to
+			db, err := sql.Open("sqlite3", path)
+			assert.NoError(t, err)
+			defer db.Close()
+			if p == 0 {
+				createCounter(t, db)
+			}
+
+			handles = append(handles, func(ctx context.Context) error {
+				tx, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					return err
+				}
+				defer tx.Rollback()
+				if err := increment(ctx, tx); err != nil {
+					return err
+				}
+				return tx.Commit()
+			})
+		}
+
+		failures := hammer(handles)
+
+		assert.Greater(t, failures, int64(0))
+	})
+
+	t.Run("should serialise writers without errors", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "shared.db")
+
+		var dbs []*database.DB
+		var handles []func(context.Context) error
+		for p := 0; p < processes; p++ {
+			db := openTuned(t, path)
+			if p == 0 {
+				createCounter(t, db.Writer())
+			}
+			dbs = append(dbs, db)
+			handles = append(handles, func(ctx context.Context) error {
+				return db.WithTx(ctx, func(tx *database.Tx) error {
+					return increment(ctx, tx)
+				})
+			})
+		}
+
+		failures := hammer(handles)
+
+		assert.Equal(t, int64(0), failures)
+		var value int
+		assert.NoError(t, dbs[0].QueryRowContext(context.Background(), "SELECT value FROM counters WHERE id = 1").Scan(&value))
+		assert.Equal(t, processes*workers*updates, value)
+	})
+}
+
+func TestOpen(t *testing.T) {
+	db := openTuned(t, filepath.Join(t.TempDir(), "tuned.db"))
+	ctx := context.Background()
+
+	t.Run("should enable WAL and foreign keys", func(t *testing.T) {
+		var mode string
+		assert.NoError(t, db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode))
+		assert.Equal(t, "wal", mode)
+
+		_, err := db.ExecContext(ctx, "CREATE TABLE parents (id INTEGER PRIMARY KEY); CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents(id));")
+		assert.NoError(t, err)
+		_, err = db.ExecContext(ctx, "INSERT INTO children (parent_id) VALUES (42)")
+		assert.ErrorContains(t, err, "FOREIGN KEY")
+	})
+
+	t.Run("should keep the read pool read only", func(t *testing.T) {
+		_, err := db.Reader().Exec("INSERT INTO parents (id) VALUES (1)")
+		assert.Error(t, err)
+	})
+
+	t.Run("should report pool statistics", func(t *testing.T) {
+		stats := db.Stats()
+		assert.Equal(t, 1, stats.Writer.MaxOpenConnections)
+		assert.Equal(t, database.DefaultConfig("").MaxReadConns, stats.Reader.MaxOpenConnections)
+	})
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM stocks WHERE name = 'why?' AND warehouse_id = ? AND product_id = ?"
+
+	t.Run("should keep placeholders for sqlite", func(t *testing.T) {
+		assert.Equal(t, query, database.Rebind(database.SQLite, query))
+	})
+
+	t.Run("should number placeholders for postgres outside literals", func(t *testing.T) {
+		assert.Equal(t, "SELECT id FROM stocks WHERE name = 'why?' AND warehouse_id = $1 AND product_id = $2", database.Rebind(database.Postgres, query))
+	})
+}
+
+func TestConfig(t *testing.T) {
+	t.Run("should accept sqlite with a path", func(t *testing.T) {
+		assert.NoError(t, database.NewConfig("sqlite", "./data/order.db", "").Validate())
+	})
+
+	t.Run("should require a url for postgres", func(t *testing.T) {
+		assert.ErrorContains(t, database.NewConfig("postgres", "./data/order.db", "").Validate(), "database_url")
+		assert.NoError(t, database.NewConfig("postgres", "", "postgres://localhost/ecommerce").Validate())
+	})
+
+	t.Run("should reject an unknown driver", func(t *testing.T) {
+		_, err := database.Open(database.NewConfig("mysql", "./data/order.db", ""))
+		assert.ErrorContains(t, err, "database_driver")
+	})
+}
+
+func TestInsertReturningID(t *testing.T) {
+	db := openTuned(t, filepath.Join(t.TempDir(), "insert.db"))
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT UNIQUE)")
+	assert.NoError(t, err)
+
+	t.Run("should return the new id on the db and in a transaction", func(t *testing.T) {
+		id, err := db.InsertReturningID(ctx, "INSERT INTO users (email) VALUES (?)", "a@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), id)
+
+		err = db.WithTx(ctx, func(tx *database.Tx) error {
+			id, err = tx.InsertReturningID(ctx, "INSERT INTO users (email) VALUES (?)", "b@example.com")
+			return err
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), id)
+	})
+
+	t.Run("should recognise a unique violation", func(t *testing.T) {
+		_, err := db.InsertReturningID(ctx, "INSERT INTO users (email) VALUES (?)", "a@example.com")
+		assert.True(t, database.IsUniqueViolation(err))
+		assert.False(t, database.IsBusy(err))
+	})
+}
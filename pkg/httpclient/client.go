@@ -0,0 +1,244 @@
+// Package httpclient is the JSON client used for every service-to-service call.
+// It bounds each call with a deadline, retries idempotent calls with jittered
+// backoff, trips a circuit breaker per upstream and forwards the request id.
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/requestid"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Config struct {
+	// Timeout bounds a call, retries included, when ctx carries no deadline.
+	Timeout          time.Duration
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          5 * time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      100 * time.Millisecond,
+		MaxBackoff:       2 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// sharedTransport pools connections across every client in the process.
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   3 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   20,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   3 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+type Client struct {
+	service string
+	baseURL string
+	cfg     Config
+	http    *http.Client
+	breaker *breaker
+}
+
+// New returns a client for the upstream called service, reachable at baseURL.
+func New(service string, baseURL string, cfg Config) *Client {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Client{
+		service: service,
+		baseURL: baseURL,
+		cfg:     cfg,
+		http:    &http.Client{Transport: sharedTransport},
+		breaker: breakerFor(baseURL, cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+type call struct {
+	idempotent bool
+}
+
+type CallOption func(*call)
+
+// Idempotent marks a call as safe to retry regardless of its method.
+func Idempotent() CallOption {
+	return func(c *call) { c.idempotent = true }
+}
+
+func (c *Client) Get(ctx context.Context, path string, out any, opts ...CallOption) error {
+	return c.Do(ctx, http.MethodGet, path, nil, out, opts...)
+}
+
+func (c *Client) Post(ctx context.Context, path string, in any, out any, opts ...CallOption) error {
+	return c.Do(ctx, http.MethodPost, path, in, out, opts...)
+}
+
+// Do sends in as JSON and decodes a 2xx response into out. Failures come back
+// as apperror values.
+func (c *Client) Do(ctx context.Context, method string, path string, in any, out any, opts ...CallOption) error {
+	cl := call{idempotent: isIdempotent(method)}
+	for _, opt := range opts {
+		opt(&cl)
+	}
+
+	if _, ok := ctx.Deadline(); !ok && c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	var payload []byte
+	if in != nil {
+		var err error
+		payload, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed marshal %s request: %w", c.service, err)
+		}
+	}
+
+	attempts := 1
+	if cl.idempotent {
+		attempts += c.cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return lastErr
+			}
+		}
+
+		if err := c.breaker.allow(); err != nil {
+			return apperror.NewUpstreamUnavailable(c.service, err)
+		}
+
+		status, body, err := c.send(ctx, method, path, payload)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				// the caller gave up, which says nothing about the upstream
+				c.breaker.release()
+				return fmt.Errorf("%s request cancelled: %w", c.service, ctx.Err())
+			}
+			c.breaker.record(false)
+			lastErr = apperror.NewUpstreamUnavailable(c.service, err)
+			continue
+		}
+
+		c.breaker.record(status < http.StatusInternalServerError)
+
+		if status >= 200 && status < 300 {
+			if out == nil || len(body) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(body, out); err != nil {
+				return fmt.Errorf("failed unmarshall %s response: %w", c.service, err)
+			}
+			return nil
+		}
+
+		lastErr = apperror.FromResponse(c.service, status, string(body))
+		if !isRetryableStatus(status) {
+			return lastErr
+		}
+	}
+
+	return lastErr
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, payload []byte) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if payload != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(echo.HeaderXRequestID, id)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
+// backoff grows exponentially and keeps half of the delay as random jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.BaseBackoff << (attempt - 1)
+	if d <= 0 || (c.cfg.MaxBackoff > 0 && d > c.cfg.MaxBackoff) {
+		d = c.cfg.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"monorepo-ecommerce/pkg/apperror"
+	"monorepo-ecommerce/pkg/httpclient"
+	"monorepo-ecommerce/pkg/requestid"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig() httpclient.Config {
+	return httpclient.Config{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  50 * time.Millisecond,
+	}
+}
+
+// flaky answers with status for the first n calls and 200 afterwards.
+func flaky(n int32, status int, calls *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"id": 1})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("should retry idempotent calls until success", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(flaky(2, http.StatusServiceUnavailable, &calls))
+		defer srv.Close()
+
+		client := httpclient.New("product", srv.URL, testConfig())
+
+		var out struct {
+			Id int `json:"id"`
+		}
+		err := client.Get(context.Background(), "/products/1", &out)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, out.Id)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should not retry non idempotent calls", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(flaky(1, http.StatusServiceUnavailable, &calls))
+		defer srv.Close()
+
+		client := httpclient.New("product", srv.URL, testConfig())
+
+		err := client.Post(context.Background(), "/products/deduct/1", map[string]int{"quantity": 1}, nil)
+
+		assert.True(t, errors.Is(err, apperror.ErrUpstreamUnavailable))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should retry post marked idempotent", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(flaky(1, http.StatusBadGateway, &calls))
+		defer srv.Close()
+
+		client := httpclient.New("product", srv.URL, testConfig())
+
+		err := client.Post(context.Background(), "/products/adjust-total-stock/1", map[string]int{"quantity": 1}, nil, httpclient.Idempotent())
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should not retry client errors", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(flaky(5, http.StatusNotFound, &calls))
+		defer srv.Close()
+
+		client := httpclient.New("product", srv.URL, testConfig())
+
+		err := client.Get(context.Background(), "/products/9", nil)
+
+		assert.True(t, errors.Is(err, apperror.ErrNotFound))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestTypedErrors(t *testing.T) {
+	e := echo.New()
+	e.POST("/products/deduct/:id", func(c echo.Context) error {
+		return apperror.JSON(c, http.StatusInternalServerError, &apperror.InsufficientStockError{ProductId: 1, Requested: 5, Available: 2})
+	})
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	client := httpclient.New("product", srv.URL, testConfig())
+
+	err := client.Post(context.Background(), "/products/deduct/1", map[string]int{"quantity": 5}, nil)
+
+	var insufficient *apperror.InsufficientStockError
+	assert.True(t, errors.As(err, &insufficient))
+	assert.Equal(t, 2, insufficient.Available)
+}
+
+func TestDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	client := httpclient.New("product", srv.URL, testConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Get(ctx, "/products", nil)
+
+	assert.True(t, errors.Is(err, apperror.ErrUpstreamUnavailable))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(flaky(100, http.StatusServiceUnavailable, &calls))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.MaxRetries = 0
+	client := httpclient.New("product", srv.URL, cfg)
+
+	for i := 0; i < cfg.BreakerThreshold; i++ {
+		client.Get(context.Background(), "/products", nil)
+	}
+	assert.Equal(t, int32(cfg.BreakerThreshold), atomic.LoadInt32(&calls))
+
+	t.Run("should fail fast while open", func(t *testing.T) {
+		err := client.Get(context.Background(), "/products", nil)
+
+		assert.True(t, errors.Is(err, httpclient.ErrCircuitOpen))
+		assert.True(t, errors.Is(err, apperror.ErrUpstreamUnavailable))
+		assert.Equal(t, int32(cfg.BreakerThreshold), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should close after a successful probe", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 100)
+		time.Sleep(cfg.BreakerCooldown + 10*time.Millisecond)
+
+		assert.NoError(t, client.Get(context.Background(), "/products", nil))
+		assert.NoError(t, client.Get(context.Background(), "/products", nil))
+	})
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(echo.HeaderXRequestID)
+	}))
+	defer srv.Close()
+
+	client := httpclient.New("product", srv.URL, testConfig())
+
+	ctx := requestid.NewContext(context.Background(), "req-123")
+	assert.NoError(t, client.Get(ctx, "/products", nil))
+	assert.Equal(t, "req-123", received)
+}
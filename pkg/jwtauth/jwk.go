@@ -0,0 +1,108 @@
+// Package jwtauth verifies the access tokens the user service signs. The user
+// service publishes its public keys as a JSON Web Key Set, so the other
+// services check tokens without holding any signing secret.
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWKSPath is where the user service publishes its key set.
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWKS is a JSON Web Key Set (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public half of a signing key. Ed25519 keys are "OKP" keys with
+// X set, RSA keys carry N and E.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// NewJWK describes key, an ed25519.PublicKey or *rsa.PublicKey, with its kid
+// set to the key's thumbprint.
+func NewJWK(key crypto.PublicKey) (JWK, error) {
+	var jwk JWK
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		jwk = JWK{KeyType: "OKP", Algorithm: "EdDSA", Curve: "Ed25519", X: encode(key)}
+	case *rsa.PublicKey:
+		jwk = JWK{KeyType: "RSA", Algorithm: "RS256", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	jwk.Use = "sig"
+	jwk.KeyId = jwk.thumbprint()
+	return jwk, nil
+}
+
+// thumbprint is the RFC 7638 thumbprint of the key: the SHA-256 of its
+// required members in lexical order.
+func (k JWK) thumbprint() string {
+	var members any
+	switch k.KeyType {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Curve, k.KeyType, k.X}
+	default:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.KeyType, k.N}
+	}
+
+	// the members are strings only, so marshalling cannot fail
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return encode(sum[:])
+}
+
+// PublicKey decodes the key for verifying signatures.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.KeyType == "OKP" && k.Curve == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case k.KeyType == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.New("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
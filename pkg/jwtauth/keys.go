@@ -0,0 +1,133 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"monorepo-ecommerce/pkg/httpclient"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for a kid that is not in the key set.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Keys looks up the public key a token names by its kid.
+type Keys interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// RemoteKeys is the key set of the user service, fetched from JWKSPath and
+// cached for cacheTTL. A kid not in the cache is most likely a key rotated in
+// since, so it refetches the set, at most once per MinRefetchInterval.
+// Concurrent lookups share one fetch.
+type RemoteKeys struct {
+	client   *httpclient.Client
+	cacheTTL time.Duration
+	// MinRefetchInterval keeps tokens with made-up kids from hammering the
+	// user service.
+	MinRefetchInterval time.Duration
+
+	// mu guards the fields below and is never held across a fetch
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	fetching  *keyFetch
+}
+
+// keyFetch is a fetch of the key set in flight. done is closed once err is
+// set and the cache is updated.
+type keyFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func NewRemoteKeys(client *httpclient.Client, cacheTTL time.Duration) *RemoteKeys {
+	return &RemoteKeys{client: client, cacheTTL: cacheTTL, MinRefetchInterval: 10 * time.Second}
+}
+
+func (r *RemoteKeys) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	r.mu.Lock()
+	key, ok := r.keys[kid]
+	sinceFetch := time.Since(r.fetchedAt)
+	if (ok && sinceFetch < r.cacheTTL) || (!ok && sinceFetch < r.MinRefetchInterval) {
+		r.mu.Unlock()
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+
+	call := r.fetching
+	if call != nil && ok {
+		// the set is already being refreshed, the cached key serves meanwhile
+		r.mu.Unlock()
+		return key, nil
+	}
+	if call == nil {
+		call = &keyFetch{done: make(chan struct{})}
+		r.fetching = call
+		// the fetch is shared, so the caller that started it giving up must
+		// not cancel it for the others
+		go r.fetch(context.WithoutCancel(ctx), call)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if call.err != nil {
+		// the user service being down does not log everyone out; the keys
+		// fetched last stay in use until it is back
+		if ok {
+			return key, nil
+		}
+		return nil, call.err
+	}
+
+	r.mu.Lock()
+	key, ok = r.keys[kid]
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// fetch replaces the cache with the published key set and completes call.
+func (r *RemoteKeys) fetch(ctx context.Context, call *keyFetch) {
+	keys, err := r.fetchKeys(ctx)
+
+	r.mu.Lock()
+	if err == nil {
+		r.keys = keys
+		r.fetchedAt = time.Now()
+	}
+	call.err = err
+	r.fetching = nil
+	r.mu.Unlock()
+	close(call.done)
+}
+
+// fetchKeys reads the published key set. Keys it cannot decode, such as ones
+// of an algorithm this package does not verify, are skipped.
+func (r *RemoteKeys) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set JWKS
+	if err := r.client.Get(ctx, JWKSPath, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyId] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,278 @@
+package test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"monorepo-ecommerce/pkg/httpclient"
+	"monorepo-ecommerce/pkg/jwtauth"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func clientConfig() httpclient.Config {
+	cfg := httpclient.DefaultConfig()
+	cfg.Timeout = time.Second
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 100
+	return cfg
+}
+
+// jwksServer publishes keys, swapped by the test, and counts the fetches.
+// While held, it answers only once release is closed.
+type jwksServer struct {
+	*httptest.Server
+	keys    atomic.Pointer[jwtauth.JWKS]
+	fetches atomic.Int32
+	down    atomic.Bool
+	held    atomic.Bool
+	release chan struct{}
+}
+
+func newJWKSServer(t *testing.T, keys ...jwtauth.JWK) *jwksServer {
+	srv := &jwksServer{release: make(chan struct{})}
+	srv.publish(keys...)
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.fetches.Add(1)
+		if srv.held.Load() {
+			<-srv.release
+		}
+		if srv.down.Load() || r.URL.Path != jwtauth.JWKSPath {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(srv.keys.Load())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *jwksServer) publish(keys ...jwtauth.JWK) {
+	s.keys.Store(&jwtauth.JWKS{Keys: keys})
+}
+
+func newKey(t *testing.T) (ed25519.PrivateKey, jwtauth.JWK) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	jwk, err := jwtauth.NewJWK(private.Public())
+	assert.NoError(t, err)
+	return private, jwk
+}
+
+func sign(t *testing.T, private ed25519.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(private)
+	assert.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": 7,
+		"email":   "test@example.com",
+		"phone":   "0811",
+		"iss":     "user-service",
+		"aud":     "monorepo-ecommerce",
+		"jti":     "token-1",
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestJWK(t *testing.T) {
+	t.Run("should use the RFC 8037 thumbprint as kid", func(t *testing.T) {
+		x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+
+		jwk, err := jwtauth.NewJWK(ed25519.PublicKey(x))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", jwk.KeyId)
+		assert.Equal(t, "EdDSA", jwk.Algorithm)
+	})
+
+	t.Run("should decode the RSA key it encoded", func(t *testing.T) {
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+
+		jwk, err := jwtauth.NewJWK(&private.PublicKey)
+		assert.NoError(t, err)
+		key, err := jwk.PublicKey()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "RS256", jwk.Algorithm)
+		assert.True(t, private.PublicKey.Equal(key))
+	})
+}
+
+func TestRemoteKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should cache the key set", func(t *testing.T) {
+		private, jwk := newKey(t)
+		srv := newJWKSServer(t, jwk)
+		keys := jwtauth.NewRemoteKeys(httpclient.New("user", srv.URL, clientConfig()), time.Minute)
+
+		for i := 0; i < 3; i++ {
+			key, err := keys.PublicKey(ctx, jwk.KeyId)
+			assert.NoError(t, err)
+			assert.True(t, private.Public().(ed25519.PublicKey).Equal(key))
+		}
+		assert.Equal(t, int32(1), srv.fetches.Load())
+	})
+
+	t.Run("should fetch again for a rotated key, but not for every unknown kid", func(t *testing.T) {
+		_, oldKey := newKey(t)
+		_, newKey := newKey(t)
+		srv := newJWKSServer(t, oldKey)
+		keys := jwtauth.NewRemoteKeys(httpclient.New("user", srv.URL, clientConfig()), time.Minute)
+		keys.MinRefetchInterval = 0
+
+		_, err := keys.PublicKey(ctx, oldKey.KeyId)
+		assert.NoError(t, err)
+		srv.publish(oldKey, newKey)
+
+		_, err = keys.PublicKey(ctx, newKey.KeyId)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), srv.fetches.Load())
+
+		keys.MinRefetchInterval = time.Minute
+		_, err = keys.PublicKey(ctx, "made-up")
+		assert.ErrorIs(t, err, jwtauth.ErrUnknownKey)
+		assert.Equal(t, int32(2), srv.fetches.Load())
+	})
+
+	t.Run("should keep the cached keys while the user service is down", func(t *testing.T) {
+		_, jwk := newKey(t)
+		srv := newJWKSServer(t, jwk)
+		keys := jwtauth.NewRemoteKeys(httpclient.New("user", srv.URL, clientConfig()), time.Nanosecond)
+
+		_, err := keys.PublicKey(ctx, jwk.KeyId)
+		assert.NoError(t, err)
+		srv.down.Store(true)
+
+		_, err = keys.PublicKey(ctx, jwk.KeyId)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), srv.fetches.Load())
+	})
+
+	t.Run("should share a slow fetch and serve cached keys meanwhile", func(t *testing.T) {
+		_, cachedKey := newKey(t)
+		_, rotatedKey := newKey(t)
+		srv := newJWKSServer(t, cachedKey)
+		keys := jwtauth.NewRemoteKeys(httpclient.New("user", srv.URL, clientConfig()), time.Minute)
+		keys.MinRefetchInterval = 0
+
+		_, err := keys.PublicKey(ctx, cachedKey.KeyId)
+		assert.NoError(t, err)
+		srv.publish(cachedKey, rotatedKey)
+		srv.held.Store(true)
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := keys.PublicKey(ctx, rotatedKey.KeyId)
+				errs <- err
+			}()
+		}
+		assert.Eventually(t, func() bool { return srv.fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = keys.PublicKey(waitCtx, cachedKey.KeyId)
+		assert.NoError(t, err)
+
+		close(srv.release)
+		assert.NoError(t, <-errs)
+		assert.NoError(t, <-errs)
+		assert.Equal(t, int32(2), srv.fetches.Load())
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	private, jwk := newKey(t)
+	srv := newJWKSServer(t, jwk)
+	verifier := jwtauth.NewVerifier(jwtauth.NewRemoteKeys(httpclient.New("user", srv.URL, clientConfig()), time.Minute), "user-service", "monorepo-ecommerce")
+	revoked := func(tokenId string) bool { return tokenId == "revoked" }
+	e := echo.New()
+
+	serve := func(authorization string) (*httptest.ResponseRecorder, echo.Context) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := jwtauth.Middleware(verifier, revoked)(func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})(c)
+		assert.NoError(t, err)
+		return rec, c
+	}
+
+	t.Run("should store the claims of a valid token", func(t *testing.T) {
+		rec, c := serve("Bearer " + sign(t, private, jwk.KeyId, validClaims()))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(7), c.Get("user_id"))
+		assert.Equal(t, "test@example.com", c.Get("email"))
+		assert.Equal(t, "token-1", c.Get("jti"))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), c.Get("exp").(time.Time), 2*time.Second)
+	})
+
+	invalid := map[string]func(claims jwt.MapClaims){
+		"another issuer":   func(claims jwt.MapClaims) { claims["iss"] = "someone-else" },
+		"another audience": func(claims jwt.MapClaims) { claims["aud"] = "another-app" },
+		"no expiry":        func(claims jwt.MapClaims) { delete(claims, "exp") },
+		"no jti":           func(claims jwt.MapClaims) { delete(claims, "jti") },
+		"an expiry passed": func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+	}
+	for name, change := range invalid {
+		t.Run("should unauthorized a token with "+name, func(t *testing.T) {
+			claims := validClaims()
+			change(claims)
+
+			rec, _ := serve("Bearer " + sign(t, private, jwk.KeyId, claims))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+
+	t.Run("should unauthorized a token signed with an unpublished key", func(t *testing.T) {
+		other, otherJwk := newKey(t)
+
+		rec, _ := serve("Bearer " + sign(t, other, otherJwk.KeyId, validClaims()))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("should unauthorized a revoked token", func(t *testing.T) {
+		claims := validClaims()
+		claims["jti"] = "revoked"
+
+		rec, _ := serve("Bearer " + sign(t, private, jwk.KeyId, claims))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Token revoked")
+	})
+
+	t.Run("should unauthorized a missing token", func(t *testing.T) {
+		rec, _ := serve("")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
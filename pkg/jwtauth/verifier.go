@@ -0,0 +1,108 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Verifier checks the signature of an access token against Keys, by the kid
+// in its header, that it was issued by issuer for audience and has not
+// expired, and that it has a jti to revoke it by.
+type Verifier struct {
+	keys   Keys
+	parser *jwt.Parser
+}
+
+func NewVerifier(keys Keys, issuer string, audience string) *Verifier {
+	return &Verifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithExpirationRequired(),
+		),
+	}
+}
+
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	token, err := v.parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid")
+		}
+		return v.keys.PublicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a token without jti could never be revoked
+	if tokenId, _ := token.Claims.(jwt.MapClaims)["jti"].(string); tokenId == "" {
+		return nil, errors.New("token has no jti")
+	}
+	return token, nil
+}
+
+// Middleware verifies the bearer token and stores its claims on the context:
+// user_id (int64), email, phone, jti and exp (time.Time). isRevoked, when not
+// nil, refuses tokens by their jti.
+func Middleware(verifier *Verifier, isRevoked func(tokenId string) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"message": "Token not found, please login first",
+				})
+			}
+
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"message": "Invalid token format, please login first",
+				})
+			}
+
+			token, err := verifier.Verify(c.Request().Context(), parts[1])
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"message": "Token invalid or expired",
+				})
+			}
+
+			claims := token.Claims.(jwt.MapClaims)
+			userId, ok := claims["user_id"].(float64)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"message": "Token invalid",
+				})
+			}
+
+			// Verify refuses tokens without jti
+			tokenId := claims["jti"].(string)
+			if isRevoked != nil && isRevoked(tokenId) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"message": "Token revoked, please login again",
+				})
+			}
+
+			email, _ := claims["email"].(string)
+			phone, _ := claims["phone"].(string)
+			c.Set("user_id", int64(userId))
+			c.Set("email", email)
+			c.Set("phone", phone)
+			c.Set("jti", tokenId)
+			// exp is required by the parser, so it is always there
+			expiresAt, _ := claims.GetExpirationTime()
+			c.Set("exp", expiresAt.Time)
+
+			return next(c)
+		}
+	}
+}
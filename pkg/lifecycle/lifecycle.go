@@ -0,0 +1,182 @@
+// Package lifecycle runs a service's HTTP servers, cron scheduler and
+// background workers, and tears them down in order on SIGINT or SIGTERM:
+// stop accepting traffic, drain in-flight requests and jobs, stop workers,
+// then run the stop hooks (closing the DB last) within one shutdown timeout.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/robfig/cron/v3"
+)
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type server struct {
+	e    *echo.Echo
+	addr string
+}
+
+type worker struct {
+	name string
+	fn   func(ctx context.Context)
+}
+
+type Runner struct {
+	timeout time.Duration
+
+	servers []server
+	cron    *cron.Cron
+
+	workers []worker
+	hooks   []hook
+
+	// hardCtx is cancelled once the shutdown timeout is spent, so jobs that
+	// use it are drained first and only aborted as a last resort.
+	hardCtx    context.Context
+	hardCancel context.CancelFunc
+}
+
+// New returns a runner that gives shutdown at most timeout to complete.
+func New(timeout time.Duration) *Runner {
+	hardCtx, hardCancel := context.WithCancel(context.Background())
+	return &Runner{timeout: timeout, hardCtx: hardCtx, hardCancel: hardCancel}
+}
+
+// Context is the context for cron jobs. It stays alive while jobs drain and
+// is cancelled when the shutdown timeout runs out.
+func (r *Runner) Context() context.Context {
+	return r.hardCtx
+}
+
+// HTTPServer registers e to listen on addr. A service can register several,
+// such as its public and its internal listener.
+func (r *Runner) HTTPServer(e *echo.Echo, addr string) {
+	r.servers = append(r.servers, server{e: e, addr: addr})
+}
+
+// Cron registers a scheduler that is started with the runner.
+func (r *Runner) Cron(c *cron.Cron) {
+	r.cron = c
+}
+
+// Worker registers a background loop. Its context is cancelled as soon as
+// shutdown begins and the runner waits for it to return.
+func (r *Runner) Worker(name string, fn func(ctx context.Context)) {
+	r.workers = append(r.workers, worker{name: name, fn: fn})
+}
+
+// OnStop registers a hook run after traffic, jobs and workers are drained.
+// Hooks run in registration order.
+func (r *Runner) OnStop(name string, fn func(ctx context.Context) error) {
+	r.hooks = append(r.hooks, hook{name: name, fn: fn})
+}
+
+// Run starts every component and blocks until SIGINT, SIGTERM, cancellation
+// of ctx or a server failure, then shuts down.
+func (r *Runner) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, len(r.servers))
+	for _, srv := range r.servers {
+		go func(srv server) {
+			log.Printf("Starting server on %s...", srv.addr)
+			if err := srv.e.Start(srv.addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}(srv)
+	}
+
+	if r.cron != nil {
+		r.cron.Start()
+	}
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	var wg sync.WaitGroup
+	for _, w := range r.workers {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+			w.fn(workerCtx)
+		}(w)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutdown signal received")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("server failed: %w", err)
+	}
+
+	shutdownErr := r.shutdown(cancelWorkers, &wg)
+
+	return errors.Join(runErr, shutdownErr)
+}
+
+func (r *Runner) shutdown(cancelWorkers context.CancelFunc, wg *sync.WaitGroup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var errs []error
+
+	// stop accepting traffic and wait for in-flight requests
+	for _, srv := range r.servers {
+		if err := srv.e.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed shutdown server on %s: %w", srv.addr, err))
+		}
+	}
+
+	// stop scheduling and wait for running jobs
+	if r.cron != nil {
+		if err := wait(ctx, r.cron.Stop().Done()); err != nil {
+			errs = append(errs, fmt.Errorf("failed drain cron jobs: %w", err))
+		}
+	}
+
+	cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	if err := wait(ctx, done); err != nil {
+		errs = append(errs, fmt.Errorf("failed stop workers: %w", err))
+	}
+
+	// jobs still running past the deadline are aborted before resources go away
+	r.hardCancel()
+
+	for _, h := range r.hooks {
+		if err := h.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed stop %s: %w", h.name, err))
+		}
+	}
+
+	log.Println("Shutdown complete")
+	return errors.Join(errs...)
+}
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
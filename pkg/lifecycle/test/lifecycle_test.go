@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"io"
+	"monorepo-ecommerce/pkg/lifecycle"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func newServer(t *testing.T, rec *recorder, started chan<- struct{}) (*echo.Echo, string) {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		rec.add("request")
+		return c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	e.Listener = listener
+
+	return e, "http://" + listener.Addr().String()
+}
+
+func TestRunner(t *testing.T) {
+	t.Run("should drain requests, jobs and workers before stop hooks", func(t *testing.T) {
+		rec := &recorder{}
+		started := make(chan struct{})
+		e, url := newServer(t, rec, started)
+
+		runner := lifecycle.New(2 * time.Second)
+		runner.HTTPServer(e, "")
+
+		jobStarted := make(chan struct{})
+		c := cron.New(cron.WithSeconds())
+		var once sync.Once
+		c.AddFunc("* * * * * *", func() {
+			once.Do(func() {
+				close(jobStarted)
+				time.Sleep(150 * time.Millisecond)
+				rec.add("job")
+			})
+		})
+		runner.Cron(c)
+
+		runner.Worker("ticker", func(ctx context.Context) {
+			<-ctx.Done()
+			rec.add("worker")
+		})
+		runner.OnStop("database", func(ctx context.Context) error {
+			rec.add("database")
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- runner.Run(ctx) }()
+
+		body := make(chan string, 1)
+		go func() {
+			resp, err := http.Get(url + "/slow")
+			if err != nil {
+				body <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			body <- string(b)
+		}()
+
+		<-started
+		<-jobStarted
+		cancel()
+
+		assert.NoError(t, <-done)
+		assert.Equal(t, "done", <-body)
+
+		events := rec.list()
+		assert.ElementsMatch(t, []string{"request", "job", "worker", "database"}, events)
+		assert.Equal(t, "database", events[len(events)-1])
+		assert.Error(t, runner.Context().Err())
+	})
+
+	t.Run("should drain every server", func(t *testing.T) {
+		rec := &recorder{}
+		publicStarted := make(chan struct{})
+		internalStarted := make(chan struct{})
+		public, publicURL := newServer(t, rec, publicStarted)
+		internal, internalURL := newServer(t, rec, internalStarted)
+
+		runner := lifecycle.New(2 * time.Second)
+		runner.HTTPServer(public, "")
+		runner.HTTPServer(internal, "")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- runner.Run(ctx) }()
+
+		statuses := make(chan int, 2)
+		for _, url := range []string{publicURL, internalURL} {
+			go func(url string) {
+				resp, err := http.Get(url + "/slow")
+				if err != nil {
+					statuses <- 0
+					return
+				}
+				resp.Body.Close()
+				statuses <- resp.StatusCode
+			}(url)
+		}
+
+		<-publicStarted
+		<-internalStarted
+		cancel()
+
+		assert.NoError(t, <-done)
+		assert.Equal(t, http.StatusOK, <-statuses)
+		assert.Equal(t, http.StatusOK, <-statuses)
+		assert.Equal(t, []string{"request", "request"}, rec.list())
+	})
+
+	t.Run("should give up draining after the timeout", func(t *testing.T) {
+		runner := lifecycle.New(50 * time.Millisecond)
+
+		runner.Worker("stuck", func(ctx context.Context) {
+			time.Sleep(time.Second)
+		})
+		closed := false
+		runner.OnStop("database", func(ctx context.Context) error {
+			closed = true
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := time.Now()
+		err := runner.Run(ctx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, closed)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("should return server start failures", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		e := echo.New()
+		e.HideBanner = true
+		e.HidePort = true
+
+		runner := lifecycle.New(time.Second)
+		runner.HTTPServer(e, listener.Addr().String())
+
+		err = runner.Run(context.Background())
+
+		assert.ErrorContains(t, err, "server failed")
+	})
+}
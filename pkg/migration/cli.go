@@ -0,0 +1,81 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = "usage: migrate status | up | down | to <version>"
+
+// IsCommand reports whether args (os.Args) ask for the migrate subcommand.
+func IsCommand(args []string) bool {
+	return len(args) > 1 && args[1] == "migrate"
+}
+
+// RunCommand executes `migrate <action>` where args are the words after
+// "migrate", and writes the resulting status to out.
+func RunCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	case "to":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = m.To(ctx, version)
+	default:
+		return errors.New(usage)
+	}
+	if err != nil {
+		return err
+	}
+
+	return PrintStatus(ctx, m, out)
+}
+
+// PrintStatus writes one line per migration.
+func PrintStatus(ctx context.Context, m *Migrator, out io.Writer) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT\n")
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "applied (file missing)"
+		case s.Modified:
+			state = "applied (modified)"
+		case s.Applied:
+			state = "applied"
+		}
+
+		appliedAt := ""
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+
+	return w.Flush()
+}
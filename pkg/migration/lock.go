@@ -0,0 +1,57 @@
+package migration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const lockPollInterval = 100 * time.Millisecond
+
+// lock takes the namespace lock row, waiting up to LockTimeout for another
+// instance to finish. The returned func releases it.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	owner := lockOwner()
+
+	ctx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+	defer cancel()
+
+	for {
+		now := time.Now()
+		// a lock older than StaleLockAfter belongs to an instance that died mid-migration
+		_, _ = m.db.ExecContext(ctx, m.rebind("DELETE FROM schema_migrations_lock WHERE namespace = ? AND locked_at < ?"),
+			m.namespace, now.Add(-m.StaleLockAfter).Unix())
+
+		_, err := m.db.ExecContext(ctx, m.rebind("INSERT INTO schema_migrations_lock (namespace, owner, locked_at) VALUES (?, ?, ?)"),
+			m.namespace, owner, now.Unix())
+		if err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed acquire migration lock for %s: %w", m.namespace, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	release := func() {
+		_, err := m.db.Exec(m.rebind("DELETE FROM schema_migrations_lock WHERE namespace = ? AND owner = ?"), m.namespace, owner)
+		if err != nil {
+			log.Printf("failed release migration lock for %s: %v", m.namespace, err)
+		}
+	}
+
+	return release, nil
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
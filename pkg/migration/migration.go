@@ -0,0 +1,403 @@
+// Package migration applies numbered SQL migrations from an fs.FS, usually
+// the embed.FS of a service's migrations package. Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Applied versions are
+// recorded per namespace in schema_migrations together with a checksum of the
+// up file, and a lock row keeps concurrently starting services from applying
+// the same migration twice.
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"monorepo-ecommerce/pkg/database"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified reports an applied migration whose up file changed since.
+	Modified bool
+	// Missing reports an applied version that has no file anymore.
+	Missing bool
+}
+
+type Migrator struct {
+	db        *sql.DB
+	namespace string
+	fsys      fs.FS
+	// Dialect selects the placeholder style of the bookkeeping queries,
+	// SQLite unless set.
+	Dialect database.Dialect
+	// LockTimeout bounds how long Up, Down and To wait for another instance.
+	LockTimeout time.Duration
+	// StaleLockAfter is the age after which a lock left by a crashed
+	// instance is taken over.
+	StaleLockAfter time.Duration
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// BootstrapFile is run, when the migrations include it, before the first
+// migration of a namespace that has none applied, in the same transaction.
+// It provides what an early migration expected to find in the database it
+// was written for, so that migration never has to change.
+const BootstrapFile = "bootstrap.sql"
+
+// New returns a migrator for the migrations in fsys, recorded under namespace.
+func New(db *sql.DB, namespace string, fsys fs.FS) *Migrator {
+	return &Migrator{
+		db:             db,
+		namespace:      namespace,
+		fsys:           fsys,
+		LockTimeout:    time.Minute,
+		StaleLockAfter: 10 * time.Minute,
+	}
+}
+
+// Load reads and orders the migrations in fsys.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Status lists every known or applied migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := Load(m.fsys)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := done[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt
+			s.Modified = a.checksum != mig.Checksum
+			delete(done, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for version, a := range done {
+		statuses = append(statuses, Status{Version: version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Version returns the highest applied version, or 0 for an empty schema.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return 0, err
+	}
+	done, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	for version := range done {
+		if version > current {
+			current = version
+		}
+	}
+
+	return current, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.migrate(ctx, func(migrations []Migration, done map[int64]applied) error {
+		for _, mig := range migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, mig, len(done) == 0); err != nil {
+				return err
+			}
+			done[mig.Version] = applied{name: mig.Name, checksum: mig.Checksum}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.migrate(ctx, func(migrations []Migration, done map[int64]applied) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := done[migrations[i].Version]; ok {
+				return m.rollback(ctx, migrations[i])
+			}
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until version is the highest applied one. Version 0
+// rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	return m.migrate(ctx, func(migrations []Migration, done map[int64]applied) error {
+		if version != 0 && !contains(migrations, version) {
+			return fmt.Errorf("unknown migration version %d", version)
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			mig := migrations[i]
+			if _, ok := done[mig.Version]; ok && mig.Version > version {
+				if err := m.rollback(ctx, mig); err != nil {
+					return err
+				}
+				delete(done, mig.Version)
+			}
+		}
+		for _, mig := range migrations {
+			if _, ok := done[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, mig, len(done) == 0); err != nil {
+					return err
+				}
+				done[mig.Version] = applied{name: mig.Name, checksum: mig.Checksum}
+			}
+		}
+		return nil
+	})
+}
+
+// migrate runs fn under the lock after checking applied checksums.
+func (m *Migrator) migrate(ctx context.Context, fn func([]Migration, map[int64]applied) error) error {
+	migrations, err := Load(m.fsys)
+	if err != nil {
+		return err
+	}
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	release, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	done, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range migrations {
+		if a, ok := done[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied", mig.Version, mig.Name)
+		}
+	}
+
+	return fn(migrations, done)
+}
+
+// apply runs mig, preceded by the bootstrap file when it is the first
+// migration of the namespace.
+func (m *Migrator) apply(ctx context.Context, mig Migration, first bool) error {
+	var bootstrap []byte
+	if first {
+		var err error
+		bootstrap, err = fs.ReadFile(m.fsys, BootstrapFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed read %s: %w", BootstrapFile, err)
+		}
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(bootstrap) > 0 {
+		if _, err := tx.ExecContext(ctx, string(bootstrap)); err != nil {
+			return fmt.Errorf("failed apply %s: %w", BootstrapFile, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return fmt.Errorf("failed apply migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, m.rebind("INSERT INTO schema_migrations (namespace, version, name, checksum, applied_at) VALUES (?, ?, ?, ?, ?)"),
+		m.namespace, mig.Version, mig.Name, mig.Checksum, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Migrator) rollback(ctx context.Context, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return fmt.Errorf("failed roll back migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, m.rebind("DELETE FROM schema_migrations WHERE namespace = ? AND version = ?"), m.namespace, mig.Version)
+	if err != nil {
+		return fmt.Errorf("failed unrecord migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		namespace TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TEXT NOT NULL,
+		PRIMARY KEY (namespace, version)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed create schema_migrations: %w", err)
+	}
+
+	_, err = m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		namespace TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		locked_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed create schema_migrations_lock: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]applied, error) {
+	rows, err := m.db.QueryContext(ctx, m.rebind("SELECT version, name, checksum, applied_at FROM schema_migrations WHERE namespace = ?"), m.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[int64]applied{}
+	for rows.Next() {
+		var version int64
+		var a applied
+		var appliedAt string
+		if err := rows.Scan(&version, &a.name, &a.checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		a.appliedAt, _ = time.Parse(time.RFC3339, appliedAt)
+		done[version] = a
+	}
+
+	return done, rows.Err()
+}
+
+func (m *Migrator) rebind(query string) string {
+	return database.Rebind(m.Dialect, query)
+}
+
+func contains(migrations []Migration, version int64) bool {
+	for _, mig := range migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
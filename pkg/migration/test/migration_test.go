@@ -0,0 +1,198 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"monorepo-ecommerce/pkg/migration"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func source() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_products.up.sql":   {Data: []byte("CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT);")},
+		"0001_products.down.sql": {Data: []byte("DROP TABLE products;")},
+		"0002_stock.up.sql":      {Data: []byte("ALTER TABLE products ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;")},
+		"0002_stock.down.sql":    {Data: []byte("ALTER TABLE products DROP COLUMN stock;")},
+		"migrations.go":          {Data: []byte("package migrations")},
+	}
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	assert.NoError(t, err)
+	return count > 0
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should order migrations by version", func(t *testing.T) {
+		migrations, err := migration.Load(source())
+
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "stock", migrations[1].Name)
+		assert.NotEmpty(t, migrations[1].Checksum)
+	})
+
+	t.Run("should reject a version without up file", func(t *testing.T) {
+		fsys := source()
+		delete(fsys, "0002_stock.up.sql")
+
+		_, err := migration.Load(fsys)
+
+		assert.ErrorContains(t, err, "no up file")
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should apply pending migrations once", func(t *testing.T) {
+		db := openDB(t)
+		m := migration.New(db, "product", source())
+
+		assert.NoError(t, m.Up(ctx))
+		assert.NoError(t, m.Up(ctx))
+
+		version, err := m.Version(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), version)
+		_, err = db.Exec("INSERT INTO products (name, stock) VALUES ('A', 1)")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should roll back one step and migrate to a version", func(t *testing.T) {
+		db := openDB(t)
+		m := migration.New(db, "product", source())
+		assert.NoError(t, m.Up(ctx))
+
+		assert.NoError(t, m.Down(ctx))
+		version, _ := m.Version(ctx)
+		assert.Equal(t, int64(1), version)
+
+		assert.NoError(t, m.To(ctx, 0))
+		assert.False(t, tableExists(t, db, "products"))
+
+		assert.NoError(t, m.To(ctx, 2))
+		version, _ = m.Version(ctx)
+		assert.Equal(t, int64(2), version)
+
+		assert.ErrorContains(t, m.To(ctx, 7), "unknown migration version")
+	})
+
+	t.Run("should bootstrap an empty namespace only", func(t *testing.T) {
+		db := openDB(t)
+		fsys := source()
+		fsys[migration.BootstrapFile] = &fstest.MapFile{Data: []byte("CREATE TABLE IF NOT EXISTS legacy (id INTEGER PRIMARY KEY); INSERT INTO legacy (id) VALUES (1);")}
+		m := migration.New(db, "product", fsys)
+
+		assert.NoError(t, m.To(ctx, 1))
+		assert.True(t, tableExists(t, db, "legacy"))
+
+		// later migrations of a namespace that has some applied do not run it
+		assert.NoError(t, m.Up(ctx))
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM legacy").Scan(&count))
+		assert.Equal(t, 1, count)
+
+		statuses, err := m.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 2)
+	})
+
+	t.Run("should keep namespaces apart", func(t *testing.T) {
+		db := openDB(t)
+		assert.NoError(t, migration.New(db, "product", source()).Up(ctx))
+
+		other := migration.New(db, "shop", fstest.MapFS{
+			"0001_shops.up.sql": {Data: []byte("CREATE TABLE shops (id INTEGER PRIMARY KEY);")},
+		})
+		statuses, err := other.Status(ctx)
+
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 1)
+		assert.False(t, statuses[0].Applied)
+	})
+
+	t.Run("should refuse to run when an applied migration changed", func(t *testing.T) {
+		db := openDB(t)
+		assert.NoError(t, migration.New(db, "product", source()).Up(ctx))
+
+		changed := source()
+		changed["0001_products.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE products (id INTEGER PRIMARY KEY, title TEXT);")}
+		m := migration.New(db, "product", changed)
+
+		assert.ErrorContains(t, m.Up(ctx), "modified")
+		statuses, err := m.Status(ctx)
+		assert.NoError(t, err)
+		assert.True(t, statuses[0].Modified)
+	})
+
+	t.Run("should leave the schema untouched when a migration fails", func(t *testing.T) {
+		db := openDB(t)
+		broken := source()
+		broken["0002_stock.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE audit (id INTEGER); ALTER TABLE missing ADD COLUMN x INTEGER;")}
+		m := migration.New(db, "product", broken)
+
+		assert.Error(t, m.Up(ctx))
+
+		version, _ := m.Version(ctx)
+		assert.Equal(t, int64(1), version)
+		assert.False(t, tableExists(t, db, "audit"))
+	})
+
+	t.Run("should apply once when instances start concurrently", func(t *testing.T) {
+		db := openDB(t)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = migration.New(db, "product", source()).Up(ctx)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE namespace = 'product'").Scan(&count))
+		assert.Equal(t, 2, count)
+	})
+}
+
+func TestRunCommand(t *testing.T) {
+	db := openDB(t)
+	m := migration.New(db, "product", source())
+	var out bytes.Buffer
+
+	t.Run("should print status after up", func(t *testing.T) {
+		assert.NoError(t, migration.RunCommand(context.Background(), m, []string{"up"}, &out))
+		assert.Contains(t, out.String(), "stock")
+		assert.NotContains(t, out.String(), "pending")
+	})
+
+	t.Run("should reject unknown actions", func(t *testing.T) {
+		assert.ErrorContains(t, migration.RunCommand(context.Background(), m, []string{"sideways"}, &out), "usage")
+		assert.Error(t, migration.RunCommand(context.Background(), m, []string{"to", "x"}, &out))
+	})
+}
@@ -0,0 +1,32 @@
+// Package requestid carries the X-Request-Id of an inbound request through
+// its context so outbound calls can forward it.
+package requestid
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware reuses the caller's X-Request-Id, or generates one, and stores it
+// in the request context.
+func Middleware() echo.MiddlewareFunc {
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			req := c.Request()
+			c.SetRequest(req.WithContext(NewContext(req.Context(), id)))
+		},
+	})
+}